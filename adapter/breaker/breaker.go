@@ -0,0 +1,87 @@
+// Package breaker exports the state of circuit breakers such as
+// sony/gobreaker or afex/hystrix-go through tagged structs.
+//
+// A map of Metrics keyed by breaker name exports every breaker under the
+// name of the map with a "breaker" label:
+//
+//	type appMetrics struct {
+//		Breakers map[string]*breaker.Metrics `metric:"breakers"`
+//	}
+//
+//	m := &appMetrics{Breakers: breaker.Map("payments", "search")}
+//	tags := tagtrics.NewMetricTags(m, handler, time.Minute, registry, ".")
+//	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+//		Name:          "payments",
+//		OnStateChange: breaker.OnStateChange[gobreaker.State](m.Breakers),
+//	})
+package breaker
+
+import (
+	"fmt"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+// The states of a breaker as named by gobreaker.  Hystrix breakers are
+// either closed or open.
+const (
+	Closed   = "closed"
+	HalfOpen = "half-open"
+	Open     = "open"
+)
+
+// Metrics holds the metrics of a circuit breaker.
+type Metrics struct {
+	// State is the current state of the breaker: 0 when closed, 1 when
+	// half-open and 2 when open.
+	State tagtrics.StateGauge `metric:"state,states=closed;half-open;open" help:"Current state of the breaker"`
+	// Trips counts the transitions to the open state.
+	Trips metrics.Counter `metric:"trips" help:"Times the breaker opened"`
+	// Rejected counts the requests rejected by the open or half-open
+	// breaker.
+	Rejected metrics.Counter `metric:"rejected" help:"Requests rejected by the breaker"`
+}
+
+// Map returns a map of Metrics for the breakers with the given names to
+// initialize with a tagged struct.
+func Map(names ...string) map[string]*Metrics {
+	m := make(map[string]*Metrics, len(names))
+	for _, name := range names {
+		m[name] = &Metrics{}
+	}
+	return m
+}
+
+// BucketName exports the name of a breaker as the "breaker" label.
+func (*Metrics) BucketName(key string) (string, map[string]string) {
+	return key, map[string]string{"breaker": key}
+}
+
+// SetState records a transition of the breaker to the named state.  The
+// transitions to Open are counted as trips.
+func (m *Metrics) SetState(state string) {
+	if state == Open && m.State.State() != Open {
+		m.Trips.Inc(1)
+	}
+	m.State.SetState(state)
+}
+
+// Reject records a request rejected by the breaker, such as a call
+// returning gobreaker.ErrOpenState or gobreaker.ErrTooManyRequests.
+func (m *Metrics) Reject() {
+	m.Rejected.Inc(1)
+}
+
+// OnStateChange returns a callback for gobreaker.Settings.OnStateChange
+// recording the transitions of the breakers in breakers by name.  S is the
+// state type of the breaker library whose String method returns the names
+// of Closed, HalfOpen and Open.  Transitions of unknown breakers are
+// ignored.
+func OnStateChange[S fmt.Stringer](breakers map[string]*Metrics) func(name string, from, to S) {
+	return func(name string, from, to S) {
+		if m := breakers[name]; m != nil {
+			m.SetState(to.String())
+		}
+	}
+}
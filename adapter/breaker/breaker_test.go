@@ -0,0 +1,47 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+// state mimics the state of gobreaker.
+type state int
+
+func (s state) String() string {
+	return [...]string{Closed, HalfOpen, Open}[s]
+}
+
+func TestBreaker(t *testing.T) {
+	m := &struct {
+		Breakers map[string]*Metrics `metric:"breakers"`
+	}{Breakers: Map("payments")}
+	tags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+
+	onStateChange := OnStateChange[state](m.Breakers)
+	onStateChange("payments", 0, 2)
+	onStateChange("payments", 2, 1)
+	onStateChange("payments", 1, 2)
+	onStateChange("unknown", 0, 2)
+	m.Breakers["payments"].Reject()
+
+	s := tags.Snapshot()
+	if v := s.Stats("breakers.payments.state")["value"]; v != 2 {
+		t.Fatalf("expected the open state, got %v", v)
+	}
+	if v := s.Stats("breakers.payments.trips")["count"]; v != 2 {
+		t.Fatalf("expected 2 trips, got %v", v)
+	}
+	if v := s.Stats("breakers.payments.rejected")["count"]; v != 1 {
+		t.Fatalf("expected 1 rejected request, got %v", v)
+	}
+	if l := s.Labels("breakers.payments.trips"); l["breaker"] != "payments" {
+		t.Fatalf("unexpected labels %v", l)
+	}
+	if f := s.Family("breakers.payments.trips"); f != "breakers.trips" {
+		t.Fatalf("unexpected family %q", f)
+	}
+}
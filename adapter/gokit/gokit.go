@@ -0,0 +1,155 @@
+// Package gokit implements the go-kit metrics interfaces with the metrics of
+// tagged structs, so libraries instrumented with go-kit feed the same
+// registry:
+//
+//	type appMetrics struct {
+//		Requests tagtrics.LabeledCounter[string] `metric:"requests"`
+//		Latency  tagtrics.LabeledTimer[string]   `metric:"latency"`
+//		Inflight tagtrics.Gauge[float64]         `metric:"inflight"`
+//	}
+//
+//	var requests kitmetrics.Counter = gokit.NewLabeledCounter(&m.Requests)
+//	var latency kitmetrics.Histogram = gokit.NewLabeledHistogram(&m.Latency, time.Second)
+//	var inflight kitmetrics.Gauge = gokit.NewGauge(&m.Inflight)
+//
+// The go-kit label values select the metric of a labeled field by the key
+// made of the values, without the label names, joined with dots, e.g.
+// With("method", "send", "code", "200") selects the key "send.200".  The
+// adapters of unlabeled fields ignore the label values.
+package gokit
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	kitmetrics "github.com/go-kit/kit/metrics"
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+// Counter implements the go-kit Counter interface with a counter.  Since
+// go-metrics counters count integers, deltas are rounded to the nearest
+// integer.
+type Counter struct {
+	counter  metrics.Counter
+	counters *tagtrics.LabeledCounter[string]
+	lvs      []string
+}
+
+// NewCounter returns a go-kit Counter incrementing c.
+func NewCounter(c metrics.Counter) *Counter {
+	return &Counter{counter: c}
+}
+
+// NewLabeledCounter returns a go-kit Counter incrementing the counters of c
+// keyed by label values.
+func NewLabeledCounter(c *tagtrics.LabeledCounter[string]) *Counter {
+	return &Counter{counters: c}
+}
+
+// With returns the counter of the label values appended to those of c.
+func (c *Counter) With(labelValues ...string) kitmetrics.Counter {
+	return &Counter{counter: c.counter, counters: c.counters, lvs: with(c.lvs, labelValues)}
+}
+
+// Add increments the counter by delta.
+func (c *Counter) Add(delta float64) {
+	n := int64(math.Round(delta))
+	if c.counters != nil {
+		c.counters.Inc(key(c.lvs), n)
+		return
+	}
+	c.counter.Inc(n)
+}
+
+// Gauge implements the go-kit Gauge interface with a gauge.
+type Gauge struct {
+	// mutex serializes Add, which reads the gauge and updates it.
+	mutex *sync.Mutex
+	gauge *tagtrics.Gauge[float64]
+}
+
+// NewGauge returns a go-kit Gauge setting g.
+func NewGauge(g *tagtrics.Gauge[float64]) *Gauge {
+	return &Gauge{mutex: new(sync.Mutex), gauge: g}
+}
+
+// With returns g since gauges are not labeled.
+func (g *Gauge) With(labelValues ...string) kitmetrics.Gauge {
+	return g
+}
+
+// Set sets the gauge to value.
+func (g *Gauge) Set(value float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.gauge.Update(value)
+}
+
+// Add adds delta to the gauge.
+func (g *Gauge) Add(delta float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.gauge.Update(g.gauge.Value() + delta)
+}
+
+// Histogram implements the go-kit Histogram interface with a histogram or
+// timers.
+type Histogram struct {
+	histogram metrics.Histogram
+	scale     float64
+	timers    *tagtrics.LabeledTimer[string]
+	unit      time.Duration
+	lvs       []string
+}
+
+// NewHistogram returns a go-kit Histogram recording the observed values
+// multiplied by scale, rounded to the nearest integer, in h.  A scale of
+// 1000 keeps three decimals of values such as seconds.
+func NewHistogram(h metrics.Histogram, scale float64) *Histogram {
+	return &Histogram{histogram: h, scale: scale}
+}
+
+// NewLabeledHistogram returns a go-kit Histogram recording the observed
+// values as durations in the timers of t keyed by label values.  The values
+// are counts of unit, usually time.Second for go-kit.
+func NewLabeledHistogram(t *tagtrics.LabeledTimer[string], unit time.Duration) *Histogram {
+	return &Histogram{timers: t, unit: unit}
+}
+
+// With returns the histogram of the label values appended to those of h.
+func (h *Histogram) With(labelValues ...string) kitmetrics.Histogram {
+	c := *h
+	c.lvs = with(h.lvs, labelValues)
+	return &c
+}
+
+// Observe records value.
+func (h *Histogram) Observe(value float64) {
+	if h.timers != nil {
+		h.timers.Update(key(h.lvs), time.Duration(value*float64(h.unit)))
+		return
+	}
+	h.histogram.Update(int64(math.Round(value * h.scale)))
+}
+
+// with returns the label names and values lvs followed by labelValues
+// without modifying lvs.
+func with(lvs, labelValues []string) []string {
+	if len(labelValues)%2 != 0 {
+		labelValues = append(labelValues[:len(labelValues):len(labelValues)], "unknown")
+	}
+	return append(lvs[:len(lvs):len(lvs)], labelValues...)
+}
+
+// key returns the key of the labeled metric of the label names and values
+// lvs.
+func key(lvs []string) string {
+	values := make([]string, 0, len(lvs)/2)
+	for i := 1; i < len(lvs); i += 2 {
+		values = append(values, lvs[i])
+	}
+	return strings.Join(values, ".")
+}
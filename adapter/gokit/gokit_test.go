@@ -0,0 +1,56 @@
+package gokit
+
+import (
+	"testing"
+	"time"
+
+	kitmetrics "github.com/go-kit/kit/metrics"
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+func TestAdapters(t *testing.T) {
+	m := &struct {
+		Sent     metrics.Counter                 `metric:"sent"`
+		Requests tagtrics.LabeledCounter[string] `metric:"requests"`
+		Size     metrics.Histogram               `metric:"size"`
+		Latency  tagtrics.LabeledTimer[string]   `metric:"latency"`
+		Inflight tagtrics.Gauge[float64]         `metric:"inflight"`
+	}{}
+	r := metrics.NewRegistry()
+	tagtrics.NewMetricTags(m, func() {}, time.Second, r, ".")
+
+	var sent kitmetrics.Counter = NewCounter(m.Sent)
+	sent.With("method", "send").Add(2)
+	var requests kitmetrics.Counter = NewLabeledCounter(&m.Requests)
+	send := requests.With("method", "send")
+	send.With("code", "200").Add(1)
+	send.With("code", "200").Add(1.4)
+	send.Add(1)
+	var size kitmetrics.Histogram = NewHistogram(m.Size, 1000)
+	size.Observe(1.5)
+	var latency kitmetrics.Histogram = NewLabeledHistogram(&m.Latency, time.Second)
+	latency.With("method", "send").Observe(0.25)
+	var inflight kitmetrics.Gauge = NewGauge(&m.Inflight)
+	inflight.Set(2)
+	inflight.With("method", "send").Add(-0.5)
+
+	if c := m.Sent.Count(); c != 2 {
+		t.Fatalf("expected 2 sent, got %d", c)
+	}
+	if c := r.Get("requests.send.200").(metrics.Counter).Count(); c != 2 {
+		t.Fatalf("expected 2 requests, got %d", c)
+	}
+	if c := r.Get("requests.send").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 request, got %d", c)
+	}
+	if v := m.Size.Max(); v != 1500 {
+		t.Fatalf("expected a size of 1500, got %d", v)
+	}
+	if v := r.Get("latency.send").(metrics.Timer).Max(); v != int64(250*time.Millisecond) {
+		t.Fatalf("expected a latency of 250ms, got %d", v)
+	}
+	if v := m.Inflight.Value(); v != 1.5 {
+		t.Fatalf("expected 1.5 inflight, got %v", v)
+	}
+}
@@ -0,0 +1,66 @@
+// Package otelspan records the durations of OpenTelemetry spans in tagged
+// timers keyed by span name, so every traced operation gets latency metrics
+// without instrumenting it twice.
+//
+// The Processor is generic over the span types of the OpenTelemetry SDK so
+// this package doesn't depend on it:
+//
+//	type appMetrics struct {
+//		Spans tagtrics.LabeledTimer[string] `metric:"spans"`
+//	}
+//
+//	p := otelspan.New[sdktrace.ReadWriteSpan, sdktrace.ReadOnlySpan](&m.Spans)
+//	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+package otelspan
+
+import (
+	"context"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// Span is the part of a finished span the Processor reads, implemented by
+// sdktrace.ReadOnlySpan.
+type Span interface {
+	Name() string
+	StartTime() time.Time
+	EndTime() time.Time
+}
+
+// Processor implements sdktrace.SpanProcessor when instantiated with the
+// sdktrace.ReadWriteSpan and sdktrace.ReadOnlySpan types as W and R.  It
+// records the duration of every finished span in the timer of its name.
+type Processor[W any, R Span] struct {
+	// Filter skips the spans it returns false for if set, e.g. to only
+	// record server spans.
+	Filter func(span R) bool
+
+	timers *tagtrics.LabeledTimer[string]
+}
+
+// New returns a Processor recording span durations in timers.
+func New[W any, R Span](timers *tagtrics.LabeledTimer[string]) *Processor[W, R] {
+	return &Processor[W, R]{timers: timers}
+}
+
+// OnStart does nothing.
+func (p *Processor[W, R]) OnStart(ctx context.Context, span W) {}
+
+// OnEnd records the duration of the finished span.
+func (p *Processor[W, R]) OnEnd(span R) {
+	if p.Filter != nil && !p.Filter(span) {
+		return
+	}
+	p.timers.Update(span.Name(), span.EndTime().Sub(span.StartTime()))
+}
+
+// Shutdown does nothing since durations are recorded right away.
+func (p *Processor[W, R]) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ForceFlush does nothing since durations are recorded right away.
+func (p *Processor[W, R]) ForceFlush(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,55 @@
+package otelspan
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type span struct {
+	name       string
+	start, end time.Time
+}
+
+func (s span) Name() string         { return s.name }
+func (s span) StartTime() time.Time { return s.start }
+func (s span) EndTime() time.Time   { return s.end }
+
+// spanProcessor has the methods of sdktrace.SpanProcessor with local span
+// types.
+type spanProcessor interface {
+	OnStart(ctx context.Context, s *span)
+	OnEnd(s span)
+	Shutdown(ctx context.Context) error
+	ForceFlush(ctx context.Context) error
+}
+
+func TestProcessor(t *testing.T) {
+	m := &struct {
+		Spans tagtrics.LabeledTimer[string] `metric:"spans"`
+	}{}
+	r := metrics.NewRegistry()
+	tagtrics.NewMetricTags(m, func() {}, time.Second, r, ".")
+
+	p := New[*span, span](&m.Spans)
+	p.Filter = func(s span) bool { return s.name != "skipped" }
+	var sp spanProcessor = p
+	start := time.Now()
+	sp.OnStart(context.Background(), &span{name: "query"})
+	sp.OnEnd(span{name: "query", start: start, end: start.Add(5 * time.Millisecond)})
+	sp.OnEnd(span{name: "skipped", start: start, end: start.Add(time.Millisecond)})
+
+	timer, ok := r.Get("spans.query").(metrics.Timer)
+	if !ok || timer.Count() != 1 || timer.Max() != int64(5*time.Millisecond) {
+		t.Fatalf("unexpected span timer %v", r.Get("spans.query"))
+	}
+	if r.Get("spans.skipped") != nil {
+		t.Fatalf("filtered span recorded")
+	}
+	if sp.Shutdown(context.Background()) != nil || sp.ForceFlush(context.Background()) != nil {
+		t.Fatalf("unexpected error")
+	}
+}
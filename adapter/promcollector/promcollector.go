@@ -0,0 +1,108 @@
+// Package promcollector exposes the metrics of a MetricTags to an existing
+// prometheus/client_golang setup, so services already serving promhttp
+// merge them into their scrape endpoint:
+//
+//	tags := tagtrics.NewMetricTags(m, handler, time.Minute, registry, ".")
+//	prometheus.MustRegister(promcollector.New(tags))
+//	http.Handle("/metrics", promhttp.Handler())
+//
+// Metrics are exported like by MetricTags.OpenMetricsHandler: names are
+// sanitized, the metrics of a family share its name and help, counters
+// become counters named with a "_total" suffix, gauges gauges, meters
+// counters of their count, Info metrics gauges of 1 named with an "_info"
+// suffix, histograms and timers tagged with sample=buckets histograms and
+// other histograms and timers summaries of their percentiles.
+package promcollector
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+	"github.com/sendgrid/tagtrics/internal/prom"
+)
+
+// Collector implements prometheus.Collector with the metrics of a
+// MetricTags.  It is an unchecked collector since the metrics of the keys
+// of maps and of labeled fields come and go.
+type Collector struct {
+	m *tagtrics.MetricTags
+}
+
+// New returns a collector of the metrics of m.
+func New(m *tagtrics.MetricTags) *Collector {
+	return &Collector{m: m}
+}
+
+// Describe describes nothing, which makes c an unchecked collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect sends the metrics of a scrape of the MetricTags, which ends a
+// window in pull-only mode.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.m.Scrape()
+	help := make(map[string]string)
+	for _, name := range s.Names() {
+		family := prom.Name(s.Family(name))
+		if _, ok := help[family]; !ok {
+			// The metrics of a family must share the help of the first.
+			help[family] = s.Meta[name].Help
+		}
+		if metric := collect(s, name, family, help[family]); metric != nil {
+			ch <- metric
+		}
+	}
+}
+
+// collect returns the Prometheus metric of the named metric of the snapshot
+// in the given family, nil if it isn't exported.
+func collect(s *tagtrics.Snapshot, name, family, help string) prometheus.Metric {
+	stats := s.Stats(name)
+	labels := make(prometheus.Labels)
+	for k, v := range s.Labels(name) {
+		labels[prom.LabelName(k)] = v
+	}
+	if s.Meta[name].Type == "info" {
+		desc := prometheus.NewDesc(family+"_info", help, nil, labels)
+		return valid(desc)(prometheus.NewConstMetric(desc, prometheus.GaugeValue, 1))
+	}
+	switch s.Metrics[name].(type) {
+	case metrics.Counter, metrics.Meter:
+		desc := prometheus.NewDesc(family+"_total", help, nil, labels)
+		return valid(desc)(prometheus.NewConstMetric(desc, prometheus.CounterValue, stats["count"]))
+	case metrics.Gauge, metrics.GaugeFloat64:
+		desc := prometheus.NewDesc(family, help, nil, labels)
+		return valid(desc)(prometheus.NewConstMetric(desc, prometheus.GaugeValue, stats["value"]))
+	case metrics.Histogram, metrics.Timer:
+		if buckets := s.Buckets(name); buckets != nil {
+			counts := make(map[float64]uint64, len(buckets))
+			for _, b := range buckets {
+				counts[b.UpperBound] = uint64(b.Count)
+			}
+			desc := prometheus.NewDesc(family, help, nil, labels)
+			return valid(desc)(prometheus.NewConstHistogram(desc, uint64(stats["count"]), stats["mean"]*stats["count"], counts))
+		}
+		quantiles := make(map[float64]float64)
+		for stat, v := range stats {
+			if _, extra := prom.Series(family, stat); extra != nil {
+				q, _ := strconv.ParseFloat(extra["quantile"], 64)
+				quantiles[q] = v
+			}
+		}
+		desc := prometheus.NewDesc(family, help, nil, labels)
+		return valid(desc)(prometheus.NewConstSummary(desc, uint64(stats["count"]), stats["mean"]*stats["count"], quantiles))
+	}
+	return nil
+}
+
+// valid returns a function returning the metric of desc it is passed, or an
+// invalid metric reporting the error to the Prometheus registry.
+func valid(desc *prometheus.Desc) func(prometheus.Metric, error) prometheus.Metric {
+	return func(metric prometheus.Metric, err error) prometheus.Metric {
+		if err != nil {
+			return prometheus.NewInvalidMetric(desc, err)
+		}
+		return metric
+	}
+}
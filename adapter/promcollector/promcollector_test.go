@@ -0,0 +1,73 @@
+package promcollector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type route struct {
+	Requests metrics.Counter `metric:"requests" help:"Requests served"`
+}
+
+func (*route) BucketName(key string) (string, map[string]string) {
+	return key, map[string]string{"route": key}
+}
+
+func TestCollector(t *testing.T) {
+	m := &struct {
+		Queue struct {
+			Depth metrics.Gauge `metric:"depth"`
+		} `metric:"queue"`
+		Latency metrics.Histogram `metric:"latency"`
+		Size    metrics.Histogram `metric:"size,sample=buckets,buckets=10;100"`
+		Routes  map[string]*route `metric:"routes"`
+	}{Routes: map[string]*route{"send": {}, "get": {}}}
+	tags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Queue.Depth.Update(3)
+	m.Latency.Update(10)
+	m.Size.Update(50)
+	m.Routes["send"].Requests.Inc(2)
+
+	var c prometheus.Collector = New(tags)
+	ch := make(chan prometheus.Metric, 1000)
+	c.Collect(ch)
+	close(ch)
+	collected := make(map[string]*dto.Metric)
+	for metric := range ch {
+		var pb dto.Metric
+		if err := metric.Write(&pb); err != nil {
+			t.Fatalf("invalid metric %s: %v", metric.Desc(), err)
+		}
+		desc := metric.Desc().String()
+		name := desc[strings.Index(desc, `"`)+1:]
+		name = name[:strings.Index(name, `"`)]
+		for _, l := range pb.GetLabel() {
+			name += "," + l.GetName() + "=" + l.GetValue()
+		}
+		collected[name] = &pb
+	}
+
+	if v := collected["queue_depth"].GetGauge().GetValue(); v != 3 {
+		t.Fatalf("expected a depth of 3, got %v", v)
+	}
+	if v := collected["routes_requests_total,route=send"].GetCounter().GetValue(); v != 2 {
+		t.Fatalf("expected 2 send requests, got %v", v)
+	}
+	if _, ok := collected["routes_requests_total,route=get"]; !ok {
+		t.Fatalf("missing get requests in %v", collected)
+	}
+	summary := collected["latency"].GetSummary()
+	if summary.GetSampleCount() != 1 || summary.GetSampleSum() != 10 || len(summary.GetQuantile()) != len(tagtrics.DefaultPercentiles) {
+		t.Fatalf("unexpected latency summary %+v", summary)
+	}
+	histogram := collected["size"].GetHistogram()
+	if histogram.GetSampleCount() != 1 || len(histogram.GetBucket()) != 2 || histogram.GetBucket()[1].GetCumulativeCount() != 1 {
+		t.Fatalf("unexpected size histogram %+v", histogram)
+	}
+}
@@ -0,0 +1,205 @@
+// Package tallyscope implements tally.Scope with metrics registered by a
+// MetricTags, so libraries requiring a tally scope report through the same
+// registry and exporters as the tagged structs.  Declare a Scope field by
+// value and pass it to the library once the struct is initialized:
+//
+//	type appMetrics struct {
+//		Cache tallyscope.Scope `metric:"cache"`
+//	}
+//
+//	tagtrics.NewMetricTags(m, handler, time.Minute, registry, ".")
+//	cache := lru.New(lru.Options{Scope: &m.Cache})
+//
+// The metrics of the scope are named after the field, the names of the
+// subscopes and the metric joined with dots, followed by the values of the
+// tags sorted by key, e.g. "cache.hits.users" for the counter "hits" of a
+// scope tagged {"table": "users"}.  The tags are the labels of the metric,
+// whose family leaves the values out.  The buckets of histograms are
+// ignored since the values are sampled.
+package tallyscope
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+	"github.com/uber-go/tally/v4"
+)
+
+// separator joins the segments of metric names.
+const separator = "."
+
+// Scope implements tally.Scope, and tagtrics.Initializer to be a field of a
+// tagged struct.  A Scope that is not initialized returns no-op metrics.
+type Scope struct {
+	reg    *registry
+	prefix string
+	tags   map[string]string
+}
+
+// registry holds the metrics created by a Scope and its subscopes keyed by
+// name.
+type registry struct {
+	r       metrics.Registry
+	mutex   sync.Mutex
+	metrics map[string]interface{}
+}
+
+// InitMetrics implements tagtrics.Initializer.
+func (s *Scope) InitMetrics(name string, r metrics.Registry) error {
+	s.reg = &registry{r: r, metrics: make(map[string]interface{})}
+	s.prefix = name
+	return nil
+}
+
+// Counter returns the counter name of the scope.
+func (s *Scope) Counter(name string) tally.Counter {
+	if c, ok := s.metric(name, func() interface{} { return metrics.NewCounter() }).(metrics.Counter); ok {
+		return c
+	}
+	return metrics.NilCounter{}
+}
+
+// Gauge returns the gauge name of the scope.
+func (s *Scope) Gauge(name string) tally.Gauge {
+	if g, ok := s.metric(name, func() interface{} { return metrics.NewGaugeFloat64() }).(metrics.GaugeFloat64); ok {
+		return g
+	}
+	return metrics.NilGaugeFloat64{}
+}
+
+// Timer returns the timer name of the scope.
+func (s *Scope) Timer(name string) tally.Timer {
+	if t, ok := s.metric(name, func() interface{} { return metrics.NewTimer() }).(metrics.Timer); ok {
+		return timer{t}
+	}
+	return timer{metrics.NilTimer{}}
+}
+
+// Histogram returns the histogram name of the scope.  Durations are recorded
+// in nanoseconds and values rounded to integers.
+func (s *Scope) Histogram(name string, buckets tally.Buckets) tally.Histogram {
+	create := func() interface{} {
+		return metrics.NewHistogram(metrics.NewUniformSample(tagtrics.DefaultSampleSize))
+	}
+	if h, ok := s.metric(name, create).(metrics.Histogram); ok {
+		return histogram{h}
+	}
+	return histogram{metrics.NilHistogram{}}
+}
+
+// Tagged returns a scope adding tags to those of s.
+func (s *Scope) Tagged(tags map[string]string) tally.Scope {
+	merged := make(map[string]string, len(s.tags)+len(tags))
+	for k, v := range s.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return &Scope{reg: s.reg, prefix: s.prefix, tags: merged}
+}
+
+// SubScope returns a scope whose metric names are prefixed with name.
+func (s *Scope) SubScope(name string) tally.Scope {
+	return &Scope{reg: s.reg, prefix: tagtrics.JoinName(s.prefix, separator, name), tags: s.tags}
+}
+
+// Capabilities returns s, which reports and supports tags.
+func (s *Scope) Capabilities() tally.Capabilities {
+	return s
+}
+
+// Reporting implements tally.Capabilities.
+func (s *Scope) Reporting() bool {
+	return true
+}
+
+// Tagging implements tally.Capabilities.
+func (s *Scope) Tagging() bool {
+	return true
+}
+
+// metric returns the metric name of the scope, registering the one returned
+// by create on first use, or nil if s is not initialized.  The metrics of
+// the tags of the scope are registered with labels if the registry supports
+// them.  Callers must check the type of the metric since name may be used
+// by another type.
+func (s *Scope) metric(name string, create func() interface{}) interface{} {
+	if s.reg == nil {
+		return nil
+	}
+	family := tagtrics.JoinName(s.prefix, separator, name)
+	keys := make([]string, 0, len(s.tags))
+	for k := range s.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	full := family
+	for _, k := range keys {
+		full = tagtrics.JoinName(full, separator, s.tags[k])
+	}
+
+	reg := s.reg
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	if metric, ok := reg.metrics[full]; ok {
+		return metric
+	}
+	var metric interface{}
+	if lr, ok := reg.r.(tagtrics.LabeledRegistry); ok {
+		metric = lr.GetOrRegisterLabeled(full, family, s.tags, create())
+	} else {
+		metric = reg.r.GetOrRegister(full, create())
+	}
+	reg.metrics[full] = metric
+	return metric
+}
+
+// timer implements tally.Timer with a timer.
+type timer struct {
+	metrics.Timer
+}
+
+// Record records the duration d.
+func (t timer) Record(d time.Duration) {
+	t.Update(d)
+}
+
+// Start returns a stopwatch recording the time until it is stopped.
+func (t timer) Start() tally.Stopwatch {
+	return tally.NewStopwatch(time.Now(), t)
+}
+
+// RecordStopwatch implements tally.StopwatchRecorder.
+func (t timer) RecordStopwatch(start time.Time) {
+	t.UpdateSince(start)
+}
+
+// histogram implements tally.Histogram with a histogram.
+type histogram struct {
+	metrics.Histogram
+}
+
+// RecordValue records value rounded to an integer.
+func (h histogram) RecordValue(value float64) {
+	h.Update(int64(math.Round(value)))
+}
+
+// RecordDuration records the duration d in nanoseconds.
+func (h histogram) RecordDuration(d time.Duration) {
+	h.Update(int64(d))
+}
+
+// Start returns a stopwatch recording the time until it is stopped.
+func (h histogram) Start() tally.Stopwatch {
+	return tally.NewStopwatch(time.Now(), h)
+}
+
+// RecordStopwatch implements tally.StopwatchRecorder.
+func (h histogram) RecordStopwatch(start time.Time) {
+	h.RecordDuration(time.Since(start))
+}
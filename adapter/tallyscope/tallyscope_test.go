@@ -0,0 +1,54 @@
+package tallyscope
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+	"github.com/uber-go/tally/v4"
+)
+
+func TestScope(t *testing.T) {
+	m := &struct {
+		Cache Scope `metric:"cache"`
+	}{}
+	r := metrics.NewRegistry()
+	tags := tagtrics.NewMetricTags(m, func() {}, time.Second, r, ".")
+
+	var scope tally.Scope = &m.Cache
+	scope.Counter("hits").Inc(1)
+	users := scope.Tagged(map[string]string{"table": "users"})
+	users.Counter("hits").Inc(2)
+	users.Counter("hits").Inc(3)
+	users.SubScope("evictions").Gauge("size").Update(1.5)
+	users.Timer("load").Record(time.Millisecond)
+	users.Histogram("bytes", tally.ValueBuckets{10, 100}).RecordValue(42)
+	if _, ok := scope.Gauge("hits").(metrics.NilGaugeFloat64); !ok {
+		t.Fatalf("expected a no-op gauge for a name used by a counter")
+	}
+
+	if c := r.Get("cache.hits").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 hit, got %d", c)
+	}
+	if c := r.Get("cache.hits.users").(metrics.Counter).Count(); c != 5 {
+		t.Fatalf("expected 5 hits, got %d", c)
+	}
+	if v := r.Get("cache.evictions.size.users").(metrics.GaugeFloat64).Value(); v != 1.5 {
+		t.Fatalf("expected a size of 1.5, got %v", v)
+	}
+	if c := r.Get("cache.load.users").(metrics.Timer).Count(); c != 1 {
+		t.Fatalf("expected 1 load, got %d", c)
+	}
+	if v := r.Get("cache.bytes.users").(metrics.Histogram).Max(); v != 42 {
+		t.Fatalf("expected 42 bytes, got %d", v)
+	}
+	meta, ok := tags.Metadata("cache.hits.users")
+	if !ok || meta.Family != "cache.hits" || meta.Labels["table"] != "users" || meta.Type != "counter" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+
+	var uninitialized Scope
+	uninitialized.Counter("hits").Inc(1)
+	uninitialized.Timer("load").Start().Stop()
+}
@@ -0,0 +1,78 @@
+package tagtrics
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// ElementName returns the name segment of element i of an array field of n
+// elements with the given "metric" tag.  Elements are named after their
+// index unless the tag has one of the options:
+//
+//   - index=hex: the index in hexadecimal padded to whole bytes, e.g. "0f"
+//     for the last element of a [16]T, for shards selected by hash bits.
+//   - names: semicolon separated names of the elements in order, e.g.
+//     "names=us;eu;ap".  Elements without a name fall back to their index.
+func ElementName(tag string, i, n int) string {
+	_, opts := parseTag(tag)
+	return opts.elementName(i, n)
+}
+
+func (o tagOptions) elementName(i, n int) string {
+	if names := o.list("names"); i < len(names) {
+		return names[i]
+	}
+	if o["index"] == "hex" {
+		// Padded to whole bytes like hash prefixes.
+		width := len(strconv.FormatInt(int64(n-1), 16))
+		return fmt.Sprintf("%0*x", width+width%2, i)
+	}
+	return strconv.Itoa(i)
+}
+
+// initArray initializes the elements of the array field f of branch b.
+// Elements are traversed like fields of their type named after
+// ElementName.
+func (m *MetricTags) initArray(val reflect.Value, f fieldPlan, b branch) error {
+	var skipped error
+	n := val.Len()
+	for i := 0; i < n; i++ {
+		elem := val.Index(i)
+		eb := b.child(m, f.opts.elementName(i, n), nil)
+		if eb.rescan && !f.elem.rescanned() {
+			continue
+		}
+		if err := eb.checkLimits(m); err != nil {
+			return err
+		}
+		switch f.elem {
+		case initializerField:
+			if err := m.initCustom(elem.Addr().Interface().(Initializer), eb); err != nil {
+				return err
+			}
+		case typedField:
+			if eb.enabled {
+				elem.Addr().Interface().(typedMetric).initTyped(m, eb.meta("", f.help, f.unit, f.opts), eb.sep)
+			}
+		case lazyField:
+			elem.Addr().Interface().(lazyMetric).initLazy(m, eb)
+		case funcField:
+			m.initFuncGauge(elem.Addr().Interface(), eb, f.help, f.unit, f.opts)
+		case structField:
+			if err := m.initializeFieldTagPath(elem, eb); err != nil {
+				if !isNameError(err) {
+					return err
+				}
+				if skipped == nil {
+					skipped = err
+				}
+			}
+		default:
+			if metric := m.initMetric(elem.Type().String(), eb, f.help, f.unit, f.opts); metric != nil {
+				elem.Set(reflect.ValueOf(metric))
+			}
+		}
+	}
+	return skipped
+}
@@ -0,0 +1,42 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+type shardMetrics struct {
+	Hits metrics.Counter `metric:"hits"`
+}
+
+func TestArray(t *testing.T) {
+	m := &struct {
+		Cache struct {
+			Shards [16]shardMetrics `metric:"shard,index=hex"`
+		} `metric:"cache"`
+		Regions [3]metrics.Counter `metric:"regions,names=us;eu"`
+		Depths  [2]Gauge[int64]    `metric:"depth"`
+	}{}
+	r := metrics.NewRegistry()
+	NewMetricTags(m, func() {}, time.Second, r, ".")
+	m.Cache.Shards[15].Hits.Inc(1)
+	m.Regions[1].Inc(2)
+	m.Depths[1].Update(3)
+
+	if c, ok := r.Get("cache.shard.0f.hits").(metrics.Counter); !ok || c.Count() != 1 {
+		t.Fatalf("cache.shard.0f.hits not registered")
+	}
+	for _, name := range []string{"cache.shard.00.hits", "regions.us", "regions.2", "depth.0"} {
+		if r.Get(name) == nil {
+			t.Fatalf("%s not registered", name)
+		}
+	}
+	if r.Get("regions.eu").(metrics.Counter).Count() != 2 || r.Get("depth.1").(metrics.Gauge).Value() != 3 {
+		t.Fatalf("array elements not registered")
+	}
+	if ElementName("x", 3, 4) != "3" || ElementName("x,index=hex", 255, 256) != "ff" {
+		t.Fatalf("unexpected element names")
+	}
+}
@@ -0,0 +1,216 @@
+package tagtrics
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Bucket is implemented by the struct types of map[string]*T fields which
+// control how the map keys enter the names of their metrics.  The key is a
+// name segment by default, e.g. "traffic.search.latency" for the key
+// "search" of the map "traffic".
+type Bucket interface {
+	// BucketName returns the name segment used for key and the labels
+	// identifying it.  When labels are returned, the segment is left out of
+	// the Family of the metrics beneath, e.g. "traffic.latency" with
+	// {"endpoint": "search"}, so dimensional backends can use labels while
+	// hierarchical ones keep using the unique name.
+	BucketName(key string) (segment string, labels map[string]string)
+}
+
+// BucketKey returns the name segment of the value stored under key in a
+// metric map, which is key unless value implements Bucket.
+func BucketKey(value interface{}, key string) string {
+	if b, ok := value.(Bucket); ok {
+		segment, _ := b.BucketName(key)
+		return segment
+	}
+	return key
+}
+
+// branch holds the state of the traversal beneath a struct field.
+type branch struct {
+	// name is the metric name of the field, prefixing the names beneath.
+	name string
+	// family is name without the segments of map keys exported as labels.
+	family string
+	// sep joins name with the names beneath.
+	sep string
+	// enabled is false beneath fields disabled by a feature flag.
+	enabled bool
+	// labels are the labels of the map keys above.
+	labels map[string]string
+	// parents are the structs above reached through pointers, innermost
+	// first, to detect cycles.
+	parents *parent
+	// depth is the number of segments of name below the root and start
+	// the number of metrics registered when the traversal started, to
+	// enforce the traversal limits.
+	depth, start int
+	// sink is the sink the metrics beneath are routed to, if any.
+	sink string
+	// rescan is true while Rescan traverses the elements initialized
+	// before, which only looks for new elements beneath them.
+	rescan bool
+	// internal is true beneath the self metrics, whose names are set by
+	// tagtrics whatever the separator.
+	internal bool
+	// keyLabel is the label the keys of the map field of the branch are
+	// exported as with the "label" tag option, if any.
+	keyLabel string
+	// ends are the lengths of the names of the branches above, where the
+	// separators in name are whatever the separator used.
+	ends []int
+}
+
+// parent is a struct on the path of a traversal.
+type parent struct {
+	addr uintptr
+	typ  reflect.Type
+	name string
+	next *parent
+}
+
+// rootBranch returns the branch of a struct whose metric names are prefixed
+// with prefix, if any.
+func rootBranch(prefix, sep string) branch {
+	return branch{name: prefix, family: prefix, sep: sep, enabled: true, ends: nameEnds(prefix, sep)}
+}
+
+// nameEnds returns the lengths of the names above name, a name joined with
+// sep only.  It is never nil.
+func nameEnds(name, sep string) []int {
+	ends := []int{}
+	if name == "" || sep == "" {
+		return ends
+	}
+	for i := 0; ; {
+		j := strings.Index(name[i:], sep)
+		if j < 0 {
+			return ends
+		}
+		ends = append(ends, i+j)
+		i += j + len(sep)
+	}
+}
+
+// extendEnds returns ends followed by the length of name, the name above
+// the next one, unless it is empty.  ends is left alone since it is shared
+// by the branches.
+func extendEnds(ends []int, name string) []int {
+	if name == "" {
+		return ends
+	}
+	return append(ends[:len(ends):len(ends)], len(name))
+}
+
+// checkLimits returns an error if the traversal at b exceeds the limits set
+// with WithTraversalLimits.
+func (b branch) checkLimits(m *MetricTags) error {
+	if m.maxDepth > 0 && b.depth > m.maxDepth {
+		return fmt.Errorf("tagtrics: %s is nested more than %d levels deep", describeName(b.name), m.maxDepth)
+	}
+	if m.maxMetrics > 0 {
+		m.metaMutex.RLock()
+		n := m.registered - b.start
+		m.metaMutex.RUnlock()
+		if n > m.maxMetrics {
+			return fmt.Errorf("tagtrics: %s exceeds the limit of %d metrics", describeName(b.name), m.maxMetrics)
+		}
+	}
+	return nil
+}
+
+// child returns the branch of the field named name beneath b with the tag
+// options opts.
+func (b branch) child(m *MetricTags, name string, opts tagOptions) branch {
+	c := b
+	c.name = JoinName(b.name, b.sep, name)
+	c.family = JoinName(b.family, b.sep, name)
+	c.ends = extendEnds(b.ends, b.name)
+	c.depth++
+	c.enabled = b.enabled && m.flagEnabled(opts)
+	c.keyLabel = opts["label"]
+	if s := opts["separator"]; s != "" {
+		c.sep = s
+	}
+	return c
+}
+
+// bucket returns the branch of value stored under key in the map field of
+// branch b.  The key is also exported as the label of the "label" tag
+// option of the field, if any.
+func (b branch) bucket(key string, value interface{}) branch {
+	c := b
+	c.keyLabel = ""
+	segment, labels := key, map[string]string(nil)
+	if bn, ok := value.(Bucket); ok {
+		segment, labels = bn.BucketName(key)
+	}
+	if b.keyLabel != "" {
+		l := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			l[k] = v
+		}
+		l[b.keyLabel] = key
+		labels = l
+	}
+	c.name = JoinName(b.name, b.sep, segment)
+	c.ends = extendEnds(b.ends, b.name)
+	c.depth++
+	if len(labels) == 0 {
+		c.family = JoinName(b.family, b.sep, segment)
+		return c
+	}
+	c.labels = make(map[string]string, len(b.labels)+len(labels))
+	for k, v := range b.labels {
+		c.labels[k] = v
+	}
+	for k, v := range labels {
+		c.labels[k] = v
+	}
+	return c
+}
+
+// enter returns b with the struct v on its path, or an error if v is
+// already on the path since traversing it again would never end, e.g. a
+// map value holding the struct the map belongs to.
+func (b branch) enter(v reflect.Value) (branch, error) {
+	addr := v.UnsafeAddr()
+	for p := b.parents; p != nil; p = p.next {
+		if p.addr == addr && p.typ == v.Type() {
+			return b, fmt.Errorf("tagtrics: cycle at %s: %s refers back to %s", describeName(b.name), v.Type(), describeName(p.name))
+		}
+	}
+	b.parents = &parent{addr: addr, typ: v.Type(), name: b.name, next: b.parents}
+	return b, nil
+}
+
+// describeName returns the name of a metric branch for errors.
+func describeName(name string) string {
+	if name == "" {
+		return "the root"
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// familyOf returns the family of the metric named name registered beneath
+// branch b, which is name unless b is beneath a bucket with labels.
+func (b branch) familyOf(name string) string {
+	if len(b.labels) == 0 || !strings.HasPrefix(name, b.name) {
+		return name
+	}
+	return b.family + name[len(b.name):]
+}
+
+// meta returns the metadata of the metric of the field of branch b.
+func (b branch) meta(kind, help, unit string, opts tagOptions) MetricMeta {
+	meta := newMeta(b.name, kind, help, unit, opts)
+	if len(b.labels) > 0 {
+		meta.Family, meta.Labels = b.family, b.labels
+	}
+	meta.Sink = b.sink
+	meta.ends = b.ends
+	return meta
+}
@@ -0,0 +1,144 @@
+package tagtrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+type tenantMetrics struct {
+	Requests metrics.Counter `metric:"requests"`
+	Hits     LabeledCounter[string]
+}
+
+func (*tenantMetrics) BucketName(key string) (string, map[string]string) {
+	return "tenant_" + key, map[string]string{"tenant": key}
+}
+
+type bucketMetrics struct {
+	Tenants map[string]*tenantMetrics `metric:"tenants"`
+}
+
+func TestBucket(t *testing.T) {
+	m := &bucketMetrics{Tenants: map[string]*tenantMetrics{"acme": {}}}
+	r := metrics.NewRegistry()
+	tags := NewMetricTags(m, func() {}, time.Second, r, ".")
+	m.Tenants["acme"].Hits.Inc("x", 1)
+
+	meta, ok := tags.Metadata("tenants.tenant_acme.requests")
+	if !ok {
+		t.Fatalf("tenants.tenant_acme.requests is not registered")
+	}
+	if meta.Family != "tenants.requests" || meta.Labels["tenant"] != "acme" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if meta, _ := tags.Metadata("tenants.tenant_acme.hits.x"); meta.Family != "tenants.hits.x" {
+		t.Fatalf("unexpected labeled counter family %q", meta.Family)
+	}
+
+	s := tags.Snapshot()
+	if l := s.Labels("tenants.tenant_acme.requests"); l["tenant"] != "acme" {
+		t.Fatalf("unexpected labels %v", l)
+	}
+	for _, p := range s.Points() {
+		if p.Name == "tenants.tenant_acme.requests" && p.Family != "tenants.requests" {
+			t.Fatalf("unexpected point %+v", p)
+		}
+	}
+	if BucketKey(m.Tenants["acme"], "acme") != "tenant_acme" || BucketKey(struct{}{}, "a") != "a" {
+		t.Fatalf("unexpected bucket keys")
+	}
+}
+
+type nodeMetrics struct {
+	Hits     metrics.Counter `metric:"hits"`
+	Children map[string]*nodeMetrics
+}
+
+func TestCycle(t *testing.T) {
+	shared := &nodeMetrics{}
+	m := &nodeMetrics{Children: map[string]*nodeMetrics{"a": shared, "b": shared}}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	if err := tags.Err(); err != nil {
+		t.Fatalf("shared values aren't a cycle: %v", err)
+	}
+
+	delete(m.Children, "b")
+	shared.Children = map[string]*nodeMetrics{"up": m}
+	tags = NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	err := tags.Err()
+	if err == nil || err.Error() != `tagtrics: cycle at "children.a.children.up": tagtrics.nodeMetrics refers back to the root` {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := tags.Metadata("children.a.hits"); !ok {
+		t.Fatalf("metrics before the cycle not initialized")
+	}
+}
+
+func TestKeyLabel(t *testing.T) {
+	m := &struct {
+		Traffic map[string]*struct {
+			Latency metrics.Timer `metric:"latency"`
+			Regions map[string]*subMetrics
+		} `metric:"traffic,label=endpoint"`
+		Tenants map[string]*tenantMetrics `metric:"tenants,label=key"`
+		Lazy    LazyMap[subMetrics]       `metric:"lazy,label=shard"`
+	}{}
+	m.Traffic = map[string]*struct {
+		Latency metrics.Timer `metric:"latency"`
+		Regions map[string]*subMetrics
+	}{"search": {Regions: map[string]*subMetrics{"eu": {}}}}
+	m.Tenants = map[string]*tenantMetrics{"acme": {}}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Lazy.Get("7").Counter.Inc(1)
+
+	for name, want := range map[string]struct {
+		family string
+		labels map[string]string
+	}{
+		"traffic.search.latency":            {"traffic.latency", map[string]string{"endpoint": "search"}},
+		"traffic.search.regions.eu.counter": {"traffic.regions.eu.counter", map[string]string{"endpoint": "search"}},
+		"tenants.tenant_acme.requests":      {"tenants.requests", map[string]string{"tenant": "acme", "key": "acme"}},
+		"lazy.7.counter":                    {"lazy.counter", map[string]string{"shard": "7"}},
+	} {
+		meta, ok := tags.Metadata(name)
+		if !ok {
+			t.Fatalf("%s is not registered", name)
+		}
+		if meta.Family != want.family || len(meta.Labels) != len(want.labels) {
+			t.Fatalf("unexpected metadata of %s: %+v", name, meta)
+		}
+		for k, v := range want.labels {
+			if meta.Labels[k] != v {
+				t.Fatalf("unexpected labels of %s: %v", name, meta.Labels)
+			}
+		}
+	}
+	if err := ValidateField("metrics.Counter", "sent,label=endpoint"); err == nil {
+		t.Fatalf("expected an error for a label on a counter")
+	}
+	if err := ValidateField("tagtrics.LazyMap[tagtrics.subMetrics]", "lazy,label="); err == nil {
+		t.Fatalf("expected an error for an empty label")
+	}
+}
+
+func TestKeyLabelValidation(t *testing.T) {
+	var l recordingLogger
+	m := &struct {
+		Cache   subMetrics             `metric:"cache,label=cache"`
+		Tenants map[string]*subMetrics `metric:"tenants,label="`
+		Shards  [2]subMetrics          `metric:"shards,label=shard"`
+		Regions map[string]*subMetrics `metric:"regions,label=region"`
+	}{Tenants: map[string]*subMetrics{"acme": {}}, Regions: map[string]*subMetrics{"eu": {}}}
+	NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithLogger(&l))
+	expected := []string{
+		"invalid tag options name cache err option label is only supported by maps",
+		"invalid tag options name tenants err option label needs a label name",
+		"invalid tag options name shards err option label is only supported by maps",
+	}
+	if fmt.Sprint(l) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected warnings %q", l)
+	}
+}
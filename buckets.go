@@ -0,0 +1,265 @@
+package tagtrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// maxBuckets bounds the number of buckets of the exponential bucket
+// options.
+const maxBuckets = 1000
+
+// bucketHistogram is a metrics.Histogram counting values in fixed buckets,
+// created for the fields tagged with sample=buckets.  Unlike samples, bucket
+// counts add up across instances and are what Prometheus histograms are
+// made of.  Percentiles are interpolated linearly within the buckets.
+type bucketHistogram struct {
+	histogramStats
+
+	// bounds holds the increasing upper bounds of the buckets.
+	bounds []int64
+	// counts holds the number of values of every bucket, the last one
+	// counting the values above the last bound.
+	counts []int64
+}
+
+// newBucketHistogram creates a histogram of buckets with the given
+// increasing upper bounds.
+func newBucketHistogram(bounds []int64) (*bucketHistogram, error) {
+	if len(bounds) == 0 {
+		return nil, fmt.Errorf("buckets need at least one bound")
+	}
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return nil, fmt.Errorf("bucket bounds must be increasing, got %d after %d", bounds[i], bounds[i-1])
+		}
+	}
+	h := &bucketHistogram{bounds: bounds, counts: make([]int64, len(bounds)+1)}
+	h.reset()
+	return h, nil
+}
+
+// reset clears all recorded values.  The caller must hold the mutex.
+func (h *bucketHistogram) reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.clearStats()
+}
+
+// Clear clears the histogram.
+func (h *bucketHistogram) Clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checkWritable("Clear", "bucketHistogram")
+	h.reset()
+}
+
+// Percentile returns the value at percentile p which is between 0 and 1.
+func (h *bucketHistogram) Percentile(p float64) float64 {
+	return h.Percentiles([]float64{p})[0]
+}
+
+// Percentiles returns the values at each of the percentiles ps which are
+// between 0 and 1, interpolated within the bucket holding them.  The
+// smallest and largest values recorded bound the first and last buckets.
+func (h *bucketHistogram) Percentiles(ps []float64) []float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	scores := make([]float64, len(ps))
+	if h.totalCount == 0 {
+		return scores
+	}
+	for i, p := range ps {
+		rank := math.Min(math.Max(p, 0), 1) * float64(h.totalCount)
+		var seen int64
+		for idx, c := range h.counts {
+			if c == 0 || float64(seen+c) < rank {
+				seen += c
+				continue
+			}
+			lower, upper := float64(h.min), float64(h.max)
+			if idx > 0 {
+				lower = math.Max(lower, float64(h.bounds[idx-1]))
+			}
+			if idx < len(h.bounds) {
+				upper = math.Min(upper, float64(h.bounds[idx]))
+			}
+			scores[i] = lower + (upper-lower)*(rank-float64(seen))/float64(c)
+			break
+		}
+	}
+	return scores
+}
+
+// Sample returns a metrics.Sample view of the histogram.
+func (h *bucketHistogram) Sample() metrics.Sample {
+	return bucketSample{h}
+}
+
+// Snapshot returns a read-only copy of the histogram.
+func (h *bucketHistogram) Snapshot() metrics.Histogram {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s := &bucketHistogram{
+		histogramStats: h.frozenStats(),
+		bounds:         h.bounds,
+		counts:         make([]int64, len(h.counts)),
+	}
+	copy(s.counts, h.counts)
+	return s
+}
+
+// Update records v.
+func (h *bucketHistogram) Update(v int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checkWritable("Update", "bucketHistogram")
+	h.counts[sort.Search(len(h.bounds), func(i int) bool { return v <= h.bounds[i] })]++
+	h.record(v)
+}
+
+// footprint implements footprinter with a count per bucket.
+func (h *bucketHistogram) footprint() (samples, bytes int64) {
+	return int64(len(h.counts)), int64(len(h.counts)+len(h.bounds)) * 8
+}
+
+// cumulative returns the number of values up to every bound, those of the
+// lower buckets included.
+func (h *bucketHistogram) cumulative() []int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	counts := make([]int64, len(h.bounds))
+	var seen int64
+	for i := range h.bounds {
+		seen += h.counts[i]
+		counts[i] = seen
+	}
+	return counts
+}
+
+// bucketSample adapts bucketHistogram to metrics.Sample.
+type bucketSample struct {
+	*bucketHistogram
+}
+
+// Size returns the number of recorded values.
+func (s bucketSample) Size() int {
+	return int(s.Count())
+}
+
+// Snapshot returns a go-metrics sample snapshot holding Values.
+func (s bucketSample) Snapshot() metrics.Sample {
+	return metrics.NewSampleSnapshot(s.Count(), s.Values())
+}
+
+// Values returns the upper bound of every non-empty bucket, the largest
+// value recorded for the last one.  A bucket histogram does not keep
+// individual values so this is an approximation of their distribution, not
+// a list of everything recorded.
+func (s bucketSample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var values []int64
+	for i, c := range s.counts {
+		if c == 0 {
+			continue
+		}
+		if i < len(s.bounds) && s.bounds[i] < s.max {
+			values = append(values, s.bounds[i])
+		} else {
+			values = append(values, s.max)
+		}
+	}
+	return values
+}
+
+// HistogramBucket is a bucket of a histogram or timer tagged with
+// sample=buckets.
+type HistogramBucket struct {
+	// UpperBound is the largest value counted in the bucket, in the
+	// duration unit of the snapshot for timers.
+	UpperBound float64
+	// Count is the number of values up to UpperBound, those of the lower
+	// buckets included.
+	Count int64
+}
+
+// Buckets returns the buckets of the named histogram or timer if it is
+// tagged with sample=buckets, or nil.  The values above the last bucket are
+// only in the count of the metric, like in the "+Inf" bucket of Prometheus.
+func (s *Snapshot) Buckets(name string) []HistogramBucket {
+	var h *bucketHistogram
+	unit := 1.0
+	switch metric := s.Metrics[name].(type) {
+	case *bucketHistogram:
+		h = metric
+	case *histogramTimer:
+		h, _ = metric.histogram.(*bucketHistogram)
+		unit = float64(s.durationUnit(name))
+	}
+	if h == nil {
+		return nil
+	}
+	buckets := make([]HistogramBucket, len(h.bounds))
+	for i, count := range h.cumulative() {
+		buckets[i] = HistogramBucket{UpperBound: float64(h.bounds[i]) / unit, Count: count}
+	}
+	return buckets
+}
+
+// bucketBounds parses the bounds of the "buckets" option, e.g.
+// "1ms;5ms;25ms", or the exponential bounds of the "start", "factor" and
+// "count" options, e.g. "start=1ms,factor=2,count=10".  Bounds are integers
+// or durations in nanoseconds.
+func (o tagOptions) bucketBounds() ([]int64, error) {
+	if o.Has("buckets") {
+		if o.Has("start") || o.Has("factor") || o.Has("count") {
+			return nil, fmt.Errorf("option buckets can't be combined with start, factor and count")
+		}
+		var bounds []int64
+		for _, v := range o.list("buckets") {
+			bound, err := parseBound(v)
+			if err != nil {
+				return nil, err
+			}
+			bounds = append(bounds, bound)
+		}
+		return bounds, nil
+	}
+	start, err := parseBound(o["start"])
+	if err != nil || start <= 0 {
+		return nil, fmt.Errorf("invalid start %q, must be positive", o["start"])
+	}
+	factor, err := strconv.ParseFloat(o["factor"], 64)
+	if err != nil || factor <= 1 {
+		return nil, fmt.Errorf("invalid factor %q, must be greater than 1", o["factor"])
+	}
+	count, err := o.int64("count", 0)
+	if err != nil || count < 1 || count > maxBuckets {
+		return nil, fmt.Errorf("invalid count %q, must be between 1 and %d", o["count"], maxBuckets)
+	}
+	bounds := make([]int64, count)
+	for i := range bounds {
+		bounds[i] = int64(math.Round(float64(start) * math.Pow(factor, float64(i))))
+	}
+	return bounds, nil
+}
+
+// parseBound parses a bucket bound, an integer or a duration.
+func parseBound(v string) (int64, error) {
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return i, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid bucket bound %q", v)
+	}
+	return int64(d), nil
+}
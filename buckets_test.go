@@ -0,0 +1,85 @@
+package tagtrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestBucketHistogram(t *testing.T) {
+	h, err := newBucketHistogram([]int64{10, 20, 50})
+	if err != nil {
+		t.Fatalf("failed to create histogram: %v", err)
+	}
+	for _, v := range []int64{5, 10, 15, 20, 30, 100} {
+		h.Update(v)
+	}
+	if h.Count() != 6 || h.Min() != 5 || h.Max() != 100 || h.Sum() != 180 {
+		t.Fatalf("unexpected count/min/max/sum: %d %d %d %d", h.Count(), h.Min(), h.Max(), h.Sum())
+	}
+	if got := h.cumulative(); got[0] != 2 || got[1] != 4 || got[2] != 5 {
+		t.Fatalf("unexpected cumulative counts %v", got)
+	}
+	// The median is halfway through the second bucket and the maximum
+	// bounds the overflow bucket.
+	if ps := h.Percentiles([]float64{0.5, 1}); ps[0] != 15 || ps[1] != 100 {
+		t.Fatalf("unexpected percentiles %v", ps)
+	}
+	s := h.Snapshot()
+	h.Update(1)
+	if s.Count() != 6 {
+		t.Fatalf("snapshot changed after update")
+	}
+	if _, err := newBucketHistogram([]int64{10, 10}); err == nil {
+		t.Fatalf("expected error for bounds which don't increase")
+	}
+}
+
+type histogramBucketMetrics struct {
+	Latency metrics.Timer     `metric:"latency,sample=buckets,buckets=1ms;10ms;100ms" help:"Request latency"`
+	Size    metrics.Histogram `metric:"size,sample=buckets,start=100,factor=10,count=3"`
+}
+
+func TestBucketsTag(t *testing.T) {
+	m := &histogramBucketMetrics{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithDurationUnit(time.Millisecond))
+	if h, ok := m.Size.(*bucketHistogram); !ok || len(h.bounds) != 3 || h.bounds[2] != 10000 {
+		t.Fatalf("size is not a bucket histogram up to 10000: %#v", m.Size)
+	}
+	m.Latency.Update(5 * time.Millisecond)
+	m.Latency.Update(time.Second)
+	tags.nowHandler = func() time.Time { return time.Unix(1700000000, 0) }
+	tags.RecordWithExemplar(m.Latency, int64(50*time.Millisecond), "abc")
+
+	s := tags.Snapshot()
+	buckets := s.Buckets("latency")
+	if len(buckets) != 3 || buckets[1].UpperBound != 10 || buckets[1].Count != 1 || buckets[2].Count != 2 {
+		t.Fatalf("unexpected buckets %+v", buckets)
+	}
+	if s.Buckets("size") == nil {
+		t.Fatalf("expected the buckets of the size")
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteOpenMetrics(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE latency histogram\n# HELP latency Request latency\n",
+		`latency_bucket{le="1"} 0` + "\n",
+		`latency_bucket{le="10"} 1` + "\n",
+		`latency_bucket{le="100"} 2 # {trace_id="abc"} 50 1700000000.000` + "\n",
+		`latency_bucket{le="+Inf"} 3` + "\n",
+		"latency_sum 1055\n",
+		"latency_count 3\n",
+		"# TYPE size histogram\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tagtrics
+
+import (
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// MetricTagsBuilder configures a MetricTags step by step as an alternative to
+// the positional arguments and options of NewMetricTags:
+//
+//	mTags := tagtrics.NewMetricTagsBuilder().
+//		Registry(registry).
+//		Separator("_").
+//		Struct(&appMetrics).
+//		Sink(sink).
+//		Build()
+//
+// Unless set, the struct is empty, the registry is metrics.DefaultRegistry,
+// the separator is DefaultSeparator, the flush interval is
+// DefaultFlushInterval and there is no update handler.
+type MetricTagsBuilder struct {
+	metricsData   interface{}
+	updateHandler MetricsUpdateHandler
+	flushInterval time.Duration
+	registry      metrics.Registry
+	separator     string
+	options       []Option
+	sinks         []routedSink
+}
+
+// NewMetricTagsBuilder returns a builder with the defaults of
+// MetricTagsBuilder.
+func NewMetricTagsBuilder() *MetricTagsBuilder {
+	return &MetricTagsBuilder{
+		metricsData:   &struct{}{},
+		flushInterval: DefaultFlushInterval,
+		registry:      metrics.DefaultRegistry,
+		separator:     DefaultSeparator,
+	}
+}
+
+// Struct sets the struct containing "metric" tags whose fields are
+// initialized by Build.
+func (b *MetricTagsBuilder) Struct(metricsData interface{}) *MetricTagsBuilder {
+	b.metricsData = metricsData
+	return b
+}
+
+// UpdateHandler sets the handler called on every flush.
+func (b *MetricTagsBuilder) UpdateHandler(h MetricsUpdateHandler) *MetricTagsBuilder {
+	b.updateHandler = h
+	return b
+}
+
+// FlushInterval sets the interval between flushes.  Zero selects the
+// pull-only mode of WithPullOnly.
+func (b *MetricTagsBuilder) FlushInterval(d time.Duration) *MetricTagsBuilder {
+	b.flushInterval = d
+	return b
+}
+
+// Registry sets the registry the metrics are registered in.
+func (b *MetricTagsBuilder) Registry(r metrics.Registry) *MetricTagsBuilder {
+	b.registry = r
+	return b
+}
+
+// Separator sets the separator of the metric names.
+func (b *MetricTagsBuilder) Separator(sep string) *MetricTagsBuilder {
+	b.separator = sep
+	return b
+}
+
+// With adds options applied in order by Build.
+func (b *MetricTagsBuilder) With(options ...Option) *MetricTagsBuilder {
+	b.options = append(b.options, options...)
+	return b
+}
+
+// Sink adds a sink like MetricTags.AddSink.
+func (b *MetricTagsBuilder) Sink(s Sink) *MetricTagsBuilder {
+	return b.NamedSink("", s)
+}
+
+// NamedSink adds a sink like MetricTags.AddNamedSink.
+func (b *MetricTagsBuilder) NamedSink(name string, s Sink) *MetricTagsBuilder {
+	b.sinks = append(b.sinks, routedSink{Sink: s, name: name})
+	return b
+}
+
+// Build creates the MetricTags like NewMetricTags and adds the sinks in
+// order.  Initialization errors are reported by Err.
+func (b *MetricTagsBuilder) Build() *MetricTags {
+	m := NewMetricTags(b.metricsData, b.updateHandler, b.flushInterval, b.registry, b.separator, b.options...)
+	for _, s := range b.sinks {
+		m.AddNamedSink(s.name, s.Sink)
+	}
+	return m
+}
@@ -0,0 +1,47 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestMetricTagsBuilder(t *testing.T) {
+	r := metrics.NewRegistry()
+	data := &metaMetrics{}
+	var sent, debug int
+	mTags := NewMetricTagsBuilder().
+		Registry(r).
+		Separator("_").
+		Struct(data).
+		FlushInterval(time.Hour).
+		With(WithFlushCounter()).
+		Sink(SinkFunc(func(*Snapshot) error {
+			sent++
+			return nil
+		})).
+		NamedSink("debug", SinkFunc(func(*Snapshot) error {
+			debug++
+			return nil
+		})).
+		Build()
+	if err := mTags.Err(); err != nil {
+		t.Fatalf("failed to build: %v", err)
+	}
+	if r.Get("queue_depth") == nil || r.Get("tagtrics_flushes") == nil {
+		t.Fatalf("unexpected metrics: %v", r.GetAll())
+	}
+	if mTags.interval() != time.Hour || mTags.pullOnly {
+		t.Fatalf("unexpected interval %v", mTags.interval())
+	}
+	mTags.flush()
+	if sent != 1 || debug != 1 {
+		t.Fatalf("expected both sinks to be sent a snapshot, got %d and %d", sent, debug)
+	}
+
+	d := NewMetricTagsBuilder().Registry(metrics.NewRegistry()).Build()
+	if d.separator != DefaultSeparator || d.interval() != DefaultFlushInterval || d.Err() != nil {
+		t.Fatalf("unexpected defaults: %q %v %v", d.separator, d.interval(), d.Err())
+	}
+}
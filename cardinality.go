@@ -0,0 +1,121 @@
+package tagtrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"strconv"
+	"sync"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// DefaultCardinalityPrecision is the number of register index bits of a
+// CardinalityCounter unless set with the "precision" tag option.  It uses
+// 2^14 bytes and has a standard error of about 0.8%.
+const DefaultCardinalityPrecision = 14
+
+// CardinalityCounter estimates the number of unique items added since the
+// last flush using HyperLogLog, such as unique senders or IP addresses.  Its
+// value is the estimate and it is cleared after every flush.  Update adds
+// the decimal form of its value as an item, so numeric IDs can be counted
+// wherever a metrics.Gauge is updated.
+type CardinalityCounter interface {
+	metrics.Gauge
+	// Add adds an item.
+	Add(item string)
+	// Clear forgets all items.
+	Clear()
+}
+
+// NewCardinalityCounter constructs a new CardinalityCounter using 2^precision
+// registers.  precision must be between 4 and 18.
+func NewCardinalityCounter(precision int) (CardinalityCounter, error) {
+	if precision < 4 || precision > 18 {
+		return nil, fmt.Errorf("cardinality precision must be between 4 and 18, got %d", precision)
+	}
+	return &hyperLogLog{
+		precision: uint(precision),
+		registers: make([]uint8, 1<<uint(precision)),
+	}, nil
+}
+
+// hyperLogLog is the standard implementation of a CardinalityCounter.
+type hyperLogLog struct {
+	mutex     sync.Mutex
+	precision uint
+	registers []uint8
+}
+
+// Add adds an item.
+func (h *hyperLogLog) Add(item string) {
+	f := fnv.New64a()
+	f.Write([]byte(item))
+	x := mix64(f.Sum64())
+	idx := x >> (64 - h.precision)
+	// The rank is the position of the first set bit in the remaining bits.
+	rank := uint8(bits.LeadingZeros64(x<<h.precision|1<<(h.precision-1)) + 1)
+	h.mutex.Lock()
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+	h.mutex.Unlock()
+}
+
+// Clear forgets all items.
+func (h *hyperLogLog) Clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i := range h.registers {
+		h.registers[i] = 0
+	}
+}
+
+// resetWindow clears the counter after a flush.
+func (h *hyperLogLog) resetWindow() {
+	h.Clear()
+}
+
+// Snapshot returns a read-only copy of the estimate.
+func (h *hyperLogLog) Snapshot() metrics.Gauge {
+	return metrics.GaugeSnapshot(h.Value())
+}
+
+// Update adds the decimal form of v as an item.
+func (h *hyperLogLog) Update(v int64) {
+	h.Add(strconv.FormatInt(v, 10))
+}
+
+// Value returns the estimated number of unique items.
+func (h *hyperLogLog) Value() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	m := float64(len(h.registers))
+	var sum float64
+	var zeros int
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(estimate + 0.5)
+}
+
+// mix64 is the murmur3 finalizer which spreads the bits of FNV hashes of short
+// strings evenly.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
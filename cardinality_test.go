@@ -0,0 +1,59 @@
+package tagtrics
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestCardinalityCounter(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100000} {
+		c, err := NewCardinalityCounter(DefaultCardinalityPrecision)
+		if err != nil {
+			t.Fatalf("failed to create counter: %v", err)
+		}
+		for i := 0; i < n; i++ {
+			c.Add("sender" + strconv.Itoa(i))
+			c.Add("sender" + strconv.Itoa(i))
+		}
+		if got := c.Value(); math.Abs(float64(got-int64(n))) > 0.03*float64(n) {
+			t.Fatalf("estimated %d unique items, want about %d", got, n)
+		}
+	}
+	c, _ := NewCardinalityCounter(DefaultCardinalityPrecision)
+	c.Add("42")
+	c.Update(42)
+	c.Update(43)
+	if c.Value() != 2 {
+		t.Fatalf("estimated %d unique IDs, want 2", c.Value())
+	}
+	if _, err := NewCardinalityCounter(2); err == nil {
+		t.Fatalf("expected error for precision 2")
+	}
+}
+
+type cardinalityMetrics struct {
+	Senders CardinalityCounter `metric:"senders,precision=10"`
+}
+
+func TestCardinalityCounterFlush(t *testing.T) {
+	m := &cardinalityMetrics{}
+	var seen int64
+	var mTags *MetricTags
+	h := func() {
+		seen = mTags.Snapshot().Metrics["senders"].(metrics.Gauge).Value()
+	}
+	mTags = NewMetricTags(m, h, time.Second, metrics.NewRegistry(), ".")
+	m.Senders.Add("a")
+	m.Senders.Add("b")
+	mTags.flush()
+	if seen != 2 {
+		t.Fatalf("handler saw %d unique items, want 2", seen)
+	}
+	if m.Senders.Value() != 0 {
+		t.Fatalf("counter not reset after flush: %d", m.Senders.Value())
+	}
+}
@@ -0,0 +1,340 @@
+package tagtrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// CKMS summary defaults used when the tag options are not set.
+const (
+	// DefaultCKMSEpsilon is the error allowed in the rank of the targeted
+	// quantiles, i.e. the 99th percentile is between the 98.9th and the
+	// 99.1th.
+	DefaultCKMSEpsilon = 0.001
+	// DefaultCKMSWindow is how far back the quantiles look.
+	DefaultCKMSWindow = 10 * time.Minute
+)
+
+const (
+	// ckmsAgeBuckets is the number of streams the window slides by, one
+	// being reset every window/ckmsAgeBuckets.
+	ckmsAgeBuckets = 5
+	// ckmsBufferSize is the number of values buffered before they are
+	// merged into the streams in one sorted batch.
+	ckmsBufferSize = 500
+)
+
+// ckmsHistogram is a metrics.Histogram computing targeted quantiles with
+// bounded errors over a sliding window with the CKMS algorithm, created for
+// the fields tagged with sample=ckms.  Unlike a reservoir it keeps just
+// enough values to answer the targeted quantiles within their error, so its
+// memory stays small at any throughput.  The count, sum, minimum, maximum
+// and variance cover every value recorded, only the quantiles cover the
+// window.
+type ckmsHistogram struct {
+	histogramStats
+	// now returns the current time, overwritten in tests.
+	now func() time.Time
+
+	targets []ckmsTarget
+	window  time.Duration
+	// streams hold the values of overlapping windows, the head one holding
+	// the oldest values.  Every value goes to all of them.
+	streams  []*ckmsStream
+	head     int
+	rotateAt time.Time
+	buffer   []float64
+}
+
+// ckmsTarget is a quantile between 0 and 1 along with the error allowed in
+// its rank.
+type ckmsTarget struct {
+	quantile, epsilon float64
+}
+
+// newCKMSHistogram creates a CKMS histogram targeting the quantiles qs,
+// which are between 0 and 1, with the rank error epsilon over window.
+func newCKMSHistogram(qs []float64, epsilon float64, window time.Duration) (*ckmsHistogram, error) {
+	if epsilon <= 0 || epsilon >= 0.5 {
+		return nil, fmt.Errorf("ckms epsilon must be between 0 and 0.5, got %v", epsilon)
+	}
+	if window < time.Second {
+		return nil, fmt.Errorf("ckms window must be at least a second, got %v", window)
+	}
+	h := &ckmsHistogram{now: time.Now, window: window}
+	for _, q := range qs {
+		h.targets = append(h.targets, ckmsTarget{quantile: q, epsilon: epsilon})
+	}
+	h.streams = make([]*ckmsStream, ckmsAgeBuckets)
+	for i := range h.streams {
+		h.streams[i] = &ckmsStream{targets: h.targets}
+	}
+	h.reset()
+	return h, nil
+}
+
+// reset clears all recorded values.  The caller must hold the mutex.
+func (h *ckmsHistogram) reset() {
+	for _, s := range h.streams {
+		s.reset()
+	}
+	h.head = 0
+	h.rotateAt = h.now().Add(h.window / ckmsAgeBuckets)
+	h.buffer = h.buffer[:0]
+	h.clearStats()
+}
+
+// flush merges the buffered values into every stream.  The caller must hold
+// the mutex.
+func (h *ckmsHistogram) flush() {
+	if len(h.buffer) == 0 {
+		return
+	}
+	sort.Float64s(h.buffer)
+	for _, s := range h.streams {
+		s.merge(h.buffer)
+	}
+	h.buffer = h.buffer[:0]
+}
+
+// rotate resets the streams whose window has passed, the head stream then
+// becoming the newest.  The caller must hold the mutex.
+func (h *ckmsHistogram) rotate() {
+	if h.frozen {
+		return
+	}
+	now := h.now()
+	if now.Before(h.rotateAt) {
+		return
+	}
+	h.flush()
+	if now.Sub(h.rotateAt) >= h.window {
+		// Every stream is past its window.
+		for _, s := range h.streams {
+			s.reset()
+		}
+		h.rotateAt = now
+	}
+	for !now.Before(h.rotateAt) {
+		h.streams[h.head].reset()
+		h.head = (h.head + 1) % len(h.streams)
+		h.rotateAt = h.rotateAt.Add(h.window / ckmsAgeBuckets)
+	}
+}
+
+// Clear clears the histogram.
+func (h *ckmsHistogram) Clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checkWritable("Clear", "ckmsHistogram")
+	h.reset()
+}
+
+// Percentile returns the value at percentile p which is between 0 and 1.
+func (h *ckmsHistogram) Percentile(p float64) float64 {
+	return h.Percentiles([]float64{p})[0]
+}
+
+// Percentiles returns the values at each of the percentiles ps which are
+// between 0 and 1, over the window.  Percentiles which aren't targeted have
+// no error bound.
+func (h *ckmsHistogram) Percentiles(ps []float64) []float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.rotate()
+	h.flush()
+	scores := make([]float64, len(ps))
+	for i, p := range ps {
+		scores[i] = h.streams[h.head].query(p)
+	}
+	return scores
+}
+
+// Sample returns a metrics.Sample view of the histogram.
+func (h *ckmsHistogram) Sample() metrics.Sample {
+	return ckmsSample{h}
+}
+
+// Snapshot returns a read-only copy of the histogram.
+func (h *ckmsHistogram) Snapshot() metrics.Histogram {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.rotate()
+	h.flush()
+	head := h.streams[h.head]
+	return &ckmsHistogram{
+		histogramStats: h.frozenStats(),
+		now:            h.now,
+		targets:        h.targets,
+		window:         h.window,
+		streams: []*ckmsStream{{
+			targets: h.targets,
+			tuples:  append([]ckmsTuple(nil), head.tuples...),
+			n:       head.n,
+		}},
+	}
+}
+
+// Update records v.
+func (h *ckmsHistogram) Update(v int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checkWritable("Update", "ckmsHistogram")
+	h.rotate()
+	h.buffer = append(h.buffer, float64(v))
+	if len(h.buffer) >= ckmsBufferSize {
+		h.flush()
+	}
+	h.record(v)
+}
+
+// footprint implements footprinter with the values kept by the streams and
+// the buffer.
+func (h *ckmsHistogram) footprint() (samples, bytes int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, s := range h.streams {
+		samples += int64(len(s.tuples))
+	}
+	return samples + int64(len(h.buffer)), samples*24 + int64(cap(h.buffer))*8
+}
+
+// ckmsSample adapts ckmsHistogram to metrics.Sample.
+type ckmsSample struct {
+	*ckmsHistogram
+}
+
+// Size returns the number of recorded values.
+func (s ckmsSample) Size() int {
+	return int(s.Count())
+}
+
+// Snapshot returns a go-metrics sample snapshot holding Values.
+func (s ckmsSample) Snapshot() metrics.Sample {
+	return metrics.NewSampleSnapshot(s.Count(), s.Values())
+}
+
+// Values returns the values kept for the window.  A CKMS histogram only
+// keeps the values needed by the targeted quantiles so this is an
+// approximation of their distribution, not a list of everything recorded.
+func (s ckmsSample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rotate()
+	s.flush()
+	var values []int64
+	for _, t := range s.streams[s.head].tuples {
+		values = append(values, int64(t.value))
+	}
+	return values
+}
+
+// ckmsStream summarizes a stream of values with the targeted quantiles of
+// Cormode, Korn, Muthukrishnan and Srivastava, "Effective Computation of
+// Biased Quantiles over Data Streams".
+type ckmsStream struct {
+	targets []ckmsTarget
+	// tuples are sorted by value.
+	tuples []ckmsTuple
+	// n is the number of values merged.
+	n float64
+}
+
+// ckmsTuple is a value kept by a ckmsStream.  width is the difference
+// between its lowest possible rank and that of the previous tuple, delta the
+// difference between its highest and lowest possible ranks.
+type ckmsTuple struct {
+	value, width, delta float64
+}
+
+// reset forgets every value.
+func (s *ckmsStream) reset() {
+	s.tuples = s.tuples[:0]
+	s.n = 0
+}
+
+// allowed returns the error allowed in the rank r so that every target
+// stays within its error.
+func (s *ckmsStream) allowed(r float64) float64 {
+	f := math.MaxFloat64
+	for _, t := range s.targets {
+		var e float64
+		if r >= t.quantile*s.n {
+			e = 2 * t.epsilon * r / t.quantile
+		} else {
+			e = 2 * t.epsilon * (s.n - r) / (1 - t.quantile)
+		}
+		f = math.Min(f, e)
+	}
+	return f
+}
+
+// merge inserts the sorted values and compresses the stream.
+func (s *ckmsStream) merge(values []float64) {
+	var r float64
+	i := 0
+	for _, v := range values {
+		for i < len(s.tuples) && s.tuples[i].value <= v {
+			r += s.tuples[i].width
+			i++
+		}
+		t := ckmsTuple{value: v, width: 1}
+		if i > 0 && i < len(s.tuples) {
+			// The first and last tuples are the exact minimum and maximum.
+			t.delta = math.Max(0, math.Floor(s.allowed(r))-1)
+		}
+		s.tuples = append(s.tuples, ckmsTuple{})
+		copy(s.tuples[i+1:], s.tuples[i:])
+		s.tuples[i] = t
+		i++
+		s.n++
+		r++
+	}
+	s.compress()
+}
+
+// compress merges the tuples whose ranks are precise enough into the next
+// ones.  The first and last tuples are kept as the minimum and maximum.
+func (s *ckmsStream) compress() {
+	if len(s.tuples) < 3 {
+		return
+	}
+	xi := len(s.tuples) - 1
+	x := s.tuples[xi]
+	r := s.n - 1 - x.width
+	for i := len(s.tuples) - 2; i >= 1; i-- {
+		c := s.tuples[i]
+		if c.width+x.width+x.delta <= s.allowed(r) {
+			x.width += c.width
+			s.tuples[xi] = x
+			s.tuples = append(s.tuples[:i], s.tuples[i+1:]...)
+			xi--
+		} else {
+			x, xi = c, i
+		}
+		r -= c.width
+	}
+}
+
+// query returns the value at quantile q, or 0 if the stream is empty.
+func (s *ckmsStream) query(q float64) float64 {
+	if len(s.tuples) == 0 {
+		return 0
+	}
+	t := math.Ceil(q * s.n)
+	t += math.Ceil(s.allowed(t) / 2)
+	prev := s.tuples[0]
+	var r float64
+	for _, c := range s.tuples[1:] {
+		r += prev.width
+		if r+c.width+c.delta > t {
+			return prev.value
+		}
+		prev = c
+	}
+	return prev.value
+}
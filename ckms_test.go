@@ -0,0 +1,109 @@
+package tagtrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestCKMSHistogram(t *testing.T) {
+	h, err := newCKMSHistogram([]float64{0.5, 0.9, 0.99}, 0.001, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create histogram: %v", err)
+	}
+	now := time.Unix(0, 0)
+	h.now = func() time.Time { return now }
+	h.reset()
+
+	const n = 100000
+	for _, i := range rand.New(rand.NewSource(1)).Perm(n) {
+		h.Update(int64(i + 1))
+	}
+	if h.Count() != n || h.Min() != 1 || h.Max() != n {
+		t.Fatalf("unexpected count/min/max: %d %d %d", h.Count(), h.Min(), h.Max())
+	}
+	qs := []float64{0.5, 0.9, 0.99}
+	ps := h.Percentiles(qs)
+	for i, q := range qs {
+		// Ranks are within epsilon of the targeted quantile.
+		if math.Abs(ps[i]-q*n) > 0.001*n {
+			t.Fatalf("percentile %v: got %f want %f", q, ps[i], q*n)
+		}
+	}
+	if samples, _ := h.footprint(); samples >= ckmsAgeBuckets*n/10 {
+		t.Fatalf("expected far fewer values kept than recorded, got %d", samples)
+	}
+
+	s := h.Snapshot()
+	h.Update(1)
+	if s.Count() != n || s.Percentile(0.5) != ps[0] {
+		t.Fatalf("snapshot changed after update")
+	}
+
+	// The values leave the window once every stream was reset.
+	now = now.Add(time.Minute)
+	h.Update(7)
+	if p := h.Percentile(0.5); p != 7 {
+		t.Fatalf("expected the old values to leave the window, got %f", p)
+	}
+	if h.Count() != n+2 {
+		t.Fatalf("expected the count to cover every value, got %d", h.Count())
+	}
+	if _, err := newCKMSHistogram(qs, 0, time.Minute); err == nil {
+		t.Fatalf("expected error for epsilon 0")
+	}
+}
+
+func TestCKMSSlidingWindow(t *testing.T) {
+	h, _ := newCKMSHistogram([]float64{0.5}, 0.01, 5*time.Second)
+	now := time.Unix(0, 0)
+	h.now = func() time.Time { return now }
+	h.reset()
+
+	for i := 0; i < 10; i++ {
+		h.Update(100)
+	}
+	// Until the window passed, the head stream still holds the value.
+	for i := 0; i < 4; i++ {
+		now = now.Add(time.Second)
+		h.Update(200)
+		if p := h.Percentile(0.5); p != 100 {
+			t.Fatalf("after %ds: expected the first values to be in the window, got %f", i+1, p)
+		}
+	}
+	now = now.Add(time.Second)
+	if p := h.Percentile(0.5); p != 200 {
+		t.Fatalf("expected the first values to leave the window, got %f", p)
+	}
+}
+
+type ckmsMetrics struct {
+	Latency metrics.Timer     `metric:"latency,sample=ckms,percentiles=50;99,epsilon=0.01,window=1m"`
+	Size    metrics.Histogram `metric:"size,sample=ckms"`
+}
+
+func TestCKMSTag(t *testing.T) {
+	m := &ckmsMetrics{}
+	NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	h, ok := m.Size.(*ckmsHistogram)
+	if !ok {
+		t.Fatalf("size is not a CKMS histogram: %T", m.Size)
+	}
+	if h.window != DefaultCKMSWindow || len(h.targets) != len(DefaultPercentiles) || h.targets[0].epsilon != DefaultCKMSEpsilon {
+		t.Fatalf("unexpected defaults %v %+v", h.window, h.targets)
+	}
+	m.Latency.Update(time.Second)
+	s := m.Latency.Snapshot()
+	if s.Count() != 1 || s.Percentile(0.99) != 1e9 {
+		t.Fatalf("unexpected timer snapshot: %d %f", s.Count(), s.Percentile(0.99))
+	}
+	if err := ValidateField("metrics.Histogram", "size,epsilon=0.01"); err == nil {
+		t.Fatalf("expected an error for epsilon without sample=ckms")
+	}
+	if err := ValidateField("metrics.Histogram", "size,sample=ckms,window=soon"); err == nil {
+		t.Fatalf("expected an error for an invalid window")
+	}
+}
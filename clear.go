@@ -0,0 +1,103 @@
+package tagtrics
+
+import (
+	"strings"
+	"sync"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// clearer is implemented by the metrics cleared by Reset.
+type clearer interface {
+	Clear()
+}
+
+// clearedKinds are the kinds of metrics cleared by Reset.
+var clearedKinds = map[string]bool{
+	"counter":   true,
+	"histogram": true,
+	"meter":     true,
+	"timer":     true,
+}
+
+// Reset clears every counter, histogram, meter and timer initialized by the
+// MetricTags as if they were just created, e.g. in between the cases of
+// table driven integration tests.  Gauges keep their values.
+func (m *MetricTags) Reset() {
+	m.ResetPrefix("")
+}
+
+// ResetPrefix clears the counters, histograms, meters and timers initialized
+// by the MetricTags named prefix or beneath it like Reset does.  Whole name
+// segments are matched like by Snapshot.WithPrefix, so "messages.smtp"
+// doesn't clear the metrics beneath "messages.smtps".  A trailing separator
+// is ignored.
+func (m *MetricTags) ResetPrefix(prefix string) {
+	prefix = strings.TrimSuffix(prefix, m.separator)
+	var names []string
+	m.metaMutex.RLock()
+	for name, meta := range m.meta {
+		if clearedKinds[meta.Type] && meta.beneath(prefix, m.separator) {
+			names = append(names, name)
+		}
+	}
+	m.metaMutex.RUnlock()
+	for _, name := range names {
+		if c, ok := m.registry.Get(name).(clearer); ok {
+			c.Clear()
+		}
+	}
+}
+
+// clearableMeter is a metrics.Meter which can be cleared, which go-metrics
+// meters can't, by replacing the meter it forwards to.
+type clearableMeter struct {
+	mutex sync.RWMutex
+	meter metrics.Meter
+}
+
+// newClearableMeter returns a new clearable meter.
+func newClearableMeter() *clearableMeter {
+	return &clearableMeter{meter: metrics.NewMeter()}
+}
+
+// current returns the meter forwarded to.
+func (c *clearableMeter) current() metrics.Meter {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.meter
+}
+
+// Clear starts over with a new meter.
+func (c *clearableMeter) Clear() {
+	next := metrics.NewMeter()
+	c.mutex.Lock()
+	prev := c.meter
+	c.meter = next
+	c.mutex.Unlock()
+	prev.Stop()
+}
+
+// Count returns the number of events recorded.
+func (c *clearableMeter) Count() int64 { return c.current().Count() }
+
+// Mark records the occurrence of n events.
+func (c *clearableMeter) Mark(n int64) { c.current().Mark(n) }
+
+// Rate1 returns the one-minute moving average rate of events per second.
+func (c *clearableMeter) Rate1() float64 { return c.current().Rate1() }
+
+// Rate5 returns the five-minute moving average rate of events per second.
+func (c *clearableMeter) Rate5() float64 { return c.current().Rate5() }
+
+// Rate15 returns the fifteen-minute moving average rate of events per second.
+func (c *clearableMeter) Rate15() float64 { return c.current().Rate15() }
+
+// RateMean returns the meter's mean rate of events per second.
+func (c *clearableMeter) RateMean() float64 { return c.current().RateMean() }
+
+// Snapshot returns a read-only copy of the meter.
+func (c *clearableMeter) Snapshot() metrics.Meter { return c.current().Snapshot() }
+
+// Stop stops the meter.
+func (c *clearableMeter) Stop() { c.current().Stop() }
@@ -0,0 +1,73 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type clearMetrics struct {
+	HTTP struct {
+		Requests metrics.Counter   `metric:"requests"`
+		Size     metrics.Histogram `metric:"size"`
+		Calls    metrics.Meter     `metric:"calls"`
+		Latency  metrics.Timer     `metric:"latency"`
+	} `metric:"http"`
+	DB struct {
+		Queries metrics.Counter `metric:"queries,reset"`
+		Open    metrics.Gauge   `metric:"open"`
+	} `metric:"db"`
+	HTTPS struct {
+		Requests metrics.Counter `metric:"requests"`
+	} `metric:"https"`
+}
+
+func TestReset(t *testing.T) {
+	m := &clearMetrics{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	record := func() {
+		m.HTTP.Requests.Inc(1)
+		m.HTTP.Size.Update(10)
+		m.HTTP.Calls.Mark(1)
+		m.HTTP.Latency.Update(time.Millisecond)
+		m.DB.Queries.Inc(1)
+		m.DB.Open.Update(3)
+		m.HTTPS.Requests.Inc(1)
+		tags.flush()
+	}
+
+	record()
+	tags.ResetPrefix("http.")
+	if m.HTTP.Requests.Count() != 0 || m.HTTP.Size.Count() != 0 || m.HTTP.Calls.Count() != 0 || m.HTTP.Latency.Count() != 0 {
+		t.Fatalf("http metrics not reset")
+	}
+	if m.DB.Queries.Count() != 1 {
+		t.Fatalf("db metrics reset by ResetPrefix")
+	}
+	if m.HTTPS.Requests.Count() != 1 {
+		t.Fatalf("https metrics reset by the sibling prefix http")
+	}
+	tags.ResetPrefix("http")
+	if m.HTTPS.Requests.Count() != 1 {
+		t.Fatalf("https metrics reset by the sibling prefix http")
+	}
+	m.HTTP.Requests.Inc(1)
+	tags.ResetPrefix("http.requests")
+	if m.HTTP.Requests.Count() != 0 {
+		t.Fatalf("the metric named by the prefix not reset")
+	}
+
+	record()
+	tags.Reset()
+	if m.HTTP.Requests.Count() != 0 || m.HTTP.Latency.Count() != 0 || m.DB.Queries.Count() != 0 {
+		t.Fatalf("metrics not reset")
+	}
+	if m.DB.Open.Value() != 3 {
+		t.Fatalf("gauge reset to %d", m.DB.Open.Value())
+	}
+	m.HTTP.Calls.Mark(2)
+	if m.HTTP.Calls.Count() != 2 {
+		t.Fatalf("meter counted %d after reset", m.HTTP.Calls.Count())
+	}
+}
@@ -0,0 +1,378 @@
+// Command tagtricsgen generates reflection free initializers for tagtrics
+// metric structs.  For every type named with -type it writes a TagtricsInit
+// method, which NewMetricTags calls instead of traversing the struct with
+// reflection, and a TagtricsVisit method calling a function with every
+// metric field and its name.
+//
+// It is meant to run with go:generate next to the metric structs:
+//
+//	//go:generate tagtricsgen -type=appMetrics
+//
+// The generated code must be regenerated whenever the structs change.
+//
+// Usage:
+//
+//	tagtricsgen -type T[,T...] [-output file] [dir]
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sendgrid/tagtrics"
+	"github.com/sendgrid/tagtrics/internal/source"
+)
+
+func main() {
+	types := flag.String("type", "", "comma separated list of metric struct type names")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_tagtrics.go")
+	flag.Parse()
+	if *types == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	p, err := source.ParseDir(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tagtricsgen:", err)
+		os.Exit(2)
+	}
+	names := strings.Split(*types, ",")
+	src, err := generate(p, names, strings.Join(os.Args[1:], " "))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tagtricsgen:", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(names[0])+"_tagtrics.go")
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "tagtricsgen:", err)
+		os.Exit(1)
+	}
+}
+
+// generator writes the initializers of the struct types of a package.
+type generator struct {
+	*source.Package
+	buf *bytes.Buffer
+	// queue holds the named struct types used by the ones generated so far
+	// and done the ones already generated.
+	queue []string
+	done  map[string]bool
+	// usesMetrics is set when the generated code refers to go-metrics.
+	usesMetrics bool
+	// blocks counts the nested blocks to name their variables uniquely.
+	blocks int
+}
+
+// generate returns the formatted source of the initializers of the given
+// root types.
+func generate(p *source.Package, roots []string, args string) ([]byte, error) {
+	g := &generator{Package: p, buf: new(bytes.Buffer), done: make(map[string]bool)}
+	for _, root := range roots {
+		if _, ok := p.Structs[root]; !ok {
+			return nil, fmt.Errorf("struct type %s not found", root)
+		}
+		g.printf("// TagtricsInit initializes the metrics of m without reflection.\n")
+		g.printf("func (m *%s) TagtricsInit(b *tagtrics.Binder) {\n", root)
+		g.printf("%s(b, m, \"\", true)\n}\n\n", initFunc(root))
+		g.printf("// TagtricsVisit calls f with every metric field of m and its name\n")
+		g.printf("// built with sep.\n")
+		g.printf("func (m *%s) TagtricsVisit(sep string, f func(name string, metric interface{})) {\n", root)
+		g.printf("%s(m, \"\", sep, f)\n}\n\n", visitFunc(root))
+		g.queue = append(g.queue, root)
+	}
+	for len(g.queue) > 0 {
+		name := g.queue[0]
+		g.queue = g.queue[1:]
+		if g.done[name] {
+			continue
+		}
+		g.done[name] = true
+		g.structFuncs(name)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by \"tagtricsgen %s\"; DO NOT EDIT.\n\n", args)
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", p.Name)
+	if g.usesMetrics {
+		fmt.Fprintf(&out, "metrics %q\n", source.MetricsPath)
+	}
+	fmt.Fprintf(&out, "%q\n)\n\n", source.TagtricsPath)
+	out.Write(g.buf.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %v", err)
+	}
+	return src, nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.buf, format, args...)
+}
+
+// initFunc and visitFunc return the names of the generated functions of a
+// struct type.
+func initFunc(typeName string) string  { return "tagtricsInit" + exported(typeName) }
+func visitFunc(typeName string) string { return "tagtricsVisit" + exported(typeName) }
+
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// structFuncs writes the init and visit functions of the named struct type.
+func (g *generator) structFuncs(typeName string) {
+	f, st := g.StructFiles[typeName], g.Structs[typeName]
+	g.blocks = 0
+	g.printf("func %s(b *tagtrics.Binder, m *%s, prefix string, enabled bool) {\n", initFunc(typeName), typeName)
+	g.fields(f, st, "m", "prefix", "enabled", true)
+	g.printf("}\n\n")
+	g.blocks = 0
+	g.printf("func %s(m *%s, prefix, sep string, f func(name string, metric interface{})) {\n", visitFunc(typeName), typeName)
+	g.fields(f, st, "m", "prefix", "", false)
+	g.printf("}\n\n")
+}
+
+// fields writes the statements initializing, or visiting if init is false,
+// the fields of st reached through the expression access.  prefix and
+// enabled are the names of the variables holding the metric name prefix and
+// whether the fields are enabled.
+func (g *generator) fields(f *ast.File, st *ast.StructType, access, prefix, enabled string, init bool) {
+	for _, field := range st.Fields.List {
+		tag, tagged := source.MetricTag(field)
+		help, _ := source.Tag(field, "help")
+		unit, _ := source.Tag(field, "unit")
+		for _, fieldName := range source.FieldNames(field) {
+			if !ast.IsExported(fieldName) {
+				continue
+			}
+			name := g.nameExpr(prefix, fieldName, tag, init)
+			fieldEnabled := enabled
+			if init && strings.Contains(tag, "optional=") {
+				fieldEnabled = fmt.Sprintf("%s && b.Enabled(%q)", enabled, tag)
+			}
+			path := access + "." + fieldName
+			// A separator option changes the binder, or the sep variable
+			// when visiting, used beneath the field.
+			binder, sep := "b", "sep"
+			override, _ := source.TagOption(tag, "separator")
+			if override != "" {
+				binder, sep = fmt.Sprintf("b.WithSeparator(%q)", override), fmt.Sprintf("%q", override)
+			}
+			// The "sink" struct tag routes the metrics beneath the field.
+			if sink, _ := source.Tag(field, "sink"); sink != "" {
+				binder = fmt.Sprintf("%s.WithSink(%q)", binder, sink)
+			}
+			switch t := field.Type.(type) {
+			case *ast.StructType:
+				g.block(f, t, path, name, fieldEnabled, binder, override, init)
+				continue
+			case *ast.Ident:
+				if g.Initializers[t.Name] {
+					// Initializers register metrics the visitor doesn't
+					// know about.
+					if init {
+						g.printf("%s.Init(%s, &%s, %s)\n", binder, fieldEnabled, path, name)
+					}
+					continue
+				}
+				if _, ok := g.Structs[t.Name]; ok {
+					g.queue = append(g.queue, t.Name)
+					if init {
+						g.printf("%s(%s, &%s, %s, %s)\n", initFunc(t.Name), binder, path, name, fieldEnabled)
+					} else {
+						g.printf("%s(&%s, %s, %s, f)\n", visitFunc(t.Name), path, name, sep)
+					}
+					continue
+				}
+			case *ast.ArrayType:
+				if t.Len != nil {
+					g.array(f, t, path, name, tag, help, unit, fieldEnabled, binder, sep, init, tagged)
+					continue
+				}
+				if v, ok := g.SliceElemStruct(t); ok {
+					g.slice(path, name, tag, v, fieldEnabled, binder, sep, init)
+					continue
+				}
+			case *ast.MapType:
+				if v, ok := g.MapValueStruct(t); ok {
+					if g.Initializers[v] && !init {
+						continue
+					}
+					key := source.KeyName(t, "k")
+					// The "label" tag option exports the keys as a label.
+					if label, _ := source.TagOption(tag, "label"); label != "" {
+						binder = fmt.Sprintf("%s.WithLabel(%q)", binder, label)
+					}
+					g.printf("for k, v := range %s {\nif v != nil {\n", path)
+					switch {
+					case g.Initializers[v]:
+						g.printf("bk, pk := %s.Bucket(%s, %s, v)\n", binder, name, key)
+						g.printf("bk.Init(%s, v, pk)\n", fieldEnabled)
+					case init:
+						g.queue = append(g.queue, v)
+						g.printf("bk, pk := %s.Bucket(%s, %s, v)\n", binder, name, key)
+						g.printf("%s(bk, v, pk, %s)\n", initFunc(v), fieldEnabled)
+					default:
+						g.queue = append(g.queue, v)
+						g.printf("%s(v, tagtrics.JoinName(%s, %s, tagtrics.BucketKey(v, %s)), %s, f)\n", visitFunc(v), name, sep, key, sep)
+					}
+					g.printf("}\n}\n")
+				}
+				continue
+			}
+			typeName := source.TypeString(f, field.Type)
+			if !tagged && (typeName == "time.Time" || typeName == "time.Duration") {
+				// Like the reflection traversal, only tagged times are
+				// gauges.
+				continue
+			}
+			if tagtrics.ValidateField(typeName, "") != nil {
+				if tagged {
+					g.printf("// %s: unsupported metric type %s\n", path, typeName)
+				}
+				continue
+			}
+			g.metric(path, name, typeName, tag, help, unit, fieldEnabled, init)
+		}
+	}
+}
+
+// array writes the statements initializing, or visiting, the elements of an
+// array field named after tagtrics.ElementName.  binder and sep are the
+// Binder and separator in effect beneath the field.
+func (g *generator) array(f *ast.File, t *ast.ArrayType, path, name, tag, help, unit, enabled, binder, sep string, init, tagged bool) {
+	elemName := fmt.Sprintf("%s.Name(%s, tagtrics.ElementName(%q, i, len(%s)))", binder, name, tag, path)
+	if !init {
+		elemName = fmt.Sprintf("tagtrics.JoinName(%s, %s, tagtrics.ElementName(%q, i, len(%s)))", name, sep, tag, path)
+	}
+	elem := path + "[i]"
+	id, isIdent := t.Elt.(*ast.Ident)
+	switch {
+	case isIdent && g.Initializers[id.Name]:
+		if init {
+			g.printf("for i := range %s {\n%s.Init(%s, &%s, %s)\n}\n", path, binder, enabled, elem, elemName)
+		}
+	case isIdent && g.Structs[id.Name] != nil:
+		g.queue = append(g.queue, id.Name)
+		if init {
+			g.printf("for i := range %s {\n%s(%s, &%s, %s, %s)\n}\n", path, initFunc(id.Name), binder, elem, elemName, enabled)
+		} else {
+			g.printf("for i := range %s {\n%s(&%s, %s, %s, f)\n}\n", path, visitFunc(id.Name), elem, elemName, sep)
+		}
+	default:
+		typeName := source.TypeString(f, t.Elt)
+		if tagtrics.ValidateField(typeName, "") != nil {
+			if tagged {
+				g.printf("// %s: unsupported metric type %s\n", path, typeName)
+			}
+			return
+		}
+		g.printf("for i := range %s {\n", path)
+		g.metric(elem, elemName, typeName, tag, help, unit, enabled, init)
+		g.printf("}\n")
+	}
+}
+
+// slice writes the statements initializing, or visiting, the non-nil
+// elements of a []*T field whose element type is typeName.  Initializers
+// skip the elements initialized before so they can be called again for the
+// elements appended since.
+func (g *generator) slice(path, name, tag, typeName, enabled, binder, sep string, init bool) {
+	if g.Initializers[typeName] && !init {
+		return
+	}
+	elemName := fmt.Sprintf("%s.Name(%s, tagtrics.ElementName(%q, i, len(%s)))", binder, name, tag, path)
+	if !init {
+		elemName = fmt.Sprintf("tagtrics.JoinName(%s, %s, tagtrics.ElementName(%q, i, len(%s)))", name, sep, tag, path)
+	}
+	cond := "v != nil"
+	if init {
+		cond = fmt.Sprintf("v != nil && %s.NewElement(v)", binder)
+	}
+	g.printf("for i, v := range %s {\nif %s {\n", path, cond)
+	switch {
+	case g.Initializers[typeName]:
+		g.printf("%s.Init(%s, v, %s)\n", binder, enabled, elemName)
+	case init:
+		g.queue = append(g.queue, typeName)
+		g.printf("%s(%s, v, %s, %s)\n", initFunc(typeName), binder, elemName, enabled)
+	default:
+		g.queue = append(g.queue, typeName)
+		g.printf("%s(v, %s, %s, f)\n", visitFunc(typeName), elemName, sep)
+	}
+	g.printf("}\n}\n")
+}
+
+// metric writes the statement initializing, or visiting, the metric field
+// at path of the given type named with the expression name.
+func (g *generator) metric(path, name, typeName, tag, help, unit, enabled string, init bool) {
+	// Generic metrics and the gauges of funcs and times are initialized
+	// through a pointer to the field.
+	generic := strings.Contains(typeName, "[") || strings.HasPrefix(typeName, "func(") || typeName == "time.Time"
+	switch {
+	case !init && generic:
+		g.printf("f(%s, &%s)\n", name, path)
+	case !init:
+		g.printf("f(%s, %s)\n", name, path)
+	case generic:
+		g.printf("b.Typed(%s, &%s, %s, %q, %q, %q)\n", enabled, path, name, tag, help, unit)
+	default:
+		if strings.HasPrefix(typeName, "metrics.") {
+			g.usesMetrics = true
+		}
+		g.printf("%s = b.Metric(%s, %s, %q, %q, %q, %q).(%s)\n", path, enabled, name, typeName, tag, help, unit, typeName)
+	}
+}
+
+// block writes the statements of an anonymous struct field in a block
+// declaring its own prefix and enabled variables.  binder is the Binder in
+// effect in the block and a non-empty separator overrides the separator in
+// the block.
+func (g *generator) block(f *ast.File, st *ast.StructType, access, name, enabled, binder, separator string, init bool) {
+	g.blocks++
+	p, e := fmt.Sprintf("prefix%d", g.blocks), fmt.Sprintf("enabled%d", g.blocks)
+	outer := g.buf
+	g.buf = new(bytes.Buffer)
+	g.fields(f, st, access, p, e, init)
+	body := g.buf.String()
+	g.buf = outer
+	if strings.TrimSpace(body) == "" {
+		return
+	}
+	g.printf("{\n%s := %s\n", p, name)
+	if strings.Contains(body, e) {
+		g.printf("%s := %s\n", e, enabled)
+	}
+	switch {
+	case binder != "b" && init:
+		g.printf("b := %s\n", binder)
+	case separator != "" && !init:
+		g.printf("sep := %q\n", separator)
+	}
+	g.printf("%s}\n", body)
+}
+
+// nameExpr returns the expression building the metric name of a field.
+// Initializers derive the names of fields without a name in their tag at
+// run time to follow the NameCase of the MetricTags while visitors use the
+// default lower case.
+func (g *generator) nameExpr(prefix, fieldName, tag string, init bool) string {
+	switch {
+	case init && source.TagName(tag) == "":
+		return fmt.Sprintf("b.Derived(%s, %q)", prefix, fieldName)
+	case init:
+		return fmt.Sprintf("b.Name(%s, %q)", prefix, source.TagName(tag))
+	}
+	return fmt.Sprintf("tagtrics.JoinName(%s, sep, %q)", prefix, source.MetricName(fieldName, tag, tagtrics.LowerCase))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/sendgrid/tagtrics/internal/source"
+)
+
+// TestGenerate checks the generated code of internal/gentest is up to date.
+func TestGenerate(t *testing.T) {
+	p, err := source.ParseDir("../../internal/gentest")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	src, err := generate(p, []string{"AppMetrics"}, "-type=AppMetrics")
+	if err != nil {
+		t.Fatalf("failed to generate: %v", err)
+	}
+	want, err := os.ReadFile("../../internal/gentest/appmetrics_tagtrics.go")
+	if err != nil {
+		t.Fatalf("failed to read generated code: %v", err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Fatalf("generated code is out of date, run go generate in internal/gentest:\n%s", src)
+	}
+	if _, err := generate(p, []string{"Missing"}, ""); err == nil {
+		t.Fatalf("expected error for a missing type")
+	}
+}
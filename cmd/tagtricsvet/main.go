@@ -0,0 +1,235 @@
+// Command tagtricsvet checks the metric structs of a Go package before they
+// are used by tagtrics.  It reports fields with a "metric" tag of a type
+// tagtrics does not support, invalid tag options, names containing the
+// separator and metrics which end up with the same name.  It exits with status 1 if any problem is found.
+//
+// It is meant to run in CI or with go:generate next to the metric structs:
+//
+//	//go:generate tagtricsvet
+//
+// Usage:
+//
+//	tagtricsvet [-separator .] [-case lower|preserve|snake] [dir]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sendgrid/tagtrics"
+	"github.com/sendgrid/tagtrics/internal/source"
+)
+
+func main() {
+	separator := flag.String("separator", ".", "separator passed to NewMetricTags")
+	nameCase := flag.String("case", "lower", "case of derived names passed to WithDerivedNameCase: lower, preserve or snake")
+	flag.Parse()
+	c, ok := nameCases[*nameCase]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "tagtricsvet: unknown case %q\n", *nameCase)
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	p, err := source.ParseDir(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tagtricsvet:", err)
+		os.Exit(2)
+	}
+	problems := check(p, *separator, c)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// nameCases maps the values of the -case flag to name cases.
+var nameCases = map[string]tagtrics.NameCase{
+	"lower":    tagtrics.LowerCase,
+	"preserve": tagtrics.PreserveCase,
+	"snake":    tagtrics.SnakeCase,
+}
+
+// checker walks metric structs the same way tagtrics does.
+type checker struct {
+	*source.Package
+	nameCase tagtrics.NameCase
+	// problems holds the reported problems keyed by message to report each
+	// once even if a struct is used in several places.
+	problems map[string]bool
+}
+
+// check returns the problems found in the struct types of p.  Every struct
+// type with a "metric" tag is checked as if passed to NewMetricTags with the
+// given separator and name case.
+func check(p *source.Package, separator string, nameCase tagtrics.NameCase) []string {
+	c := &checker{Package: p, nameCase: nameCase, problems: make(map[string]bool)}
+	var roots []string
+	for name, st := range p.Structs {
+		if source.HasMetricTag(st) {
+			roots = append(roots, name)
+		}
+	}
+	sort.Strings(roots)
+	for _, root := range roots {
+		names := make(map[string]token.Pos)
+		c.walk(p.StructFiles[root], p.Structs[root], "", separator, names, map[string]bool{root: true})
+	}
+	problems := make([]string, 0, len(c.problems))
+	for problem := range c.problems {
+		problems = append(problems, problem)
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// report records a problem at pos.
+func (c *checker) report(pos token.Pos, format string, args ...interface{}) {
+	c.problems[fmt.Sprintf("%s: %s", c.Fset.Position(pos), fmt.Sprintf(format, args...))] = true
+}
+
+// walk checks the fields of st whose metrics are prefixed with prefix joined
+// with sep.  names holds the metric names seen so far in the root struct and
+// seen the struct types being walked to stop on recursive types.
+func (c *checker) walk(f *ast.File, st *ast.StructType, prefix, sep string, names map[string]token.Pos, seen map[string]bool) {
+	for _, field := range st.Fields.List {
+		tag, tagged := source.MetricTag(field)
+		for _, fieldName := range source.FieldNames(field) {
+			segment := source.MetricName(fieldName, tag, c.nameCase)
+			name := tagtrics.JoinName(prefix, sep, segment)
+			if strings.Contains(segment, sep) {
+				c.report(field.Pos(), "%s: metric name %q contains the separator %q", name, segment, sep)
+				continue
+			}
+			c.field(f, field, fieldName, tag, tagged, name, sep, names, seen)
+		}
+	}
+}
+
+// field checks a single struct field named name in a struct using sep.
+func (c *checker) field(f *ast.File, field *ast.Field, fieldName, tag string, tagged bool, name, sep string, names map[string]token.Pos, seen map[string]bool) {
+	if s, ok := source.TagOption(tag, "separator"); ok {
+		if s == "" {
+			c.report(field.Pos(), "%s: option separator needs a separator", name)
+		}
+		sep = s
+	}
+	switch t := field.Type.(type) {
+	case *ast.StructType:
+		c.label(field, tag, name, false)
+		c.walk(f, t, name, sep, names, seen)
+		return
+	case *ast.Ident:
+		if c.Initializers[t.Name] {
+			c.label(field, tag, name, false)
+			return
+		}
+		if st, ok := c.Structs[t.Name]; ok {
+			c.label(field, tag, name, false)
+			c.nested(field, t.Name, st, name, sep, names, seen)
+			return
+		}
+	case *ast.ArrayType:
+		if t.Len != nil {
+			c.array(f, field, t, fieldName, tag, tagged, name, sep, names, seen)
+			return
+		}
+		if v, ok := c.SliceElemStruct(t); ok {
+			c.label(field, tag, name, false)
+			c.nested(field, v, c.Structs[v], name+sep+"{index}", sep, names, seen)
+			return
+		}
+	case *ast.MapType:
+		if v, ok := c.MapValueStruct(t); ok {
+			c.label(field, tag, name, true)
+			c.nested(field, v, c.Structs[v], name+sep+"{key}", sep, names, seen)
+		} else if tagged {
+			c.report(field.Pos(), "%s: unsupported metric map %s, only map[K]*T with K string or a fmt.Stringer is", name, source.TypeString(f, t))
+		}
+		return
+	}
+	c.leaf(f, field, field.Type, fieldName, tag, tagged, name, sep, names)
+}
+
+// label checks the "label" option of the tag of a field named name which
+// isn't a metric, isMap reporting whether it is a map whose keys the option
+// exports.  The options of metrics are checked by tagtrics.ValidateField.
+func (c *checker) label(field *ast.Field, tag, name string, isMap bool) {
+	label, ok := source.TagOption(tag, "label")
+	switch {
+	case !ok:
+	case !isMap:
+		c.report(field.Pos(), "%s: option label is only supported by maps", name)
+	case label == "":
+		c.report(field.Pos(), "%s: option label needs a label name", name)
+	}
+}
+
+// leaf checks a metric of the given type named name, a field or an array
+// element.
+func (c *checker) leaf(f *ast.File, field *ast.Field, typ ast.Expr, fieldName, tag string, tagged bool, name, sep string, names map[string]token.Pos) {
+	typeName := source.TypeString(f, typ)
+	if !tagged && tagtrics.ValidateField(typeName, "") != nil {
+		// Untagged fields of other types are used for configuration.
+		return
+	}
+	if err := tagtrics.ValidateField(typeName, tag); err != nil {
+		c.report(field.Pos(), "%s: %v", name, err)
+		return
+	}
+	if !ast.IsExported(fieldName) {
+		c.report(field.Pos(), "%s: unexported field %s cannot be initialized", name, fieldName)
+	}
+	c.name(field.Pos(), name, names)
+	if _, ok := source.TagOption(tag, "rate"); ok {
+		c.name(field.Pos(), name+sep+"rate", names)
+	}
+}
+
+// array checks the elements of an array field named name, whose names are
+// represented by an "{index}" segment.
+func (c *checker) array(f *ast.File, field *ast.Field, t *ast.ArrayType, fieldName, tag string, tagged bool, name, sep string, names map[string]token.Pos, seen map[string]bool) {
+	elem := name + sep + "{index}"
+	if id, ok := t.Elt.(*ast.Ident); ok {
+		if st, ok := c.Structs[id.Name]; ok {
+			c.label(field, tag, name, false)
+			c.nested(field, id.Name, st, elem, sep, names, seen)
+			return
+		}
+	}
+	c.leaf(f, field, t.Elt, fieldName, tag, tagged, elem, sep, names)
+}
+
+// nested walks the named struct type typeName used by field.  Types
+// implementing tagtrics.Initializer register their metrics themselves and
+// aren't walked.
+func (c *checker) nested(field *ast.Field, typeName string, st *ast.StructType, prefix, sep string, names map[string]token.Pos, seen map[string]bool) {
+	if c.Initializers[typeName] {
+		return
+	}
+	if seen[typeName] {
+		c.report(field.Pos(), "%s: recursive metric struct %s", prefix, typeName)
+		return
+	}
+	seen[typeName] = true
+	c.walk(c.StructFiles[typeName], st, prefix, sep, names, seen)
+	delete(seen, typeName)
+}
+
+// name records a metric name and reports it if it is already used.
+func (c *checker) name(pos token.Pos, name string, names map[string]token.Pos) {
+	if prev, ok := names[name]; ok {
+		c.report(pos, "duplicate metric name %s, also used at %s", name, c.Fset.Position(prev))
+		return
+	}
+	names[name] = pos
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/sendgrid/tagtrics"
+	"github.com/sendgrid/tagtrics/internal/source"
+)
+
+const src = `package app
+
+import (
+	"github.com/rcrowley/go-metrics"
+	tt "github.com/sendgrid/tagtrics"
+)
+
+type service struct {
+	Count metrics.Counter
+}
+
+type region int
+
+func (r region) String() string { return "us-east" }
+
+type appMetrics struct {
+	HTTP struct {
+		Latency metrics.Timer   ` + "`metric:\"latency,percentiles=50;99\"`" + `
+		Count   metrics.Counter ` + "`metric:\"latency\"`" + `
+	} ` + "`metric:\"http\"`" + `
+	Depth    tt.Gauge[int64]  ` + "`metric:\"depth\"`" + `
+	Bad      metrics.Meter    ` + "`metric:\"bad,percentiles=99\"`" + `
+	Name     string           ` + "`metric:\"name\"`" + `
+	hidden   metrics.Counter  ` + "`metric:\"hidden\"`" + `
+	Timeout  int
+	Services map[string]*service ` + "`metric:\"services,label=service\"`" + `
+	Tenants  map[string]*service ` + "`metric:\"tenants,label=\"`" + `
+	Pools    tt.LazyMap[service] ` + "`metric:\"pools,label=pool\"`" + `
+	Regions  map[region]*service ` + "`metric:\"regions\"`" + `
+	Shards   map[int]*service    ` + "`metric:\"shards\"`" + `
+	Legacy   struct {
+		Hits metrics.Counter ` + "`metric:\"hits\"`" + `
+	} ` + "`metric:\"legacy,separator=_\"`" + `
+	Cache struct {
+		Hits metrics.Counter ` + "`metric:\"hits\"`" + `
+	} ` + "`metric:\"cache,label=cache\"`" + `
+	LegacyHits metrics.Counter ` + "`metric:\"legacy_hits\"`" + `
+	Slow       metrics.Timer   ` + "`metric:\"slow.p99\"`" + `
+	Sent       metrics.Counter ` + "`metric:\"sent, rate\"`" + `
+	SentStats  struct {
+		Rate metrics.Gauge ` + "`metric:\"rate\"`" + `
+	} ` + "`metric:\"sent\"`" + `
+}
+`
+
+func TestCheck(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "app.go", src, 0)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	problems := check(source.NewPackage(fset, []*ast.File{f}), ".", tagtrics.LowerCase)
+	want := []string{
+		"duplicate metric name http.latency",
+		"bad: option percentiles is not supported by metrics.Meter",
+		"name: unsupported metric type string",
+		"hidden: unexported field hidden",
+		"duplicate metric name legacy_hits",
+		"shards: unsupported metric map map[int]*service",
+		"tenants: option label needs a label name",
+		"cache: option label is only supported by maps",
+		`slow.p99: metric name "slow.p99" contains the separator "."`,
+		"duplicate metric name sent.rate",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %q", len(want), problems)
+	}
+	for _, w := range want {
+		found := false
+		for _, p := range problems {
+			found = found || strings.Contains(p, w)
+		}
+		if !found {
+			t.Errorf("missing problem %q in %q", w, problems)
+		}
+	}
+}
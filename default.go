@@ -0,0 +1,41 @@
+package tagtrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// DefaultFlushInterval is the flush interval of the Default MetricTags.
+const DefaultFlushInterval = time.Minute
+
+var (
+	defaultOnce sync.Once
+	defaultTags *MetricTags
+)
+
+// Default returns the package level MetricTags for small services which
+// don't want to pass a *MetricTags around.  It is created on first use with
+// metrics.DefaultRegistry, "." as separator and no update handler.  Add
+// sinks to it and start its Run worker to export metrics periodically.
+func Default() *MetricTags {
+	defaultOnce.Do(func() {
+		defaultTags = NewMetricTags(&struct{}{}, func() {}, DefaultFlushInterval, metrics.DefaultRegistry, ".")
+	})
+	return defaultTags
+}
+
+// Register initializes the metric fields of the struct structPtr points to
+// in the Default MetricTags.  It is meant to be called while the program is
+// initializing, e.g. from a package level var or init func, before the
+// Default Run worker is started.
+func Register(structPtr interface{}) error {
+	return Default().Register("", structPtr)
+}
+
+// Handler returns the HTTP handler of the Default MetricTags.
+func Handler() http.Handler {
+	return Default().Handler()
+}
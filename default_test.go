@@ -0,0 +1,40 @@
+package tagtrics
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type defaultMetrics struct {
+	Jobs struct {
+		Done metrics.Counter `metric:"done"`
+	} `metric:"jobs"`
+}
+
+func TestDefault(t *testing.T) {
+	if Default() != Default() {
+		t.Fatalf("Default returned different instances")
+	}
+	m := &defaultMetrics{}
+	if err := Register(m); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	if err := Register(defaultMetrics{}); err == nil {
+		t.Fatalf("expected error registering a struct value")
+	}
+	defer Default().Unregister()
+	m.Jobs.Done.Inc(2)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	var data map[string]map[string]float64
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body, err)
+	}
+	if data["jobs.done"]["count"] != 2 || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %v", data)
+	}
+}
@@ -0,0 +1,221 @@
+package tagtrics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Delivery configures how snapshots are delivered to the sinks when set
+// with WithDelivery.
+type Delivery struct {
+	// Retries is how many times a failed Send is retried before the
+	// snapshot is dropped.
+	Retries int
+	// MinBackoff is the wait before the first retry, doubled for every
+	// following retry up to MaxBackoff.
+	MinBackoff, MaxBackoff time.Duration
+	// QueueDepth is how many snapshots are queued for a sink still sending
+	// an older one.  The oldest snapshot is dropped when the queue is
+	// full.  It is at least 1.
+	QueueDepth int
+}
+
+// DefaultDelivery is a Delivery riding out outages of a few seconds.
+var DefaultDelivery = Delivery{
+	Retries:    3,
+	MinBackoff: 100 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+	QueueDepth: 10,
+}
+
+// WithDelivery sends the snapshots to every sink from a queue of its own in
+// the background, retrying failed sends with an exponential backoff as
+// configured by d, so a sink backend going down neither blocks the flushes
+// nor loses the snapshots of a transient outage.  The retries and the
+// snapshots dropped are counted in the "tagtrics.sink.retries" and
+// "tagtrics.sink.dropped" self metrics.  Stop waits for the queues to be
+// delivered.
+func WithDelivery(d Delivery) Option {
+	return func(m *MetricTags) {
+		if d.QueueDepth < 1 {
+			d.QueueDepth = 1
+		}
+		if d.MaxBackoff < d.MinBackoff {
+			d.MaxBackoff = d.MinBackoff
+		}
+		m.delivery = &d
+	}
+}
+
+// deliverer delivers the snapshots queued for a sink.
+type deliverer struct {
+	m    *MetricTags
+	sink Sink
+	name string
+	d    Delivery
+	// mutex guards queue and done, which are replaced when the deliverer
+	// is stopped so it can be started again, and last.
+	mutex sync.Mutex
+	queue chan queuedSnapshot
+	// done is closed once the snapshots queued are delivered after stop,
+	// nil while the deliverer isn't running.
+	done chan struct{}
+	// last is the settled channel of the last snapshot queued, if any.
+	last chan struct{}
+}
+
+// queuedSnapshot is a snapshot waiting for a deliverer.  settled is closed
+// once it is delivered or dropped.
+type queuedSnapshot struct {
+	s       *Snapshot
+	settled chan struct{}
+}
+
+// newDeliverer starts delivering the snapshots queued for the sink named
+// name.
+func (m *MetricTags) newDeliverer(sink Sink, name string) *deliverer {
+	d := &deliverer{
+		m:     m,
+		sink:  sink,
+		name:  name,
+		d:     *m.delivery,
+		queue: make(chan queuedSnapshot, m.delivery.QueueDepth),
+	}
+	d.start()
+	return d
+}
+
+// start delivers the snapshots queued, if it isn't already.
+func (d *deliverer) start() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.done != nil {
+		return
+	}
+	d.done = make(chan struct{})
+	go d.run(d.queue, d.done)
+}
+
+// stop waits for the snapshots queued to be delivered.  The snapshots
+// queued until it is started again wait in a new queue.
+func (d *deliverer) stop() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.done == nil {
+		return
+	}
+	close(d.queue)
+	<-d.done
+	d.queue = make(chan queuedSnapshot, d.d.QueueDepth)
+	d.done = nil
+}
+
+// wait waits for the snapshots queued so far to be delivered or dropped, or
+// for ctx to be done.  It returns right away if the deliverer isn't
+// running.
+func (d *deliverer) wait(ctx context.Context) error {
+	d.mutex.Lock()
+	last := d.last
+	if d.done == nil {
+		last = nil
+	}
+	d.mutex.Unlock()
+	if last == nil {
+		return nil
+	}
+	select {
+	case <-last:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue queues s, dropping the oldest snapshots queued if the queue is
+// full.  It never blocks.
+func (d *deliverer) enqueue(s *Snapshot) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	q := queuedSnapshot{s: s, settled: make(chan struct{})}
+	for {
+		select {
+		case d.queue <- q:
+			d.last = q.settled
+			return
+		default:
+		}
+		select {
+		case old := <-d.queue:
+			close(old.settled)
+			d.m.self.Sink.Dropped.Inc(1)
+			d.m.warn("sink queue full, dropped snapshot", "sink", d.name)
+		default:
+		}
+	}
+}
+
+// run delivers the snapshots of queue until it is closed, then closes done.
+func (d *deliverer) run(queue chan queuedSnapshot, done chan struct{}) {
+	defer close(done)
+	for q := range queue {
+		d.deliver(q.s)
+		close(q.settled)
+	}
+}
+
+// deliver sends s, retrying with an exponential backoff.
+func (d *deliverer) deliver(s *Snapshot) {
+	backoff := d.d.MinBackoff
+	for attempt := 0; ; attempt++ {
+		err := d.sink.Send(s)
+		if err == nil {
+			return
+		}
+		if attempt >= d.d.Retries {
+			d.m.self.Sink.Errors.Inc(1)
+			d.m.self.Sink.Dropped.Inc(1)
+			d.m.warn("sink failed", "sink", d.name, "attempts", attempt+1, "err", err)
+			d.m.reportError(&SinkError{Sink: d.name, Err: err})
+			return
+		}
+		d.m.self.Sink.Retries.Inc(1)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > d.d.MaxBackoff {
+			backoff = d.d.MaxBackoff
+		}
+	}
+}
+
+// stopDelivery waits for the snapshots queued for every sink to be
+// delivered.
+func (m *MetricTags) stopDelivery() {
+	for _, sink := range m.sinks {
+		if sink.deliverer != nil {
+			sink.deliverer.stop()
+		}
+	}
+}
+
+// waitDelivery waits for the snapshots queued so far for every sink to be
+// delivered or dropped, or for ctx to be done.
+func (m *MetricTags) waitDelivery(ctx context.Context) error {
+	for _, sink := range m.sinks {
+		if sink.deliverer != nil {
+			if err := sink.deliverer.wait(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// startDelivery delivers the snapshots queued for every sink again after
+// stopDelivery.
+func (m *MetricTags) startDelivery() {
+	for _, sink := range m.sinks {
+		if sink.deliverer != nil {
+			sink.deliverer.start()
+		}
+	}
+}
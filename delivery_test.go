@@ -0,0 +1,109 @@
+package tagtrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestDeliveryRetries(t *testing.T) {
+	r := metrics.NewRegistry()
+	d := Delivery{Retries: 2, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, QueueDepth: 10}
+	mTags := NewMetricTags(&metaMetrics{}, func() {}, time.Second, r, ".", WithDelivery(d), WithLogger(&recordingLogger{}))
+	failures := 2
+	var delivered int
+	mTags.AddSink(SinkFunc(func(*Snapshot) error {
+		if failures > 0 {
+			failures--
+			return errors.New("backend down")
+		}
+		delivered++
+		return nil
+	}))
+	mTags.AddNamedSink("debug", SinkFunc(func(*Snapshot) error {
+		return errors.New("backend down")
+	}))
+
+	mTags.flush()
+	mTags.flush()
+	mTags.stopDelivery()
+
+	if delivered != 2 {
+		t.Fatalf("expected 2 snapshots delivered, got %d", delivered)
+	}
+	if c := r.Get("tagtrics.sink.retries").(metrics.Counter).Count(); c != 2+2*2 {
+		t.Fatalf("expected 6 retries, got %d", c)
+	}
+	if c := r.Get("tagtrics.sink.errors").(metrics.Counter).Count(); c != 2 {
+		t.Fatalf("expected 2 sink errors, got %d", c)
+	}
+	if c := r.Get("tagtrics.sink.dropped").(metrics.Counter).Count(); c != 2 {
+		t.Fatalf("expected 2 dropped snapshots, got %d", c)
+	}
+}
+
+func TestDeliveryQueue(t *testing.T) {
+	r := metrics.NewRegistry()
+	d := Delivery{QueueDepth: 1}
+	mTags := NewMetricTags(&metaMetrics{}, func() {}, time.Second, r, ".", WithDelivery(d), WithLogger(&recordingLogger{}))
+	sending := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var sent []*Snapshot
+	mTags.AddSink(SinkFunc(func(s *Snapshot) error {
+		sending <- struct{}{}
+		<-release
+		sent = append(sent, s)
+		return nil
+	}))
+
+	mTags.flush()
+	<-sending
+	mTags.flush()
+	mTags.flush()
+	close(release)
+	mTags.stopDelivery()
+
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 snapshots delivered, got %d", len(sent))
+	}
+	if !sent[1].Time.After(sent[0].Time) {
+		t.Fatalf("expected the oldest queued snapshot to be dropped")
+	}
+	if c := r.Get("tagtrics.sink.dropped").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 dropped snapshot, got %d", c)
+	}
+}
+
+func TestFlushWaitsForDelivery(t *testing.T) {
+	d := Delivery{Retries: 1, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, QueueDepth: 10}
+	mTags := NewMetricTags(&metaMetrics{}, func() {}, time.Second, metrics.NewRegistry(), ".", WithDelivery(d), WithLogger(&recordingLogger{}))
+	delivered := make(chan struct{}, 10)
+	release := make(chan struct{})
+	mTags.AddSink(SinkFunc(func(*Snapshot) error {
+		<-release
+		delivered <- struct{}{}
+		return nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mTags.FlushContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded while delivering, got %v", err)
+	}
+	close(release)
+	if err := mTags.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 2 {
+		t.Fatalf("expected 2 snapshots delivered when Flush returns, got %d", len(delivered))
+	}
+	if err := mTags.FlushPrefix(context.Background(), "tagtrics"); err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 3 {
+		t.Fatalf("expected 3 snapshots delivered when FlushPrefix returns, got %d", len(delivered))
+	}
+}
@@ -0,0 +1,95 @@
+package tagtrics
+
+import (
+	"net"
+	"os"
+	"strconv"
+)
+
+// Registrar registers the HTTP endpoint serving the metrics of the process
+// with a service discovery system so scrapers find new instances, see the
+// packages under discovery/.  Registrars added with AddRegistrar are
+// registered by Run and deregistered by Stop.
+type Registrar interface {
+	// Register registers the endpoint.
+	Register() error
+	// Deregister removes the registration.
+	Deregister() error
+}
+
+// LoggingRegistrar is a Registrar reporting the failures it runs into in
+// the background, such as failing to keep a registration alive.
+// AddRegistrar sets its logger to the one of the MetricTags, see
+// WithLogger.
+type LoggingRegistrar interface {
+	Registrar
+	// SetLogger sets the logger the failures are reported to.
+	SetLogger(l Logger)
+}
+
+// Endpoint describes the HTTP endpoint serving the metrics of an instance of
+// a service, e.g. the one of ListenAndServe.
+type Endpoint struct {
+	// Service is the name of the service, e.g. "api".
+	Service string
+	// ID identifies the instance among the instances of the service.  If
+	// not set, the service, address and port joined with "-" are used.
+	ID string
+	// Address is the host name or IP address scrapers connect to.  If not
+	// set, the host name is used.
+	Address string
+	// Port is the port of the endpoint.
+	Port int
+	// Path is the path the metrics are served at.  If not set,
+	// DefaultMetricsPath is used.
+	Path string
+	// Meta holds additional metadata of the instance, e.g. its version.
+	Meta map[string]string
+}
+
+// WithDefaults returns e with the defaults of the fields which are not set.
+func (e Endpoint) WithDefaults() Endpoint {
+	if e.Address == "" {
+		e.Address, _ = os.Hostname()
+	}
+	if e.Path == "" {
+		e.Path = DefaultMetricsPath
+	}
+	if e.ID == "" {
+		e.ID = e.Service + "-" + e.Address + "-" + strconv.Itoa(e.Port)
+	}
+	return e
+}
+
+// URL returns the URL of the metrics served at e.
+func (e Endpoint) URL() string {
+	return "http://" + net.JoinHostPort(e.Address, strconv.Itoa(e.Port)) + e.Path
+}
+
+// AddRegistrar adds a registrar registered by Run and deregistered by Stop,
+// in pull-only mode too.  It must be called before Run.
+func (m *MetricTags) AddRegistrar(r Registrar) {
+	if l, ok := r.(LoggingRegistrar); ok {
+		l.SetLogger(m.log())
+	}
+	m.registrars = append(m.registrars, r)
+}
+
+// registerEndpoints registers the endpoint with every registrar.  Failures
+// are logged without stopping the others.
+func (m *MetricTags) registerEndpoints() {
+	for _, r := range m.registrars {
+		if err := r.Register(); err != nil {
+			m.warn("failed to register endpoint", "err", err)
+		}
+	}
+}
+
+// deregisterEndpoints removes the registrations of every registrar.
+func (m *MetricTags) deregisterEndpoints() {
+	for _, r := range m.registrars {
+		if err := r.Deregister(); err != nil {
+			m.warn("failed to deregister endpoint", "err", err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package consul registers the metrics endpoint of a service with the
+// Consul agent so scrapers using Consul service discovery find it.
+package consul
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// DefaultAgent is the URL of the Consul agent unless configured otherwise.
+const DefaultAgent = "http://127.0.0.1:8500"
+
+// Registrar registers an endpoint as a Consul service whose "metrics_path"
+// metadata holds the path of the metrics, as expected by the
+// consul_sd_configs of Prometheus with a relabeling of
+// __meta_consul_service_metadata_metrics_path.
+type Registrar struct {
+	// Token is the ACL token sent to the agent, if any.
+	Token string
+	// Tags are the tags of the service, e.g. "metrics".
+	Tags []string
+	// CheckInterval registers an HTTP check of the metrics endpoint run at
+	// that interval if set.  Instances failing it for a minute are
+	// deregistered by Consul.
+	CheckInterval time.Duration
+	// Client is the HTTP client used to call the agent.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	agent    string
+	endpoint tagtrics.Endpoint
+}
+
+// service is the body of the service registration API.
+type service struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Meta    map[string]string `json:"Meta"`
+	Check   *check            `json:"Check,omitempty"`
+}
+
+// check is an HTTP check of a service.
+type check struct {
+	HTTP                           string `json:"HTTP"`
+	Interval                       string `json:"Interval"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+// New returns a registrar registering e with the Consul agent at agent,
+// e.g. DefaultAgent.
+func New(agent string, e tagtrics.Endpoint) *Registrar {
+	return &Registrar{agent: agent, endpoint: e.WithDefaults()}
+}
+
+// Register registers the service with the agent.
+func (r *Registrar) Register() error {
+	e := r.endpoint
+	s := service{
+		ID:      e.ID,
+		Name:    e.Service,
+		Address: e.Address,
+		Port:    e.Port,
+		Tags:    r.Tags,
+		Meta:    map[string]string{"metrics_path": e.Path},
+	}
+	for k, v := range e.Meta {
+		s.Meta[k] = v
+	}
+	if r.CheckInterval > 0 {
+		s.Check = &check{HTTP: e.URL(), Interval: r.CheckInterval.String(), DeregisterCriticalServiceAfter: "1m"}
+	}
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return r.put("/v1/agent/service/register", body)
+}
+
+// Deregister removes the service from the agent.
+func (r *Registrar) Deregister() error {
+	return r.put("/v1/agent/service/deregister/"+url.PathEscape(r.endpoint.ID), nil)
+}
+
+// put calls the agent API at path.
+func (r *Registrar) put(path string, body []byte) error {
+	req, err := http.NewRequest("PUT", r.agent+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Token != "" {
+		req.Header.Set("X-Consul-Token", r.Token)
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("consul: unexpected status %s: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+func TestRegistrar(t *testing.T) {
+	var paths []string
+	var registered service
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.Header.Get("X-Consul-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/v1/agent/service/register" {
+			json.NewDecoder(r.Body).Decode(&registered)
+		}
+	}))
+	defer srv.Close()
+
+	r := New(srv.URL, tagtrics.Endpoint{Service: "api", Address: "10.0.0.1", Port: 9090, Path: "/stats", Meta: map[string]string{"version": "1.2"}})
+	r.Token = "secret"
+	r.CheckInterval = 10 * time.Second
+	if err := r.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Deregister(); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || paths[1] != "/v1/agent/service/deregister/api-10.0.0.1-9090" {
+		t.Fatalf("unexpected calls %v", paths)
+	}
+	if registered.Name != "api" || registered.Meta["metrics_path"] != "/stats" || registered.Meta["version"] != "1.2" {
+		t.Fatalf("unexpected registration %+v", registered)
+	}
+	if registered.Check == nil || registered.Check.HTTP != "http://10.0.0.1:9090/stats" || registered.Check.Interval != "10s" {
+		t.Fatalf("unexpected check %+v", registered.Check)
+	}
+
+	r.Token = ""
+	if err := r.Register(); err == nil {
+		t.Fatalf("expected an error for a rejected registration")
+	}
+}
@@ -0,0 +1,182 @@
+// Package etcd registers the metrics endpoint of a service in etcd so
+// scrapers watching a key prefix find it.
+package etcd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// DefaultPrefix is the prefix of the keys of the endpoints unless configured
+// otherwise.
+const DefaultPrefix = "/services/"
+
+// DefaultTTL is the time to live of a registration unless configured
+// otherwise.
+const DefaultTTL = 30 * time.Second
+
+// Registrar stores an endpoint under the key Prefix + service + "/" + ID
+// with the gRPC gateway of etcd v3.  The key is attached to a lease kept
+// alive while registered so instances which die without deregistering
+// expire after TTL.  The value is the JSON of Value.
+type Registrar struct {
+	// Prefix is the prefix of the key.  If not set, DefaultPrefix is used.
+	Prefix string
+	// TTL is the time to live of the lease.  If not set, DefaultTTL is
+	// used.
+	TTL time.Duration
+	// Client is the HTTP client used to call etcd.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	url      string
+	endpoint tagtrics.Endpoint
+	// mu guards lease, the ID of the lease while registered, stop, which
+	// stops keeping it alive, and logger, which the failures to renew it
+	// are reported to.
+	mu     sync.Mutex
+	lease  string
+	stop   chan struct{}
+	logger tagtrics.Logger
+}
+
+// Value is the value stored for an endpoint.
+type Value struct {
+	// Address is the host and port of the endpoint.
+	Address string `json:"address"`
+	// Path is the path the metrics are served at.
+	Path string `json:"metrics_path"`
+	// Meta is the metadata of the endpoint.
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// New returns a registrar storing e in the etcd cluster at url, e.g.
+// "http://127.0.0.1:2379".
+func New(url string, e tagtrics.Endpoint) *Registrar {
+	return &Registrar{url: url, endpoint: e.WithDefaults()}
+}
+
+// SetLogger reports the failures to renew the lease to l.  It is called by
+// MetricTags.AddRegistrar with the logger of the MetricTags.  Without a
+// logger they are written to the standard logger.
+func (r *Registrar) SetLogger(l tagtrics.Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logger = l
+}
+
+// Key returns the key of the endpoint.
+func (r *Registrar) Key() string {
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return prefix + r.endpoint.Service + "/" + r.endpoint.ID
+}
+
+// Register grants a lease, stores the endpoint with it and keeps it alive
+// until Deregister.
+func (r *Registrar) Register() error {
+	ttl := r.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	var grant struct {
+		ID string `json:"ID"`
+	}
+	if err := r.post("/v3/lease/grant", map[string]interface{}{"TTL": int64(ttl / time.Second)}, &grant); err != nil {
+		return err
+	}
+	e := r.endpoint
+	value, err := json.Marshal(Value{Address: net.JoinHostPort(e.Address, strconv.Itoa(e.Port)), Path: e.Path, Meta: e.Meta})
+	if err != nil {
+		return err
+	}
+	put := map[string]interface{}{
+		"key":   base64.StdEncoding.EncodeToString([]byte(r.Key())),
+		"value": base64.StdEncoding.EncodeToString(value),
+		"lease": grant.ID,
+	}
+	if err := r.post("/v3/kv/put", put, nil); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lease, r.stop = grant.ID, make(chan struct{})
+	go r.keepAlive(grant.ID, ttl/3, r.stop)
+	return nil
+}
+
+// keepAlive renews the lease every interval until stop is closed.
+func (r *Registrar) keepAlive(lease string, interval time.Duration, stop chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if err := r.post("/v3/lease/keepalive", map[string]interface{}{"ID": lease}, nil); err != nil {
+				r.mu.Lock()
+				l := r.logger
+				r.mu.Unlock()
+				if l == nil {
+					log.Printf("etcd: failed to renew lease: %v", err)
+				} else {
+					l.Warn("failed to renew etcd lease", "key", r.Key(), "err", err)
+				}
+			}
+		}
+	}
+}
+
+// Deregister revokes the lease, which deletes the key.
+func (r *Registrar) Deregister() error {
+	r.mu.Lock()
+	lease, stop := r.lease, r.stop
+	r.lease, r.stop = "", nil
+	r.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	return r.post("/v3/lease/revoke", map[string]interface{}{"ID": lease}, nil)
+}
+
+// post calls the gateway API at path with the JSON of req and decodes the
+// response into resp if not nil.
+func (r *Registrar) post(path string, req interface{}, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Post(r.url+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("etcd: unexpected status %s: %s", res.Status, msg)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
@@ -0,0 +1,102 @@
+package etcd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+func TestRegistrar(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	var put map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.URL.Path)
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			w.Write([]byte(`{"ID":"7587","TTL":"30"}`))
+		case "/v3/kv/put":
+			json.NewDecoder(r.Body).Decode(&put)
+		}
+	}))
+	defer srv.Close()
+
+	r := New(srv.URL, tagtrics.Endpoint{Service: "api", Address: "10.0.0.1", Port: 9090})
+	if err := r.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Deregister(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 3 || calls[2] != "/v3/lease/revoke" {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	key, _ := base64.StdEncoding.DecodeString(put["key"])
+	value, _ := base64.StdEncoding.DecodeString(put["value"])
+	if string(key) != "/services/api/api-10.0.0.1-9090" || put["lease"] != "7587" {
+		t.Fatalf("unexpected put %v", put)
+	}
+	if string(value) != `{"address":"10.0.0.1:9090","metrics_path":"/metrics"}` {
+		t.Fatalf("unexpected value %s", value)
+	}
+}
+
+type recordingLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, strings.TrimSpace(fmt.Sprintln(append([]interface{}{msg}, keyvals...)...)))
+}
+
+func (l *recordingLogger) logged() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.msgs...)
+}
+
+func TestRenewFailureLogged(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			w.Write([]byte(`{"ID":"7587","TTL":"1"}`))
+		case "/v3/lease/keepalive":
+			http.Error(w, "lease not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	var l recordingLogger
+	tags := tagtrics.NewMetricTags(&struct{}{}, func() {}, 0, metrics.NewRegistry(), ".", tagtrics.WithLogger(&l))
+	r := New(srv.URL, tagtrics.Endpoint{Service: "api", Address: "10.0.0.1", Port: 9090})
+	r.TTL = 30 * time.Millisecond
+	tags.AddRegistrar(r)
+	if err := r.Register(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Deregister()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(l.logged()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	msgs := l.logged()
+	if len(msgs) == 0 || !strings.HasPrefix(msgs[0], "failed to renew etcd lease key /services/api/api-10.0.0.1-9090 err etcd: unexpected status 404") {
+		t.Fatalf("unexpected logs %v", msgs)
+	}
+}
@@ -0,0 +1,35 @@
+package tagtrics
+
+import (
+	"testing"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+type recordingRegistrar []string
+
+func (r *recordingRegistrar) Register() error {
+	*r = append(*r, "register")
+	return nil
+}
+
+func (r *recordingRegistrar) Deregister() error {
+	*r = append(*r, "deregister")
+	return nil
+}
+
+func TestRegistrar(t *testing.T) {
+	tags := NewMetricTags(&metaMetrics{}, func() {}, 0, metrics.NewRegistry(), ".")
+	var r recordingRegistrar
+	tags.AddRegistrar(&r)
+	tags.Run()
+	tags.Stop()
+	if len(r) != 2 || r[0] != "register" || r[1] != "deregister" {
+		t.Fatalf("unexpected calls %v", r)
+	}
+
+	e := Endpoint{Service: "api", Address: "::1", Port: 9090}.WithDefaults()
+	if e.ID != "api-::1-9090" || e.URL() != "http://[::1]:9090/metrics" {
+		t.Fatalf("unexpected endpoint %+v at %s", e, e.URL())
+	}
+}
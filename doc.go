@@ -0,0 +1,91 @@
+// Package tagtrics initializes the go-metrics metrics held by a struct from
+// its struct tags and keeps them updated, exported and flushed.
+//
+// The "metric" struct tag names the metric of each field of the struct given
+// to NewMetricTags, prefixed with the names of the structs above it.  Fields
+// without a tag are named after their lowercased field name, or as set with
+// WithDerivedNameCase.
+//
+// The keys of map[string]*T fields are name segments of the metrics of the
+// values beneath the map's name, unless T implements Bucket.  Maps keyed by
+// other types implementing fmt.Stringer, e.g. a Region int, use the output
+// of String instead.  A value which is a struct above it, such as the struct
+// holding the map, is a cycle which stops the traversal with an error.  The
+// values of the keys of a LazyMap field are initialized on first use
+// instead.
+//
+// Fields of type func() int64 or func() float64 are exported as gauges
+// calling them whenever they are read, e.g. for live readings such as the
+// size of a cache.  time.Time fields with a "metric" tag are exported as
+// gauges of their unix time in seconds, zero until set, e.g. the time a
+// configuration was loaded.  time.Duration fields with a "metric" tag, e.g.
+// configured timeouts, are exported as gauges in the unit of the "duration"
+// option, or the one set with WithDurationUnit, so configuration appears
+// alongside the behavior it explains.  Untagged time.Time and time.Duration
+// fields are left alone.
+//
+// Func, time.Time and time.Duration fields are read without synchronization
+// whenever their gauges are read, so they must be set before NewMetricTags
+// and never changed afterwards.  Values changing later, e.g. the time of the
+// last successful sync, belong behind a func reading them safely, e.g. from
+// a sync/atomic.Int64 holding the unix time.
+//
+// The elements of array fields, e.g. [16]ShardMetrics, are named after their
+// index beneath the array's name, or as set with the "index" and "names"
+// options described by ElementName.  The non-nil elements of slices of
+// pointers to structs, e.g. []*ConsumerMetrics, are named the same way.
+// Elements appended later are initialized by Rescan.
+//
+// Structs and fields implementing Initializer register their metrics
+// themselves instead of being traversed.
+//
+// The optional "help" and "unit" struct tags are kept as metadata of the
+// metric and can be queried with Metadata.  The optional "sink" struct tag
+// routes the metrics beneath the field to the sinks added with that name by
+// AddNamedSink only, e.g. `metric:"internal" sink:"debug"` for verbose
+// metrics which should not leave the box by default.
+//
+// The metric name in the "metric" tag may be followed by comma separated
+// options, e.g. `metric:"latency,percentiles=50;90;99;99.9"`:
+//
+//   - percentiles: semicolon separated percentiles exported for a histogram
+//     or timer instead of MetricTags.Percentiles.
+//   - duration: unit of the durations exported for a timer or a
+//     time.Duration field, one of "ns", "us", "ms" or "s", instead of the one
+//     set with WithDurationUnit.
+//   - sample=hdr: backs a histogram or timer with an HDR histogram which
+//     records every value.  Its range and precision are set with the "min",
+//     "max" and "sigfigs" options.
+//   - sample=ckms: backs a histogram or timer with a CKMS summary computing
+//     its percentiles within the rank error of the "epsilon" option,
+//     DefaultCKMSEpsilon by default, over the sliding window of the
+//     "window" option, DefaultCKMSWindow by default, in little memory at
+//     any throughput.
+//   - sample=tdigest: backs a histogram or timer with a t-digest of the
+//     accuracy of the "compression" option, DefaultTDigestCompression by
+//     default, whose digests merge across shards and processes, see
+//     Snapshot.Digest.
+//   - states: semicolon separated states of a StateGauge in order of their
+//     values, e.g. "states=closed;connecting;open".
+//   - precision: number of register index bits of a CardinalityCounter.
+//   - rate: adds a gauge suffixed with "rate" next to a counter holding its
+//     change per second between flushes.
+//   - reset: makes a counter, histogram or timer only hold the values of
+//     the previous flush interval as expected by statsd-style backends.
+//     Updates go to the current interval which is swapped in atomically
+//     right before every flush.  WithResetOnFlush applies it to every field.
+//   - separator: replaces the separator in the names of the metrics beneath
+//     the field, including the suffixes of its own metrics, e.g.
+//     `metric:"http,separator=_"` yields "api.http_latency" with "." as the
+//     separator of the MetricTags.
+//   - index, names: name the elements of an array or slice field, see
+//     ElementName.
+//   - label: exports the keys of a map or LazyMap field as the named label
+//     too, e.g. `metric:"traffic,label=endpoint"` yields
+//     "traffic.search.latency" in the family "traffic.latency" with
+//     {"endpoint": "search"}, like the Bucket interface does.  It is
+//     reported as an invalid option on the fields of other kinds.
+//   - optional: only registers the field, or every metric beneath it, when
+//     the named feature flag is enabled according to the FlagResolver, e.g.
+//     "optional=new-router".
+package tagtrics
@@ -0,0 +1,24 @@
+package tagtrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// dump writes the current snapshot to w as indented JSON for people to read.
+func (m *MetricTags) dump(w io.Writer) {
+	buf := bytes.NewBuffer(nil)
+	if err := m.Snapshot().WriteJSON(buf); err != nil {
+		m.warn("failed to dump metrics", "err", err)
+		return
+	}
+	pretty := bytes.NewBuffer(nil)
+	if err := json.Indent(pretty, buf.Bytes(), "", "    "); err != nil {
+		m.warn("failed to dump metrics", "err", err)
+		return
+	}
+	if _, err := pretty.WriteTo(w); err != nil {
+		m.warn("failed to dump metrics", "err", err)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package tagtrics
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// DumpOnSignal writes every metric to w as indented JSON whenever the process
+// receives SIGUSR1, e.g. with "kill -USR1 <pid>", for inspecting production
+// processes without an HTTP port.  The returned function stops listening for
+// the signal.
+func (m *MetricTags) DumpOnSignal(w io.Writer) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+				m.dump(w)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
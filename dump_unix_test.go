@@ -0,0 +1,60 @@
+//go:build !windows
+// +build !windows
+
+package tagtrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func TestDumpOnSignal(t *testing.T) {
+	m := &metaMetrics{}
+	mTags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Queue.Depth.Update(3)
+	w := &syncBuffer{}
+	stop := mTags.DumpOnSignal(w)
+	defer stop()
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(w.Bytes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Wait for the dump to be complete.
+	time.Sleep(50 * time.Millisecond)
+	var dump map[string]map[string]float64
+	if err := json.Unmarshal(w.Bytes(), &dump); err != nil {
+		t.Fatalf("invalid dump %q: %v", w.Bytes(), err)
+	}
+	if dump["queue.depth"]["value"] != 3 {
+		t.Fatalf("unexpected dump: %v", dump)
+	}
+	if !bytes.Contains(w.Bytes(), []byte("\n    ")) {
+		t.Fatalf("dump is not indented")
+	}
+}
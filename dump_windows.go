@@ -0,0 +1,10 @@
+package tagtrics
+
+import (
+	"io"
+)
+
+// DumpOnSignal does nothing on Windows since there is no SIGUSR1.
+func (m *MetricTags) DumpOnSignal(w io.Writer) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,73 @@
+package tagtrics
+
+import (
+	"time"
+)
+
+// MaxBufferedEvents is the number of annotations kept in between flushes.
+// The oldest ones are dropped once it is reached.
+const MaxBufferedEvents = 1000
+
+// Event is an annotation such as a deploy or a configuration change shown
+// alongside the metrics by backends supporting events.
+type Event struct {
+	// Name is the title of the event, e.g. "deploy".
+	Name string
+	// Text describes the event.
+	Text string
+	// Tags are the tags of the event, e.g. {"version": "1.2"}.
+	Tags map[string]string
+	// Time is when Annotate was called.
+	Time time.Time
+}
+
+// EventSink is implemented by the sinks of backends supporting events, such
+// as Grafana annotations.  They are sent the events annotated since the
+// previous flush after the snapshot.
+type EventSink interface {
+	// SendEvents exports the events.  It must not modify them since they
+	// are shared with the other sinks.
+	SendEvents(events []Event) error
+}
+
+// Annotate records an event which is delivered on the next flush to the
+// sinks implementing EventSink, so deploys and configuration changes appear
+// alongside the metrics.  At most MaxBufferedEvents are kept in between
+// flushes.  Events are dropped in pull-only mode since there are no flushes.
+func (m *MetricTags) Annotate(name, text string, tags map[string]string) {
+	if m.pullOnly {
+		return
+	}
+	e := Event{Name: name, Text: text, Tags: make(map[string]string, len(tags)), Time: m.nowHandler()}
+	for k, v := range tags {
+		e.Tags[k] = v
+	}
+	m.eventMutex.Lock()
+	defer m.eventMutex.Unlock()
+	if len(m.events) == MaxBufferedEvents {
+		m.events = m.events[1:]
+	}
+	m.events = append(m.events, e)
+}
+
+// sendEvents sends the events annotated since the previous flush to the
+// sinks implementing EventSink.  Failures are counted as sink errors.
+func (m *MetricTags) sendEvents() {
+	m.eventMutex.Lock()
+	events := m.events
+	m.events = nil
+	m.eventMutex.Unlock()
+	if len(events) == 0 {
+		return
+	}
+	for _, sink := range m.sinks {
+		es, ok := sink.Sink.(EventSink)
+		if !ok {
+			continue
+		}
+		if err := es.SendEvents(events); err != nil {
+			m.self.Sink.Errors.Inc(1)
+			m.warn("sink failed to send events", "sink", sink.name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package tagtrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// eventSink records the events it is sent.
+type eventSink struct {
+	events [][]Event
+	err    error
+}
+
+func (s *eventSink) Send(*Snapshot) error { return nil }
+
+func (s *eventSink) SendEvents(events []Event) error {
+	s.events = append(s.events, events)
+	return s.err
+}
+
+func TestAnnotate(t *testing.T) {
+	r := metrics.NewRegistry()
+	tags := NewMetricTags(&struct{}{}, func() {}, time.Second, r, ".")
+	ok, failing := &eventSink{}, &eventSink{err: errors.New("backend down")}
+	tags.AddSink(ok)
+	tags.AddSink(failing)
+	tags.AddSink(SinkFunc(func(*Snapshot) error { return nil }))
+
+	labels := map[string]string{"version": "1.2"}
+	tags.Annotate("deploy", "Deployed 1.2", labels)
+	labels["version"] = "changed"
+	tags.flush()
+	tags.flush()
+	if len(ok.events) != 1 || len(ok.events[0]) != 1 {
+		t.Fatalf("expected the event to be sent once, got %v", ok.events)
+	}
+	if e := ok.events[0][0]; e.Name != "deploy" || e.Text != "Deployed 1.2" || e.Tags["version"] != "1.2" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if c := r.Get("tagtrics.sink.errors").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 sink error, got %d", c)
+	}
+
+	for i := 0; i < MaxBufferedEvents+1; i++ {
+		tags.Annotate("config", "", nil)
+	}
+	tags.flush()
+	if n := len(ok.events[1]); n != MaxBufferedEvents {
+		t.Fatalf("expected %d buffered events, got %d", MaxBufferedEvents, n)
+	}
+}
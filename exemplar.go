@@ -0,0 +1,63 @@
+package tagtrics
+
+import (
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// Exemplar is a value recorded by a histogram or timer along with the trace
+// it was recorded in, so a latency spike can be linked to a trace.
+type Exemplar struct {
+	// Value is the recorded value, in nanoseconds for timers.
+	Value float64
+	// Labels identify the trace, e.g. {"trace_id": "4bf92f3577b34da6"}.
+	Labels map[string]string
+	// Time is when the value was recorded.
+	Time time.Time
+}
+
+// RecordWithExemplar records value in a metrics.Histogram, or the duration
+// value in nanoseconds in a metrics.Timer, initialized by m and keeps it as
+// the exemplar of the metric along with traceID.  The last exemplar of every
+// metric is included in snapshots and exported with the OpenMetrics
+// exposition format.  An empty traceID records value without an exemplar.
+func (m *MetricTags) RecordWithExemplar(metric interface{}, value int64, traceID string) {
+	switch h := metric.(type) {
+	case metrics.Timer:
+		h.Update(time.Duration(value))
+	case metrics.Histogram:
+		h.Update(value)
+	default:
+		return
+	}
+	if traceID == "" {
+		return
+	}
+	e := Exemplar{
+		Value:  float64(value),
+		Labels: map[string]string{"trace_id": traceID},
+		Time:   m.nowHandler(),
+	}
+	m.exemplarMutex.Lock()
+	defer m.exemplarMutex.Unlock()
+	if m.exemplars == nil {
+		m.exemplars = make(map[interface{}]Exemplar)
+	}
+	m.exemplars[metric] = e
+}
+
+// exemplar returns the last exemplar recorded for metric, if any.
+func (m *MetricTags) exemplar(metric interface{}) (Exemplar, bool) {
+	switch metric.(type) {
+	case metrics.Histogram, metrics.Timer:
+	default:
+		// Only histograms and timers have exemplars, other metrics may
+		// not even be usable as map keys.
+		return Exemplar{}, false
+	}
+	m.exemplarMutex.RLock()
+	defer m.exemplarMutex.RUnlock()
+	e, ok := m.exemplars[metric]
+	return e, ok
+}
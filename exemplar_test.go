@@ -0,0 +1,73 @@
+package tagtrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type exemplarMetrics struct {
+	Latency  metrics.Timer     `metric:"latency,percentiles=50" help:"Request latency"`
+	Size     metrics.Histogram `metric:"size"`
+	Requests metrics.Counter   `metric:"requests"`
+}
+
+func TestRecordWithExemplar(t *testing.T) {
+	m := &exemplarMetrics{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithDurationUnit(time.Millisecond))
+	tags.nowHandler = func() time.Time { return time.Unix(1700000000, 0) }
+	tags.RecordWithExemplar(m.Latency, int64(2*time.Millisecond), "abc")
+	tags.RecordWithExemplar(m.Size, 10, "")
+	tags.RecordWithExemplar(m.Requests, 1, "ignored")
+
+	s := tags.Snapshot()
+	if m.Latency.Count() != 1 || m.Size.Count() != 1 || m.Requests.Count() != 0 {
+		t.Fatalf("unexpected counts %d %d %d", m.Latency.Count(), m.Size.Count(), m.Requests.Count())
+	}
+	if e := s.Exemplars["latency"]; e.Labels["trace_id"] != "abc" || e.Value != 2e6 {
+		t.Fatalf("unexpected exemplar %+v", e)
+	}
+	if _, ok := s.Exemplars["size"]; ok {
+		t.Fatalf("exemplar recorded without a trace ID")
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteOpenMetrics(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE latency summary\n# HELP latency Request latency\n",
+		`latency{quantile="0.5"} 2` + "\n",
+		"latency_sum 2\n",
+		`latency_count 1 # {trace_id="abc"} 2 1700000000.000` + "\n",
+		"# TYPE requests counter\nrequests_total 0\n",
+		"size_count 1\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Fatalf("missing EOF")
+	}
+}
+
+func TestOpenMetricsFamilies(t *testing.T) {
+	m := &bucketMetrics{Tenants: map[string]*tenantMetrics{"a": {}, "b\"": {}}}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	var buf bytes.Buffer
+	tags.Snapshot().WriteOpenMetrics(&buf)
+	out := buf.String()
+	if strings.Count(out, "# TYPE tenants_requests counter") != 1 {
+		t.Fatalf("expected a single family for the tenants:\n%s", out)
+	}
+	for _, line := range []string{`tenants_requests_total{tenant="a"} 0`, `tenants_requests_total{tenant="b\""} 0`} {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestExistingMetrics(t *testing.T) {
+	r := metrics.NewRegistry()
+	sent := metrics.GetOrRegisterCounter("queue.sent", r)
+	sent.Inc(3)
+	// Not a gauge, so not bound.
+	metrics.GetOrRegisterCounter("queue.depth", r)
+
+	m := &struct {
+		Queue struct {
+			Sent  metrics.Counter `metric:"sent" help:"Messages sent"`
+			Depth metrics.Gauge   `metric:"depth"`
+		} `metric:"queue"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, r, ".", WithExistingMetrics(), WithLogger(&recordingLogger{}))
+	if m.Queue.Sent != sent {
+		t.Fatalf("expected the registered counter to be bound")
+	}
+	m.Queue.Sent.Inc(1)
+	if c := r.Get("queue.sent").(metrics.Counter).Count(); c != 4 {
+		t.Fatalf("expected 4 sent, got %d", c)
+	}
+	if meta, ok := tags.Metadata("queue.sent"); !ok || meta.Help != "Messages sent" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if _, ok := r.Get("queue.depth").(metrics.Counter); !ok || m.Queue.Depth == nil {
+		t.Fatalf("expected a new gauge for a name registered as a counter")
+	}
+
+	tags.Unregister()
+	if r.Get("queue.sent") != nil {
+		t.Fatalf("expected the bound counter to be unregistered")
+	}
+}
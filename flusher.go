@@ -0,0 +1,176 @@
+package tagtrics
+
+import (
+	"sync"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// Flusher flushes several MetricTags on a single schedule so a process
+// holding a MetricTags per module runs a single loop.  The flush interval
+// of the attached MetricTags is ignored and their Run method must not be
+// called, but their metrics with an "interval" tag option are sent when due
+// like with Run.  Like Run and Stop, the Flusher registers the endpoints of the
+// attached MetricTags with their registrars and delivers the snapshots they
+// queue with WithDelivery while it runs.
+type Flusher struct {
+	// quitCh is a channel used to signal that Run should quit.
+	quitCh chan struct{}
+	// interval holds how often the attached MetricTags are flushed.
+	interval time.Duration
+	// attached holds the MetricTags in the order they are flushed.
+	attached []*MetricTags
+	// stats holds the runtime statistics sampled per registry so
+	// MetricTags sharing a registry only sample them once.
+	stats map[metrics.Registry]*runtimeStats
+	// running is set while Run is running.
+	running bool
+	mutex   sync.Mutex
+}
+
+// NewFlusher creates a Flusher flushing every interval.
+func NewFlusher(interval time.Duration) *Flusher {
+	return &Flusher{
+		quitCh:   make(chan struct{}),
+		interval: interval,
+		stats:    make(map[metrics.Registry]*runtimeStats),
+	}
+}
+
+// Attach adds m to the MetricTags flushed by f.  It can be called while f
+// is running.
+func (f *Flusher) Attach(m *MetricTags) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.attached = append(f.attached, m)
+	if _, ok := f.stats[m.registry]; !ok {
+		f.stats[m.registry] = newRuntimeStats(m, m.nowHandler())
+	}
+	if f.running {
+		m.start()
+	}
+}
+
+// Detach removes m from the MetricTags flushed by f.  m is not flushed one
+// last time, but its endpoint is deregistered and the snapshots it queued
+// are delivered if f is running.
+func (f *Flusher) Detach(m *MetricTags) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i, a := range f.attached {
+		if a == m {
+			f.attached = append(f.attached[:i:i], f.attached[i+1:]...)
+			if f.running {
+				m.deregisterEndpoints()
+				m.stopDelivery()
+			}
+			return
+		}
+	}
+}
+
+// Run flushes the attached MetricTags every interval until Stop is called.
+func (f *Flusher) Run() {
+	f.mutex.Lock()
+	f.running = true
+	for _, m := range f.attached {
+		m.start()
+	}
+	f.mutex.Unlock()
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		// Wake up early for the metrics flushed more often than the
+		// others.
+		var early <-chan time.Time
+		if due, ok := f.nextIntervalDue(); ok {
+			early = time.After(time.Until(due))
+		}
+		select {
+		case <-f.quitCh:
+			f.stop()
+			f.quitCh <- struct{}{}
+			return
+		case <-ticker.C:
+			f.flush()
+		case <-early:
+			f.flushIntervals()
+		}
+	}
+}
+
+// nextIntervalDue returns when the metrics of the earliest interval set by
+// the "interval" tag option of the attached MetricTags are due, or false if
+// no metric has one.
+func (f *Flusher) nextIntervalDue() (time.Time, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var next time.Time
+	found := false
+	for _, m := range f.attached {
+		if due, ok := m.nextIntervalDue(); ok && (!found || due.Before(next)) {
+			next, found = due, true
+		}
+	}
+	return next, found
+}
+
+// flushIntervals sends the metrics of the attached MetricTags whose interval
+// set by the "interval" tag option is due between the flushes.
+func (f *Flusher) flushIntervals() {
+	f.mutex.Lock()
+	attached := f.attached
+	f.mutex.Unlock()
+	now := time.Now()
+	for _, m := range attached {
+		if due, ok := m.nextIntervalDue(); ok && !now.Before(due) {
+			m.flushIntervals()
+		}
+	}
+}
+
+// flush samples the runtime statistics which are due and flushes every
+// attached MetricTags in order.
+func (f *Flusher) flush() {
+	f.mutex.Lock()
+	attached := f.attached
+	sampled := make(map[metrics.Registry]bool)
+	for _, m := range attached {
+		if !sampled[m.registry] {
+			f.stats[m.registry].capture(m, m.nowHandler())
+			sampled[m.registry] = true
+		}
+	}
+	f.mutex.Unlock()
+	for _, m := range attached {
+		m.flushWithin(f.interval)
+	}
+}
+
+// stop deregisters the endpoints of the attached MetricTags, flushes them
+// one last time and waits for the snapshots they queued to be delivered,
+// like MetricTags.Stop.
+func (f *Flusher) stop() {
+	f.mutex.Lock()
+	f.running = false
+	attached := f.attached
+	for _, m := range attached {
+		m.deregisterEndpoints()
+	}
+	f.mutex.Unlock()
+	// Update stats one last time
+	f.flush()
+	for _, m := range attached {
+		m.stopDelivery()
+	}
+}
+
+// Stop stops the Run worker and waits for it to finish, once the attached
+// MetricTags were stopped like by MetricTags.Stop.  Run can then be called
+// again.
+func (f *Flusher) Stop() {
+	f.quitCh <- struct{}{}
+	// Wait for it to quit
+	<-f.quitCh
+}
@@ -0,0 +1,97 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFlusher(t *testing.T) {
+	var flushes []string
+	r := metrics.NewRegistry()
+	first := NewMetricTags(&metaMetrics{}, func() { flushes = append(flushes, "first") }, time.Hour, r, ".",
+		WithDelivery(Delivery{QueueDepth: 10}))
+	var registrar recordingRegistrar
+	first.AddRegistrar(&registrar)
+	delivered := 0
+	first.AddSink(SinkFunc(func(*Snapshot) error {
+		delivered++
+		return nil
+	}))
+	second := NewMetricTags(&metaMetrics{}, func() { flushes = append(flushes, "second") }, time.Hour, r, ".")
+	third := NewMetricTags(&metaMetrics{}, func() { flushes = append(flushes, "third") }, time.Hour, r, ".")
+
+	f := NewFlusher(time.Hour)
+	f.Attach(first)
+	f.Attach(second)
+	f.Attach(third)
+	f.Detach(second)
+	f.flush()
+	if len(flushes) != 2 || flushes[0] != "first" || flushes[1] != "third" {
+		t.Fatalf("unexpected flushes %v", flushes)
+	}
+
+	// Stop flushes one last time.
+	go f.Run()
+	f.Stop()
+	if len(flushes) != 4 {
+		t.Fatalf("expected a final flush, got %v", flushes)
+	}
+	if r.Get("runtime.MemStats.Alloc") == nil {
+		t.Fatalf("runtime stats are not registered")
+	}
+	// The Flusher starts and stops the attached MetricTags like Run and
+	// Stop.
+	if len(registrar) != 2 || registrar[0] != "register" || registrar[1] != "deregister" {
+		t.Fatalf("unexpected registrar calls %v", registrar)
+	}
+	if delivered != 2 {
+		t.Fatalf("expected the queued snapshots to be delivered, got %d", delivered)
+	}
+}
+
+func TestFlusherIntervals(t *testing.T) {
+	r := metrics.NewRegistry()
+	var sent []map[string]bool
+	sink := SinkFunc(func(s *Snapshot) error {
+		found := make(map[string]bool)
+		for _, name := range []string{"fast", "slow", "plain"} {
+			_, found[name] = s.Metrics[name]
+		}
+		sent = append(sent, found)
+		return nil
+	})
+	plain := NewMetricTags(&metaMetrics{}, func() {}, time.Hour, r, ".")
+	m := NewMetricTags(&intervalMetrics{}, func() {}, time.Hour, metrics.NewRegistry(), ".", WithLogger(&recordingLogger{}))
+	m.AddSink(sink)
+
+	f := NewFlusher(time.Hour)
+	f.Attach(plain)
+	if _, ok := f.nextIntervalDue(); ok {
+		t.Fatalf("expected no interval without interval metrics")
+	}
+	f.Attach(m)
+	f.flush()
+	if len(sent) != 1 || !sent[0]["fast"] || !sent[0]["slow"] || !sent[0]["plain"] {
+		t.Fatalf("expected every metric at the first flush, got %v", sent)
+	}
+	next, ok := f.nextIntervalDue()
+	if !ok || time.Until(next) > 10*time.Second {
+		t.Fatalf("unexpected next due %v", next)
+	}
+	// Nothing is due yet.
+	f.flushIntervals()
+	if len(sent) != 1 {
+		t.Fatalf("expected no send before the interval, got %v", sent)
+	}
+
+	// Make the fast metric due.
+	m.metaMutex.Lock()
+	m.intervalDue[10*time.Second] = time.Now()
+	m.metaMutex.Unlock()
+	f.flushIntervals()
+	if len(sent) != 2 || !sent[1]["fast"] || sent[1]["slow"] || sent[1]["plain"] {
+		t.Fatalf("expected the fast metric between flushes, got %v", sent)
+	}
+}
@@ -0,0 +1,40 @@
+package tagtrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFlushFunc(t *testing.T) {
+	r := metrics.NewRegistry()
+	var got *Snapshot
+	var deadline time.Time
+	fail := false
+	f := func(ctx context.Context, s *Snapshot) error {
+		got = s
+		deadline, _ = ctx.Deadline()
+		if fail {
+			return errors.New("backend down")
+		}
+		return nil
+	}
+	mTags := NewMetricTags(&metaMetrics{}, nil, time.Minute, r, ".", WithFlushFunc(f), WithLogger(&recordingLogger{}))
+
+	start := time.Now()
+	mTags.flush()
+	if got == nil || got.Stats("queue.depth") == nil {
+		t.Fatalf("expected the snapshot of the flush")
+	}
+	if deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+		t.Fatalf("expected a deadline a flush interval away, got %v", deadline)
+	}
+	fail = true
+	mTags.flush()
+	if c := r.Get("tagtrics.flush.errors").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 flush error, got %d", c)
+	}
+}
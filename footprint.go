@@ -0,0 +1,84 @@
+package tagtrics
+
+import (
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// sampleValueBytes estimates the bytes a sample of go-metrics takes per
+// value: the value itself, plus its priority in exponentially decaying
+// samples.
+const sampleValueBytes = 16
+
+// Footprint estimates the memory held by the metrics of a registry, most of
+// which is the storage of the samples of histograms and timers.
+type Footprint struct {
+	// Series is the number of metrics in the registry.
+	Series int64
+	// Samples is the number of values held by the samples of histograms and
+	// timers, or the number of buckets of HDR histograms.
+	Samples int64
+	// SampleBytes estimates the bytes of storage of those samples.
+	SampleBytes int64
+}
+
+// footprinter is implemented by metrics which know the storage of their
+// samples better than their metrics.Sample tells.
+type footprinter interface {
+	footprint() (samples, bytes int64)
+}
+
+// Footprint estimates the memory held by the metrics of the registry, so
+// that histograms of a high cardinality eating the heap can be spotted.  It
+// is also reported every flush as the "tagtrics.registry.samples" and
+// "tagtrics.registry.sample_bytes" self metrics.
+func (m *MetricTags) Footprint() Footprint {
+	return registryFootprint(m.registry)
+}
+
+// registryFootprint estimates the memory held by the metrics of r.
+func registryFootprint(r metrics.Registry) Footprint {
+	var f Footprint
+	r.Each(func(_ string, metric interface{}) {
+		samples, bytes := metricFootprint(metric)
+		f.Series++
+		f.Samples += samples
+		f.SampleBytes += bytes
+	})
+	return f
+}
+
+// metricFootprint estimates the storage of the samples of metric, zero for
+// metrics without samples.
+func metricFootprint(metric interface{}) (samples, bytes int64) {
+	switch metric := metric.(type) {
+	case footprinter:
+		return metric.footprint()
+	case metrics.Histogram:
+		n := int64(metric.Sample().Size())
+		return n, n * sampleValueBytes
+	}
+	return 0, 0
+}
+
+// footprint returns the number of buckets of h, which are allocated up front
+// whatever the number of values recorded.
+func (h *hdrHistogram) footprint() (samples, bytes int64) {
+	n := int64(len(h.counts))
+	return n, n * 8
+}
+
+// footprint returns the footprint of the histogram of t.
+func (t *histogramTimer) footprint() (samples, bytes int64) {
+	return metricFootprint(t.histogram)
+}
+
+// footprint returns the footprint of the histograms of the current and the
+// last intervals.
+func (h *resetHistogram) footprint() (samples, bytes int64) {
+	h.mutex.RLock()
+	current, last := h.current, h.last
+	h.mutex.RUnlock()
+	samples, bytes = metricFootprint(current)
+	s, b := metricFootprint(last)
+	return samples + s, bytes + b
+}
@@ -0,0 +1,41 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type footprintMetrics struct {
+	Sent    metrics.Counter   `metric:"sent"`
+	Size    metrics.Histogram `metric:"size"`
+	Latency metrics.Timer     `metric:"latency,sample=hdr,sigfigs=1"`
+	Reset   metrics.Histogram `metric:"reset,reset,sample=hdr,sigfigs=1"`
+}
+
+func TestFootprint(t *testing.T) {
+	r := metrics.NewRegistry()
+	data := &footprintMetrics{}
+	mTags := NewMetricTags(data, func() {}, time.Second, r, ".", WithDefaultSampleSize(10))
+	for i := int64(0); i < 20; i++ {
+		data.Size.Update(i)
+	}
+
+	f := mTags.Footprint()
+	if f.Series != registryFootprint(r).Series || f.Series < 4 {
+		t.Fatalf("unexpected series %d", f.Series)
+	}
+	buckets := int64(len(r.Get("latency").(*histogramTimer).histogram.(*hdrHistogram).counts))
+	if samples := 10 + 3*buckets; f.Samples != samples {
+		t.Fatalf("expected %d samples, got %d", samples, f.Samples)
+	}
+	if bytes := 10*sampleValueBytes + 3*buckets*8; f.SampleBytes != bytes {
+		t.Fatalf("expected %d sample bytes, got %d", bytes, f.SampleBytes)
+	}
+
+	mTags.flush()
+	if v := r.Get("tagtrics.registry.sample_bytes").(metrics.Gauge).Value(); v != f.SampleBytes {
+		t.Fatalf("expected %d sample bytes reported, got %d", f.SampleBytes, v)
+	}
+}
@@ -0,0 +1,99 @@
+package tagtrics
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// MethodPrefix starts the names of the methods registered as gauges with
+// WithMethodGauges.
+const MethodPrefix = "Metric"
+
+// newFuncGauge returns a functional gauge calling the func() int64 or
+// func() float64 given as is or through a pointer to the field holding it
+// whenever it is read, or reading the unix time in seconds of the time.Time
+// field p points to.  It returns nil for other types.  Fields are read on
+// every call without synchronization so they must not change after
+// NewMetricTags; nil funcs and zero times read as zero.
+func newFuncGauge(p interface{}) interface{} {
+	switch f := p.(type) {
+	case func() int64:
+		return metrics.NewFunctionalGauge(f)
+	case func() float64:
+		return metrics.NewFunctionalGaugeFloat64(f)
+	case *func() int64:
+		return metrics.NewFunctionalGauge(func() int64 {
+			if fn := *f; fn != nil {
+				return fn()
+			}
+			return 0
+		})
+	case *func() float64:
+		return metrics.NewFunctionalGaugeFloat64(func() float64 {
+			if fn := *f; fn != nil {
+				return fn()
+			}
+			return 0
+		})
+	case *time.Time:
+		return metrics.NewFunctionalGauge(func() int64 {
+			if f.IsZero() {
+				return 0
+			}
+			return f.Unix()
+		})
+	}
+	return nil
+}
+
+// newDurationGauge returns a functional gauge reading the time.Duration
+// field p points to in the given unit.
+func newDurationGauge(p *time.Duration, unit time.Duration) metrics.GaugeFloat64 {
+	return metrics.NewFunctionalGaugeFloat64(func() float64 {
+		return float64(*p) / float64(unit)
+	})
+}
+
+// initFuncGauge registers the functional gauge of the field p points to
+// as the name of its branch b.  time.Duration fields are read in the unit
+// of the "duration" tag option, or the one set with WithDurationUnit.
+func (m *MetricTags) initFuncGauge(p interface{}, b branch, help, unit string, opts tagOptions) {
+	g := newFuncGauge(p)
+	if d, ok := p.(*time.Duration); ok {
+		g = newDurationGauge(d, m.fieldDurationUnit(opts))
+	}
+	if g == nil || !b.enabled {
+		return
+	}
+	m.register(b.meta("gauge", help, unit, opts), g)
+}
+
+// WithMethodGauges registers the methods of the metrics struct named
+// MethodPrefix followed by a name, taking no arguments and returning an int64
+// or a float64, as gauges calling them whenever they are read, e.g.
+// MetricQueueDepth() int64 as "queuedepth".  The metric name is derived from
+// the rest of the method name like the names of untagged fields.  It lets
+// computed metrics live next to the state they are computed from.
+func WithMethodGauges() Option {
+	return func(m *MetricTags) {
+		m.methodGauges = true
+	}
+}
+
+// initMethods registers the gauges of the methods of structPtr matching
+// MethodPrefix with names prefixed with prefix, if any.
+func (m *MetricTags) initMethods(prefix string, structPtr interface{}) {
+	root := rootBranch(prefix, m.separator)
+	v := reflect.ValueOf(structPtr)
+	for i := 0; i < v.NumMethod(); i++ {
+		name := v.Type().Method(i).Name
+		if !strings.HasPrefix(name, MethodPrefix) || name == MethodPrefix {
+			continue
+		}
+		b := root.child(m, DerivedName(strings.TrimPrefix(name, MethodPrefix), m.nameCase), nil)
+		m.initFuncGauge(v.Method(i).Interface(), b, "", "", nil)
+	}
+}
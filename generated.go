@@ -0,0 +1,199 @@
+package tagtrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Generated is implemented by metric structs with initializers generated by
+// cmd/tagtricsgen.  NewMetricTags calls TagtricsInit instead of traversing
+// the struct with reflection.
+type Generated interface {
+	// TagtricsInit initializes every metric of the struct with b.
+	TagtricsInit(b *Binder)
+}
+
+// Binder creates and registers metrics for initializers generated by
+// cmd/tagtricsgen following the same rules as the reflection based
+// traversal of NewMetricTags.
+type Binder struct {
+	m *MetricTags
+	// prefix is the prefix of the names of the struct's top level fields.
+	prefix string
+	// sep overrides the separator of the MetricTags if set.
+	sep string
+	// sink is the sink the metrics are routed to with the "sink" struct
+	// tag, if any.
+	sink string
+	// bucket is the name of the map value the Binder was returned for by
+	// Bucket, whose family is used for the names beneath it.
+	bucket, family string
+	// labels are the labels of the map keys above.
+	labels map[string]string
+	// keyLabel is the label the keys passed to Bucket are exported as with
+	// the "label" tag option, if any.
+	keyLabel string
+	// err receives the first error of an Initializer or a name.
+	err *error
+	// ends holds the lengths of the names above every name returned by
+	// Name and Bucket, shared by the copies of the Binder.
+	ends map[string][]int
+}
+
+// Name joins prefix and name with the separator of the MetricTags.  An empty
+// prefix is used for the top level fields of the struct whose names only get
+// the prefix the struct was registered with, if any.  A name containing the
+// separator is reported by the Err method of the MetricTags like it is for
+// the structs traversed with reflection, the metric still being created.
+func (b *Binder) Name(prefix, name string) string {
+	if prefix == "" {
+		prefix = b.prefix
+	}
+	sep := b.separator()
+	if strings.Contains(name, sep) && *b.err == nil {
+		*b.err = &nameError{fmt.Errorf("tagtrics: metric name %q beneath %s contains the separator %q", name, describeName(prefix), sep)}
+	}
+	joined := JoinName(prefix, sep, name)
+	b.ends[joined] = extendEnds(b.endsOf(prefix), prefix)
+	return joined
+}
+
+// endsOf returns the lengths of the names above name, a name returned by
+// Name or Bucket or the prefix of the struct.
+func (b *Binder) endsOf(name string) []int {
+	if ends, ok := b.ends[name]; ok {
+		return ends
+	}
+	return nameEnds(name, b.m.separator)
+}
+
+// Derived joins prefix like Name with the metric name derived from the name
+// of a field without a name in its "metric" tag according to the NameCase
+// of the MetricTags.
+func (b *Binder) Derived(prefix, fieldName string) string {
+	return b.Name(prefix, DerivedName(fieldName, b.m.nameCase))
+}
+
+// WithSeparator returns a Binder using sep as separator for the fields of a
+// branch with the "separator" tag option.
+func (b *Binder) WithSeparator(sep string) *Binder {
+	c := *b
+	c.sep = sep
+	return &c
+}
+
+// WithSink returns a Binder routing the metrics of a branch with the "sink"
+// struct tag to the sink named sink.
+func (b *Binder) WithSink(sink string) *Binder {
+	c := *b
+	c.sink = sink
+	return &c
+}
+
+// WithLabel returns a Binder exporting the keys passed to Bucket as label
+// for a map field with the "label" tag option.
+func (b *Binder) WithLabel(label string) *Binder {
+	c := *b
+	c.keyLabel = label
+	return &c
+}
+
+// Bucket returns the Binder and the name prefix of value stored under key in
+// the map field named name, honoring the Bucket interface of value and the
+// label of WithLabel.
+func (b *Binder) Bucket(name, key string, value interface{}) (*Binder, string) {
+	br := branch{name: name, family: b.familyOf(name), sep: b.separator(), labels: b.labels, sink: b.sink, keyLabel: b.keyLabel, ends: b.endsOf(name)}
+	br = br.bucket(key, value)
+	b.ends[br.name] = br.ends
+	b.m.markElement(value)
+	c := *b
+	c.bucket, c.family, c.labels, c.keyLabel = br.name, br.family, br.labels, ""
+	return &c, br.name
+}
+
+// NewElement records value, an element of a slice field, as initialized and
+// reports whether it was not initialized before.
+func (b *Binder) NewElement(value interface{}) bool {
+	return !b.m.markElement(value)
+}
+
+// familyOf returns the family of the metric named name, which is name
+// unless it is beneath a bucket with labels.
+func (b *Binder) familyOf(name string) string {
+	if b.bucket == "" || !strings.HasPrefix(name, b.bucket) {
+		return name
+	}
+	return b.family + name[len(b.bucket):]
+}
+
+// branch returns the branch of the field named name with the tag options
+// opts.
+func (b *Binder) branch(enabled bool, name string, opts tagOptions) branch {
+	sep := b.separator()
+	if s := opts["separator"]; s != "" {
+		sep = s
+	}
+	return branch{name: name, family: b.familyOf(name), sep: sep, enabled: enabled, labels: b.labels, sink: b.sink, keyLabel: opts["label"], ends: b.endsOf(name)}
+}
+
+// separator returns the separator in effect.
+func (b *Binder) separator() string {
+	if b.sep != "" {
+		return b.sep
+	}
+	return b.m.separator
+}
+
+// Enabled reports whether a field with the given "metric" tag is enabled
+// according to its "optional" feature flag.
+func (b *Binder) Enabled(tag string) bool {
+	_, opts := parseTag(tag)
+	return b.m.flagEnabled(opts)
+}
+
+// Metric creates the metric for a field of the given type, formatted like
+// reflect.Type.String(), with the given "metric", "help" and "unit" tags.
+// It is registered as name unless enabled is false.  It returns nil for
+// unsupported types.
+func (b *Binder) Metric(enabled bool, name, typeName, tag, help, unit string) interface{} {
+	_, opts := parseTag(tag)
+	return b.m.initMetric(typeName, b.branch(enabled, name, opts), help, unit, opts)
+}
+
+// Init calls the InitMetrics method of a field or map value implementing
+// Initializer for the metrics named name unless enabled is false.  The
+// first error is returned by the Err method of the MetricTags.
+func (b *Binder) Init(enabled bool, in Initializer, name string) {
+	if err := b.m.initCustom(in, b.branch(enabled, name, nil)); err != nil && *b.err == nil {
+		*b.err = err
+	}
+}
+
+// Typed initializes a generic metric such as a Gauge[int64] or a LazyMap,
+// or registers the gauge of a func() int64 or func() float64 field, given a
+// pointer to it.
+func (b *Binder) Typed(enabled bool, metric interface{}, name, tag, help, unit string) {
+	_, opts := parseTag(tag)
+	br := b.branch(enabled, name, opts)
+	if l, ok := metric.(lazyMetric); ok {
+		l.initLazy(b.m, br)
+		return
+	}
+	t, ok := metric.(typedMetric)
+	if !ok {
+		b.m.initFuncGauge(metric, br, help, unit, opts)
+		return
+	}
+	if enabled {
+		t.initTyped(b.m, br.meta("", help, unit, opts), br.sep)
+	}
+}
+
+// JoinName joins prefix and name with sep the way metric names are built.
+// An empty prefix returns name as is.
+func JoinName(prefix, sep, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + sep + name
+}
@@ -0,0 +1,219 @@
+package tagtrics
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// HDR histogram defaults used when the tag options are not set.  The maximum
+// is one hour in nanoseconds so timers work out of the box.
+const (
+	DefaultHDRMin     = 1
+	DefaultHDRMax     = int64(3600 * 1e9)
+	DefaultHDRSigFigs = 3
+)
+
+// hdrHistogram is a metrics.Histogram backed by a High Dynamic Range
+// histogram.  Unlike the uniform sample it records every value, so high
+// percentiles are accurate to the configured number of significant figures
+// no matter how many values are recorded.  Values above max are clamped to
+// max and negative values to zero.  min is the resolution of the smallest
+// values rather than a bound, values below it still being recorded.
+type hdrHistogram struct {
+	histogramStats
+
+	lowest, highest int64
+	sigFigs         int
+
+	unitMagnitude               uint
+	subBucketHalfCountMagnitude uint
+	subBucketCount              int64
+	subBucketHalfCount          int64
+	subBucketMask               int64
+
+	counts []int64
+}
+
+// newHDRHistogram creates an HDR histogram tracking values between lowest and
+// highest with sigFigs significant figures of precision.
+func newHDRHistogram(lowest, highest int64, sigFigs int) (*hdrHistogram, error) {
+	if lowest < 1 {
+		return nil, fmt.Errorf("hdr min must be at least 1, got %d", lowest)
+	}
+	if highest < 2*lowest {
+		return nil, fmt.Errorf("hdr max must be at least twice min, got %d", highest)
+	}
+	if sigFigs < 1 || sigFigs > 5 {
+		return nil, fmt.Errorf("hdr sigfigs must be between 1 and 5, got %d", sigFigs)
+	}
+	h := &hdrHistogram{lowest: lowest, highest: highest, sigFigs: sigFigs}
+	largestSingleUnit := 2 * int64(math.Pow10(sigFigs))
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(float64(largestSingleUnit))))
+	h.subBucketHalfCountMagnitude = subBucketCountMagnitude - 1
+	h.unitMagnitude = uint(math.Floor(math.Log2(float64(lowest))))
+	h.subBucketCount = 1 << subBucketCountMagnitude
+	h.subBucketHalfCount = h.subBucketCount / 2
+	h.subBucketMask = (h.subBucketCount - 1) << h.unitMagnitude
+
+	// Find how many buckets are needed to cover highest.
+	smallestUntrackable := h.subBucketCount << h.unitMagnitude
+	bucketCount := int64(1)
+	for smallestUntrackable <= highest {
+		if smallestUntrackable > math.MaxInt64/2 {
+			bucketCount++
+			break
+		}
+		smallestUntrackable <<= 1
+		bucketCount++
+	}
+	h.counts = make([]int64, (bucketCount+1)*h.subBucketHalfCount)
+	h.reset()
+	return h, nil
+}
+
+// reset clears all recorded values.  The caller must hold the mutex.
+func (h *hdrHistogram) reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.clearStats()
+}
+
+func (h *hdrHistogram) bucketIndex(v int64) int64 {
+	pow2Ceiling := int64(64 - bits.LeadingZeros64(uint64(v|h.subBucketMask)))
+	return pow2Ceiling - int64(h.unitMagnitude) - int64(h.subBucketHalfCountMagnitude+1)
+}
+
+func (h *hdrHistogram) countsIndex(v int64) int64 {
+	bucketIdx := h.bucketIndex(v)
+	subBucketIdx := v >> uint(bucketIdx+int64(h.unitMagnitude))
+	return (bucketIdx+1)<<h.subBucketHalfCountMagnitude + (subBucketIdx - h.subBucketHalfCount)
+}
+
+// highestEquivalentValue returns the largest value that is recorded in the
+// same slot as counts index i.
+func (h *hdrHistogram) highestEquivalentValue(i int64) int64 {
+	bucketIdx := (i >> h.subBucketHalfCountMagnitude) - 1
+	subBucketIdx := (i & (h.subBucketHalfCount - 1)) + h.subBucketHalfCount
+	if bucketIdx < 0 {
+		subBucketIdx -= h.subBucketHalfCount
+		bucketIdx = 0
+	}
+	lowest := subBucketIdx << uint(bucketIdx+int64(h.unitMagnitude))
+	return lowest + (int64(1) << uint(bucketIdx+int64(h.unitMagnitude))) - 1
+}
+
+// Clear clears the histogram.
+func (h *hdrHistogram) Clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checkWritable("Clear", "hdrHistogram")
+	h.reset()
+}
+
+// Percentile returns the value at percentile p which is between 0 and 1.
+func (h *hdrHistogram) Percentile(p float64) float64 {
+	return h.Percentiles([]float64{p})[0]
+}
+
+// Percentiles returns the values at each of the percentiles ps which are
+// between 0 and 1.
+func (h *hdrHistogram) Percentiles(ps []float64) []float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	scores := make([]float64, len(ps))
+	if h.totalCount == 0 {
+		return scores
+	}
+	for i, p := range ps {
+		target := int64(math.Ceil(math.Min(p, 1) * float64(h.totalCount)))
+		if target < 1 {
+			target = 1
+		}
+		var seen int64
+		for idx, c := range h.counts {
+			seen += c
+			if seen >= target {
+				v := h.highestEquivalentValue(int64(idx))
+				if v > h.max {
+					v = h.max
+				}
+				scores[i] = float64(v)
+				break
+			}
+		}
+	}
+	return scores
+}
+
+// Sample returns a metrics.Sample view of the histogram.
+func (h *hdrHistogram) Sample() metrics.Sample {
+	return hdrSample{h}
+}
+
+// Snapshot returns a read-only copy of the histogram.
+func (h *hdrHistogram) Snapshot() metrics.Histogram {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s := &hdrHistogram{
+		histogramStats:              h.frozenStats(),
+		lowest:                      h.lowest,
+		highest:                     h.highest,
+		sigFigs:                     h.sigFigs,
+		unitMagnitude:               h.unitMagnitude,
+		subBucketHalfCountMagnitude: h.subBucketHalfCountMagnitude,
+		subBucketCount:              h.subBucketCount,
+		subBucketHalfCount:          h.subBucketHalfCount,
+		subBucketMask:               h.subBucketMask,
+		counts:                      make([]int64, len(h.counts)),
+	}
+	copy(s.counts, h.counts)
+	return s
+}
+
+// Update records v.
+func (h *hdrHistogram) Update(v int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checkWritable("Update", "hdrHistogram")
+	if v < 0 {
+		v = 0
+	} else if v > h.highest {
+		v = h.highest
+	}
+	h.counts[h.countsIndex(v)]++
+	h.record(v)
+}
+
+// hdrSample adapts hdrHistogram to metrics.Sample.
+type hdrSample struct {
+	*hdrHistogram
+}
+
+// Size returns the number of recorded values.
+func (s hdrSample) Size() int {
+	return int(s.Count())
+}
+
+// Snapshot returns a go-metrics sample snapshot holding Values.
+func (s hdrSample) Snapshot() metrics.Sample {
+	return metrics.NewSampleSnapshot(s.Count(), s.Values())
+}
+
+// Values returns the highest equivalent value of every non-empty slot.  An HDR
+// histogram does not keep individual values so this is an approximation of
+// their distribution, not a list of everything recorded.
+func (s hdrSample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var values []int64
+	for i, c := range s.counts {
+		if c > 0 {
+			values = append(values, s.highestEquivalentValue(int64(i)))
+		}
+	}
+	return values
+}
@@ -0,0 +1,55 @@
+package tagtrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestHDRHistogram(t *testing.T) {
+	h, err := newHDRHistogram(1, 3600*1e9, 3)
+	if err != nil {
+		t.Fatalf("failed to create histogram: %v", err)
+	}
+	for i := int64(1); i <= 100000; i++ {
+		h.Update(i * 1000)
+	}
+	if h.Count() != 100000 || h.Min() != 1000 || h.Max() != 100000000 {
+		t.Fatalf("unexpected count/min/max: %d %d %d", h.Count(), h.Min(), h.Max())
+	}
+	ps := h.Percentiles([]float64{0.5, 0.99, 0.999, 1})
+	want := []float64{50000000, 99000000, 99900000, 100000000}
+	for i := range want {
+		if math.Abs(ps[i]-want[i])/want[i] > 0.001 {
+			t.Fatalf("percentile %d: got %f want %f", i, ps[i], want[i])
+		}
+	}
+	s := h.Snapshot()
+	h.Update(1)
+	if s.Count() != 100000 {
+		t.Fatalf("snapshot changed after update")
+	}
+	if _, err := newHDRHistogram(0, 10, 3); err == nil {
+		t.Fatalf("expected error for min 0")
+	}
+}
+
+type hdrMetrics struct {
+	Latency metrics.Timer     `metric:"latency,sample=hdr,min=1000,sigfigs=2"`
+	Size    metrics.Histogram `metric:"size,sample=hdr"`
+}
+
+func TestHDRTag(t *testing.T) {
+	m := &hdrMetrics{}
+	NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	if _, ok := m.Size.(*hdrHistogram); !ok {
+		t.Fatalf("size is not an HDR histogram: %T", m.Size)
+	}
+	m.Latency.Update(time.Second)
+	s := m.Latency.Snapshot()
+	if s.Count() != 1 || math.Abs(s.Percentile(0.99)-1e9)/1e9 > 0.01 {
+		t.Fatalf("unexpected timer snapshot: %d %f", s.Count(), s.Percentile(0.99))
+	}
+}
@@ -0,0 +1,55 @@
+package tagtrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// flushHook is a FlushFunc added with AddFlushHook along with its self
+// metrics.
+type flushHook struct {
+	name     string
+	f        FlushFunc
+	duration metrics.Timer
+	errors   metrics.Counter
+}
+
+// AddFlushHook adds a FlushFunc called with the snapshot of every flush
+// after the update handler and the FlushFunc of WithFlushFunc, in the order
+// the hooks are added, so several exporters don't have to be chained in a
+// single function.  A failing hook doesn't stop the following ones.  The
+// time every hook takes and its failures are counted in the
+// "tagtrics.flush.hooks.<name>.duration" and
+// "tagtrics.flush.hooks.<name>.errors" self metrics as well as in the
+// metrics of the flush.  It must be called before Run.
+func (m *MetricTags) AddFlushHook(name string, f FlushFunc) {
+	prefix := m.selfPrefix
+	for _, segment := range []string{"flush", "hooks", name} {
+		prefix = JoinName(prefix, m.separator, segment)
+	}
+	h := flushHook{name: name, f: f, duration: metrics.NewTimer(), errors: metrics.NewCounter()}
+	m.register(newMeta(JoinName(prefix, m.separator, "duration"), "timer", "Time spent in the flush hook", "nanoseconds", nil), h.duration)
+	m.register(newMeta(JoinName(prefix, m.separator, "errors"), "counter", "Flush hook calls that failed", "", nil), h.errors)
+	m.hooks = append(m.hooks, h)
+}
+
+// callHooks calls the flush hooks with ctx and s in order and returns the
+// first error.
+func (m *MetricTags) callHooks(ctx context.Context, s *Snapshot) error {
+	var first error
+	for _, h := range m.hooks {
+		start := time.Now()
+		err := h.f(ctx, s)
+		h.duration.UpdateSince(start)
+		if err != nil {
+			h.errors.Inc(1)
+			if first == nil {
+				first = fmt.Errorf("tagtrics: flush hook %s: %w", h.name, err)
+			}
+		}
+	}
+	return first
+}
@@ -0,0 +1,45 @@
+package tagtrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFlushHooks(t *testing.T) {
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(&metaMetrics{}, nil, time.Second, r, ".", WithLogger(&recordingLogger{}))
+	var calls []string
+	mTags.AddFlushHook("graphite", func(ctx context.Context, s *Snapshot) error {
+		calls = append(calls, "graphite")
+		return errors.New("backend down")
+	})
+	mTags.AddFlushHook("audit", func(ctx context.Context, s *Snapshot) error {
+		if _, ok := ctx.Deadline(); !ok || s == nil {
+			t.Errorf("expected a deadline and a snapshot")
+		}
+		calls = append(calls, "audit")
+		return nil
+	})
+
+	err := mTags.flushWithin(time.Second)
+	if err == nil || !strings.Contains(err.Error(), "graphite: backend down") {
+		t.Fatalf("expected the error of the graphite hook, got %v", err)
+	}
+	if strings.Join(calls, ",") != "graphite,audit" {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	if c := r.Get("tagtrics.flush.hooks.graphite.errors").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 graphite error, got %d", c)
+	}
+	if c := r.Get("tagtrics.flush.hooks.audit.duration").(metrics.Timer).Count(); c != 1 {
+		t.Fatalf("expected 1 audit duration, got %d", c)
+	}
+	if c := r.Get("tagtrics.flush.errors").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 flush error, got %d", c)
+	}
+}
@@ -0,0 +1,117 @@
+package tagtrics
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handler returns an HTTP handler serving every metric in the format the
+// client asks for in its Accept header so one endpoint serves people,
+// scrapers and scripts: OpenMetrics for "application/openmetrics-text", the
+// Prometheus text format for "text/plain" and otherwise the same JSON
+// format as ToJSON.  The "format" query parameter, one of "json",
+// "prometheus" or "openmetrics", overrides the Accept header, e.g.
+// "/metrics?format=prometheus".  The response is compressed with gzip if
+// the client accepts it.  The metrics routed to named sinks with the "sink"
+// struct tag are left out.  The Prometheus and OpenMetrics formats are
+// served from Scrape and JSON from Snapshot, so in pull-only mode only
+// scrapers end windows, not people and scripts reading the JSON.
+func (m *MetricTags) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer m.self.Snapshot.Serialization.UpdateSince(time.Now())
+		format := negotiate(r)
+		var s *Snapshot
+		if format == "openmetrics" || format == "prometheus" {
+			s = m.Scrape().ForSink("")
+		} else {
+			s = m.Snapshot().ForSink("")
+		}
+		w.Header().Add("Vary", "Accept")
+		switch format {
+		case "openmetrics":
+			w.Header().Set("Content-Type", OpenMetricsContentType)
+			writeCompressed(w, r, s.WriteOpenMetrics)
+		case "prometheus":
+			w.Header().Set("Content-Type", PrometheusContentType)
+			writeCompressed(w, r, s.WritePrometheus)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			writeCompressed(w, r, s.WriteJSON)
+		}
+	})
+}
+
+// formats maps the media types served by Handler to their format.
+var formats = map[string]string{
+	"application/json":             "json",
+	"application/openmetrics-text": "openmetrics",
+	"text/plain":                   "prometheus",
+}
+
+// negotiate returns the format of the response to r: the format query
+// parameter if set, or else the format of the media type with the highest
+// quality in the Accept header, the first one among equals.
+func negotiate(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f
+	}
+	best, quality := "json", 0.0
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		media, params, _ := strings.Cut(accepted, ";")
+		f, ok := formats[strings.TrimSpace(media)]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, _ := strings.Cut(strings.TrimSpace(param), "="); k == "q" {
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		if q > quality {
+			best, quality = f, q
+		}
+	}
+	return best
+}
+
+// OpenMetricsHandler returns an HTTP handler serving every metric in the
+// OpenMetrics text exposition format to be scraped by Prometheus.  The
+// response is compressed with gzip if the client accepts it.  Like Handler
+// it leaves out the metrics routed to named sinks.
+func (m *MetricTags) OpenMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", OpenMetricsContentType)
+		writeCompressed(w, r, m.Scrape().ForSink("").WriteOpenMetrics)
+	})
+}
+
+// writeCompressed writes the response with write, compressed with gzip if
+// the Accept-Encoding header of r allows it.
+func writeCompressed(w http.ResponseWriter, r *http.Request, write func(io.Writer) error) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		write(w)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	zw := gzip.NewWriter(w)
+	write(zw)
+	zw.Close()
+}
+
+// acceptsGzip returns whether the client accepts responses compressed with
+// gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
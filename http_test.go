@@ -0,0 +1,85 @@
+package tagtrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestHandlerGzip(t *testing.T) {
+	m := &struct {
+		Sent metrics.Counter `metric:"sent"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Sent.Inc(3)
+
+	for _, accept := range []string{"", "gzip;q=0", "deflate, gzip"} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		tags.Handler().ServeHTTP(rec, req)
+		body := rec.Body.Bytes()
+		gzipped := rec.Header().Get("Content-Encoding") == "gzip"
+		if gzipped != (accept == "deflate, gzip") {
+			t.Fatalf("Accept-Encoding %q: unexpected Content-Encoding %q", accept, rec.Header().Get("Content-Encoding"))
+		}
+		if gzipped {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("invalid gzip response: %v", err)
+			}
+			body, _ = ioutil.ReadAll(zr)
+		}
+		var data map[string]map[string]float64
+		if err := json.Unmarshal(body, &data); err != nil || data["sent"]["count"] != 3 {
+			t.Fatalf("Accept-Encoding %q: unexpected response %q: %v", accept, body, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tags.Snapshot().WriteJSONGzip(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("invalid gzip output: %v", err)
+	}
+	if out, _ := ioutil.ReadAll(zr); !bytes.Contains(out, []byte(`"sent":{"count":3}`)) {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestHandlerNegotiation(t *testing.T) {
+	m := &struct {
+		Sent  metrics.Counter `metric:"sent" help:"Messages sent"`
+		Build Info            `metric:"build"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Sent.Inc(3)
+
+	for _, c := range []struct {
+		url, accept, contentType, want string
+	}{
+		{"/metrics", "", "application/json", `"sent":{"count":3}`},
+		{"/metrics", "text/html,*/*;q=0.8", "application/json", `"sent":{"count":3}`},
+		{"/metrics", "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1", OpenMetricsContentType, "# TYPE sent counter\n# HELP sent Messages sent\nsent_total 3\n"},
+		{"/metrics", "text/plain", PrometheusContentType, "# TYPE sent_total counter\n# HELP sent_total Messages sent\nsent_total 3\n"},
+		{"/metrics", "text/plain;q=0.2,application/json", "application/json", `"sent":{"count":3}`},
+		{"/metrics?format=prometheus", "application/json", PrometheusContentType, "# TYPE build_info gauge\nbuild_info 1\n"},
+	} {
+		req := httptest.NewRequest("GET", c.url, nil)
+		req.Header.Set("Accept", c.accept)
+		rec := httptest.NewRecorder()
+		tags.Handler().ServeHTTP(rec, req)
+		if ct := rec.Header().Get("Content-Type"); ct != c.contentType || !strings.Contains(rec.Body.String(), c.want) {
+			t.Errorf("%s with Accept %q: unexpected %s response:\n%s", c.url, c.accept, ct, rec.Body)
+		}
+	}
+}
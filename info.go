@@ -0,0 +1,70 @@
+package tagtrics
+
+import (
+	"sync"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// Info is a constant gauge of 1 carrying static string labels such as the
+// version, region or configuration hash of the application, following the
+// Prometheus info metric convention.
+type Info interface {
+	metrics.Gauge
+	// Labels returns a copy of the labels.
+	Labels() map[string]string
+	// Set sets the label key to value.
+	Set(key, value string)
+}
+
+// NewInfo constructs a new Info with the given labels.
+func NewInfo(labels map[string]string) Info {
+	i := &info{labels: make(map[string]string, len(labels))}
+	for k, v := range labels {
+		i.labels[k] = v
+	}
+	return i
+}
+
+// info is the standard implementation of an Info.
+type info struct {
+	mutex  sync.RWMutex
+	labels map[string]string
+	// frozen is set on snapshots which must not be updated.
+	frozen bool
+}
+
+// Labels returns a copy of the labels.
+func (i *info) Labels() map[string]string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	labels := make(map[string]string, len(i.labels))
+	for k, v := range i.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// Set sets the label key to value.
+func (i *info) Set(key, value string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.frozen {
+		panic("Set called on an Info snapshot")
+	}
+	i.labels[key] = value
+}
+
+// Snapshot returns a read-only copy of the info.
+func (i *info) Snapshot() metrics.Gauge {
+	return &info{labels: i.Labels(), frozen: true}
+}
+
+// Update does nothing since the value of an info metric is always 1.  It
+// lets an Info be used wherever a metrics.Gauge is updated.
+func (i *info) Update(int64) {}
+
+// Value returns 1.
+func (i *info) Value() int64 {
+	return 1
+}
@@ -0,0 +1,36 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type infoMetrics struct {
+	Build Info `metric:"build"`
+}
+
+func TestInfo(t *testing.T) {
+	m := &infoMetrics{}
+	mTags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Build.Set("version", "1.2.3")
+	m.Build.Set("region", "us-east")
+
+	s := mTags.Snapshot()
+	m.Build.Set("version", "1.2.4")
+	labels := s.Labels("build")
+	if len(labels) != 2 || labels["version"] != "1.2.3" || labels["region"] != "us-east" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+	m.Build.Update(5)
+	if s.Stats("build")["value"] != 1 || m.Build.Value() != 1 {
+		t.Fatalf("unexpected info value: %v", s.Stats("build"))
+	}
+	if meta, _ := mTags.Metadata("build"); meta.Type != "info" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if s.Labels("tagtrics.flush.errors") != nil {
+		t.Fatalf("unexpected labels on a counter")
+	}
+}
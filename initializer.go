@@ -0,0 +1,113 @@
+package tagtrics
+
+import (
+	"fmt"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// Initializer is implemented by metric structs, or the types of their
+// fields, which register their metrics themselves, e.g. a library exposing
+// a metrics block it wants full control over.  The traversal calls
+// InitMetrics instead of traversing the struct or field, with the metric
+// name of the field, or the prefix of a struct registered with Register,
+// and the registry to register the metrics in.  The metrics registered are
+// unregistered and reset along with the others.  An error stops the
+// traversal and is returned by Err.
+type Initializer interface {
+	InitMetrics(name string, r metrics.Registry) error
+}
+
+// LabeledRegistry is implemented by the registry given to an Initializer.
+// GetOrRegisterLabeled is like GetOrRegister for a metric identified by
+// labels beyond its unique name, e.g. the tags of another metrics library,
+// like the metrics beneath the keys of a map whose Bucket returns labels:
+// family is name without the segments of the labels.
+type LabeledRegistry interface {
+	metrics.Registry
+	GetOrRegisterLabeled(name, family string, labels map[string]string, i interface{}) interface{}
+}
+
+// initRegistry is the registry given to an Initializer.  It records the
+// metadata of the metrics registered through it.
+type initRegistry struct {
+	metrics.Registry
+	m *MetricTags
+	b branch
+}
+
+// Register registers the metric and records its metadata.
+func (r *initRegistry) Register(name string, i interface{}) error {
+	if err := r.Registry.Register(name, i); err != nil {
+		return err
+	}
+	r.record(name, i)
+	return nil
+}
+
+// GetOrRegister returns the metric registered as name, registering i if
+// there is none, and records its metadata.
+func (r *initRegistry) GetOrRegister(name string, i interface{}) interface{} {
+	metric := r.Registry.GetOrRegister(name, i)
+	r.record(name, metric)
+	return metric
+}
+
+// GetOrRegisterLabeled implements LabeledRegistry.
+func (r *initRegistry) GetOrRegisterLabeled(name, family string, labels map[string]string, i interface{}) interface{} {
+	metric := r.Registry.GetOrRegister(name, i)
+	b := r.b
+	if len(labels) > 0 {
+		merged := make(map[string]string, len(b.labels)+len(labels))
+		for k, v := range b.labels {
+			merged[k] = v
+		}
+		for k, v := range labels {
+			merged[k] = v
+		}
+		b.labels = merged
+	}
+	b.family = b.familyOf(family)
+	b.name = name
+	r.m.record(b.meta(metricKind(metric), "", "", nil), metric)
+	return metric
+}
+
+// record records the metadata of the metric registered as name.  The
+// metrics beneath the branch get its family and labels.
+func (r *initRegistry) record(name string, metric interface{}) {
+	b := r.b
+	b.family = b.familyOf(name)
+	b.name = name
+	r.m.record(b.meta(metricKind(metric), "", "", nil), metric)
+}
+
+// metricKind returns the kind of metric in the metadata of a go-metrics
+// metric.
+func metricKind(metric interface{}) string {
+	switch metric.(type) {
+	case metrics.Counter:
+		return "counter"
+	case metrics.Gauge, metrics.GaugeFloat64:
+		return "gauge"
+	case metrics.Histogram:
+		return "histogram"
+	case metrics.Meter:
+		return "meter"
+	case metrics.Timer:
+		return "timer"
+	}
+	return ""
+}
+
+// initCustom calls the InitMetrics method of in for branch b if b is
+// enabled.
+func (m *MetricTags) initCustom(in Initializer, b branch) error {
+	if !b.enabled {
+		return nil
+	}
+	if err := in.InitMetrics(b.name, &initRegistry{Registry: m.registry, m: m, b: b}); err != nil {
+		return fmt.Errorf("tagtrics: failed to initialize %s: %v", describeName(b.name), err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package tagtrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// poolMetrics registers its metrics itself.
+type poolMetrics struct {
+	Conns metrics.Counter
+	fail  bool
+}
+
+func (p *poolMetrics) InitMetrics(name string, r metrics.Registry) error {
+	if p.fail {
+		return errors.New("pool closed")
+	}
+	p.Conns = r.GetOrRegister(name+".conns", metrics.NewCounter).(metrics.Counter)
+	return nil
+}
+
+func (*poolMetrics) BucketName(key string) (string, map[string]string) {
+	return key, map[string]string{"pool": key}
+}
+
+func TestInitializer(t *testing.T) {
+	m := &struct {
+		Main  poolMetrics             `metric:"main"`
+		Pools map[string]*poolMetrics `metric:"pools"`
+		After metrics.Counter         `metric:"after"`
+	}{Pools: map[string]*poolMetrics{"replica": {}}}
+	r := metrics.NewRegistry()
+	tags := NewMetricTags(m, func() {}, time.Second, r, ".")
+	if tags.Err() != nil {
+		t.Fatalf("unexpected error %v", tags.Err())
+	}
+	m.Main.Conns.Inc(2)
+	if r.Get("main.conns").(metrics.Counter).Count() != 2 {
+		t.Fatalf("main.conns not registered by InitMetrics")
+	}
+	meta, ok := tags.Metadata("pools.replica.conns")
+	if !ok || meta.Type != "counter" || meta.Family != "pools.conns" || meta.Labels["pool"] != "replica" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	tags.Unregister()
+	if r.Get("main.conns") != nil {
+		t.Fatalf("main.conns not unregistered")
+	}
+
+	m.Main.fail = true
+	tags = NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	if err := tags.Err(); err == nil || err.Error() != `tagtrics: failed to initialize "main": pool closed` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+// taggedMetrics registers a counter per shard with a label.
+type taggedMetrics struct{}
+
+func (taggedMetrics) InitMetrics(name string, r metrics.Registry) error {
+	lr := r.(LabeledRegistry)
+	lr.GetOrRegisterLabeled(name+".hits.a", name+".hits", map[string]string{"shard": "a"}, metrics.NewCounter())
+	return nil
+}
+
+func TestLabeledRegistry(t *testing.T) {
+	m := &struct {
+		Pools map[string]*struct {
+			Shards taggedMetrics `metric:"shards"`
+		} `metric:"pools"`
+	}{}
+	m.Pools = map[string]*struct {
+		Shards taggedMetrics `metric:"shards"`
+	}{"main": {}}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	meta, ok := tags.Metadata("pools.main.shards.hits.a")
+	if !ok || meta.Type != "counter" || meta.Family != "pools.main.shards.hits" || meta.Labels["shard"] != "a" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+}
@@ -0,0 +1,50 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// doublingCounter is a custom Counter counting every increment twice.
+type doublingCounter struct {
+	metrics.Counter
+}
+
+func (c doublingCounter) Inc(n int64) {
+	c.Counter.Inc(2 * n)
+}
+
+func TestInterfaceFields(t *testing.T) {
+	r := metrics.NewRegistry()
+	counter := doublingCounter{metrics.NewCounter()}
+	ratio := metrics.NewGaugeFloat64()
+	m := &struct {
+		Sent    metrics.Counter      `metric:"sent" help:"Messages sent"`
+		Wait    metrics.Timer        `metric:"wait"`
+		Ratio   metrics.GaugeFloat64 `metric:"ratio"`
+		Created metrics.Counter      `metric:"created"`
+	}{Sent: counter, Wait: metrics.NilTimer{}, Ratio: ratio}
+	tags := NewMetricTags(m, func() {}, time.Second, r, ".")
+
+	if m.Sent != counter || m.Wait != (metrics.NilTimer{}) || m.Ratio != ratio {
+		t.Fatalf("the implementations held were replaced")
+	}
+	if r.Get("sent") != counter || r.Get("wait") != (metrics.NilTimer{}) || r.Get("ratio") != ratio {
+		t.Fatalf("the implementations held weren't registered")
+	}
+	if _, ok := m.Created.(metrics.NilCounter); ok || m.Created == nil || r.Get("created") != m.Created {
+		t.Fatalf("nil field not initialized")
+	}
+	m.Sent.Inc(1)
+	if c := tags.Snapshot().Stats("sent")["count"]; c != 2 {
+		t.Fatalf("expected the custom counter to count 2, got %f", c)
+	}
+	if meta, ok := tags.Metadata("sent"); !ok || meta.Type != "counter" || meta.Help != "Messages sent" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if meta, ok := tags.Metadata("ratio"); !ok || meta.Type != "gauge" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+}
@@ -0,0 +1,165 @@
+// Code generated by "tagtricsgen -type=AppMetrics"; DO NOT EDIT.
+
+package gentest
+
+import (
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+// TagtricsInit initializes the metrics of m without reflection.
+func (m *AppMetrics) TagtricsInit(b *tagtrics.Binder) {
+	tagtricsInitAppMetrics(b, m, "", true)
+}
+
+// TagtricsVisit calls f with every metric field of m and its name
+// built with sep.
+func (m *AppMetrics) TagtricsVisit(sep string, f func(name string, metric interface{})) {
+	tagtricsVisitAppMetrics(m, "", sep, f)
+}
+
+func tagtricsInitAppMetrics(b *tagtrics.Binder, m *AppMetrics, prefix string, enabled bool) {
+	{
+		prefix1 := b.Name(prefix, "http")
+		enabled1 := enabled
+		b := b.WithSeparator("_")
+		m.HTTP.Latency = b.Metric(enabled1, b.Name(prefix1, "latency"), "metrics.Timer", "latency,percentiles=50;99", "Request latency", "nanoseconds").(metrics.Timer)
+		m.HTTP.Requests = b.Metric(enabled1, b.Name(prefix1, "requests"), "metrics.Counter", "requests,rate", "", "").(metrics.Counter)
+	}
+	{
+		prefix2 := b.Name(prefix, "debug")
+		enabled2 := enabled
+		b := b.WithSink("debug")
+		m.Debug.Allocs = b.Metric(enabled2, b.Name(prefix2, "allocs"), "metrics.Counter", "allocs", "", "").(metrics.Counter)
+	}
+	{
+		prefix3 := b.Name(prefix, "beta")
+		enabled3 := enabled && b.Enabled("beta,optional=beta")
+		m.Beta.Calls = b.Metric(enabled3, b.Name(prefix3, "calls"), "metrics.Meter", "calls", "", "").(metrics.Meter)
+	}
+	b.Typed(enabled, &m.Depth, b.Derived(prefix, "Depth"), "", "", "")
+	m.State = b.Metric(enabled, b.Name(prefix, "state"), "tagtrics.StateGauge", "state,states=idle;busy", "", "").(tagtrics.StateGauge)
+	tagtricsInitQueueMetrics(b, &m.Queue, b.Name(prefix, "queue"), enabled)
+	for k, v := range m.Services {
+		if v != nil {
+			bk, pk := b.WithSeparator("_").WithLabel("service").Bucket(b.Name(prefix, "services"), k, v)
+			tagtricsInitServiceMetrics(bk, v, pk, enabled)
+		}
+	}
+	for k, v := range m.Routes {
+		if v != nil {
+			bk, pk := b.Bucket(b.Name(prefix, "routes"), k, v)
+			tagtricsInitRouteMetrics(bk, v, pk, enabled)
+		}
+	}
+	for k, v := range m.Regions {
+		if v != nil {
+			bk, pk := b.Bucket(b.Name(prefix, "regions"), k.String(), v)
+			tagtricsInitServiceMetrics(bk, v, pk, enabled)
+		}
+	}
+	b.Typed(enabled, &m.Backlog, b.Name(prefix, "backlog"), "backlog", "", "")
+	b.Typed(enabled, &m.LastSync, b.Name(prefix, "last_sync"), "last_sync", "", "")
+	b.Init(enabled, &m.Pool, b.Name(prefix, "pool"))
+	for i := range m.Shards {
+		tagtricsInitShardMetrics(b, &m.Shards[i], b.Name(b.Name(prefix, "shard"), tagtrics.ElementName("shard,index=hex", i, len(m.Shards))), enabled)
+	}
+	for i := range m.Workers {
+		m.Workers[i] = b.Metric(enabled, b.Name(b.Name(prefix, "workers"), tagtrics.ElementName("workers,names=reader", i, len(m.Workers))), "metrics.Counter", "workers,names=reader", "", "").(metrics.Counter)
+	}
+	for i, v := range m.Consumers {
+		if v != nil && b.NewElement(v) {
+			tagtricsInitConsumerMetrics(b, v, b.Name(b.Name(prefix, "consumers"), tagtrics.ElementName("consumers", i, len(m.Consumers))), enabled)
+		}
+	}
+	b.Typed(enabled, &m.Customers, b.Name(prefix, "customers"), "customers,label=customer", "", "")
+}
+
+func tagtricsVisitAppMetrics(m *AppMetrics, prefix, sep string, f func(name string, metric interface{})) {
+	{
+		prefix1 := tagtrics.JoinName(prefix, sep, "http")
+		sep := "_"
+		f(tagtrics.JoinName(prefix1, sep, "latency"), m.HTTP.Latency)
+		f(tagtrics.JoinName(prefix1, sep, "requests"), m.HTTP.Requests)
+	}
+	{
+		prefix2 := tagtrics.JoinName(prefix, sep, "debug")
+		f(tagtrics.JoinName(prefix2, sep, "allocs"), m.Debug.Allocs)
+	}
+	{
+		prefix3 := tagtrics.JoinName(prefix, sep, "beta")
+		f(tagtrics.JoinName(prefix3, sep, "calls"), m.Beta.Calls)
+	}
+	f(tagtrics.JoinName(prefix, sep, "depth"), &m.Depth)
+	f(tagtrics.JoinName(prefix, sep, "state"), m.State)
+	tagtricsVisitQueueMetrics(&m.Queue, tagtrics.JoinName(prefix, sep, "queue"), sep, f)
+	for k, v := range m.Services {
+		if v != nil {
+			tagtricsVisitServiceMetrics(v, tagtrics.JoinName(tagtrics.JoinName(prefix, sep, "services"), "_", tagtrics.BucketKey(v, k)), "_", f)
+		}
+	}
+	for k, v := range m.Routes {
+		if v != nil {
+			tagtricsVisitRouteMetrics(v, tagtrics.JoinName(tagtrics.JoinName(prefix, sep, "routes"), sep, tagtrics.BucketKey(v, k)), sep, f)
+		}
+	}
+	for k, v := range m.Regions {
+		if v != nil {
+			tagtricsVisitServiceMetrics(v, tagtrics.JoinName(tagtrics.JoinName(prefix, sep, "regions"), sep, tagtrics.BucketKey(v, k.String())), sep, f)
+		}
+	}
+	f(tagtrics.JoinName(prefix, sep, "backlog"), &m.Backlog)
+	f(tagtrics.JoinName(prefix, sep, "last_sync"), &m.LastSync)
+	for i := range m.Shards {
+		tagtricsVisitShardMetrics(&m.Shards[i], tagtrics.JoinName(tagtrics.JoinName(prefix, sep, "shard"), sep, tagtrics.ElementName("shard,index=hex", i, len(m.Shards))), sep, f)
+	}
+	for i := range m.Workers {
+		f(tagtrics.JoinName(tagtrics.JoinName(prefix, sep, "workers"), sep, tagtrics.ElementName("workers,names=reader", i, len(m.Workers))), m.Workers[i])
+	}
+	for i, v := range m.Consumers {
+		if v != nil {
+			tagtricsVisitConsumerMetrics(v, tagtrics.JoinName(tagtrics.JoinName(prefix, sep, "consumers"), sep, tagtrics.ElementName("consumers", i, len(m.Consumers))), sep, f)
+		}
+	}
+	f(tagtrics.JoinName(prefix, sep, "customers"), &m.Customers)
+}
+
+func tagtricsInitQueueMetrics(b *tagtrics.Binder, m *QueueMetrics, prefix string, enabled bool) {
+	m.Size = b.Metric(enabled, b.Name(prefix, "size"), "metrics.Histogram", "size", "", "").(metrics.Histogram)
+}
+
+func tagtricsVisitQueueMetrics(m *QueueMetrics, prefix, sep string, f func(name string, metric interface{})) {
+	f(tagtrics.JoinName(prefix, sep, "size"), m.Size)
+}
+
+func tagtricsInitServiceMetrics(b *tagtrics.Binder, m *ServiceMetrics, prefix string, enabled bool) {
+	m.Errors = b.Metric(enabled, b.Name(prefix, "errors"), "metrics.Counter", "errors", "", "").(metrics.Counter)
+}
+
+func tagtricsVisitServiceMetrics(m *ServiceMetrics, prefix, sep string, f func(name string, metric interface{})) {
+	f(tagtrics.JoinName(prefix, sep, "errors"), m.Errors)
+}
+
+func tagtricsInitRouteMetrics(b *tagtrics.Binder, m *RouteMetrics, prefix string, enabled bool) {
+	m.Hits = b.Metric(enabled, b.Name(prefix, "hits"), "metrics.Counter", "hits", "", "").(metrics.Counter)
+}
+
+func tagtricsVisitRouteMetrics(m *RouteMetrics, prefix, sep string, f func(name string, metric interface{})) {
+	f(tagtrics.JoinName(prefix, sep, "hits"), m.Hits)
+}
+
+func tagtricsInitShardMetrics(b *tagtrics.Binder, m *ShardMetrics, prefix string, enabled bool) {
+	m.Hits = b.Metric(enabled, b.Name(prefix, "hits"), "metrics.Counter", "hits", "", "").(metrics.Counter)
+}
+
+func tagtricsVisitShardMetrics(m *ShardMetrics, prefix, sep string, f func(name string, metric interface{})) {
+	f(tagtrics.JoinName(prefix, sep, "hits"), m.Hits)
+}
+
+func tagtricsInitConsumerMetrics(b *tagtrics.Binder, m *ConsumerMetrics, prefix string, enabled bool) {
+	m.Lag = b.Metric(enabled, b.Name(prefix, "lag"), "metrics.Gauge", "lag", "", "").(metrics.Gauge)
+}
+
+func tagtricsVisitConsumerMetrics(m *ConsumerMetrics, prefix, sep string, f func(name string, metric interface{})) {
+	f(tagtrics.JoinName(prefix, sep, "lag"), m.Lag)
+}
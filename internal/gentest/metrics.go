@@ -0,0 +1,90 @@
+// Package gentest holds metric structs with initializers generated by
+// tagtricsgen to check they behave like the reflection based traversal.
+package gentest
+
+import (
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+//go:generate go run ../../cmd/tagtricsgen -type=AppMetrics
+
+// AppMetrics uses every kind of field tagtricsgen supports.
+type AppMetrics struct {
+	HTTP struct {
+		Latency  metrics.Timer   `metric:"latency,percentiles=50;99" help:"Request latency" unit:"nanoseconds"`
+		Requests metrics.Counter `metric:"requests,rate"`
+	} `metric:"http,separator=_"`
+	Debug struct {
+		Allocs metrics.Counter `metric:"allocs"`
+	} `metric:"debug" sink:"debug"`
+	Beta struct {
+		Calls metrics.Meter `metric:"calls"`
+	} `metric:"beta,optional=beta"`
+	Depth     tagtrics.Gauge[int64]
+	State     tagtrics.StateGauge            `metric:"state,states=idle;busy"`
+	Queue     QueueMetrics                   `metric:"queue"`
+	Services  map[string]*ServiceMetrics     `metric:"services,separator=_,label=service"`
+	Routes    map[string]*RouteMetrics       `metric:"routes"`
+	Regions   map[Region]*ServiceMetrics     `metric:"regions"`
+	Backlog   func() int64                   `metric:"backlog"`
+	LastSync  time.Time                      `metric:"last_sync"`
+	Pool      PoolMetrics                    `metric:"pool"`
+	Shards    [2]ShardMetrics                `metric:"shard,index=hex"`
+	Workers   [2]metrics.Counter             `metric:"workers,names=reader"`
+	Consumers []*ConsumerMetrics             `metric:"consumers"`
+	Customers tagtrics.LazyMap[RouteMetrics] `metric:"customers,label=customer"`
+	// Timeout is configuration and is not a metric.
+	Timeout int
+}
+
+// QueueMetrics is a named struct used by value.
+type QueueMetrics struct {
+	Size metrics.Histogram `metric:"size"`
+}
+
+// ServiceMetrics is used as a map value.
+type ServiceMetrics struct {
+	Errors metrics.Counter `metric:"errors"`
+}
+
+// Region is a map key implementing fmt.Stringer.
+type Region int
+
+// String implements fmt.Stringer.
+func (r Region) String() string {
+	return [...]string{"us-east", "eu-west"}[r]
+}
+
+// RouteMetrics is a map value exporting its key as a label.
+type RouteMetrics struct {
+	Hits metrics.Counter `metric:"hits"`
+}
+
+// BucketName implements tagtrics.Bucket.
+func (*RouteMetrics) BucketName(key string) (string, map[string]string) {
+	return "route_" + key, map[string]string{"route": key}
+}
+
+// ShardMetrics is used as an array element.
+type ShardMetrics struct {
+	Hits metrics.Counter `metric:"hits"`
+}
+
+// ConsumerMetrics is used as a slice element.
+type ConsumerMetrics struct {
+	Lag metrics.Gauge `metric:"lag"`
+}
+
+// PoolMetrics registers its metrics itself.
+type PoolMetrics struct {
+	Conns metrics.Counter
+}
+
+// InitMetrics implements tagtrics.Initializer.
+func (p *PoolMetrics) InitMetrics(name string, r metrics.Registry) error {
+	p.Conns = metrics.NewCounter()
+	return r.Register(name+".conns", p.Conns)
+}
@@ -0,0 +1,110 @@
+package gentest
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+// reflected has the fields of AppMetrics without the generated methods so it
+// is initialized with reflection.
+type reflected AppMetrics
+
+func newMetrics() *AppMetrics {
+	return &AppMetrics{
+		Services:  map[string]*ServiceMetrics{"mysql": {}, "redis": {}},
+		Routes:    map[string]*RouteMetrics{"search": {}},
+		Regions:   map[Region]*ServiceMetrics{1: {}},
+		Consumers: []*ConsumerMetrics{{}, nil, {}},
+	}
+}
+
+func registered(r metrics.Registry) []string {
+	var names []string
+	r.Each(func(name string, _ interface{}) {
+		names = append(names, name)
+	})
+	sort.Strings(names)
+	return names
+}
+
+func TestGenerated(t *testing.T) {
+	flags := tagtrics.WithFlagResolver(func(string) bool { return true })
+	genRegistry, refRegistry := metrics.NewRegistry(), metrics.NewRegistry()
+	gen, ref := newMetrics(), newMetrics()
+	genTags := tagtrics.NewMetricTags(gen, func() {}, time.Second, genRegistry, ".", flags)
+	refTags := tagtrics.NewMetricTags((*reflected)(ref), func() {}, time.Second, refRegistry, ".", flags)
+
+	if g, r := registered(genRegistry), registered(refRegistry); !reflect.DeepEqual(g, r) {
+		t.Fatalf("generated initializer registered %v, reflection %v", g, r)
+	}
+	for _, name := range registered(refRegistry) {
+		g, _ := genTags.Metadata(name)
+		r, _ := refTags.Metadata(name)
+		if !reflect.DeepEqual(g, r) {
+			t.Fatalf("metadata of %s differs: %+v vs %+v", name, g, r)
+		}
+	}
+
+	var visited []string
+	gen.TagtricsVisit(".", func(name string, metric interface{}) {
+		visited = append(visited, name)
+	})
+	sort.Strings(visited)
+	want := []string{"backlog", "beta.calls", "consumers.0.lag", "consumers.2.lag", "customers", "debug.allocs", "depth", "http_latency", "http_requests", "last_sync", "queue.size",
+		"regions.eu-west.errors", "routes.route_search.hits", "services_mysql_errors", "services_redis_errors", "shard.00.hits", "shard.01.hits",
+		"state", "workers.1", "workers.reader"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Fatalf("visited %v, want %v", visited, want)
+	}
+	meta, _ := genTags.Metadata("services_mysql_errors")
+	if meta.Family != "services_errors" || meta.Labels["service"] != "mysql" {
+		t.Fatalf("labeled bucket metadata %+v", meta)
+	}
+	meta, _ = genTags.Metadata("routes.route_search.hits")
+	if meta.Family != "routes.hits" || meta.Labels["route"] != "search" {
+		t.Fatalf("bucket metadata %+v", meta)
+	}
+	if got := genTags.Snapshot().WithPrefix("http").Names(); !reflect.DeepEqual(got, []string{"http_latency", "http_requests", "http_requests_rate"}) {
+		t.Fatalf("unexpected metrics beneath http %v", got)
+	}
+	gen.Depth.Update(2)
+	if genRegistry.Get("depth").(metrics.Gauge).Value() != 2 {
+		t.Fatalf("typed gauge not registered by the generated initializer")
+	}
+
+	genErr := tagtrics.NewMetricTags(newMetrics(), func() {}, time.Second, metrics.NewRegistry(), "_", flags).Err()
+	refErr := tagtrics.NewMetricTags((*reflected)(newMetrics()), func() {}, time.Second, metrics.NewRegistry(), "_", flags).Err()
+	if genErr == nil || refErr == nil {
+		t.Fatalf("names containing the separator not reported: %v, %v", genErr, refErr)
+	}
+
+	gen.Consumers = append(gen.Consumers, &ConsumerMetrics{})
+	ref.Consumers = append(ref.Consumers, &ConsumerMetrics{})
+	if err := genTags.Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if err := refTags.Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if g, r := registered(genRegistry), registered(refRegistry); !reflect.DeepEqual(g, r) {
+		t.Fatalf("rescan registered %v with the generated initializer, %v with reflection", g, r)
+	}
+	if gen.Consumers[3].Lag == nil {
+		t.Fatalf("appended element not initialized by Rescan")
+	}
+
+	gen.Customers.Get("acme").Hits.Inc(1)
+	ref.Customers.Get("acme").Hits.Inc(1)
+	if g, r := registered(genRegistry), registered(refRegistry); !reflect.DeepEqual(g, r) {
+		t.Fatalf("lazy map registered %v with the generated initializer, %v with reflection", g, r)
+	}
+	meta, _ = genTags.Metadata("customers.route_acme.hits")
+	if meta.Family != "customers.hits" || meta.Labels["route"] != "acme" || meta.Labels["customer"] != "acme" {
+		t.Fatalf("lazy map metadata %+v", meta)
+	}
+}
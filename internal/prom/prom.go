@@ -0,0 +1,53 @@
+// Package prom maps tagtrics statistics to Prometheus series.
+package prom
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Series returns the Prometheus name of the statistic stat of the named
+// metric and the labels identifying it beyond its name.  Percentiles such as
+// "99.9%" and "median" become a "quantile" label of the metric, every other
+// statistic is appended to the name, e.g. "queue_wait_count".
+func Series(name, stat string) (string, map[string]string) {
+	if q, ok := quantile(stat); ok {
+		return Name(name), map[string]string{"quantile": q}
+	}
+	return Name(name + "_" + stat), nil
+}
+
+// quantile returns the quantile of a percentile statistic.
+func quantile(stat string) (string, bool) {
+	if stat == "median" {
+		return "0.5", true
+	}
+	if !strings.HasSuffix(stat, "%") {
+		return "", false
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(stat, "%"), 64)
+	if err != nil {
+		return "", false
+	}
+	// Round to get rid of floating point noise such as 0.9990000000000001.
+	return strconv.FormatFloat(math.Round(pct*1e4)/1e6, 'g', -1, 64), true
+}
+
+// Name returns name with the characters Prometheus doesn't allow in metric
+// names replaced with underscores.
+func Name(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || c == ':' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// LabelName returns name with the characters Prometheus doesn't allow in
+// label names replaced with underscores.
+func LabelName(name string) string {
+	return strings.Replace(Name(name), ":", "_", -1)
+}
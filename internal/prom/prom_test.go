@@ -0,0 +1,29 @@
+package prom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeries(t *testing.T) {
+	tests := []struct {
+		name, stat string
+		want       string
+		labels     map[string]string
+	}{
+		{"queue.wait", "count", "queue_wait_count", nil},
+		{"queue.wait", "1m.rate", "queue_wait_1m_rate", nil},
+		{"queue.wait", "99.9%", "queue_wait", map[string]string{"quantile": "0.999"}},
+		{"queue.wait", "median", "queue_wait", map[string]string{"quantile": "0.5"}},
+		{"9lives", "value", "_lives_value", nil},
+	}
+	for _, test := range tests {
+		name, labels := Series(test.name, test.stat)
+		if name != test.want || !reflect.DeepEqual(labels, test.labels) {
+			t.Fatalf("Series(%q, %q) = %q, %v, want %q, %v", test.name, test.stat, name, labels, test.want, test.labels)
+		}
+	}
+	if n := LabelName("a:b.c"); n != "a_b_c" {
+		t.Fatalf("unexpected label name %q", n)
+	}
+}
@@ -0,0 +1,329 @@
+// Package source reads metric structs from Go source files for the tagtrics
+// commands.
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// Import paths of the packages declaring the metric types.
+const (
+	MetricsPath  = "github.com/rcrowley/go-metrics"
+	TagtricsPath = "github.com/sendgrid/tagtrics"
+)
+
+// Package holds the parsed files of a Go package.
+type Package struct {
+	Fset  *token.FileSet
+	Name  string
+	Files []*ast.File
+	// Structs holds the struct types declared in the package by name.
+	Structs map[string]*ast.StructType
+	// StructFiles holds the file each struct type is declared in by name.
+	StructFiles map[string]*ast.File
+	// Initializers holds the names of the types with an InitMetrics method
+	// implementing tagtrics.Initializer, which aren't traversed.
+	Initializers map[string]bool
+	// Stringers holds the names of the types with a String method on their
+	// values implementing fmt.Stringer, which can key map fields.
+	Stringers map[string]bool
+}
+
+// ParseDir parses the non-test Go files in dir.
+func ParseDir(dir string) (*Package, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return NewPackage(fset, files), nil
+}
+
+// NewPackage indexes the struct types declared in files.
+func NewPackage(fset *token.FileSet, files []*ast.File) *Package {
+	p := &Package{
+		Fset:         fset,
+		Files:        files,
+		Structs:      make(map[string]*ast.StructType),
+		StructFiles:  make(map[string]*ast.File),
+		Initializers: make(map[string]bool),
+		Stringers:    make(map[string]bool),
+	}
+	for _, f := range files {
+		p.Name = f.Name.Name
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "InitMetrics" && fn.Recv != nil {
+				p.Initializers[embeddedName(fn.Recv.List[0].Type)] = true
+				continue
+			}
+			if fn, ok := decl.(*ast.FuncDecl); ok && isStringMethod(fn) {
+				p.Stringers[fn.Recv.List[0].Type.(*ast.Ident).Name] = true
+				continue
+			}
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					p.Structs[ts.Name.Name] = st
+					p.StructFiles[ts.Name.Name] = f
+				}
+			}
+		}
+	}
+	return p
+}
+
+// isStringMethod reports whether fn is a String() string method with a value
+// receiver, making the values of its type, such as map keys, implement
+// fmt.Stringer.
+func isStringMethod(fn *ast.FuncDecl) bool {
+	if fn.Name.Name != "String" || fn.Recv == nil || len(fn.Type.Params.List) != 0 {
+		return false
+	}
+	if _, ok := fn.Recv.List[0].Type.(*ast.Ident); !ok {
+		return false
+	}
+	results := fn.Type.Results
+	if results == nil || len(results.List) != 1 || len(results.List[0].Names) > 1 {
+		return false
+	}
+	result, ok := results.List[0].Type.(*ast.Ident)
+	return ok && result.Name == "string"
+}
+
+// MetricTag returns the "metric" tag of field.
+func MetricTag(field *ast.Field) (string, bool) {
+	return Tag(field, "metric")
+}
+
+// Tag returns the struct tag key of field.
+func Tag(field *ast.Field, key string) (string, bool) {
+	if field.Tag == nil {
+		return "", false
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return "", false
+	}
+	return reflect.StructTag(tag).Lookup(key)
+}
+
+// HasMetricTag reports whether any field of st has a "metric" tag.
+func HasMetricTag(st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if _, ok := MetricTag(field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldNames returns the names of field, which for embedded fields is the
+// name of their type.
+func FieldNames(field *ast.Field) []string {
+	if len(field.Names) == 0 {
+		return []string{embeddedName(field.Type)}
+	}
+	names := make([]string, len(field.Names))
+	for i, ident := range field.Names {
+		names[i] = ident.Name
+	}
+	return names
+}
+
+// TagName returns the name in a "metric" tag, which is empty if the name
+// is derived from the field name.
+func TagName(tag string) string {
+	return strings.TrimSpace(strings.SplitN(tag, ",", 2)[0])
+}
+
+// MetricName returns the name of a field's metric, which is the name in its
+// "metric" tag or the field name converted according to c.
+func MetricName(fieldName, tag string, c tagtrics.NameCase) string {
+	if name := TagName(tag); name != "" {
+		return name
+	}
+	return tagtrics.DerivedName(fieldName, c)
+}
+
+// TagOption returns the value of the named option of a "metric" tag, parsed
+// the same way tagtrics does.
+func TagOption(tag, name string) (string, bool) {
+	for _, opt := range strings.Split(tag, ",")[1:] {
+		kv := strings.SplitN(opt, "=", 2)
+		if strings.TrimSpace(kv[0]) != name {
+			continue
+		}
+		if len(kv) == 2 {
+			return strings.TrimSpace(kv[1]), true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// embeddedName returns the field name of an embedded field of type expr.
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	}
+	return ""
+}
+
+// TypeString formats expr like reflect.Type.String() does for the types
+// tagtrics knows, e.g. "metrics.Timer" whatever the import is named.
+func TypeString(f *ast.File, expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok {
+			switch importPath(f, pkg.Name) {
+			case MetricsPath:
+				return "metrics." + t.Sel.Name
+			case TagtricsPath:
+				return "tagtrics." + t.Sel.Name
+			}
+			return pkg.Name + "." + t.Sel.Name
+		}
+	case *ast.IndexExpr:
+		return TypeString(f, t.X) + "[" + TypeString(f, t.Index) + "]"
+	case *ast.IndexListExpr:
+		args := make([]string, len(t.Indices))
+		for i, index := range t.Indices {
+			args[i] = TypeString(f, index)
+		}
+		return TypeString(f, t.X) + "[" + strings.Join(args, ",") + "]"
+	case *ast.StarExpr:
+		return "*" + TypeString(f, t.X)
+	case *ast.MapType:
+		return "map[" + TypeString(f, t.Key) + "]" + TypeString(f, t.Value)
+	case *ast.Ident:
+		return t.Name
+	case *ast.FuncType:
+		s := "func(" + strings.Join(fieldTypes(f, t.Params), ", ") + ")"
+		switch results := fieldTypes(f, t.Results); len(results) {
+		case 0:
+		case 1:
+			s += " " + results[0]
+		default:
+			s += " (" + strings.Join(results, ", ") + ")"
+		}
+		return s
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// fieldTypes returns the types of the fields of a parameter or result list
+// formatted by TypeString, once per name.
+func fieldTypes(f *ast.File, fields *ast.FieldList) []string {
+	if fields == nil {
+		return nil
+	}
+	var types []string
+	for _, field := range fields.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, TypeString(f, field.Type))
+		}
+	}
+	return types
+}
+
+// importPath returns the path of the package imported as name in f.
+func importPath(f *ast.File, name string) string {
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		local := path[strings.LastIndex(path, "/")+1:]
+		if path == MetricsPath {
+			local = "metrics"
+		}
+		if imp.Name != nil {
+			local = imp.Name.Name
+		}
+		if local == name {
+			return path
+		}
+	}
+	return ""
+}
+
+// MapValueStruct returns the name of the struct type T of a map[K]*T field
+// type, the only maps traversed by tagtrics, where K is string or a type of
+// the package implementing fmt.Stringer.
+func (p *Package) MapValueStruct(t *ast.MapType) (string, bool) {
+	key, ok := t.Key.(*ast.Ident)
+	if !ok || key.Name != "string" && !p.Stringers[key.Name] {
+		return "", false
+	}
+	return p.pointerStruct(t.Value)
+}
+
+// KeyName returns the expression of the name segment of the key k of a map
+// of type t accepted by MapValueStruct, k itself or the result of its
+// String method.
+func KeyName(t *ast.MapType, k string) string {
+	if key, ok := t.Key.(*ast.Ident); ok && key.Name == "string" {
+		return k
+	}
+	return k + ".String()"
+}
+
+// SliceElemStruct returns the name of the struct type T of a []*T field
+// type.
+func (p *Package) SliceElemStruct(t *ast.ArrayType) (string, bool) {
+	if t.Len != nil {
+		return "", false
+	}
+	return p.pointerStruct(t.Elt)
+}
+
+// pointerStruct returns the name of the struct type T of the type *T.
+func (p *Package) pointerStruct(expr ast.Expr) (string, bool) {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return "", false
+	}
+	v, ok := star.X.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	_, ok = p.Structs[v.Name]
+	return v.Name, ok
+}
@@ -0,0 +1,73 @@
+package source
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+const src = `package app
+
+import (
+	gm "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type service struct{}
+
+type region int
+
+func (r region) String() string { return "us-east" }
+
+type appMetrics struct {
+	Latency gm.Timer ` + "`metric:\"latency,percentiles=99\"`" + `
+	Depth   tagtrics.Gauge[int64]
+	Other   map[string]*service
+	Values  map[string]service
+	service
+	Regions map[region]*service
+}
+`
+
+func TestPackage(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "app.go", src, 0)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	p := NewPackage(fset, []*ast.File{f})
+	st := p.Structs["appMetrics"]
+	if st == nil || !HasMetricTag(st) || HasMetricTag(p.Structs["service"]) {
+		t.Fatalf("unexpected structs: %v", p.Structs)
+	}
+	fields := st.Fields.List
+	if got := TypeString(f, fields[0].Type); got != "metrics.Timer" {
+		t.Errorf("unexpected type %q", got)
+	}
+	if got := TypeString(f, fields[1].Type); got != "tagtrics.Gauge[int64]" {
+		t.Errorf("unexpected type %q", got)
+	}
+	tag, _ := MetricTag(fields[0])
+	if got := MetricName("Latency", tag, tagtrics.LowerCase); got != "latency" {
+		t.Errorf("unexpected name %q", got)
+	}
+	if got := MetricName("Depth", "", tagtrics.SnakeCase); got != "depth" {
+		t.Errorf("unexpected name %q", got)
+	}
+	if name, ok := p.MapValueStruct(fields[2].Type.(*ast.MapType)); !ok || name != "service" {
+		t.Errorf("unexpected map value %q", name)
+	}
+	if _, ok := p.MapValueStruct(fields[3].Type.(*ast.MapType)); ok {
+		t.Errorf("map of struct values is not traversed")
+	}
+	if names := FieldNames(fields[4]); names[0] != "service" {
+		t.Errorf("unexpected embedded field name %v", names)
+	}
+	regions := fields[5].Type.(*ast.MapType)
+	if name, ok := p.MapValueStruct(regions); !ok || name != "service" || KeyName(regions, "k") != "k.String()" {
+		t.Errorf("map keyed by a fmt.Stringer not traversed")
+	}
+}
@@ -0,0 +1,82 @@
+package tagtrics
+
+import (
+	"time"
+)
+
+// recordInterval schedules the metrics of the interval set by the
+// "interval" tag option for the next flush.  It must be called with
+// metaMutex held.
+func (m *MetricTags) recordInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	if m.intervalDue == nil {
+		m.intervalDue = make(map[time.Duration]time.Time)
+	}
+	if _, ok := m.intervalDue[interval]; !ok {
+		// The zero time is due at once.
+		m.intervalDue[interval] = time.Time{}
+	}
+}
+
+// nextIntervalDue returns when the metrics of the earliest interval set by
+// the "interval" tag option are due, or false if no metric has one.
+func (m *MetricTags) nextIntervalDue() (time.Time, bool) {
+	m.metaMutex.RLock()
+	defer m.metaMutex.RUnlock()
+	var next time.Time
+	found := false
+	for _, due := range m.intervalDue {
+		if !found || due.Before(next) {
+			next, found = due, true
+		}
+	}
+	return next, found
+}
+
+// dueMetrics returns the snapshot of the metrics of s whose interval is due
+// at now on the wall clock, including those without an interval if all is
+// true, and schedules the next send of the intervals due.  It returns nil if
+// no metric is due.
+func (m *MetricTags) dueMetrics(s *Snapshot, now time.Time, all bool) *Snapshot {
+	m.metaMutex.Lock()
+	if len(m.intervalDue) == 0 {
+		m.metaMutex.Unlock()
+		if all {
+			return s
+		}
+		return nil
+	}
+	due := make(map[time.Duration]bool)
+	for interval, at := range m.intervalDue {
+		if !now.Before(at) {
+			due[interval] = true
+			m.intervalDue[interval] = now.Add(interval)
+		}
+	}
+	m.metaMutex.Unlock()
+	if len(due) == 0 && !all {
+		return nil
+	}
+	return s.filter(func(name string) bool {
+		interval := s.Meta[name].Interval
+		if interval == 0 {
+			return all
+		}
+		return due[interval]
+	})
+}
+
+// flushIntervals sends the metrics whose interval set by the "interval" tag
+// option is due between the flushes to the sinks.  Like with FlushPrefix,
+// the update handler and the FlushFunc aren't called and the metrics
+// derived or windowed per flush are left for the next flush.
+func (m *MetricTags) flushIntervals() {
+	if m.Paused() {
+		return
+	}
+	if s := m.dueMetrics(m.snapshot(m.nowHandler()), time.Now(), false); s != nil {
+		m.send(s)
+	}
+}
@@ -0,0 +1,49 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type intervalMetrics struct {
+	Fast  metrics.Counter `metric:"fast,interval=10s"`
+	Slow  metrics.Counter `metric:"slow,interval=1h"`
+	Plain metrics.Counter `metric:"plain"`
+}
+
+func TestIntervalTag(t *testing.T) {
+	m := &intervalMetrics{}
+	mTags := NewMetricTags(m, func() {}, time.Minute, metrics.NewRegistry(), ".", WithLogger(&recordingLogger{}))
+	now := time.Now()
+	s := mTags.snapshot(now)
+	names := func(s *Snapshot) map[string]bool {
+		found := make(map[string]bool)
+		if s != nil {
+			for _, name := range []string{"fast", "slow", "plain"} {
+				_, found[name] = s.Metrics[name]
+			}
+		}
+		return found
+	}
+
+	if got := names(mTags.dueMetrics(s, now, true)); !got["fast"] || !got["slow"] || !got["plain"] {
+		t.Fatalf("expected every metric at the first flush, got %v", got)
+	}
+	if got := names(mTags.dueMetrics(s, now.Add(10*time.Second), false)); !got["fast"] || got["slow"] || got["plain"] {
+		t.Fatalf("expected the fast metric between flushes, got %v", got)
+	}
+	if got := mTags.dueMetrics(s, now.Add(15*time.Second), false); got != nil {
+		t.Fatalf("expected no metric due, got %v", got.Names())
+	}
+	if got := names(mTags.dueMetrics(s, now.Add(time.Minute), true)); !got["fast"] || got["slow"] || !got["plain"] {
+		t.Fatalf("expected the slow metric to be skipped, got %v", got)
+	}
+	if next, ok := mTags.nextIntervalDue(); !ok || !next.Equal(now.Add(time.Minute+10*time.Second)) {
+		t.Fatalf("unexpected next due %v", next)
+	}
+	if s.Meta["fast"].Interval != 10*time.Second {
+		t.Fatalf("unexpected interval %v", s.Meta["fast"].Interval)
+	}
+}
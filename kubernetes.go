@@ -0,0 +1,72 @@
+package tagtrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultPodInfoDir is the directory KubernetesLabels reads the files of a
+// downward API volume from unless told otherwise.
+const DefaultPodInfoDir = "/etc/podinfo"
+
+// serviceAccountNamespace holds the namespace of the pod in every pod with a
+// service account token mounted.
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// kubernetesLabels maps the labels returned by KubernetesLabels to the
+// environment variable and the file of the downward API volume holding them.
+var kubernetesLabels = []struct {
+	label, env, file string
+}{
+	{"pod", "POD_NAME", "pod_name"},
+	{"namespace", "POD_NAMESPACE", "pod_namespace"},
+	{"node", "NODE_NAME", "node_name"},
+}
+
+// KubernetesLabels returns the "pod", "namespace" and "node" labels of the
+// pod the process runs in as exposed by the Kubernetes downward API, so one
+// image reports correctly from any pod, e.g.
+//
+//	tagtrics.WithBaseLabels(tagtrics.KubernetesLabels(tagtrics.DefaultPodInfoDir))
+//
+// Each is read from the POD_NAME, POD_NAMESPACE and NODE_NAME environment
+// variables, or else from the files "pod_name", "pod_namespace" and
+// "node_name" of a downward API volume mounted at dir.  The pod name falls
+// back to the host name and the namespace to the one of the service
+// account.  Labels which can't be found are left out, so it returns an empty
+// map outside of Kubernetes.
+func KubernetesLabels(dir string) map[string]string {
+	labels := make(map[string]string)
+	for _, l := range kubernetesLabels {
+		if v := os.Getenv(l.env); v != "" {
+			labels[l.label] = v
+		} else if v := readTrimmed(filepath.Join(dir, l.file)); v != "" {
+			labels[l.label] = v
+		}
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return labels
+	}
+	if labels["pod"] == "" {
+		if v, err := os.Hostname(); err == nil {
+			labels["pod"] = v
+		}
+	}
+	if labels["namespace"] == "" {
+		if v := readTrimmed(serviceAccountNamespace); v != "" {
+			labels["namespace"] = v
+		}
+	}
+	return labels
+}
+
+// readTrimmed returns the content of the file at path without surrounding
+// white space, or an empty string if it can't be read.
+func readTrimmed(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
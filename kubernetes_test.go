@@ -0,0 +1,34 @@
+package tagtrics
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestKubernetesLabels(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "node_name"), []byte("node-7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("POD_NAME", "api-5d9c")
+	t.Setenv("POD_NAMESPACE", "")
+	t.Setenv("NODE_NAME", "")
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	labels := KubernetesLabels(dir)
+	if want := map[string]string{"pod": "api-5d9c", "node": "node-7"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("got %v, want %v", labels, want)
+	}
+
+	m := &bucketMetrics{Tenants: map[string]*tenantMetrics{"acme": {}}}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".",
+		WithBaseLabels(labels), WithBaseLabels(map[string]string{"pod": "override"}))
+	got := tags.Snapshot().Labels("tenants.tenant_acme.requests")
+	if want := map[string]string{"pod": "override", "node": "node-7", "tenant": "acme"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got labels %v, want %v", got, want)
+	}
+}
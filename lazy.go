@@ -0,0 +1,91 @@
+package tagtrics
+
+import (
+	"reflect"
+	"sync"
+)
+
+// lazyMetric is implemented by fields whose metrics are initialized on
+// first use beneath the branch of the field, even if it is disabled.
+type lazyMetric interface {
+	initLazy(m *MetricTags, b branch)
+}
+
+// LazyMap holds the metric structs of type T of many keys, such as the
+// buckets of tens of thousands of customers, initialized on first use of a
+// key rather than when metricsData is traversed, cutting the startup time
+// and the memory of mostly idle keys.  The metrics of a key are named like
+// those of the values of a map[string]*T field, honoring the Bucket
+// interface of *T.  Declare it by value:
+//
+//	Customers tagtrics.LazyMap[CustomerMetrics] `metric:"customers"`
+//
+// and get the metrics of a key with Get:
+//
+//	m.Customers.Get(customerID).Requests.Inc(1)
+//
+// A LazyMap that is not initialized returns values whose metrics are nil.
+type LazyMap[T any] struct {
+	mutex  sync.RWMutex
+	m      *MetricTags
+	b      branch
+	values map[string]*T
+}
+
+// initLazy keeps what is needed to initialize values on first use.
+func (l *LazyMap[T]) initLazy(m *MetricTags, b branch) {
+	l.m, l.b = m, b
+	l.values = make(map[string]*T)
+}
+
+// Get returns the metrics of key, initializing them on first use.  Errors
+// of the traversal of T, such as exceeding the traversal limits, are
+// logged.
+func (l *LazyMap[T]) Get(key string) *T {
+	l.mutex.RLock()
+	v, ok := l.values[key]
+	l.mutex.RUnlock()
+	if ok {
+		return v
+	}
+	if l.m == nil {
+		return new(T)
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if v, ok := l.values[key]; ok {
+		return v
+	}
+	v = new(T)
+	if err := l.m.initLazyValue(l.b.bucket(key, v), reflect.ValueOf(v)); err != nil {
+		l.m.warn("failed to initialize lazy metrics", "name", l.b.name, "err", err)
+	}
+	l.values[key] = v
+	return v
+}
+
+// Len returns the number of keys initialized so far.
+func (l *LazyMap[T]) Len() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return len(l.values)
+}
+
+// initLazyValue initializes the value v of a lazy field for branch b like
+// the values of a map field.  The traversal limits apply to every value on
+// its own.
+func (m *MetricTags) initLazyValue(b branch, v reflect.Value) error {
+	if v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	m.scanMutex.Lock()
+	defer m.scanMutex.Unlock()
+	m.metaMutex.RLock()
+	b.start = m.registered
+	m.metaMutex.RUnlock()
+	b, err := b.enter(v.Elem())
+	if err != nil {
+		return err
+	}
+	return m.initElement(v, b)
+}
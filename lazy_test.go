@@ -0,0 +1,35 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestLazyMap(t *testing.T) {
+	m := &struct {
+		Tenants LazyMap[tenantMetrics] `metric:"tenants"`
+		Beta    struct {
+			Tenants LazyMap[tenantMetrics] `metric:"tenants"`
+		} `metric:"beta,optional=beta"`
+	}{}
+	r := metrics.NewRegistry()
+	NewMetricTags(m, func() {}, time.Second, r, ".", WithFlagResolver(func(string) bool { return false }))
+	if r.Get("tenants.tenant_acme.requests") != nil {
+		t.Fatalf("metrics registered before first use")
+	}
+
+	acme := m.Tenants.Get("acme")
+	acme.Requests.Inc(1)
+	if m.Tenants.Get("acme") != acme || m.Tenants.Len() != 1 {
+		t.Fatalf("expected the same metrics for a key")
+	}
+	if c := r.Get("tenants.tenant_acme.requests").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 request, got %d", c)
+	}
+	m.Beta.Tenants.Get("acme").Requests.Inc(1)
+	if r.Get("beta.tenants.tenant_acme.requests") != nil {
+		t.Fatalf("metrics of a disabled lazy map registered")
+	}
+}
@@ -0,0 +1,103 @@
+package tagtrics
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger receives the recoverable conditions tagtrics runs into, such as
+// skipped fields, metrics failing to register, sinks failing to send and
+// series dropped by cardinality caps.  keyvals alternate keys and values
+// describing the condition, e.g. "name", "requests", "err", err.
+// *slog.Logger implements it.
+type Logger interface {
+	Warn(msg string, keyvals ...interface{})
+}
+
+// WithLogger reports the recoverable conditions tagtrics runs into to l, e.g.
+// the structured logger of the application, instead of the standard logger.
+func WithLogger(l Logger) Option {
+	return func(m *MetricTags) {
+		m.logger = l
+	}
+}
+
+// stdLogger is the default Logger, writing to the standard logger a line
+// such as:
+//
+//	tagtrics: sink failed sink=debug err=connection refused
+type stdLogger struct{}
+
+// Warn logs msg and keyvals to the standard logger.
+func (stdLogger) Warn(msg string, keyvals ...interface{}) {
+	var b strings.Builder
+	b.WriteString("tagtrics: ")
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			fmt.Fprintf(&b, " %v", keyvals[i])
+			break
+		}
+		fmt.Fprintf(&b, " %v=%v", keyvals[i], keyvals[i+1])
+	}
+	log.Print(b.String())
+}
+
+// log returns the logger of m, the standard logger unless set with
+// WithLogger.
+func (m *MetricTags) log() Logger {
+	if m.logger == nil {
+		return stdLogger{}
+	}
+	return m.logger
+}
+
+// warn reports a recoverable condition to the logger of m.
+func (m *MetricTags) warn(msg string, keyvals ...interface{}) {
+	m.log().Warn(msg, keyvals...)
+}
+
+// ErrorHandler is called with the failures of the flushes and the sinks when
+// set with WithErrorHandler.
+type ErrorHandler func(err error)
+
+// WithErrorHandler calls h with every failure of a flush, such as an error
+// of the FlushFunc or an update handler panicking with WithPanicRecovery,
+// and of a sink, once it is logged and counted in the
+// "tagtrics.flush.errors" or "tagtrics.sink.errors" self metric, so delivery
+// problems can be alerted on or reported to an error tracker.  Sink failures
+// are wrapped in a SinkError.  h is called from the flushing goroutine, or
+// the delivering ones with WithDelivery, and must not block.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(m *MetricTags) {
+		m.errorHandler = h
+	}
+}
+
+// SinkError is the failure of a sink passed to the ErrorHandler.
+type SinkError struct {
+	// Sink is the name of the sink, empty for the sinks added with AddSink.
+	Sink string
+	Err  error
+}
+
+// Error returns the error of the sink prefixed with its name.
+func (e *SinkError) Error() string {
+	if e.Sink == "" {
+		return "tagtrics: sink failed: " + e.Err.Error()
+	}
+	return "tagtrics: sink " + e.Sink + " failed: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the sink.
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// reportError passes err to the ErrorHandler, if any.
+func (m *MetricTags) reportError(err error) {
+	if m.errorHandler != nil {
+		m.errorHandler(err)
+	}
+}
@@ -0,0 +1,66 @@
+package tagtrics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type recordingLogger []string
+
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{}) {
+	*l = append(*l, strings.TrimSpace(fmt.Sprintln(append([]interface{}{msg}, keyvals...)...)))
+}
+
+type loggedMetrics struct {
+	Sent    metrics.Counter `metric:"sent"`
+	Latency metrics.Timer   `metric:"latency,percentiles=fifty"`
+	Handler func()          `metric:"handler"`
+}
+
+func TestWithLogger(t *testing.T) {
+	var l recordingLogger
+	r := metrics.NewRegistry()
+	r.Register("sent", metrics.NewCounter())
+	mTags := NewMetricTags(&loggedMetrics{}, func() {}, time.Second, r, ".", WithLogger(&l))
+	mTags.AddNamedSink("debug", SinkFunc(func(*Snapshot) error {
+		return errors.New("backend down")
+	}))
+	mTags.flush()
+
+	expected := []string{
+		"failed to register metric name sent err duplicate metric: sent",
+		`invalid tag options name latency err invalid percentile "fifty"`,
+		"skipped field of unsupported type name handler type func()",
+		"sink failed sink debug err backend down",
+	}
+	if fmt.Sprint(l) != fmt.Sprint(expected) {
+		t.Fatalf("expected warnings %q, got %q", expected, l)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	var errs []error
+	mTags := NewMetricTags(&metaMetrics{}, func() { panic("boom") }, time.Second, metrics.NewRegistry(), ".",
+		WithLogger(&recordingLogger{}), WithPanicRecovery(),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	backendDown := errors.New("backend down")
+	mTags.AddNamedSink("debug", SinkFunc(func(s *Snapshot) error { return backendDown }))
+
+	mTags.flush()
+	mTags.send(mTags.Snapshot())
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "boom") {
+		t.Fatalf("expected the panic of the update handler, got %v", errs[0])
+	}
+	var serr *SinkError
+	if !errors.As(errs[1], &serr) || serr.Sink != "debug" || !errors.Is(errs[1], backendDown) {
+		t.Fatalf("expected the error of the debug sink, got %v", errs[1])
+	}
+}
@@ -0,0 +1,90 @@
+package tagtrics
+
+import (
+	"strings"
+	"time"
+)
+
+// MetricMeta describes a metric initialized from a tagged struct.
+type MetricMeta struct {
+	// Name is the full name of the metric in the registry.
+	Name string `json:"name"`
+	// Type is the kind of metric such as "counter" or "timer".
+	Type string `json:"type"`
+	// Help is the description of the metric taken from the "help" struct tag.
+	Help string `json:"help,omitempty"`
+	// Unit is the unit of the recorded values taken from the "unit" struct
+	// tag.
+	Unit string `json:"unit,omitempty"`
+	// Percentiles are the percentiles exported for a histogram or timer as
+	// set by the "percentiles" tag option.  Nil means the instance default.
+	Percentiles []float64 `json:"percentiles,omitempty"`
+	// DurationUnit is the unit of the durations exported for a timer as set
+	// by the "duration" tag option, e.g. "ms".  Empty means the instance
+	// default.
+	DurationUnit string `json:"duration_unit,omitempty"`
+	// States are the names of the values of a StateGauge.
+	States []string `json:"states,omitempty"`
+	// Family is the name of the metric without the segments of map keys
+	// exported as labels by a Bucket, e.g. "traffic.latency" for
+	// "traffic.search.latency".  It is empty for metrics without labels.
+	Family string `json:"family,omitempty"`
+	// Labels are the labels of the map keys the metric is stored under
+	// returned by a Bucket.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Sink is the name of the only sink the metric is exported to as set
+	// by the "sink" struct tag of a field above it.  Empty means the
+	// default sinks.
+	Sink string `json:"sink,omitempty"`
+	// Interval is how often the metric is sent to the sinks as set by the
+	// "interval" tag option.  Zero means every flush.
+	Interval time.Duration `json:"interval,omitempty"`
+	// ends are the lengths of the names of the branches above the metric,
+	// nil if unknown.  Name is split where they end rather than on the
+	// separator of the MetricTags which fields may override.
+	ends []int
+}
+
+// suffixed returns the metadata of a metric exported next to the one
+// described by meta with a name suffixed with sep and suffix.
+func (meta MetricMeta) suffixed(sep, suffix string) MetricMeta {
+	if meta.ends != nil {
+		meta.ends = extendEnds(meta.ends, meta.Name)
+	}
+	meta.Name += sep + suffix
+	if meta.Family != "" {
+		meta.Family += sep + suffix
+	}
+	return meta
+}
+
+// Metadata returns the metadata of the metric with the given name.  The
+// boolean is false if the metric was not initialized by m.
+func (m *MetricTags) Metadata(name string) (MetricMeta, bool) {
+	m.metaMutex.RLock()
+	defer m.metaMutex.RUnlock()
+	meta, ok := m.meta[name]
+	return meta, ok
+}
+
+// beneath reports whether the metric described by meta is named prefix or
+// is beneath it, every metric being beneath the empty prefix.  Metrics of a
+// family exported with labels are also matched by their family.  Without
+// ends, the name is split on sep.
+func (meta MetricMeta) beneath(prefix, sep string) bool {
+	if prefix == "" || meta.Name == prefix || meta.Family == prefix {
+		return true
+	}
+	if !strings.HasPrefix(meta.Name, prefix) {
+		return false
+	}
+	if meta.ends == nil {
+		return strings.HasPrefix(meta.Name, prefix+sep)
+	}
+	for _, end := range meta.ends {
+		if end == len(prefix) {
+			return true
+		}
+	}
+	return false
+}
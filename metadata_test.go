@@ -0,0 +1,47 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type metaMetrics struct {
+	Queue struct {
+		Depth metrics.Gauge `metric:"depth" help:"Messages waiting to be sent" unit:"messages"`
+		Wait  metrics.Timer `metric:"wait"`
+	} `metric:"queue"`
+}
+
+func TestMetadata(t *testing.T) {
+	m := &metaMetrics{}
+	mTags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+
+	meta, ok := mTags.Metadata("queue.depth")
+	if !ok {
+		t.Fatalf("metadata not found for queue.depth")
+	}
+	if meta.Type != "gauge" || meta.Help != "Messages waiting to be sent" || meta.Unit != "messages" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if meta, ok := mTags.Metadata("queue.wait"); !ok || meta.Type != "timer" || meta.Help != "" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if _, ok := mTags.Metadata("queue.missing"); ok {
+		t.Fatalf("found metadata for unknown metric")
+	}
+
+	m.Queue.Depth.Update(3)
+	s := mTags.Snapshot()
+	if s.Meta["queue.depth"].Help != "Messages waiting to be sent" {
+		t.Fatalf("snapshot is missing metadata: %v", s.Meta)
+	}
+	if g, ok := s.Metrics["queue.depth"].(metrics.Gauge); !ok || g.Value() != 3 {
+		t.Fatalf("unexpected snapshot value: %v", s.Metrics["queue.depth"])
+	}
+	m.Queue.Depth.Update(4)
+	if s.Metrics["queue.depth"].(metrics.Gauge).Value() != 3 {
+		t.Fatalf("snapshot changed after update")
+	}
+}
@@ -0,0 +1,93 @@
+package tagtrics
+
+import (
+	"sync"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// MinMaxGauge is a gauge which also tracks the minimum and maximum values it
+// held since the last flush, for watermarks such as the maximum queue depth
+// or the minimum free memory.  It is exported as two gauges suffixed with
+// "min" and "max".  They are seeded by the first update of every window, so
+// they hold the current value after a flush until the gauge is updated.
+type MinMaxGauge interface {
+	metrics.Gauge
+	// Min returns the smallest value since the last flush.
+	Min() int64
+	// Max returns the largest value since the last flush.
+	Max() int64
+}
+
+// NewMinMaxGauge constructs a new MinMaxGauge.
+func NewMinMaxGauge() MinMaxGauge {
+	return &minMaxGauge{}
+}
+
+// minMaxGauge is the standard implementation of a MinMaxGauge.
+type minMaxGauge struct {
+	mutex           sync.Mutex
+	value, min, max int64
+	// set is whether the gauge was updated in the current window, the first
+	// update seeding min and max.
+	set bool
+}
+
+// Max returns the largest value since the last flush.
+func (g *minMaxGauge) Max() int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.max
+}
+
+// Min returns the smallest value since the last flush.
+func (g *minMaxGauge) Min() int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.min
+}
+
+// Snapshot returns a read-only copy of the current value.
+func (g *minMaxGauge) Snapshot() metrics.Gauge {
+	return metrics.GaugeSnapshot(g.Value())
+}
+
+// Update sets the current value.
+func (g *minMaxGauge) Update(v int64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.value = v
+	if !g.set {
+		g.min, g.max, g.set = v, v, true
+		return
+	}
+	if v < g.min {
+		g.min = v
+	}
+	if v > g.max {
+		g.max = v
+	}
+}
+
+// Value returns the current value.
+func (g *minMaxGauge) Value() int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.value
+}
+
+// resetWindow starts tracking extremes again from the current value until
+// the next update.
+func (g *minMaxGauge) resetWindow() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.min, g.max, g.set = g.value, g.value, false
+}
+
+// exportedMetrics returns the min and max gauges.
+func (g *minMaxGauge) exportedMetrics() map[string]interface{} {
+	return map[string]interface{}{
+		"min": metrics.NewFunctionalGauge(g.Min),
+		"max": metrics.NewFunctionalGauge(g.Max),
+	}
+}
@@ -0,0 +1,57 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type minMaxMetrics struct {
+	Queue struct {
+		Depth MinMaxGauge `metric:"depth"`
+	} `metric:"queue"`
+}
+
+func TestMinMaxGauge(t *testing.T) {
+	m := &minMaxMetrics{}
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(m, func() {}, time.Second, r, ".")
+	for _, v := range []int64{5, 12, -3, 4} {
+		m.Queue.Depth.Update(v)
+	}
+	s := mTags.Snapshot()
+	if s.Stats("queue.depth.min")["value"] != -3 || s.Stats("queue.depth.max")["value"] != 12 {
+		t.Fatalf("unexpected extremes: %v %v", s.Stats("queue.depth.min"), s.Stats("queue.depth.max"))
+	}
+	if _, ok := mTags.Metadata("queue.depth.max"); !ok {
+		t.Fatalf("missing metadata for queue.depth.max")
+	}
+	if r.Get("queue.depth") != nil {
+		t.Fatalf("the gauge itself should not be registered")
+	}
+
+	mTags.flush()
+	if m.Queue.Depth.Min() != 4 || m.Queue.Depth.Max() != 4 {
+		t.Fatalf("extremes not reset to current value: %d %d", m.Queue.Depth.Min(), m.Queue.Depth.Max())
+	}
+	m.Queue.Depth.Update(7)
+	m.Queue.Depth.Update(9)
+	if m.Queue.Depth.Min() != 7 || m.Queue.Depth.Max() != 9 {
+		t.Fatalf("unexpected extremes after flush: %d %d", m.Queue.Depth.Min(), m.Queue.Depth.Max())
+	}
+}
+
+func TestMinMaxGaugeSeed(t *testing.T) {
+	g := NewMinMaxGauge()
+	g.Update(5)
+	g.Update(8)
+	if g.Min() != 5 || g.Max() != 8 {
+		t.Fatalf("extremes not seeded by the first update: %d %d", g.Min(), g.Max())
+	}
+	g = NewMinMaxGauge()
+	g.Update(-5)
+	if g.Min() != -5 || g.Max() != -5 {
+		t.Fatalf("extremes not seeded by the first update: %d %d", g.Min(), g.Max())
+	}
+}
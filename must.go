@@ -0,0 +1,58 @@
+package tagtrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// MustNewMetricTags is like NewMetricTags but panics if the initialization
+// of metricsData fails, with a message naming the path of the struct field
+// at fault, e.g. "Routes[\"send\"].Latency", and the reason.  It is meant
+// for main() where the metrics are set up once and handling Err is
+// boilerplate.
+func MustNewMetricTags(metricsData interface{}, updateHandler MetricsUpdateHandler, flushInterval time.Duration, registry metrics.Registry, separator string, options ...Option) *MetricTags {
+	m := NewMetricTags(metricsData, updateHandler, flushInterval, registry, separator, options...)
+	if err := m.Err(); err != nil {
+		if fe, ok := err.(*fieldError); ok {
+			panic(fmt.Sprintf("tagtrics: failed to initialize field %s of %T: %v", fe.path, metricsData, fe.err))
+		}
+		panic(fmt.Sprintf("tagtrics: failed to initialize %T: %v", metricsData, err))
+	}
+	return m
+}
+
+// fieldError is an error of the initialization of a struct field along with
+// the path of the field beneath the struct initialized.  Its message is the
+// one of the error so that Err reads the same.
+type fieldError struct {
+	path string
+	err  error
+}
+
+// Error returns the message of the error of the field.
+func (e *fieldError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error of the field.
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// withField returns err with segment prepended to the path of the field at
+// fault, a field name or a map key in brackets.
+func withField(segment string, err error) error {
+	fe, ok := err.(*fieldError)
+	if !ok {
+		return &fieldError{path: segment, err: err}
+	}
+	if strings.HasPrefix(fe.path, "[") {
+		fe.path = segment + fe.path
+	} else {
+		fe.path = segment + "." + fe.path
+	}
+	return fe
+}
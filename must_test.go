@@ -0,0 +1,53 @@
+package tagtrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type mustRoute struct {
+	Latency struct {
+		Slow metrics.Timer `metric:"slow.p99"`
+	}
+}
+
+func TestMustNewMetricTags(t *testing.T) {
+	if m := MustNewMetricTags(&metaMetrics{}, func() {}, time.Second, metrics.NewRegistry(), "."); m == nil {
+		t.Fatalf("expected a MetricTags")
+	}
+
+	defer func() {
+		msg := fmt.Sprint(recover())
+		if !strings.Contains(msg, `field Routes["send"].Latency.Slow of *struct`) || !strings.Contains(msg, "contains the separator") {
+			t.Fatalf("unexpected panic %q", msg)
+		}
+	}()
+	MustNewMetricTags(&struct {
+		Routes map[string]*mustRoute
+	}{Routes: map[string]*mustRoute{"send": {}}}, func() {}, time.Second, metrics.NewRegistry(), ".", WithLogger(&recordingLogger{}))
+	t.Fatalf("expected a panic")
+}
+
+func TestNameWithSeparator(t *testing.T) {
+	m := &struct {
+		Slow   metrics.Timer `metric:"slow.p99"`
+		Routes map[string]*mustRoute
+		Fast   metrics.Timer `metric:"fast"`
+	}{Routes: map[string]*mustRoute{"send": {}}}
+	var l recordingLogger
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithLogger(&l))
+	err := tags.Err()
+	if err == nil || !strings.Contains(err.Error(), `metric name "slow.p99" beneath the root contains the separator "."`) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if m.Slow != nil || m.Routes["send"].Latency.Slow != nil {
+		t.Fatalf("fields named with the separator were initialized")
+	}
+	if m.Fast == nil {
+		t.Fatalf("the fields after a name containing the separator were not initialized")
+	}
+}
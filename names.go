@@ -0,0 +1,62 @@
+package tagtrics
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NameCase controls how the metric names of fields without a name in their
+// "metric" tag are derived from the field names.
+type NameCase int
+
+const (
+	// LowerCase lowercases the field name, e.g. "RequestCount" becomes
+	// "requestcount".  It is the default.
+	LowerCase NameCase = iota
+	// PreserveCase uses the field name as is, e.g. "RequestCount".
+	PreserveCase
+	// SnakeCase splits the field name into lowercase words separated by
+	// underscores, e.g. "RequestCount" becomes "request_count" and
+	// "HTTPStatus" becomes "http_status".
+	SnakeCase
+)
+
+// WithDerivedNameCase sets how the metric names of fields without a name in
+// their "metric" tag are derived from the field names.  The default is
+// LowerCase.
+func WithDerivedNameCase(c NameCase) Option {
+	return func(m *MetricTags) {
+		m.nameCase = c
+	}
+}
+
+// DerivedName returns the metric name of a field named fieldName without a
+// name in its "metric" tag.
+func DerivedName(fieldName string, c NameCase) string {
+	switch c {
+	case PreserveCase:
+		return fieldName
+	case SnakeCase:
+		return snakeCase(fieldName)
+	}
+	return strings.ToLower(fieldName)
+}
+
+// snakeCase converts a Go identifier to snake case.  A word starts at an
+// upper case letter following a lower case letter or digit, or at the last
+// upper case letter of an acronym followed by a lower case letter.
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
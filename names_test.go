@@ -0,0 +1,52 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestDerivedName(t *testing.T) {
+	tests := []struct {
+		field                  string
+		lower, preserve, snake string
+	}{
+		{"RequestCount", "requestcount", "RequestCount", "request_count"},
+		{"HTTPStatus", "httpstatus", "HTTPStatus", "http_status"},
+		{"P99Latency", "p99latency", "P99Latency", "p99_latency"},
+		{"Depth", "depth", "Depth", "depth"},
+		{"ID", "id", "ID", "id"},
+	}
+	for _, test := range tests {
+		if got := DerivedName(test.field, LowerCase); got != test.lower {
+			t.Errorf("lower %s = %q", test.field, got)
+		}
+		if got := DerivedName(test.field, PreserveCase); got != test.preserve {
+			t.Errorf("preserve %s = %q", test.field, got)
+		}
+		if got := DerivedName(test.field, SnakeCase); got != test.snake {
+			t.Errorf("snake %s = %q", test.field, got)
+		}
+	}
+}
+
+type caseMetrics struct {
+	QueueStats struct {
+		MaxDepth metrics.Gauge
+		Sent     metrics.Counter `metric:"sent"`
+	}
+}
+
+func TestWithDerivedNameCase(t *testing.T) {
+	r := metrics.NewRegistry()
+	NewMetricTags(&caseMetrics{}, func() {}, time.Second, r, ".", WithDerivedNameCase(PreserveCase))
+	if r.Get("QueueStats.MaxDepth") == nil || r.Get("QueueStats.sent") == nil {
+		t.Fatalf("field name case not preserved")
+	}
+	r = metrics.NewRegistry()
+	NewMetricTags(&caseMetrics{}, func() {}, time.Second, r, ".", WithDerivedNameCase(SnakeCase))
+	if r.Get("queue_stats.max_depth") == nil {
+		t.Fatalf("field names not converted to snake case")
+	}
+}
@@ -0,0 +1,218 @@
+package tagtrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics/internal/prom"
+)
+
+// OpenMetricsContentType is the content type of the OpenMetrics exposition
+// format written by WriteOpenMetrics.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// PrometheusContentType is the content type of the Prometheus text
+// exposition format written by WritePrometheus.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteOpenMetrics writes the snapshot to w in the OpenMetrics text
+// exposition format understood by Prometheus.  Metric names are sanitized
+// and the metrics of a family, the name without the map keys exported as
+// labels, share one set of metadata.  Counters become counters, gauges
+// gauges, meters counters of their count, Info metrics infos, histograms
+// and timers tagged with sample=buckets histograms and other histograms and
+// timers summaries of their percentiles.  The count of summaries and the
+// bucket of histograms holding it carry the last exemplar recorded with
+// RecordWithExemplar.
+func (s *Snapshot) WriteOpenMetrics(w io.Writer) error {
+	return s.writeExposition(w, true)
+}
+
+// WritePrometheus writes the snapshot to w in the older Prometheus text
+// exposition format for scrapers which don't support OpenMetrics.  Metrics
+// are exported as by WriteOpenMetrics except that Info metrics are gauges
+// and exemplars are left out.
+func (s *Snapshot) WritePrometheus(w io.Writer) error {
+	return s.writeExposition(w, false)
+}
+
+// writeExposition writes the snapshot in the OpenMetrics format, or the
+// Prometheus text format if openMetrics is false.
+func (s *Snapshot) writeExposition(w io.Writer, openMetrics bool) error {
+	families := make(map[string][]string)
+	for _, name := range s.Names() {
+		family := prom.Name(s.Family(name))
+		families[family] = append(families[family], name)
+	}
+	order := make([]string, 0, len(families))
+	for family := range families {
+		order = append(order, family)
+	}
+	sort.Strings(order)
+
+	bw := bufio.NewWriter(w)
+	for _, family := range order {
+		names := families[family]
+		kind := s.openMetricsType(names[0])
+		if kind == "" {
+			continue
+		}
+		// The metadata of the Prometheus format names the samples, e.g.
+		// "sent_total", rather than the family and has no info type.
+		described, typ := family, kind
+		switch {
+		case !openMetrics && kind == "counter":
+			described = family + "_total"
+		case !openMetrics && kind == "info":
+			described, typ = family+"_info", "gauge"
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", described, typ)
+		if help := s.Meta[names[0]].Help; help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", described, escapeHelp(help))
+		}
+		for _, name := range names {
+			s.writeOpenMetricsSamples(bw, family, kind, name, openMetrics)
+		}
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// openMetricsType returns the OpenMetrics type of the named metric or an
+// empty string for metrics which aren't exported.
+func (s *Snapshot) openMetricsType(name string) string {
+	if s.Meta[name].Type == "info" {
+		return "info"
+	}
+	switch s.Metrics[name].(type) {
+	case metrics.Counter, metrics.Meter:
+		return "counter"
+	case metrics.Gauge, metrics.GaugeFloat64:
+		return "gauge"
+	case metrics.Histogram, metrics.Timer:
+		if s.Buckets(name) != nil {
+			return "histogram"
+		}
+		return "summary"
+	}
+	return ""
+}
+
+// writeOpenMetricsSamples writes the samples of the named metric of the
+// given family and OpenMetrics type, with its exemplar if exemplars is set.
+func (s *Snapshot) writeOpenMetricsSamples(w *bufio.Writer, family, kind, name string, exemplars bool) {
+	stats := s.Stats(name)
+	labels := s.Labels(name)
+	switch kind {
+	case "info":
+		writeSample(w, family+"_info", labels, nil, 1)
+	case "counter":
+		writeSample(w, family+"_total", labels, nil, stats["count"])
+	case "gauge":
+		writeSample(w, family, labels, nil, stats["value"])
+	case "summary":
+		for _, p := range s.percentiles(name) {
+			q := map[string]string{"quantile": strconv.FormatFloat(p, 'g', -1, 64)}
+			writeSample(w, family, labels, q, stats[percentileKey(p)])
+		}
+		writeSample(w, family+"_sum", labels, nil, stats["mean"]*stats["count"])
+		w.WriteString(family + "_count" + formatLabels(labels, nil) + " " + formatFloat(stats["count"]))
+		if exemplars {
+			s.writeExemplar(w, name)
+		}
+		w.WriteString("\n")
+	case "histogram":
+		// The exemplar goes to the first bucket holding its value.
+		e, hasExemplar := s.exemplarValue(name)
+		hasExemplar = hasExemplar && exemplars
+		for _, b := range s.Buckets(name) {
+			le := map[string]string{"le": formatFloat(b.UpperBound)}
+			w.WriteString(family + "_bucket" + formatLabels(labels, le) + " " + formatFloat(float64(b.Count)))
+			if hasExemplar && e <= b.UpperBound {
+				s.writeExemplar(w, name)
+				hasExemplar = false
+			}
+			w.WriteString("\n")
+		}
+		w.WriteString(family + "_bucket" + formatLabels(labels, map[string]string{"le": "+Inf"}) + " " + formatFloat(stats["count"]))
+		if hasExemplar {
+			s.writeExemplar(w, name)
+		}
+		w.WriteString("\n")
+		writeSample(w, family+"_sum", labels, nil, stats["mean"]*stats["count"])
+		writeSample(w, family+"_count", labels, nil, stats["count"])
+	}
+}
+
+// exemplarValue returns the value of the exemplar of the named metric, in
+// the duration unit of the snapshot for timers.
+func (s *Snapshot) exemplarValue(name string) (float64, bool) {
+	e, ok := s.Exemplars[name]
+	if !ok {
+		return 0, false
+	}
+	value := e.Value
+	if _, ok := s.Metrics[name].(metrics.Timer); ok {
+		value /= float64(s.durationUnit(name))
+	}
+	return value, true
+}
+
+// writeExemplar appends the exemplar of the named metric, if any, to the
+// sample being written.
+func (s *Snapshot) writeExemplar(w *bufio.Writer, name string) {
+	e, ok := s.Exemplars[name]
+	if !ok {
+		return
+	}
+	v, _ := s.exemplarValue(name)
+	fmt.Fprintf(w, " # %s %s %s", formatLabels(e.Labels, nil), formatFloat(v),
+		strconv.FormatFloat(float64(e.Time.UnixNano())/1e9, 'f', 3, 64))
+}
+
+// writeSample writes a sample with the labels of the metric and the extra
+// labels of the sample.
+func writeSample(w *bufio.Writer, name string, labels, extra map[string]string, value float64) {
+	w.WriteString(name + formatLabels(labels, extra) + " " + formatFloat(value) + "\n")
+}
+
+// formatLabels formats the union of labels and extra as sorted label pairs
+// in braces, or an empty string without labels.
+func formatLabels(labels, extra map[string]string) string {
+	if len(labels)+len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)+len(extra))
+	for k, v := range labels {
+		if _, ok := extra[k]; !ok {
+			pairs = append(pairs, prom.LabelName(k)+`="`+escapeLabel(v)+`"`)
+		}
+	}
+	for k, v := range extra {
+		pairs = append(pairs, prom.LabelName(k)+`="`+escapeLabel(v)+`"`)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats a sample value.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabel escapes a label value.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// escapeHelp escapes the text of a HELP line.
+func escapeHelp(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(v)
+}
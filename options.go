@@ -0,0 +1,166 @@
+package tagtrics
+
+import (
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// Option configures a MetricTags in NewMetricTags.
+type Option func(*MetricTags)
+
+// FlagResolver reports whether the named feature flag is enabled.
+type FlagResolver func(flag string) bool
+
+// WithFlagResolver sets the resolver of the feature flags named by the
+// "optional" tag option.  Without a resolver every optional metric is
+// disabled.
+func WithFlagResolver(r FlagResolver) Option {
+	return func(m *MetricTags) {
+		m.flagResolver = r
+	}
+}
+
+// WithResetOnFlush makes every counter, histogram and timer only hold the
+// values of the previous flush interval like the "reset" tag option.
+func WithResetOnFlush() Option {
+	return func(m *MetricTags) {
+		m.resetOnFlush = true
+	}
+}
+
+// WithPullOnly disables the Run worker for services which are scraped rather
+// than pushing metrics.  The update handler and sinks are never called and
+// metrics are only collected when Snapshot, Scrape, ToJSON or Handler are
+// called, windows ending on every Scrape.  A zero flush interval has the
+// same effect.
+func WithPullOnly() Option {
+	return func(m *MetricTags) {
+		m.pullOnly = true
+	}
+}
+
+// WithDefaultSampleSize sets the number of values sampled by histograms and
+// timers without a "sample" tag option, DefaultSampleSize by default.  Smaller
+// samples use less memory while larger ones give more accurate percentiles.
+// Sizes below 1 are ignored.
+func WithDefaultSampleSize(size int) Option {
+	return func(m *MetricTags) {
+		if size > 0 {
+			m.sampleSize = size
+		}
+	}
+}
+
+// WithFloatPrecision rounds the exported statistics, such as the rates and
+// means in ToJSON and the values sent to sinks, to the given number of
+// decimal places to keep payloads small and golden files stable.  Zero or
+// less keeps full precision.
+func WithFloatPrecision(places int) Option {
+	return func(m *MetricTags) {
+		if places > 0 {
+			m.floatPrecision = places
+		}
+	}
+}
+
+// WithTraversalLimits limits the nesting depth of the fields of metricsData
+// and the number of metrics it registers, DefaultMaxDepth and
+// DefaultMaxMetrics by default, to fail fast when a large domain object is
+// registered by mistake.  The traversal stops at the field exceeding a
+// limit with an error returned by Err.  Zero or less disables a limit.
+func WithTraversalLimits(maxDepth, maxMetrics int) Option {
+	return func(m *MetricTags) {
+		m.maxDepth, m.maxMetrics = maxDepth, maxMetrics
+	}
+}
+
+// WithFlushCounter registers a "tagtrics.flushes" counter of the successful
+// flushes next to the "tagtrics.last_flush_timestamp" heartbeat, for
+// monitors which prefer a monotonic counter to a timestamp.
+func WithFlushCounter() Option {
+	return func(m *MetricTags) {
+		m.flushes = metrics.NewCounter()
+	}
+}
+
+// WithBaseLabels attaches labels to every exported metric, e.g. the region
+// or the pod reporting them, in addition to the labels of the map keys above
+// it and its own which take precedence.  It can be used several times.
+func WithBaseLabels(labels map[string]string) Option {
+	return func(m *MetricTags) {
+		if len(labels) == 0 {
+			return
+		}
+		base := make(map[string]string, len(m.baseLabels)+len(labels))
+		for k, v := range m.baseLabels {
+			base[k] = v
+		}
+		for k, v := range labels {
+			base[k] = v
+		}
+		m.baseLabels = base
+	}
+}
+
+// WithoutRuntimeStats disables the Go runtime statistics, which are neither
+// registered nor sampled, for sidecars and command line tools where those
+// series are noise and sampling them, which stops the world, is unwanted.
+// StatsGCCollection and StatsMemCollection are then ignored.
+func WithoutRuntimeStats() Option {
+	return func(m *MetricTags) {
+		m.noRuntimeStats = true
+	}
+}
+
+// initFlushCounter registers the counter of WithFlushCounter, or sets a no-op
+// counter if it wasn't used.
+func (m *MetricTags) initFlushCounter() {
+	if m.flushes == nil {
+		m.flushes = metrics.NilCounter{}
+		return
+	}
+	name := JoinName(m.selfPrefix, m.separator, "flushes")
+	m.register(newMeta(name, "counter", "Successful flushes", "", nil), m.flushes)
+}
+
+// flagEnabled reports whether a field with the given tag options should be
+// registered according to its "optional" feature flag.
+func (m *MetricTags) flagEnabled(opts tagOptions) bool {
+	flag, ok := opts["optional"]
+	if !ok {
+		return true
+	}
+	return m.flagResolver != nil && m.flagResolver(flag)
+}
+
+// WithExistingMetrics binds the go-metrics fields whose names are already
+// registered in the registry to the metrics registered, if they are of the
+// same kind, instead of creating new ones.  Hand-registered metrics can
+// then move to a tagged struct one at a time while the code still using
+// them by name keeps working.  The metrics bound keep their implementation,
+// so tag options changing it such as "reset" or "sample" have no effect.
+// They are unregistered along with the others.
+func WithExistingMetrics() Option {
+	return func(m *MetricTags) {
+		m.existingMetrics = true
+	}
+}
+
+// WithFlushFunc calls f with the snapshot of every flush after the update
+// handler, which may be nil.  An error returned by f is counted in the
+// "tagtrics.flush.errors" self metric, logged and passed to the
+// ErrorHandler.
+func WithFlushFunc(f FlushFunc) Option {
+	return func(m *MetricTags) {
+		m.flushFunc = f
+	}
+}
+
+// WithPanicRecovery recovers from the panics of the update handler, the
+// FlushFunc and the flush hooks, counting them as flush errors which are
+// logged and passed to the ErrorHandler instead of taking the Run worker,
+// and the process, down.  By default panics go on like in any goroutine.
+func WithPanicRecovery() Option {
+	return func(m *MetricTags) {
+		m.recoverPanics = true
+	}
+}
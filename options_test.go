@@ -0,0 +1,183 @@
+package tagtrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type optionalMetrics struct {
+	Stable metrics.Counter `metric:"stable"`
+	Beta   struct {
+		Requests metrics.Meter `metric:"requests"`
+		Enabled  BoolGauge     `metric:"enabled"`
+	} `metric:"beta,optional=beta"`
+	Experiment metrics.Timer `metric:"experiment,optional=experiment"`
+}
+
+func TestFlagResolver(t *testing.T) {
+	r := metrics.NewRegistry()
+	m := &optionalMetrics{}
+	flags := map[string]bool{"experiment": true}
+	NewMetricTags(m, func() {}, time.Second, r, ".", WithFlagResolver(func(flag string) bool {
+		return flags[flag]
+	}))
+	if r.Get("stable") == nil || r.Get("experiment") == nil {
+		t.Fatalf("enabled metrics were not registered")
+	}
+	if r.Get("beta.requests") != nil || r.Get("beta.enabled") != nil {
+		t.Fatalf("disabled metrics were registered")
+	}
+	if _, ok := m.Beta.Requests.(metrics.NilMeter); !ok {
+		t.Fatalf("disabled meter is not a no-op: %T", m.Beta.Requests)
+	}
+	// Disabled fields must still be safe to use.
+	m.Beta.Requests.Mark(1)
+	m.Beta.Enabled.Set(true)
+
+	r = metrics.NewRegistry()
+	NewMetricTags(&optionalMetrics{}, func() {}, time.Second, r, ".")
+	if r.Get("experiment") != nil {
+		t.Fatalf("optional metric registered without a resolver")
+	}
+}
+
+func TestPullOnly(t *testing.T) {
+	m := &resetMetrics{}
+	called := false
+	mTags := NewMetricTags(m, func() { called = true }, 0, metrics.NewRegistry(), ".")
+	done := make(chan struct{})
+	go func() {
+		mTags.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Run didn't return in pull-only mode")
+	}
+	mTags.Stop()
+
+	m.Sent.Inc(3)
+	if c := mTags.Snapshot().Stats("sent")["count"]; c != 0 {
+		t.Fatalf("expected the snapshot not to end the interval, got %f", c)
+	}
+	if c := mTags.Scrape().Stats("sent")["count"]; c != 3 {
+		t.Fatalf("expected the scrape to end the interval, got %f", c)
+	}
+	if c := mTags.Snapshot().Stats("sent")["count"]; c != 3 {
+		t.Fatalf("expected the scraped interval, got %f", c)
+	}
+	if c := mTags.Scrape().Stats("sent")["count"]; c != 0 {
+		t.Fatalf("expected a new interval, got %f", c)
+	}
+	if called {
+		t.Fatalf("update handler called in pull-only mode")
+	}
+	mTags = NewMetricTags(&resetMetrics{}, func() {}, time.Second, metrics.NewRegistry(), ".", WithPullOnly())
+	if !mTags.pullOnly {
+		t.Fatalf("WithPullOnly didn't select pull-only mode")
+	}
+}
+
+func TestDefaultSampleSize(t *testing.T) {
+	m := &struct {
+		Size metrics.Histogram `metric:"size"`
+		HDR  metrics.Histogram `metric:"hdr,sample=hdr"`
+	}{}
+	NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithDefaultSampleSize(10))
+	for i := int64(0); i < 100; i++ {
+		m.Size.Update(i)
+		m.HDR.Update(i)
+	}
+	if n := m.Size.Sample().Size(); n != 10 {
+		t.Fatalf("expected a sample of 10 values, got %d", n)
+	}
+	if m.HDR.Count() != 100 {
+		t.Fatalf("sample option overridden by the default sample size")
+	}
+}
+
+func TestFloatPrecision(t *testing.T) {
+	m := &struct {
+		Latency metrics.Timer `metric:"latency"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".",
+		WithDurationUnit(time.Millisecond), WithFloatPrecision(3))
+	m.Latency.Update(1234567)
+	if v := tags.Snapshot().Stats("latency")["max"]; v != 1.235 {
+		t.Fatalf("expected 1.235, got %v", v)
+	}
+	if got := string(tags.ToJSON()); !strings.Contains(got, `"max":1.235`) {
+		t.Fatalf("unexpected JSON %s", got)
+	}
+}
+
+func TestTraversalLimits(t *testing.T) {
+	m := &struct {
+		A struct {
+			B struct {
+				C metrics.Counter `metric:"c"`
+			} `metric:"b"`
+		} `metric:"a"`
+		Shards map[string]*struct {
+			Hits metrics.Counter `metric:"hits"`
+			Miss metrics.Counter `metric:"miss"`
+		} `metric:"shards"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithTraversalLimits(2, 0))
+	if err := tags.Err(); err == nil || err.Error() != `tagtrics: "a.b.c" is nested more than 2 levels deep` {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	m.Shards = map[string]*struct {
+		Hits metrics.Counter `metric:"hits"`
+		Miss metrics.Counter `metric:"miss"`
+	}{"one": {}}
+	tags = NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithTraversalLimits(0, 2))
+	if err := tags.Err(); err == nil || err.Error() != `tagtrics: "shards.one.miss" exceeds the limit of 2 metrics` {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := tags.Metadata("a.b.c"); !ok {
+		t.Fatalf("metrics before the limit not initialized")
+	}
+	if tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), "."); tags.Err() != nil {
+		t.Fatalf("default limits exceeded: %v", tags.Err())
+	}
+}
+
+func TestWithoutRuntimeStats(t *testing.T) {
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(&resetMetrics{}, func() {}, 0, r, ".", WithoutRuntimeStats())
+	mTags.Scrape()
+	r.Each(func(name string, _ interface{}) {
+		if strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "debug.") {
+			t.Fatalf("runtime stat %s registered", name)
+		}
+	})
+}
+
+func TestPanicRecovery(t *testing.T) {
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(&metaMetrics{}, func() { panic("boom") }, time.Second, r, ".")
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("the panic was recovered without WithPanicRecovery")
+			}
+		}()
+		mTags.flush()
+	}()
+	if c := r.Get("tagtrics.flush.errors").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 flush error, got %d", c)
+	}
+
+	r = metrics.NewRegistry()
+	mTags = NewMetricTags(&metaMetrics{}, func() { panic("boom") }, time.Second, r, ".",
+		WithLogger(&recordingLogger{}), WithPanicRecovery())
+	if err := mTags.flushWithin(time.Second); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected the panic as an error, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package tagtrics
+
+import (
+	"sync"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// Outcome counts the successes and failures of an operation such as calls
+// to a dependency.  It is exported as two counters suffixed with "success"
+// and "failure" and a gauge suffixed with "error_rate" holding the ratio of
+// failures to all outcomes recorded in the last flush interval, between 0
+// and 1, so alerts don't have to compute it from two series.
+type Outcome interface {
+	// Success records a successful outcome.
+	Success()
+	// Failure records a failed outcome.
+	Failure()
+	// Successes returns the number of successful outcomes.
+	Successes() int64
+	// Failures returns the number of failed outcomes.
+	Failures() int64
+	// ErrorRate returns the ratio of failures in the last flush interval.
+	ErrorRate() float64
+}
+
+// NewOutcome constructs a new Outcome.
+func NewOutcome() Outcome {
+	return &outcome{
+		success:   metrics.NewCounter(),
+		failure:   metrics.NewCounter(),
+		errorRate: metrics.NewGaugeFloat64(),
+	}
+}
+
+// outcome is the standard implementation of an Outcome.
+type outcome struct {
+	success, failure metrics.Counter
+	errorRate        metrics.GaugeFloat64
+	// mutex guards the counts at the previous update.
+	mutex                    sync.Mutex
+	lastSuccess, lastFailure int64
+}
+
+// ErrorRate returns the ratio of failures in the last flush interval.
+func (o *outcome) ErrorRate() float64 {
+	return o.errorRate.Value()
+}
+
+// Failure records a failed outcome.
+func (o *outcome) Failure() {
+	o.failure.Inc(1)
+}
+
+// Failures returns the number of failed outcomes.
+func (o *outcome) Failures() int64 {
+	return o.failure.Count()
+}
+
+// Success records a successful outcome.
+func (o *outcome) Success() {
+	o.success.Inc(1)
+}
+
+// Successes returns the number of successful outcomes.
+func (o *outcome) Successes() int64 {
+	return o.success.Count()
+}
+
+// update sets the error rate to the ratio of failures since the previous
+// update.
+func (o *outcome) update(time.Time) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	success, failure := o.success.Count(), o.failure.Count()
+	ds, df := success-o.lastSuccess, failure-o.lastFailure
+	if ds < 0 || df < 0 {
+		// The counters were cleared, e.g. by Reset.
+		ds, df = success, failure
+	}
+	rate := 0.0
+	if total := ds + df; total > 0 {
+		rate = float64(df) / float64(total)
+	}
+	o.errorRate.Update(rate)
+	o.lastSuccess, o.lastFailure = success, failure
+}
+
+// exportedMetrics returns the success and failure counters and the error
+// rate gauge.
+func (o *outcome) exportedMetrics() map[string]interface{} {
+	return map[string]interface{}{
+		"success":    o.success,
+		"failure":    o.failure,
+		"error_rate": o.errorRate,
+	}
+}
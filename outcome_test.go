@@ -0,0 +1,46 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type outcomeMetrics struct {
+	Calls Outcome `metric:"calls"`
+}
+
+func TestOutcome(t *testing.T) {
+	m := &outcomeMetrics{}
+	mTags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	for i := 0; i < 3; i++ {
+		m.Calls.Success()
+	}
+	m.Calls.Failure()
+	mTags.flush()
+	if m.Calls.ErrorRate() != 0.25 {
+		t.Fatalf("expected an error rate of 0.25, got %v", m.Calls.ErrorRate())
+	}
+
+	m.Calls.Failure()
+	mTags.flush()
+	s := mTags.Snapshot()
+	if s.Stats("calls.success")["count"] != 3 || s.Stats("calls.failure")["count"] != 2 {
+		t.Fatalf("unexpected counts %v %v", s.Stats("calls.success"), s.Stats("calls.failure"))
+	}
+	if s.Stats("calls.error_rate")["value"] != 1 {
+		t.Fatalf("expected the error rate of the last interval, got %v", s.Stats("calls.error_rate"))
+	}
+	if meta, _ := mTags.Metadata("calls.error_rate"); meta.Type != "gauge" {
+		t.Fatalf("unexpected error rate metadata %+v", meta)
+	}
+	if meta, _ := mTags.Metadata("calls.success"); meta.Type != "counter" {
+		t.Fatalf("unexpected success metadata %+v", meta)
+	}
+
+	mTags.flush()
+	if m.Calls.ErrorRate() != 0 {
+		t.Fatalf("expected no error rate without outcomes, got %v", m.Calls.ErrorRate())
+	}
+}
@@ -0,0 +1,53 @@
+package tagtrics
+
+import (
+	"time"
+)
+
+// WithFlushPacing sends the snapshot of every flush to the sinks in chunks
+// of at most chunkSize metrics spread evenly over window, half the flush
+// interval if zero or less, instead of in one burst which spikes the CPU
+// and the network of registries of a hundred thousand series.  Every chunk
+// is a part of the same snapshot so the values of a flush are still from
+// the same point in time.  The update handler and the FlushFunc still get
+// the whole snapshot.  With WithDelivery, the queues must hold the chunks
+// of a flush.  Sizes below 1 disable pacing.
+func WithFlushPacing(chunkSize int, window time.Duration) Option {
+	return func(m *MetricTags) {
+		m.chunkSize, m.pacingWindow = chunkSize, window
+	}
+}
+
+// sendPaced sends the snapshot s to the sinks in chunks as configured by
+// WithFlushPacing, the next flush being due in interval.
+func (m *MetricTags) sendPaced(s *Snapshot, interval time.Duration) {
+	if m.chunkSize < 1 {
+		m.send(s)
+		return
+	}
+	names := s.Names()
+	if len(names) <= m.chunkSize {
+		m.send(s)
+		return
+	}
+	window := m.pacingWindow
+	if window <= 0 {
+		window = interval / 2
+	}
+	chunks := (len(names) + m.chunkSize - 1) / m.chunkSize
+	pause := window / time.Duration(chunks)
+	for i := 0; i < len(names); i += m.chunkSize {
+		if i > 0 {
+			time.Sleep(pause)
+		}
+		end := i + m.chunkSize
+		if end > len(names) {
+			end = len(names)
+		}
+		chunk := make(map[string]bool, end-i)
+		for _, name := range names[i:end] {
+			chunk[name] = true
+		}
+		m.send(s.filter(func(name string) bool { return chunk[name] }))
+	}
+}
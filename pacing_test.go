@@ -0,0 +1,43 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFlushPacing(t *testing.T) {
+	m := &metaMetrics{}
+	mTags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".",
+		WithLogger(&recordingLogger{}), WithFlushPacing(1, 10*time.Millisecond))
+	var chunks []*Snapshot
+	mTags.AddSink(SinkFunc(func(s *Snapshot) error {
+		chunks = append(chunks, s)
+		return nil
+	}))
+	m.Queue.Depth.Update(3)
+	mTags.flush()
+
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	seen := make(map[string]bool)
+	for _, chunk := range chunks {
+		if len(chunk.Metrics) != 1 {
+			t.Fatalf("unexpected chunk of %d metrics", len(chunk.Metrics))
+		}
+		if !chunk.Time.Equal(chunks[0].Time) {
+			t.Fatalf("expected the chunks of the same snapshot")
+		}
+		for name := range chunk.Metrics {
+			if seen[name] {
+				t.Fatalf("metric %q sent twice", name)
+			}
+			seen[name] = true
+		}
+	}
+	if !seen["queue.depth"] || !seen["queue.wait"] {
+		t.Fatalf("expected every metric to be sent, got %v", seen)
+	}
+}
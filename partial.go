@@ -0,0 +1,67 @@
+package tagtrics
+
+import (
+	"context"
+)
+
+// FlushPrefix sends the metrics named prefix or beneath it, e.g.
+// "messages.smtp", to the sinks and the FlushFunc right away instead of
+// waiting for the next flush, e.g. once a batch job is done.  The update
+// handler, which reads the metrics itself, isn't called and the metrics
+// derived or windowed per flush are left for the next flush.  With
+// WithDelivery it waits for the metrics to be delivered to the sinks, or
+// dropped after the retries.  It returns ctx.Err() if ctx is done first, or
+// the error of the FlushFunc or of the first sink which failed, or
+// ErrPaused while the flushes are paused.
+func (m *MetricTags) FlushPrefix(ctx context.Context, prefix string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if m.Paused() {
+		return ErrPaused
+	}
+	s := m.snapshot(m.nowHandler()).WithPrefix(prefix)
+	var err error
+	if m.flushFunc != nil {
+		err = m.flushFunc(ctx, s)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if serr := m.send(s); err == nil {
+		err = serr
+	}
+	if werr := m.waitDelivery(ctx); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// Flush flushes right away like Run does on schedule, e.g. before a command
+// line tool exits or once a significant unit of work is done.  With
+// WithDelivery it waits for the snapshot to be delivered to the sinks, or
+// dropped after the retries, so nothing is lost if the process exits next.
+// It returns the error of the update handler, the FlushFunc or the first
+// failing flush hook, or ErrPaused while the flushes are paused.  It can be
+// called whether Run is running or not.
+func (m *MetricTags) Flush() error {
+	return m.FlushContext(context.Background())
+}
+
+// FlushContext flushes like Flush, passing ctx to the FlushFunc and the
+// flush hooks instead of a context whose deadline is the next flush.  It
+// returns ctx.Err() without flushing if ctx is already done, or if it is
+// done before the snapshot is delivered with WithDelivery.
+func (m *MetricTags) FlushContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if m.Paused() {
+		return ErrPaused
+	}
+	err := m.flushContext(ctx, 0)
+	if werr := m.waitDelivery(ctx); werr != nil {
+		return werr
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package tagtrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFlushPrefix(t *testing.T) {
+	m := &metaMetrics{}
+	mTags := NewMetricTags(m, func() { t.Fatalf("unexpected update handler call") }, time.Second, metrics.NewRegistry(), ".", WithLogger(&recordingLogger{}))
+	var sent *Snapshot
+	mTags.AddSink(SinkFunc(func(s *Snapshot) error {
+		sent = s
+		return nil
+	}))
+	m.Queue.Depth.Update(3)
+
+	if err := mTags.FlushPrefix(context.Background(), "queue"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(sent.Names()) == 0 {
+		t.Fatalf("expected the metrics of the queue")
+	}
+	for _, name := range sent.Names() {
+		if !strings.HasPrefix(name, "queue.") {
+			t.Fatalf("unexpected metric %q", name)
+		}
+	}
+	if sent.Stats("queue.depth")["value"] != 3 {
+		t.Fatalf("unexpected depth %v", sent.Stats("queue.depth"))
+	}
+
+	mTags.AddSink(SinkFunc(func(s *Snapshot) error {
+		return errors.New("backend down")
+	}))
+	if err := mTags.FlushPrefix(context.Background(), "queue"); err == nil || err.Error() != "backend down" {
+		t.Fatalf("expected the sink error, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := mTags.FlushPrefix(ctx, "queue"); err != context.Canceled {
+		t.Fatalf("expected a canceled context, got %v", err)
+	}
+}
+
+type apiMetrics struct {
+	API struct {
+		HTTP struct {
+			Latency  metrics.Timer   `metric:"latency"`
+			Requests metrics.Counter `metric:"requests,rate"`
+		} `metric:"http,separator=_"`
+		HTTPS struct {
+			Latency metrics.Timer `metric:"latency"`
+		} `metric:"https"`
+	} `metric:"api"`
+}
+
+func TestWithPrefixSeparator(t *testing.T) {
+	m := &apiMetrics{}
+	mTags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithLogger(&recordingLogger{}))
+	s := mTags.Snapshot()
+	for prefix, want := range map[string]string{
+		"api.http":          "api.http_latency api.http_requests api.http_requests_rate",
+		"api.http_requests": "api.http_requests api.http_requests_rate",
+		"api.https":         "api.https.latency",
+		"api.ht":            "",
+	} {
+		if got := strings.Join(s.WithPrefix(prefix).Names(), " "); got != want {
+			t.Errorf("WithPrefix(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+	if got := len(s.WithPrefix("api").Names()); got != 4 {
+		t.Errorf("expected every api metric, got %v", s.WithPrefix("api").Names())
+	}
+
+	m.API.HTTP.Requests.Inc(1)
+	m.API.HTTPS.Latency.Update(time.Second)
+	mTags.ResetPrefix("api.http")
+	if m.API.HTTP.Requests.Count() != 0 || m.API.HTTPS.Latency.Count() != 1 {
+		t.Fatalf("unexpected reset of api.http")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	flushes := 0
+	mTags := NewMetricTags(&metaMetrics{}, func() { flushes++ }, time.Hour, metrics.NewRegistry(), ".", WithLogger(&recordingLogger{}),
+		WithFlushFunc(func(ctx context.Context, s *Snapshot) error {
+			if ctx.Value(flushKey{}) != "manual" {
+				return errors.New("missing context")
+			}
+			return nil
+		}))
+	var sent *Snapshot
+	mTags.AddSink(SinkFunc(func(s *Snapshot) error {
+		sent = s
+		return nil
+	}))
+
+	ctx := context.WithValue(context.Background(), flushKey{}, "manual")
+	if err := mTags.FlushContext(ctx); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if flushes != 1 || sent == nil {
+		t.Fatalf("expected a full flush, got %d flushes", flushes)
+	}
+	if err := mTags.Flush(); err == nil || err.Error() != "missing context" {
+		t.Fatalf("expected the error of the FlushFunc, got %v", err)
+	}
+	mTags.Pause()
+	if err := mTags.Flush(); err != ErrPaused {
+		t.Fatalf("expected ErrPaused, got %v", err)
+	}
+}
+
+type flushKey struct{}
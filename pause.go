@@ -0,0 +1,52 @@
+package tagtrics
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPaused is returned by FlushPrefix while the flushes are paused.
+var ErrPaused = errors.New("tagtrics: flushes are paused")
+
+// Pause stops the flushes, e.g. while the metrics backend is migrated, until
+// Resume is called.  The metrics keep being updated, the metrics reset per
+// flush accumulate until the next flush and the scrape handlers keep being
+// served.  Flushes due while paused, including the final one of Stop, are
+// skipped and counted in the "tagtrics.flush.skipped" self metric.
+func (m *MetricTags) Pause() {
+	m.pauseMutex.Lock()
+	defer m.pauseMutex.Unlock()
+	if m.pausedAt.IsZero() {
+		m.pausedAt = time.Now()
+	}
+}
+
+// Resume resumes the flushes stopped by Pause.  The length of the pause is
+// reported by the next flush in the "tagtrics.flush.gap" self metric so
+// dashboards can tell the gap from an outage.
+func (m *MetricTags) Resume() {
+	m.pauseMutex.Lock()
+	defer m.pauseMutex.Unlock()
+	if m.pausedAt.IsZero() {
+		return
+	}
+	m.self.Flush.Gap.Update(int64(time.Since(m.pausedAt) / time.Second))
+	m.pausedAt = time.Time{}
+}
+
+// Paused reports whether the flushes are paused.
+func (m *MetricTags) Paused() bool {
+	m.pauseMutex.Lock()
+	defer m.pauseMutex.Unlock()
+	return !m.pausedAt.IsZero()
+}
+
+// skipFlush reports whether the flushes are paused, counting the flush
+// skipped.
+func (m *MetricTags) skipFlush() bool {
+	if !m.Paused() {
+		return false
+	}
+	m.self.Flush.Skipped.Inc(1)
+	return true
+}
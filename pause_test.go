@@ -0,0 +1,41 @@
+package tagtrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestPause(t *testing.T) {
+	flushes := 0
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(&metaMetrics{}, func() { flushes++ }, time.Second, r, ".")
+
+	mTags.Pause()
+	if !mTags.Paused() {
+		t.Fatalf("expected the flushes to be paused")
+	}
+	mTags.flush()
+	if err := mTags.FlushPrefix(context.Background(), "queue"); err != ErrPaused {
+		t.Fatalf("expected ErrPaused, got %v", err)
+	}
+	if flushes != 0 {
+		t.Fatalf("unexpected flush while paused")
+	}
+	if c := r.Get("tagtrics.flush.skipped").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 skipped flush, got %d", c)
+	}
+
+	// Pretend the pause lasted a minute.
+	mTags.pausedAt = mTags.pausedAt.Add(-time.Minute)
+	mTags.Resume()
+	mTags.flush()
+	if flushes != 1 || mTags.Paused() {
+		t.Fatalf("expected the flushes to resume")
+	}
+	if v := r.Get("tagtrics.flush.gap").(metrics.Gauge).Value(); v != 60 {
+		t.Fatalf("expected a gap of 60s, got %d", v)
+	}
+}
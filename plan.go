@@ -0,0 +1,149 @@
+package tagtrics
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// fieldKind is how the traversal handles a struct field or array element.
+type fieldKind int
+
+const (
+	// metricField is a metric, or a field of an unsupported type.
+	metricField fieldKind = iota
+	initializerField
+	typedField
+	lazyField
+	funcField
+	structField
+	arrayField
+	sliceField
+	mapField
+	// skippedField is an unexported field, which reflect cannot set or
+	// read the address of, or a time field without a "metric" tag.
+	skippedField
+)
+
+var (
+	initializerType = reflect.TypeOf((*Initializer)(nil)).Elem()
+	typedMetricType = reflect.TypeOf((*typedMetric)(nil)).Elem()
+	lazyMetricType  = reflect.TypeOf((*lazyMetric)(nil)).Elem()
+	stringerType    = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+)
+
+// kindOf returns how the traversal handles values of type t.
+func kindOf(t reflect.Type) fieldKind {
+	p := reflect.PointerTo(t)
+	switch {
+	case p.Implements(initializerType):
+		return initializerField
+	case p.Implements(typedMetricType):
+		return typedField
+	case p.Implements(lazyMetricType):
+		return lazyField
+	}
+	if _, ok := funcKinds[t.String()]; ok {
+		return funcField
+	}
+	switch {
+	case t.Kind() == reflect.Struct:
+		return structField
+	case t.Kind() == reflect.Array:
+		return arrayField
+	case isStructSlice(t):
+		return sliceField
+	case t.Kind() == reflect.Map && isNameKey(t.Key()):
+		return mapField
+	}
+	return metricField
+}
+
+// isTimeType reports whether t is time.Time or time.Duration, which are
+// only exported as gauges when tagged since structs commonly hold them for
+// other purposes, e.g. timeouts and deadlines.
+func isTimeType(t reflect.Type) bool {
+	return t == timeType || t == durationType
+}
+
+// isNameKey reports whether the keys of type t of a map field can be used
+// as name segments, which strings and fmt.Stringer implementations can.
+func isNameKey(t reflect.Type) bool {
+	return t.Kind() == reflect.String || t.Implements(stringerType)
+}
+
+// keyName returns the name segment of the map key k, the string itself or
+// the output of String for other fmt.Stringer implementations.
+func keyName(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	return k.Interface().(fmt.Stringer).String()
+}
+
+// fieldPlan is what the traversal needs to know about a struct field.  It
+// only depends on the struct type so it is computed once per type.
+type fieldPlan struct {
+	index int
+	// name is the name in the "metric" tag, empty to derive it from
+	// fieldName.
+	name, fieldName  string
+	opts             tagOptions
+	help, unit, sink string
+	// typeName is the type of the field formatted by reflect.Type.String.
+	typeName string
+	kind     fieldKind
+	// elem is the kind of the elements of an array field.
+	elem fieldKind
+}
+
+// plans caches the fields of the struct types traversed so far keyed by
+// type, so MetricTags of the same type, such as one per plugin, only
+// inspect it once.
+var plans sync.Map
+
+// planOf returns the plan of the fields of the struct type t.  The plan and
+// its tag options must not be modified.
+func planOf(t reflect.Type) []fieldPlan {
+	if p, ok := plans.Load(t); ok {
+		return p.([]fieldPlan)
+	}
+	p := make([]fieldPlan, t.NumField())
+	for i := range p {
+		field := t.Field(i)
+		name, opts := parseTag(field.Tag.Get("metric"))
+		p[i] = fieldPlan{
+			index:     i,
+			name:      name,
+			fieldName: field.Name,
+			opts:      opts,
+			help:      field.Tag.Get("help"),
+			unit:      field.Tag.Get("unit"),
+			sink:      field.Tag.Get("sink"),
+			typeName:  field.Type.String(),
+			kind:      kindOf(field.Type),
+		}
+		_, tagged := field.Tag.Lookup("metric")
+		if !field.IsExported() || !tagged && isTimeType(field.Type) {
+			p[i].kind = skippedField
+		}
+		if p[i].kind == arrayField {
+			p[i].elem = kindOf(field.Type.Elem())
+		}
+	}
+	actual, _ := plans.LoadOrStore(t, p)
+	return actual.([]fieldPlan)
+}
+
+// rescanned reports whether fields of kind k are traversed by a rescan,
+// which only traverses the fields which may hold maps and slices.
+func (k fieldKind) rescanned() bool {
+	switch k {
+	case structField, arrayField, sliceField, mapField:
+		return true
+	}
+	return false
+}
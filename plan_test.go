@@ -0,0 +1,87 @@
+package tagtrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+type pluginMetrics struct {
+	Calls      metrics.Counter `metric:"calls"`
+	ErrorCount metrics.Counter
+	Shards     [2]shardMetrics `metric:"shard"`
+	Config     string
+}
+
+func TestPlanShared(t *testing.T) {
+	typ := reflect.TypeOf(pluginMetrics{})
+	plan := planOf(typ)
+	if &planOf(typ)[0] != &plan[0] {
+		t.Fatalf("plan not cached")
+	}
+	if plan[0].name != "calls" || plan[1].name != "" || plan[2].kind != arrayField || plan[2].elem != structField {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+
+	for nameCase, derived := range map[NameCase]string{LowerCase: "errorcount", SnakeCase: "error_count"} {
+		r := metrics.NewRegistry()
+		m := &pluginMetrics{}
+		NewMetricTags(m, func() {}, time.Second, r, ".", WithDerivedNameCase(nameCase))
+		m.Shards[1].Hits.Inc(1)
+		if r.Get("shard.1.hits").(metrics.Counter).Count() != 1 {
+			t.Fatalf("shard.1.hits not registered")
+		}
+		if r.Get(derived) == nil {
+			t.Fatalf("%s not registered", derived)
+		}
+	}
+}
+
+// region is a map key implementing fmt.Stringer.
+type region int
+
+func (r region) String() string {
+	return [...]string{"us-east", "eu-west"}[r]
+}
+
+func TestStringerKeys(t *testing.T) {
+	r := metrics.NewRegistry()
+	m := &struct {
+		Regions map[region]*subMetrics `metric:"regions"`
+		Shards  map[int]*subMetrics    `metric:"shards"`
+	}{
+		Regions: map[region]*subMetrics{0: {}, 1: {}},
+		Shards:  map[int]*subMetrics{0: {}},
+	}
+	NewMetricTags(m, func() {}, time.Second, r, ".", WithLogger(&recordingLogger{}))
+	if r.Get("regions.us-east.counter") == nil || r.Get("regions.eu-west.counter") == nil {
+		t.Fatalf("unexpected metrics: %v", r.GetAll())
+	}
+	if m.Shards[0].Counter != nil {
+		t.Fatalf("map keyed by a type without a String method was traversed")
+	}
+}
+
+func TestUnexportedFields(t *testing.T) {
+	r := metrics.NewRegistry()
+	m := &struct {
+		Calls   metrics.Counter `metric:"calls"`
+		started time.Time
+		elapsed time.Duration
+		depth   func() int64
+	}{started: time.Now()}
+	mTags := NewMetricTags(m, func() {}, time.Second, r, ".")
+	if err := mTags.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Get("calls") == nil {
+		t.Fatalf("calls not registered")
+	}
+	for _, name := range []string{"started", "elapsed", "depth"} {
+		if r.Get(name) != nil {
+			t.Fatalf("unexported field %s registered", name)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package tagtrics
+
+import (
+	"context"
+	"runtime/pprof"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// PprofLabel is the pprof label holding the metric name of the timer of a
+// TimedSection.
+const PprofLabel = "metric"
+
+// TimedSection calls f, records its duration in timer and sets the PprofLabel
+// pprof label to the metric name of timer for the goroutine while f runs, so
+// CPU profiles can be sliced by the names dashboards and alerts use, e.g.
+// with "go tool pprof -tagfocus metric=http.latency".  f gets the context
+// carrying the label to pass to goroutines it starts.  Timers not initialized
+// by m are labeled with an empty name.
+func (m *MetricTags) TimedSection(ctx context.Context, timer metrics.Timer, f func(ctx context.Context)) {
+	m.metaMutex.RLock()
+	name := m.timerNames[timer]
+	m.metaMutex.RUnlock()
+	pprof.Do(ctx, pprof.Labels(PprofLabel, name), func(ctx context.Context) {
+		start := time.Now()
+		defer timer.UpdateSince(start)
+		f(ctx)
+	})
+}
@@ -0,0 +1,29 @@
+package tagtrics
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestTimedSection(t *testing.T) {
+	m := &struct {
+		HTTP struct {
+			Latency metrics.Timer `metric:"latency"`
+		} `metric:"http"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	var label string
+	tags.TimedSection(context.Background(), m.HTTP.Latency, func(ctx context.Context) {
+		label, _ = pprof.Label(ctx, PprofLabel)
+	})
+	if label != "http.latency" {
+		t.Fatalf("expected the http.latency label, got %q", label)
+	}
+	if m.HTTP.Latency.Count() != 1 {
+		t.Fatalf("section not timed")
+	}
+}
@@ -0,0 +1,53 @@
+package tagtrics
+
+import (
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// derived is implemented by metrics computed from other metrics right before
+// every flush.
+type derived interface {
+	// update recomputes the metric at time now.
+	update(now time.Time)
+}
+
+// counterRate maintains the per second rate of a counter between flushes for
+// backends without a native rate function.
+type counterRate struct {
+	counter  metrics.Counter
+	gauge    metrics.GaugeFloat64
+	count    int64
+	lastTime time.Time
+	// reset is set for the counters reset at every flush, whose count is
+	// already the change since the previous flush.
+	reset bool
+}
+
+// newCounterRate returns the rate of c starting at now.
+func newCounterRate(c metrics.Counter, now time.Time) *counterRate {
+	_, reset := c.(*resetCounter)
+	return &counterRate{
+		counter:  c,
+		gauge:    metrics.NewGaugeFloat64(),
+		count:    c.Count(),
+		lastTime: now,
+		reset:    reset,
+	}
+}
+
+// update sets the gauge to the change of the counter per second since the
+// previous update.  It must run after the update of a reset counter.
+func (r *counterRate) update(now time.Time) {
+	count := r.counter.Count()
+	if count < r.count || r.reset {
+		// The counter was cleared, e.g. by Reset, or counts from zero
+		// every flush.
+		r.count = 0
+	}
+	if elapsed := now.Sub(r.lastTime).Seconds(); elapsed > 0 {
+		r.gauge.Update(float64(count-r.count) / elapsed)
+	}
+	r.count, r.lastTime = count, now
+}
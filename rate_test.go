@@ -0,0 +1,70 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type rateMetrics struct {
+	Sent metrics.Counter `metric:"sent,rate" unit:"messages"`
+}
+
+func TestCounterRate(t *testing.T) {
+	m := &rateMetrics{}
+	now := time.Unix(1000, 0)
+	var rate float64
+	var mTags *MetricTags
+	h := func() {
+		rate = mTags.Snapshot().Stats("sent.rate")["value"]
+	}
+	mTags = NewMetricTags(m, h, time.Second, metrics.NewRegistry(), ".")
+	mTags.nowHandler = func() time.Time { return now }
+	// Reset the starting point to the fake clock.
+	mTags.derived[0].(*counterRate).lastTime = now
+
+	m.Sent.Inc(50)
+	now = now.Add(10 * time.Second)
+	mTags.flush()
+	if rate != 5 {
+		t.Fatalf("expected a rate of 5/s, got %f", rate)
+	}
+	m.Sent.Inc(10)
+	now = now.Add(5 * time.Second)
+	mTags.flush()
+	if rate != 2 {
+		t.Fatalf("expected a rate of 2/s, got %f", rate)
+	}
+	if meta, _ := mTags.Metadata("sent.rate"); meta.Unit != "messages/s" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+}
+
+type resetRateMetrics struct {
+	Sent metrics.Counter `metric:"sent,reset,rate"`
+}
+
+func TestResetCounterRate(t *testing.T) {
+	m := &resetRateMetrics{}
+	now := time.Unix(1000, 0)
+	var count int64
+	var rate float64
+	var mTags *MetricTags
+	h := func() {
+		s := mTags.Snapshot()
+		count, rate = int64(s.Stats("sent")["count"]), s.Stats("sent.rate")["value"]
+	}
+	mTags = NewMetricTags(m, h, time.Second, metrics.NewRegistry(), ".")
+	mTags.nowHandler = func() time.Time { return now }
+	mTags.derived[1].(*counterRate).lastTime = now
+
+	for i := 0; i < 3; i++ {
+		m.Sent.Inc(10)
+		now = now.Add(10 * time.Second)
+		mTags.flush()
+		if count != 10 || rate != 1 {
+			t.Fatalf("flush %d: expected a count of 10 at 1/s, got %d at %f/s", i, count, rate)
+		}
+	}
+}
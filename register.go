@@ -0,0 +1,32 @@
+package tagtrics
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// registeredStruct is a struct added with Register.
+type registeredStruct struct {
+	prefix    string
+	structPtr interface{}
+}
+
+// Register initializes the metric fields of the struct structPtr points to
+// with names prefixed with prefix, if any, so that every package can own
+// its metrics struct while a single MetricTags manages the registry, the
+// flushes and the sinks of the process.  The metrics are named, flushed,
+// unregistered and rescanned along with those of metricsData.  It returns
+// the error which stopped the initialization, if any.
+func (m *MetricTags) Register(prefix string, structPtr interface{}) error {
+	v := reflect.ValueOf(structPtr)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("tagtrics: %T is not a pointer to a struct", structPtr)
+	}
+	if err := m.initStruct(prefix, structPtr); err != nil {
+		return err
+	}
+	m.scanMutex.Lock()
+	m.structs = append(m.structs, registeredStruct{prefix: prefix, structPtr: structPtr})
+	m.scanMutex.Unlock()
+	return nil
+}
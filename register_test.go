@@ -0,0 +1,55 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestRegister(t *testing.T) {
+	r := metrics.NewRegistry()
+	var sent *Snapshot
+	tags := NewMetricTags(&metaMetrics{}, func() {}, time.Second, r, ".")
+	tags.AddSink(SinkFunc(func(s *Snapshot) error {
+		sent = s
+		return nil
+	}))
+
+	consumers := &struct {
+		Services map[string]*consumerMetrics `metric:"services"`
+	}{Services: map[string]*consumerMetrics{"mysql": {}}}
+	if err := tags.Register("kafka", consumers); err != nil {
+		t.Fatal(err)
+	}
+	if err := tags.Register("", &defaultMetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tags.Register("bad", defaultMetrics{}); err == nil {
+		t.Fatalf("expected error registering a struct value")
+	}
+	for _, name := range []string{"queue.depth", "kafka.services.mysql.lag", "jobs.done"} {
+		if r.Get(name) == nil {
+			t.Fatalf("%s not registered", name)
+		}
+	}
+
+	consumers.Services["mysql"].Lag.Update(3)
+	tags.flush()
+	if sent == nil || sent.Stats("kafka.services.mysql.lag")["value"] != 3 {
+		t.Fatalf("registered metrics not flushed: %+v", sent)
+	}
+
+	consumers.Services["redis"] = &consumerMetrics{}
+	if err := tags.Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Get("kafka.services.redis.lag") == nil {
+		t.Fatalf("registered struct not rescanned")
+	}
+
+	tags.Unregister()
+	if r.Get("kafka.services.mysql.lag") != nil || r.Get("jobs.done") != nil {
+		t.Fatalf("registered metrics not unregistered")
+	}
+}
@@ -0,0 +1,150 @@
+package tagtrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// newResetMetric returns a metric for a field of the given type which is
+// reset at every flush or nil if the type doesn't support it.
+func newResetMetric(typeName string, opts tagOptions, sampleSize int) interface{} {
+	switch typeName {
+	case "metrics.Counter":
+		return &resetCounter{}
+	case "metrics.Histogram":
+		return newResetHistogram(func() metrics.Histogram {
+			return newMetric(typeName, opts, sampleSize).(metrics.Histogram)
+		})
+	case "metrics.Timer":
+		return newHistogramTimer(newResetHistogram(func() metrics.Histogram {
+			if h, _ := opts.histogram(); h != nil {
+				return h
+			}
+			return defaultTimerHistogram(sampleSize)
+		}))
+	}
+	return nil
+}
+
+// resetCounter is a metrics.Counter counting per flush interval.  Updates
+// go to the current interval while readers see the count of the previous
+// interval, which is swapped in atomically right before every flush.
+type resetCounter struct {
+	current int64
+	last    int64
+}
+
+// Clear sets the counter to zero.
+func (c *resetCounter) Clear() {
+	atomic.StoreInt64(&c.current, 0)
+	atomic.StoreInt64(&c.last, 0)
+}
+
+// Count returns the count of the previous interval.
+func (c *resetCounter) Count() int64 {
+	return atomic.LoadInt64(&c.last)
+}
+
+// Dec decrements the counter by the given amount.
+func (c *resetCounter) Dec(i int64) {
+	atomic.AddInt64(&c.current, -i)
+}
+
+// Inc increments the counter by the given amount.
+func (c *resetCounter) Inc(i int64) {
+	atomic.AddInt64(&c.current, i)
+}
+
+// Snapshot returns a read-only copy of the count of the previous interval.
+func (c *resetCounter) Snapshot() metrics.Counter {
+	return metrics.CounterSnapshot(c.Count())
+}
+
+// update starts a new interval.
+func (c *resetCounter) update(time.Time) {
+	atomic.StoreInt64(&c.last, atomic.SwapInt64(&c.current, 0))
+}
+
+// resetHistogram is a metrics.Histogram sampling per flush interval.  Updates
+// go to the histogram of the current interval while readers see the
+// histogram of the previous interval, which is swapped in right before every
+// flush.
+type resetHistogram struct {
+	mutex   sync.RWMutex
+	current metrics.Histogram
+	last    metrics.Histogram
+	// create returns an empty histogram for a new interval.
+	create func() metrics.Histogram
+}
+
+// newResetHistogram returns a histogram of histograms created with create.
+func newResetHistogram(create func() metrics.Histogram) *resetHistogram {
+	return &resetHistogram{current: create(), last: create(), create: create}
+}
+
+// lastInterval returns the histogram of the previous interval.
+func (h *resetHistogram) lastInterval() metrics.Histogram {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.last
+}
+
+// Clear clears the histogram.
+func (h *resetHistogram) Clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.current, h.last = h.create(), h.create()
+}
+
+// Count returns the number of samples recorded in the previous interval.
+func (h *resetHistogram) Count() int64 { return h.lastInterval().Count() }
+
+// Max returns the maximum value of the previous interval.
+func (h *resetHistogram) Max() int64 { return h.lastInterval().Max() }
+
+// Mean returns the mean of the values of the previous interval.
+func (h *resetHistogram) Mean() float64 { return h.lastInterval().Mean() }
+
+// Min returns the minimum value of the previous interval.
+func (h *resetHistogram) Min() int64 { return h.lastInterval().Min() }
+
+// Percentile returns an arbitrary percentile of the previous interval.
+func (h *resetHistogram) Percentile(p float64) float64 { return h.lastInterval().Percentile(p) }
+
+// Percentiles returns a slice of arbitrary percentiles of the previous
+// interval.
+func (h *resetHistogram) Percentiles(ps []float64) []float64 { return h.lastInterval().Percentiles(ps) }
+
+// Sample returns the sample of the previous interval.
+func (h *resetHistogram) Sample() metrics.Sample { return h.lastInterval().Sample() }
+
+// Snapshot returns a read-only copy of the previous interval.
+func (h *resetHistogram) Snapshot() metrics.Histogram { return h.lastInterval().Snapshot() }
+
+// StdDev returns the standard deviation of the values of the previous
+// interval.
+func (h *resetHistogram) StdDev() float64 { return h.lastInterval().StdDev() }
+
+// Sum returns the sum of the values of the previous interval.
+func (h *resetHistogram) Sum() int64 { return h.lastInterval().Sum() }
+
+// Update records a value in the current interval.
+func (h *resetHistogram) Update(v int64) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	h.current.Update(v)
+}
+
+// Variance returns the variance of the values of the previous interval.
+func (h *resetHistogram) Variance() float64 { return h.lastInterval().Variance() }
+
+// update starts a new interval.
+func (h *resetHistogram) update(time.Time) {
+	next := h.create()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.current, h.last = next, h.current
+}
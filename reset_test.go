@@ -0,0 +1,65 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type resetMetrics struct {
+	Sent    metrics.Counter   `metric:"sent,reset"`
+	Total   metrics.Counter   `metric:"total"`
+	Size    metrics.Histogram `metric:"size,reset"`
+	Latency metrics.Timer     `metric:"latency,reset,sample=hdr"`
+}
+
+func TestResetOnFlush(t *testing.T) {
+	m := &resetMetrics{}
+	var stats map[string]map[string]float64
+	var mTags *MetricTags
+	h := func() {
+		s := mTags.Snapshot()
+		stats = map[string]map[string]float64{}
+		for _, name := range []string{"sent", "total", "size", "latency"} {
+			stats[name] = s.Stats(name)
+		}
+	}
+	mTags = NewMetricTags(m, h, time.Second, metrics.NewRegistry(), ".")
+
+	m.Sent.Inc(3)
+	m.Total.Inc(3)
+	m.Size.Update(10)
+	m.Latency.Update(time.Millisecond)
+	if m.Sent.Count() != 0 {
+		t.Fatalf("current interval visible before flush")
+	}
+	mTags.flush()
+	if stats["sent"]["count"] != 3 || stats["size"]["max"] != 10 || stats["latency"]["count"] != 1 {
+		t.Fatalf("unexpected stats of the first interval: %v", stats)
+	}
+	m.Sent.Inc(2)
+	m.Total.Inc(2)
+	mTags.flush()
+	if stats["sent"]["count"] != 2 || stats["total"]["count"] != 5 {
+		t.Fatalf("unexpected counts of the second interval: %v", stats)
+	}
+	if stats["size"]["count"] != 0 || stats["latency"]["count"] != 0 {
+		t.Fatalf("histograms not reset: %v", stats)
+	}
+}
+
+func TestWithResetOnFlush(t *testing.T) {
+	m := &testMetrics{}
+	mTags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithResetOnFlush())
+	m.Counter.Inc(1)
+	m.Counter.Inc(1)
+	mTags.flush()
+	mTags.flush()
+	if c := m.Counter.Count(); c != 0 {
+		t.Fatalf("expected the counter to be reset, got %d", c)
+	}
+	if _, ok := m.Meter.(metrics.Meter); !ok {
+		t.Fatalf("meter not initialized")
+	}
+}
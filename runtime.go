@@ -0,0 +1,46 @@
+package tagtrics
+
+import (
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// runtimeStats samples the Go runtime statistics of a registry as often as
+// configured in the MetricTags owning it.  They are those of
+// metrics.RegisterRuntimeMemStats and metrics.RegisterDebugGCStats, which
+// only register them in the first registry of the process.
+type runtimeStats struct {
+	gcTime, memTime time.Time
+	// disabled is set by WithoutRuntimeStats.
+	disabled bool
+}
+
+// newRuntimeStats registers the Go runtime statistics in the registry of m,
+// unless WithoutRuntimeStats is used.  They are first sampled when the
+// collection intervals have passed since now.
+func newRuntimeStats(m *MetricTags, now time.Time) *runtimeStats {
+	if m.noRuntimeStats {
+		return &runtimeStats{disabled: true}
+	}
+	metrics.RegisterDebugGCStats(m.registry)
+	metrics.RegisterRuntimeMemStats(m.registry)
+	return &runtimeStats{gcTime: now, memTime: now}
+}
+
+// capture samples the statistics which are due at now.
+func (r *runtimeStats) capture(m *MetricTags, now time.Time) {
+	if r.disabled {
+		return
+	}
+	// Get GC runtime stats
+	if now.Sub(r.gcTime) > m.StatsGCCollection {
+		metrics.CaptureDebugGCStatsOnce(m.registry)
+		r.gcTime = now
+	}
+	// Get memory runtime stats
+	if now.Sub(r.memTime) > m.StatsMemCollection {
+		metrics.CaptureRuntimeMemStatsOnce(m.registry)
+		r.memTime = now
+	}
+}
@@ -0,0 +1,60 @@
+package tagtrics
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WithFlushJitter makes Run wait a random duration within ±fraction of the
+// flush interval between flushes, e.g. 0.1 for ±10%, so instances started
+// together by a deploy don't all hit the metrics backend at once.  The
+// fraction is capped at 1 and values of zero or less disable the jitter.
+func WithFlushJitter(fraction float64) Option {
+	return func(m *MetricTags) {
+		if fraction > 1 {
+			fraction = 1
+		}
+		if fraction > 0 {
+			m.jitter = fraction
+		}
+	}
+}
+
+// WithAlignedFlushes makes Run flush on the multiples of the flush interval
+// since the Unix epoch, e.g. at the start of every minute, rather than on
+// multiples of the interval since Run started, so the flushes of every
+// instance happen at the same time.  The jitter of WithFlushJitter is
+// ignored.
+func WithAlignedFlushes() Option {
+	return func(m *MetricTags) {
+		m.aligned = true
+	}
+}
+
+// nextTick returns the tick of the flush following the tick prev and how
+// many ticks up to now were missed because a flush overran the interval.
+// Ticks are an interval apart, whatever time the flushes take, so the
+// cadence of the flushes doesn't drift.
+func (m *MetricTags) nextTick(prev, now time.Time) (time.Time, int64) {
+	interval := m.interval()
+	next := prev.Add(interval)
+	if m.aligned {
+		// Truncate would align on multiples since the zero time instead.
+		next = prev.Add(interval - time.Duration(prev.UnixNano()%int64(interval)))
+	}
+	var missed int64
+	if !next.After(now) {
+		missed = int64(now.Sub(next)/interval) + 1
+		next = next.Add(time.Duration(missed) * interval)
+	}
+	return next, missed
+}
+
+// flushTime returns when the flush of tick is due, randomized by the jitter
+// of WithFlushJitter.
+func (m *MetricTags) flushTime(tick time.Time) time.Time {
+	if m.jitter == 0 || m.aligned {
+		return tick
+	}
+	return tick.Add(time.Duration((2*rand.Float64() - 1) * m.jitter * float64(m.interval())))
+}
@@ -0,0 +1,66 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFlushJitter(t *testing.T) {
+	mTags := NewMetricTags(&metaMetrics{}, func() {}, time.Minute, metrics.NewRegistry(), ".", WithFlushJitter(0.1))
+	tick := time.Unix(1700000000, 0)
+	varied := false
+	for i := 0; i < 100; i++ {
+		d := mTags.flushTime(tick).Sub(tick)
+		if d < -6*time.Second || d > 6*time.Second {
+			t.Fatalf("offset %v out of ±10%% of the interval", d)
+		}
+		varied = varied || d != 0
+	}
+	if !varied {
+		t.Fatalf("expected the offsets to vary")
+	}
+
+	mTags = NewMetricTags(&metaMetrics{}, func() {}, time.Minute, metrics.NewRegistry(), ".", WithFlushJitter(-1))
+	if at := mTags.flushTime(tick); !at.Equal(tick) {
+		t.Fatalf("unexpected jitter %v", at.Sub(tick))
+	}
+}
+
+func TestAlignedFlushes(t *testing.T) {
+	mTags := NewMetricTags(&metaMetrics{}, func() {}, time.Minute, metrics.NewRegistry(), ".", WithAlignedFlushes(), WithFlushJitter(0.5))
+	now := time.Now()
+	next, _ := mTags.nextTick(now, now)
+	if !next.Truncate(time.Minute).Equal(next) || next.Sub(now) > time.Minute || !next.After(now) {
+		t.Fatalf("expected the start of the next minute, got %v", next)
+	}
+	if at := mTags.flushTime(next); !at.Equal(next) {
+		t.Fatalf("unexpected jitter of aligned flushes %v", at.Sub(next))
+	}
+
+	// Intervals which don't divide the time since the zero time evenly are
+	// still aligned on the Unix epoch.
+	mTags.SetFlushInterval(7 * time.Second)
+	prev := time.Unix(1700000003, 5e8)
+	next, _ = mTags.nextTick(prev, prev)
+	if next.UnixNano()%int64(7*time.Second) != 0 || !next.After(prev) || next.Sub(prev) > 7*time.Second {
+		t.Fatalf("expected a multiple of 7s since the epoch, got %v", next)
+	}
+}
+
+func TestFlushCadence(t *testing.T) {
+	mTags := NewMetricTags(&metaMetrics{}, func() {}, 30*time.Second, metrics.NewRegistry(), ".")
+	prev := time.Unix(1700000000, 0)
+
+	// A flush taking 5s doesn't delay the next one.
+	next, missed := mTags.nextTick(prev, prev.Add(5*time.Second))
+	if !next.Equal(prev.Add(30*time.Second)) || missed != 0 {
+		t.Fatalf("unexpected next tick %v, %d missed", next.Sub(prev), missed)
+	}
+	// A flush taking 70s overruns the next two ticks.
+	next, missed = mTags.nextTick(prev, prev.Add(70*time.Second))
+	if !next.Equal(prev.Add(90*time.Second)) || missed != 2 {
+		t.Fatalf("unexpected next tick %v, %d missed", next.Sub(prev), missed)
+	}
+}
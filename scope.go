@@ -0,0 +1,140 @@
+package tagtrics
+
+import (
+	"sync"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// DefaultMaxTenants and DefaultMaxTenantMetrics are the cardinality caps of
+// the scopes returned by Scope unless set with WithScopeLimits.
+const (
+	DefaultMaxTenants       = 100
+	DefaultMaxTenantMetrics = 100
+)
+
+// OtherTenant is the tenant of the scope shared by the tenants beyond the
+// cap on the number of tenants.
+const OtherTenant = "other"
+
+// scopePrefix is the namespace of the metrics created by scopes.
+const scopePrefix = "tenant"
+
+// Scope creates the metrics of a tenant of a multi-tenant service on first
+// use, e.g. the requests of each customer.  The metric created as name is
+// registered as "tenant.<tenant>.<name>" with the family "tenant.<name>"
+// and the label tenant=<tenant>.  Metrics beyond the cap of a scope are
+// no-ops counted in the "tagtrics.scope.dropped" self metric.
+type Scope struct {
+	m      *MetricTags
+	tenant string
+	mutex  sync.Mutex
+	// metrics holds the metrics created so far keyed by name.
+	metrics map[string]interface{}
+	// dropped holds the names of the metrics dropped so far, which are
+	// only logged the first time.
+	dropped map[string]bool
+}
+
+// WithScopeLimits caps the number of tenants the scopes returned by Scope
+// are created for, DefaultMaxTenants by default, and the number of metrics
+// each scope creates, DefaultMaxTenantMetrics by default, so a flood of
+// tenants or metric names can't overwhelm the registry.  The tenants beyond
+// the cap share the scope of OtherTenant.  Zero or less disables a cap.
+func WithScopeLimits(maxTenants, maxMetrics int) Option {
+	return func(m *MetricTags) {
+		m.maxTenants, m.maxTenantMetrics = maxTenants, maxMetrics
+	}
+}
+
+// Scope returns the scope of tenant.  Every call for a tenant returns the
+// same scope.
+func (m *MetricTags) Scope(tenant string) *Scope {
+	m.scopeMutex.Lock()
+	defer m.scopeMutex.Unlock()
+	if s, ok := m.scopes[tenant]; ok {
+		return s
+	}
+	if m.scopes == nil {
+		m.scopes = make(map[string]*Scope)
+	}
+	if m.maxTenants > 0 && len(m.scopes) >= m.maxTenants {
+		tenant = OtherTenant
+		if s, ok := m.scopes[tenant]; ok {
+			return s
+		}
+	}
+	s := &Scope{m: m, tenant: tenant, metrics: make(map[string]interface{})}
+	m.scopes[tenant] = s
+	return s
+}
+
+// Tenant returns the tenant of s, OtherTenant for the tenants beyond the
+// cap.
+func (s *Scope) Tenant() string {
+	return s.tenant
+}
+
+// Counter returns the counter name of the tenant.
+func (s *Scope) Counter(name string) metrics.Counter {
+	return s.metric(name, "metrics.Counter", "counter").(metrics.Counter)
+}
+
+// Gauge returns the gauge name of the tenant.
+func (s *Scope) Gauge(name string) metrics.Gauge {
+	return s.metric(name, "metrics.Gauge", "gauge").(metrics.Gauge)
+}
+
+// Histogram returns the histogram name of the tenant.
+func (s *Scope) Histogram(name string) metrics.Histogram {
+	return s.metric(name, "metrics.Histogram", "histogram").(metrics.Histogram)
+}
+
+// Meter returns the meter name of the tenant.
+func (s *Scope) Meter(name string) metrics.Meter {
+	return s.metric(name, "metrics.Meter", "meter").(metrics.Meter)
+}
+
+// Timer returns the timer name of the tenant.
+func (s *Scope) Timer(name string) metrics.Timer {
+	return s.metric(name, "metrics.Timer", "timer").(metrics.Timer)
+}
+
+// drop counts the metric name dropped for the given reason and returns a
+// no-op metric of the given type.  The caller must hold the mutex.
+func (s *Scope) drop(name, typeName, reason string) interface{} {
+	s.m.self.Scope.Dropped.Inc(1)
+	if !s.dropped[name] {
+		if s.dropped == nil {
+			s.dropped = make(map[string]bool)
+		}
+		s.dropped[name] = true
+		s.m.warn("dropped tenant metric", "tenant", s.tenant, "name", name, "reason", reason)
+	}
+	return nilMetric(typeName)
+}
+
+// metric returns the metric name of the given type, creating and
+// registering it on first use.  It returns a no-op metric if name was used
+// for another type or the scope is full.
+func (s *Scope) metric(name, typeName, kind string) interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if metric, ok := s.metrics[name]; ok {
+		if metricKind(metric) == kind {
+			return metric
+		}
+		return s.drop(name, typeName, "name used by a "+metricKind(metric))
+	}
+	m := s.m
+	if m.maxTenantMetrics > 0 && len(s.metrics) >= m.maxTenantMetrics {
+		return s.drop(name, typeName, "too many metrics")
+	}
+	metric := newMetric(typeName, nil, m.sampleSize)
+	meta := newMeta(JoinName(JoinName(scopePrefix, m.separator, s.tenant), m.separator, name), kind, "", "", nil)
+	meta.Family = JoinName(scopePrefix, m.separator, name)
+	meta.Labels = map[string]string{"tenant": s.tenant}
+	m.register(meta, metric)
+	s.metrics[name] = metric
+	return metric
+}
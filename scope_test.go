@@ -0,0 +1,42 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestScope(t *testing.T) {
+	r := metrics.NewRegistry()
+	tags := NewMetricTags(&struct{}{}, func() {}, time.Second, r, ".", WithScopeLimits(2, 2))
+	acme := tags.Scope("acme")
+	acme.Counter("requests").Inc(1)
+	tags.Scope("acme").Counter("requests").Inc(2)
+	acme.Timer("latency").Update(time.Millisecond)
+	if _, ok := acme.Gauge("queue").(metrics.NilGauge); !ok {
+		t.Fatalf("expected a no-op gauge beyond the metric cap")
+	}
+	if _, ok := acme.Gauge("requests").(metrics.NilGauge); !ok {
+		t.Fatalf("expected a no-op gauge for a name used by a counter")
+	}
+
+	if tags.Scope("globex").Tenant() != "globex" || tags.Scope("initech").Tenant() != OtherTenant {
+		t.Fatalf("expected tenants beyond the cap to share %q", OtherTenant)
+	}
+	tags.Scope("initech").Counter("requests").Inc(1)
+
+	if c := r.Get("tenant.acme.requests").(metrics.Counter).Count(); c != 3 {
+		t.Fatalf("expected 3 requests, got %d", c)
+	}
+	if r.Get("tenant.other.requests") == nil || r.Get("tenant.acme.queue") != nil {
+		t.Fatalf("unexpected metrics registered")
+	}
+	meta, _ := tags.Metadata("tenant.acme.latency")
+	if meta.Family != "tenant.latency" || meta.Labels["tenant"] != "acme" || meta.Type != "timer" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if c := r.Get("tagtrics.scope.dropped").(metrics.Counter).Count(); c != 2 {
+		t.Fatalf("expected 2 dropped metrics, got %d", c)
+	}
+}
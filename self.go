@@ -0,0 +1,58 @@
+package tagtrics
+
+import (
+	"strconv"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// selfPrefix is the namespace of the metrics tagtrics keeps about itself.
+const selfPrefix = "tagtrics"
+
+// selfNamespace returns the prefix of the self metrics of m, selfPrefix
+// unless another MetricTags sharing the registry, e.g. the
+// metrics.DefaultRegistry, already uses it.  Later ones then number their
+// self metrics, e.g. "tagtrics.2.flush.errors", so every instance exports
+// its own.
+func (m *MetricTags) selfNamespace() string {
+	prefix := selfPrefix
+	for n := 2; m.registry.Get(JoinName(prefix, m.separator, "uptime_seconds")) != nil; n++ {
+		prefix = JoinName(selfPrefix, m.separator, strconv.Itoa(n))
+	}
+	return prefix
+}
+
+// selfMetrics holds the metrics tagtrics keeps about itself so that degraded
+// metric delivery can be alerted on like any other metric.
+type selfMetrics struct {
+	Flush struct {
+		Duration metrics.Timer   `metric:"duration" help:"Time spent in the update handler" unit:"nanoseconds"`
+		Errors   metrics.Counter `metric:"errors" help:"Update handler calls that failed"`
+		Skipped  metrics.Counter `metric:"skipped" help:"Flushes skipped while paused"`
+		Gap      metrics.Gauge   `metric:"gap" help:"Length of the last pause of the flushes" unit:"seconds"`
+		Overruns metrics.Counter `metric:"overruns" help:"Flushes skipped because the previous one overran the interval"`
+	} `metric:"flush"`
+	Sink struct {
+		Errors  metrics.Counter `metric:"errors" help:"Snapshots a sink failed to send"`
+		Retries metrics.Counter `metric:"retries" help:"Sends retried after a sink failed"`
+		Dropped metrics.Counter `metric:"dropped" help:"Snapshots dropped by the delivery to the sinks"`
+	} `metric:"sink"`
+	Scope struct {
+		Dropped metrics.Counter `metric:"dropped" help:"Metrics of tenant scopes dropped by the cardinality caps"`
+	} `metric:"scope"`
+	Registry struct {
+		Size        metrics.Gauge `metric:"size" help:"Number of metrics in the registry" unit:"metrics"`
+		Samples     metrics.Gauge `metric:"samples" help:"Values held by the samples of histograms and timers" unit:"values"`
+		SampleBytes metrics.Gauge `metric:"sample_bytes" help:"Estimated storage of the samples of histograms and timers" unit:"bytes"`
+	} `metric:"registry"`
+	Snapshot struct {
+		Serialization metrics.Timer `metric:"serialization" help:"Time spent serializing snapshots" unit:"nanoseconds"`
+	} `metric:"snapshot"`
+	// LastFlushTimestamp is a heartbeat for external monitors alerting when
+	// a service stops reporting.
+	LastFlushTimestamp metrics.Gauge `metric:"last_flush_timestamp" help:"Unix time of the last successful flush" unit:"seconds"`
+	// Uptime and StartTimestamp reveal restarts, updated before every
+	// snapshot.
+	Uptime         metrics.Gauge `metric:"uptime_seconds" help:"Time since the process started" unit:"seconds"`
+	StartTimestamp metrics.Gauge `metric:"start_timestamp" help:"Unix time the process started" unit:"seconds"`
+}
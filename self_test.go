@@ -0,0 +1,103 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSelfMetrics(t *testing.T) {
+	fail := false
+	h := func() {
+		if fail {
+			panic("backend down")
+		}
+	}
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(&metaMetrics{}, h, time.Second, r, ".", WithPanicRecovery())
+
+	mTags.flush()
+	fail = true
+	mTags.flush()
+	mTags.ToJSON()
+
+	if c := r.Get("tagtrics.flush.duration").(metrics.Timer).Count(); c != 2 {
+		t.Fatalf("expected 2 flush durations, got %d", c)
+	}
+	if c := r.Get("tagtrics.flush.errors").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 flush error, got %d", c)
+	}
+	if v := r.Get("tagtrics.registry.size").(metrics.Gauge).Value(); v != registryFootprint(r).Series {
+		t.Fatalf("unexpected registry size %d", v)
+	}
+	if c := r.Get("tagtrics.snapshot.serialization").(metrics.Timer).Count(); c != 1 {
+		t.Fatalf("expected 1 serialization, got %d", c)
+	}
+	if _, ok := mTags.Metadata("tagtrics.flush.errors"); !ok {
+		t.Fatalf("self metrics are missing metadata")
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	fail := false
+	h := func() {
+		if fail {
+			panic("backend down")
+		}
+	}
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(&metaMetrics{}, h, time.Second, r, ".", WithFlushCounter(), WithPanicRecovery())
+	mTags.nowHandler = func() time.Time { return time.Unix(100, 0) }
+	mTags.flush()
+	mTags.nowHandler = func() time.Time { return time.Unix(200, 0) }
+	fail = true
+	mTags.flush()
+
+	if v := r.Get("tagtrics.last_flush_timestamp").(metrics.Gauge).Value(); v != 100 {
+		t.Fatalf("expected the time of the last successful flush, got %d", v)
+	}
+	if c := r.Get("tagtrics.flushes").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 successful flush, got %d", c)
+	}
+	if NewMetricTags(&metaMetrics{}, h, time.Second, metrics.NewRegistry(), ".").registry.Get("tagtrics.flushes") != nil {
+		t.Fatalf("flush counter registered without WithFlushCounter")
+	}
+}
+
+func TestUptime(t *testing.T) {
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(&metaMetrics{}, func() {}, time.Second, r, ".")
+	defer func(start time.Time) { processStart = start }(processStart)
+	processStart = time.Now().Add(-90 * time.Second)
+
+	s := mTags.Snapshot()
+	if v := s.Stats("tagtrics.uptime_seconds")["value"]; v != 90 {
+		t.Fatalf("expected 90s of uptime, got %v", v)
+	}
+	if v := s.Stats("tagtrics.start_timestamp")["value"]; v != float64(processStart.Unix()) {
+		t.Fatalf("unexpected start timestamp %v", v)
+	}
+}
+
+func TestSharedRegistrySelfMetrics(t *testing.T) {
+	var l recordingLogger
+	r := metrics.NewRegistry()
+	first := NewMetricTags(&struct{}{}, func() {}, time.Second, r, ".", WithLogger(&l))
+	second := NewMetricTags(&struct{}{}, func() {}, time.Second, r, ".", WithLogger(&l))
+	if len(l) != 0 {
+		t.Fatalf("unexpected warnings %q", l)
+	}
+	second.flush()
+	if r.Get("tagtrics.2.flush.duration").(metrics.Timer).Count() != 1 || r.Get("tagtrics.flush.duration").(metrics.Timer).Count() != 0 {
+		t.Fatalf("the flush of the second instance was not timed in its own self metrics")
+	}
+
+	second.Unregister()
+	if r.Get("tagtrics.flush.duration") == nil {
+		t.Fatalf("unregistering the second instance removed the self metrics of the first")
+	}
+	if _, ok := first.Metadata("tagtrics.flush.errors"); !ok {
+		t.Fatalf("self metrics of the first instance are missing metadata")
+	}
+}
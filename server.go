@@ -0,0 +1,149 @@
+package tagtrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultMetricsPath is the path ListenAndServe serves the metrics at unless
+// configured otherwise.
+const DefaultMetricsPath = "/metrics"
+
+// Timeouts of the server started by ListenAndServe so slow or idle clients
+// can't hold its connections forever.  The write timeout leaves room for
+// the 30 second CPU profiles of net/http/pprof served with WithHandler.
+const (
+	DefaultServerReadHeaderTimeout = 5 * time.Second
+	DefaultServerReadTimeout       = 10 * time.Second
+	DefaultServerWriteTimeout      = time.Minute
+	DefaultServerIdleTimeout       = 2 * time.Minute
+)
+
+// ServerOption configures the server started by ListenAndServe.
+type ServerOption func(*serverConfig)
+
+// serverConfig holds the configuration of a server started by
+// ListenAndServe.
+type serverConfig struct {
+	certFile, keyFile  string
+	username, password string
+	token              string
+	metricsPath        string
+	// handlers holds the additional handlers keyed by path.
+	handlers map[string]http.Handler
+}
+
+// WithTLS serves HTTPS with the certificate and key in the given PEM files.
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(c *serverConfig) {
+		c.certFile, c.keyFile = certFile, keyFile
+	}
+}
+
+// WithBasicAuth requires requests to authenticate with HTTP basic
+// authentication.  Along with WithBearerToken, either is accepted.
+func WithBasicAuth(username, password string) ServerOption {
+	return func(c *serverConfig) {
+		c.username, c.password = username, password
+	}
+}
+
+// WithBearerToken requires requests to send the token in their
+// Authorization header, e.g. "Authorization: Bearer <token>".  Along with
+// WithBasicAuth, either is accepted.
+func WithBearerToken(token string) ServerOption {
+	return func(c *serverConfig) {
+		c.token = token
+	}
+}
+
+// WithMetricsPath serves the metrics at path instead of DefaultMetricsPath.
+func WithMetricsPath(path string) ServerOption {
+	return func(c *serverConfig) {
+		c.metricsPath = path
+	}
+}
+
+// WithHandler serves h at path along with the metrics, e.g. the handlers of
+// net/http/pprof, behind the same authentication.
+func WithHandler(path string, h http.Handler) ServerOption {
+	return func(c *serverConfig) {
+		c.handlers[path] = h
+	}
+}
+
+// ListenAndServe runs a dedicated HTTP server on addr serving the metrics
+// with Handler at DefaultMetricsPath, and the page of UIHandler beneath it
+// at "/metrics/ui", so they don't have to be mounted on the API of the
+// application, e.g.
+//
+//	go mTags.ListenAndServe(":9090", tagtrics.WithBearerToken(token))
+//
+// It always returns a non-nil error like http.ListenAndServe.
+func (m *MetricTags) ListenAndServe(addr string, opts ...ServerOption) error {
+	c := &serverConfig{metricsPath: DefaultMetricsPath, handlers: make(map[string]http.Handler)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	srv := m.newServer(addr, c)
+	if c.certFile != "" {
+		return srv.ListenAndServeTLS(c.certFile, c.keyFile)
+	}
+	return srv.ListenAndServe()
+}
+
+// newServer returns the server of ListenAndServe listening on addr,
+// configured with c.
+func (m *MetricTags) newServer(addr string, c *serverConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           m.serverHandler(c),
+		ReadHeaderTimeout: DefaultServerReadHeaderTimeout,
+		ReadTimeout:       DefaultServerReadTimeout,
+		WriteTimeout:      DefaultServerWriteTimeout,
+		IdleTimeout:       DefaultServerIdleTimeout,
+	}
+}
+
+// serverHandler returns the handler of a server configured with c.
+func (m *MetricTags) serverHandler(c *serverConfig) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(c.metricsPath, m.Handler())
+	mux.Handle(strings.TrimSuffix(c.metricsPath, "/")+"/ui", m.UIHandler(c.metricsPath))
+	for path, h := range c.handlers {
+		mux.Handle(path, h)
+	}
+	if c.username == "" && c.token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.authorized(r) {
+			if c.username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// authorized returns whether r carries the credentials required by c.
+func (c *serverConfig) authorized(r *http.Request) bool {
+	if c.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) == 1 {
+			return true
+		}
+	}
+	if c.username != "" {
+		username, password, ok := r.BasicAuth()
+		if ok && subtle.ConstantTimeCompare([]byte(username), []byte(c.username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(c.password)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package tagtrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestServerHandler(t *testing.T) {
+	m := &struct {
+		Sent metrics.Counter `metric:"sent"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	c := &serverConfig{metricsPath: DefaultMetricsPath, handlers: make(map[string]http.Handler)}
+	for _, opt := range []ServerOption{
+		WithBasicAuth("admin", "secret"),
+		WithBearerToken("token"),
+		WithMetricsPath("/stats"),
+		WithHandler("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})),
+	} {
+		opt(c)
+	}
+	h := tags.serverHandler(c)
+
+	for _, tc := range []struct {
+		path, user, password, token string
+		status                      int
+	}{
+		{"/stats", "", "", "", http.StatusUnauthorized},
+		{"/stats", "admin", "wrong", "", http.StatusUnauthorized},
+		{"/stats", "", "", "wrong", http.StatusUnauthorized},
+		{"/stats", "admin", "secret", "", http.StatusOK},
+		{"/stats", "", "", "token", http.StatusOK},
+		{"/healthz", "", "", "token", http.StatusOK},
+		{"/metrics", "", "", "token", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, tc.password)
+		}
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s as %q/%q: expected status %d, got %d", tc.path, tc.user, tc.token, tc.status, rec.Code)
+		}
+	}
+
+	srv := tags.newServer(":9090", c)
+	if srv.ReadHeaderTimeout == 0 || srv.ReadTimeout == 0 || srv.WriteTimeout == 0 || srv.IdleTimeout == 0 {
+		t.Fatalf("server without timeouts %+v", srv)
+	}
+}
+
+func TestUIHandler(t *testing.T) {
+	tags := NewMetricTags(&struct{}{}, func() {}, time.Second, metrics.NewRegistry(), ".")
+	c := &serverConfig{metricsPath: DefaultMetricsPath, handlers: make(map[string]http.Handler)}
+	rec := httptest.NewRecorder()
+	tags.serverHandler(c).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics/ui", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected response %d %v", rec.Code, rec.Header())
+	}
+	if !strings.Contains(body, `var endpoint = "/metrics", separator = "."`) {
+		t.Fatalf("page not configured:\n%s", body)
+	}
+}
@@ -0,0 +1,89 @@
+package tagtrics
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long RunUntilSignal waits for the final
+// flush unless configured otherwise.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// ErrShutdownTimeout is returned by RunUntilSignal when the final flush
+// doesn't finish within MetricTags.ShutdownTimeout.
+var ErrShutdownTimeout = errors.New("tagtrics: final flush timed out")
+
+// RunUntilSignal runs m until the process receives one of sigs, or SIGINT or
+// SIGTERM if none are given.  It then stops m, waiting up to
+// m.ShutdownTimeout for the final flush, and unregisters the metrics of m.
+// It is meant to be the last call in main():
+//
+//	go server.ListenAndServe()
+//	if err := mTags.RunUntilSignal(); err != nil {
+//		log.Print(err)
+//	}
+func (m *MetricTags) RunUntilSignal(sigs ...os.Signal) error {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+
+	go m.Run()
+	<-ch
+
+	stopped := make(chan struct{})
+	go func() {
+		m.Stop()
+		close(stopped)
+	}()
+	timeout := m.ShutdownTimeout
+	if timeout == 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		return ErrShutdownTimeout
+	}
+	m.Unregister()
+	return nil
+}
+
+// RunContext runs like Run until ctx is done, then flushes one last time
+// and stops like Stop, which need not be called, so it fits services whose
+// goroutines are managed by an errgroup.  In pull-only mode it waits for ctx
+// to be done.  It returns the error of the update handler or the FlushFunc
+// of the final flush, if any.  Stop must not be called once it returned, but
+// RunContext or Run can be called again.
+func (m *MetricTags) RunContext(ctx context.Context) error {
+	m.start()
+	if m.pullOnly {
+		<-ctx.Done()
+		m.deregisterEndpoints()
+		m.stopDelivery()
+		return nil
+	}
+	stopped, err := m.run(ctx.Done())
+	if !stopped {
+		m.deregisterEndpoints()
+		m.stopDelivery()
+	}
+	return err
+}
+
+// Unregister removes the metrics initialized by m from its registry.
+func (m *MetricTags) Unregister() {
+	m.metaMutex.Lock()
+	defer m.metaMutex.Unlock()
+	for name := range m.meta {
+		m.registry.Unregister(name)
+	}
+	m.meta = make(map[string]MetricMeta)
+	m.timerNames = nil
+}
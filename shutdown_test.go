@@ -0,0 +1,38 @@
+package tagtrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestRunContext(t *testing.T) {
+	flushed := make(chan struct{}, 10)
+	mTags := NewMetricTags(&metaMetrics{}, nil, time.Hour, metrics.NewRegistry(), ".",
+		WithLogger(&recordingLogger{}),
+		WithFlushFunc(func(ctx context.Context, s *Snapshot) error {
+			flushed <- struct{}{}
+			return errors.New("backend down")
+		}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- mTags.RunContext(ctx)
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "backend down" {
+			t.Fatalf("expected the error of the final flush, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("RunContext didn't return")
+	}
+	if len(flushed) != 1 {
+		t.Fatalf("expected a final flush, got %d", len(flushed))
+	}
+}
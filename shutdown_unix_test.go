@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package tagtrics
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestRunUntilSignal(t *testing.T) {
+	flushed := make(chan struct{}, 10)
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(&metaMetrics{}, func() { flushed <- struct{}{} }, time.Hour, r, ".")
+
+	done := make(chan error)
+	go func() {
+		done <- mTags.RunUntilSignal(syscall.SIGUSR2)
+	}()
+	// Give RunUntilSignal time to subscribe to the signal.
+	time.Sleep(50 * time.Millisecond)
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("RunUntilSignal didn't return")
+	}
+	if len(flushed) != 1 {
+		t.Fatalf("expected a final flush, got %d", len(flushed))
+	}
+	if r.Get("queue.depth") != nil || r.Get("tagtrics.flush.errors") != nil {
+		t.Fatalf("metrics still registered")
+	}
+}
@@ -0,0 +1,82 @@
+package tagtrics
+
+// Sink exports snapshots to a remote system.  Sinks added with AddSink are
+// sent a snapshot on every flush after the update handler is called.
+type Sink interface {
+	// Send exports the snapshot.  It must not modify s since it is shared
+	// with the other sinks.
+	Send(s *Snapshot) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(s *Snapshot) error
+
+// Send calls f(s).
+func (f SinkFunc) Send(s *Snapshot) error {
+	return f(s)
+}
+
+// routedSink is a sink along with the name of the sink the metrics it is
+// sent are routed to with the "sink" struct tag, empty for the default.
+type routedSink struct {
+	Sink
+	name string
+	// deliverer delivers the snapshots in the background if WithDelivery
+	// is used.
+	deliverer *deliverer
+}
+
+// routed returns s routed to name.
+func (m *MetricTags) routed(s Sink, name string) routedSink {
+	rs := routedSink{Sink: s, name: name}
+	if m.delivery != nil {
+		rs.deliverer = m.newDeliverer(s, name)
+	}
+	return rs
+}
+
+// AddSink adds a sink the snapshots are sent to on every flush.  The metrics
+// routed to named sinks with the "sink" struct tag are left out.  It must be
+// called before Run.
+func (m *MetricTags) AddSink(s Sink) {
+	m.sinks = append(m.sinks, m.routed(s, ""))
+}
+
+// AddNamedSink adds a sink only sent the metrics routed to name with the
+// "sink" struct tag on every flush, e.g. a local file receiving the verbose
+// metrics of the fields tagged `sink:"debug"`.  It must be called before
+// Run.
+func (m *MetricTags) AddNamedSink(name string, s Sink) {
+	m.sinks = append(m.sinks, m.routed(s, name))
+}
+
+// send sends the metrics of the snapshot s routed to every sink, or queues
+// them if WithDelivery is used.  Failures are counted in the self metrics
+// and logged without stopping the other sinks.  It returns the first
+// failure.
+func (m *MetricTags) send(s *Snapshot) error {
+	m.sendMutex.Lock()
+	defer m.sendMutex.Unlock()
+	var first error
+	routed := make(map[string]*Snapshot)
+	for _, sink := range m.sinks {
+		rs, ok := routed[sink.name]
+		if !ok {
+			rs = s.ForSink(sink.name)
+			routed[sink.name] = rs
+		}
+		if sink.deliverer != nil {
+			sink.deliverer.enqueue(rs)
+			continue
+		}
+		if err := sink.Send(rs); err != nil {
+			m.self.Sink.Errors.Inc(1)
+			m.warn("sink failed", "sink", sink.name, "err", err)
+			m.reportError(&SinkError{Sink: sink.name, Err: err})
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
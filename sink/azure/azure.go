@@ -0,0 +1,249 @@
+// Package azure exports tagtrics snapshots to Azure Monitor as custom
+// metrics.
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+const (
+	// DefaultNamespace is the namespace of the custom metrics unless
+	// configured otherwise.
+	DefaultNamespace = "tagtrics"
+	// Resource is the resource tokens for the custom metrics API are
+	// requested for.
+	Resource = "https://monitoring.azure.com/"
+	// DefaultMSIEndpoint is the instance metadata service endpoint managed
+	// identity tokens are requested from.
+	DefaultMSIEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// TokenSource returns bearer tokens for the custom metrics API.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// Sink sends every statistic of every snapshot as a custom metric named
+// after the metric and statistic, e.g. "queue.wait.count", of the resource.
+// The labels of a metric are sent as dimensions.
+type Sink struct {
+	// Namespace is the namespace of the custom metrics.  If not set,
+	// DefaultNamespace is used.
+	Namespace string
+	// Endpoint is the URL of the ingestion API.  If not set, the regional
+	// endpoint "https://<region>.monitoring.azure.com" is used.
+	Endpoint string
+	// Client is the HTTP client used to send metrics.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	region     string
+	resourceID string
+	tokens     TokenSource
+}
+
+// New returns a sink sending metrics of the resource with the given ID, e.g.
+// "/subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.Compute/virtualMachines/<vm>",
+// to the ingestion endpoint of region, e.g. "westus2".
+func New(region, resourceID string, tokens TokenSource) *Sink {
+	return &Sink{region: region, resourceID: resourceID, tokens: tokens}
+}
+
+// customMetric is the body of a request to the custom metrics API.
+type customMetric struct {
+	Time time.Time `json:"time"`
+	Data struct {
+		BaseData struct {
+			Metric    string   `json:"metric"`
+			Namespace string   `json:"namespace"`
+			DimNames  []string `json:"dimNames,omitempty"`
+			Series    []series `json:"series"`
+		} `json:"baseData"`
+	} `json:"data"`
+}
+
+// series is a pre-aggregated value of a custom metric.
+type series struct {
+	DimValues []string `json:"dimValues,omitempty"`
+	Min       float64  `json:"min"`
+	Max       float64  `json:"max"`
+	Sum       float64  `json:"sum"`
+	Count     int      `json:"count"`
+}
+
+// Send sends the snapshot, a request per statistic since the API accepts a
+// single metric per request, holding the series of every combination of
+// dimension values of the statistic.  A failing request doesn't stop the
+// others and the errors of all of them are returned.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	token, err := s.tokens.Token()
+	if err != nil {
+		return err
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + s.region + ".monitoring.azure.com"
+	}
+	url := strings.TrimSuffix(endpoint, "/") + s.resourceID + "/metrics"
+	var errs []error
+	for _, c := range s.customMetrics(snapshot.Time, snapshot.Points()) {
+		if err := s.post(url, token, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// customMetrics returns the custom metrics of the points, one per statistic
+// and set of dimension names, in the order of the points.
+func (s *Sink) customMetrics(t time.Time, points []tagtrics.Point) []*customMetric {
+	var cs []*customMetric
+	byKey := make(map[string]*customMetric)
+	for _, p := range points {
+		dimNames := make([]string, 0, len(p.Labels))
+		for k := range p.Labels {
+			dimNames = append(dimNames, k)
+		}
+		sort.Strings(dimNames)
+		name := p.Family + "." + p.Stat
+		key := name + "\x00" + strings.Join(dimNames, "\x00")
+		c, ok := byKey[key]
+		if !ok {
+			c = s.customMetric(t, name, dimNames)
+			byKey[key] = c
+			cs = append(cs, c)
+		}
+		v := series{Min: p.Value, Max: p.Value, Sum: p.Value, Count: 1}
+		for _, k := range dimNames {
+			v.DimValues = append(v.DimValues, p.Labels[k])
+		}
+		c.Data.BaseData.Series = append(c.Data.BaseData.Series, v)
+	}
+	return cs
+}
+
+// customMetric returns an empty custom metric with the given name and
+// dimension names.
+func (s *Sink) customMetric(t time.Time, name string, dimNames []string) *customMetric {
+	c := &customMetric{Time: t.UTC()}
+	c.Data.BaseData.Metric = name
+	c.Data.BaseData.Namespace = s.Namespace
+	if c.Data.BaseData.Namespace == "" {
+		c.Data.BaseData.Namespace = DefaultNamespace
+	}
+	if len(dimNames) > 0 {
+		c.Data.BaseData.DimNames = dimNames
+	}
+	return c
+}
+
+// post sends a custom metric.
+func (s *Sink) post(url, token string, c *customMetric) error {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client(s.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("azure: unexpected status %s for %s: %s", resp.Status, c.Data.BaseData.Metric, msg)
+	}
+	return nil
+}
+
+// MSI is a TokenSource getting tokens of the managed identity of the host
+// from the instance metadata service.  Tokens are cached until shortly
+// before they expire.
+type MSI struct {
+	// ClientID selects a user-assigned identity.  If not set, the system
+	// assigned identity is used.
+	ClientID string
+	// Endpoint is the token endpoint of the instance metadata service.  If
+	// not set, DefaultMSIEndpoint is used.
+	Endpoint string
+	// Client is the HTTP client used to request tokens.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Token returns a cached token or requests a new one.
+func (m *MSI) Token() (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.token != "" && time.Now().Before(m.expires.Add(-5*time.Minute)) {
+		return m.token, nil
+	}
+	endpoint := m.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultMSIEndpoint
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	q := req.URL.Query()
+	q.Set("api-version", "2018-02-01")
+	q.Set("resource", Resource)
+	if m.ClientID != "" {
+		q.Set("client_id", m.ClientID)
+	}
+	req.URL.RawQuery = q.Encode()
+	req.Header.Set("Metadata", "true")
+	resp, err := client(m.Client).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("azure: failed to get managed identity token %s: %s", resp.Status, msg)
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	expires, err := strconv.ParseInt(result.ExpiresOn, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("azure: invalid token expiry %q", result.ExpiresOn)
+	}
+	m.token, m.expires = result.AccessToken, time.Unix(expires, 0)
+	return m.token, nil
+}
+
+// client returns c or http.DefaultClient if c is nil.
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
@@ -0,0 +1,99 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Build   tagtrics.Info             `metric:"build"`
+	Sent    metrics.Counter           `metric:"sent"`
+	Regions map[string]*regionMetrics `metric:"regions"`
+}
+
+// regionMetrics exports its key as the region label.
+type regionMetrics struct {
+	Hits metrics.Counter `metric:"hits"`
+}
+
+// BucketName implements tagtrics.Bucket.
+func (*regionMetrics) BucketName(key string) (string, map[string]string) {
+	return key, map[string]string{"region": key}
+}
+
+func TestSend(t *testing.T) {
+	var tokenRequests int
+	msi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != Resource {
+			t.Errorf("unexpected token request %s", r.URL)
+		}
+		tokenRequests++
+		fmt.Fprintf(w, `{"access_token":"token","expires_on":"%d"}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer msi.Close()
+	got := make(map[string]customMetric)
+	requests, failSent := 0, false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/subscriptions/s/vm/metrics" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var c customMetric
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			t.Errorf("invalid custom metric: %v", err)
+		}
+		got[c.Data.BaseData.Metric] = c
+		if c.Data.BaseData.Metric == "sent.count" && failSent {
+			http.Error(w, "throttled", http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	m := &testMetrics{Regions: map[string]*regionMetrics{"eu": {}, "us": {}}}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Build.Set("version", "1.2")
+	m.Sent.Inc(4)
+	sink := New("westus2", "/subscriptions/s/vm", &MSI{Endpoint: msi.URL})
+	sink.Endpoint = srv.URL
+
+	for i := 0; i < 2; i++ {
+		if err := sink.Send(mTags.Snapshot()); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Fatalf("expected the token to be cached, got %d requests", tokenRequests)
+	}
+	sent := got["sent.count"].Data.BaseData
+	if sent.Namespace != DefaultNamespace || len(sent.Series) != 1 || sent.Series[0].Sum != 4 || sent.Series[0].Count != 1 {
+		t.Fatalf("unexpected custom metric: %+v", sent)
+	}
+	build := got["build.value"].Data.BaseData
+	if len(build.DimNames) != 1 || build.DimNames[0] != "version" || build.Series[0].DimValues[0] != "1.2" {
+		t.Fatalf("labels not sent as dimensions: %+v", build)
+	}
+	hits := got["regions.hits.count"].Data.BaseData
+	if len(hits.DimNames) != 1 || len(hits.Series) != 2 {
+		t.Fatalf("expected the series of both regions in one request: %+v", hits)
+	}
+	if requests != 2*len(got) {
+		t.Fatalf("expected a request per metric, got %d for %d metrics", requests, len(got))
+	}
+
+	failSent, requests = true, 0
+	err := sink.Send(mTags.Snapshot())
+	if err == nil || !strings.Contains(err.Error(), "sent.count") {
+		t.Fatalf("expected the error of sent.count, got %v", err)
+	}
+	if requests != len(got) {
+		t.Fatalf("expected every metric to be sent despite the failure, got %d requests", requests)
+	}
+}
@@ -0,0 +1,151 @@
+// Package elasticsearch exports tagtrics snapshots to Elasticsearch or
+// OpenSearch with the bulk API.
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// DefaultIndexPattern is the index pattern used unless configured otherwise,
+// yielding a daily index such as "tagtrics-2017.03.21".
+const DefaultIndexPattern = "tagtrics-2006.01.02"
+
+// Sink indexes a document per metric of every snapshot.  Documents hold the
+// snapshot time as "@timestamp", the metric "name", "type", "unit" and
+// "labels" and the statistics of the metric in "stats".  Dots in statistic
+// names are replaced with underscores, e.g. "1m.rate" becomes "1m_rate", so
+// they aren't mapped as objects.
+type Sink struct {
+	// IndexPattern is formatted with the snapshot time in UTC as a
+	// time.Format layout to get the index name.  If not set,
+	// DefaultIndexPattern is used.
+	IndexPattern string
+	// Username and Password are used for basic authentication if set.
+	Username, Password string
+	// APIKey is the base64 encoded API key used for authentication if set.
+	APIKey string
+	// Client is the HTTP client used for the bulk requests.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	url string
+}
+
+// document is the document indexed for a metric.
+type document struct {
+	Timestamp time.Time          `json:"@timestamp"`
+	Name      string             `json:"name"`
+	Type      string             `json:"type,omitempty"`
+	Unit      string             `json:"unit,omitempty"`
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Stats     map[string]float64 `json:"stats"`
+}
+
+// New returns a sink indexing documents in the cluster at url, e.g.
+// "http://localhost:9200".
+func New(url string) *Sink {
+	return &Sink{url: strings.TrimSuffix(url, "/")}
+}
+
+// Index returns the index the documents of a snapshot taken at t go to.
+func (s *Sink) Index(t time.Time) string {
+	pattern := s.IndexPattern
+	if pattern == "" {
+		pattern = DefaultIndexPattern
+	}
+	return t.UTC().Format(pattern)
+}
+
+// Send bulk-indexes the snapshot.  Nothing is sent for empty snapshots.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	body, err := s.bulk(snapshot)
+	if err != nil || body.Len() == 0 {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.url+"/_bulk", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.APIKey)
+	} else if s.Username != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("elasticsearch: unexpected status %s: %s", resp.Status, msg)
+	}
+	// The bulk API reports failures of single documents in the body.
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, action := range item {
+				if action.Error != nil {
+					return fmt.Errorf("elasticsearch: failed to index document: %s", action.Error)
+				}
+			}
+		}
+		return fmt.Errorf("elasticsearch: failed to index documents")
+	}
+	return nil
+}
+
+// bulk returns the body of the bulk request indexing the snapshot.
+func (s *Sink) bulk(snapshot *tagtrics.Snapshot) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+	action := map[string]interface{}{
+		"index": map[string]string{"_index": s.Index(snapshot.Time)},
+	}
+	for _, name := range snapshot.Names() {
+		stats := snapshot.Stats(name)
+		if stats == nil {
+			continue
+		}
+		doc := document{
+			Timestamp: snapshot.Time,
+			Name:      name,
+			Type:      snapshot.Meta[name].Type,
+			Unit:      snapshot.Meta[name].Unit,
+			Labels:    snapshot.Labels(name),
+			Stats:     make(map[string]float64, len(stats)),
+		}
+		for k, v := range stats {
+			doc.Stats[strings.Replace(k, ".", "_", -1)] = v
+		}
+		if err := enc.Encode(action); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
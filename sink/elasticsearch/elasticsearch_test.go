@@ -0,0 +1,85 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Queue struct {
+		Depth metrics.Gauge `metric:"depth" unit:"messages"`
+		Wait  metrics.Timer `metric:"wait"`
+	} `metric:"queue"`
+}
+
+func TestSend(t *testing.T) {
+	var lines []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); r.URL.Path != "/_bulk" || user != "elastic" || pass != "secret" {
+			t.Errorf("unexpected request %s from %q", r.URL.Path, user)
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Errorf("invalid bulk line %q: %v", scanner.Text(), err)
+			}
+			lines = append(lines, line)
+		}
+		w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer srv.Close()
+
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Queue.Depth.Update(3)
+	sink := New(srv.URL + "/")
+	sink.Username, sink.Password = "elastic", "secret"
+	sink.IndexPattern = "metrics-2006.01"
+	snapshot := mTags.Snapshot()
+	snapshot.Time = time.Date(2017, 3, 21, 0, 0, 0, 0, time.UTC)
+
+	if err := sink.Send(snapshot); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if len(lines) == 0 || len(lines)%2 != 0 {
+		t.Fatalf("unexpected number of bulk lines: %d", len(lines))
+	}
+	var depth, wait map[string]interface{}
+	for i := 0; i < len(lines); i += 2 {
+		index := lines[i]["index"].(map[string]interface{})["_index"]
+		if index != "metrics-2017.03" {
+			t.Fatalf("unexpected index %v", index)
+		}
+		switch lines[i+1]["name"] {
+		case "queue.depth":
+			depth = lines[i+1]
+		case "queue.wait":
+			wait = lines[i+1]
+		}
+	}
+	if depth["unit"] != "messages" || depth["stats"].(map[string]interface{})["value"] != 3.0 {
+		t.Fatalf("unexpected document: %v", depth)
+	}
+	if _, ok := wait["stats"].(map[string]interface{})["1m_rate"]; !ok {
+		t.Fatalf("statistic names are not escaped: %v", wait)
+	}
+}
+
+func TestSendItemError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":true,"items":[{"index":{"error":{"type":"mapper_parsing_exception"}}}]}`))
+	}))
+	defer srv.Close()
+	mTags := tagtrics.NewMetricTags(&testMetrics{}, func() {}, time.Second, metrics.NewRegistry(), ".")
+	if err := New(srv.URL).Send(mTags.Snapshot()); err == nil {
+		t.Fatalf("expected error for failed documents")
+	}
+}
@@ -0,0 +1,63 @@
+// Package file exports tagtrics snapshots to a local file, e.g. for agents
+// collecting metrics from disk.
+package file
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// Sink replaces the file with every snapshot in the JSON format of
+// Snapshot.WriteJSON.  The file is replaced atomically so readers never see
+// partial snapshots.
+type Sink struct {
+	// Gzip compresses the file with gzip, which usually makes the JSON of
+	// large registries about 20 times smaller.
+	Gzip bool
+	// Perm holds the permissions of the file.  If not set, 0644 is used.
+	Perm os.FileMode
+
+	path string
+}
+
+// New returns a sink writing snapshots to the file at path, e.g.
+// "/var/run/api/metrics.json".
+func New(path string) *Sink {
+	return &Sink{path: path}
+}
+
+// Send replaces the file with the snapshot.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	var buf bytes.Buffer
+	write := snapshot.WriteJSON
+	if s.Gzip {
+		write = snapshot.WriteJSONGzip
+	}
+	if err := write(&buf); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	perm := s.Perm
+	if perm == 0 {
+		perm = 0644
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := buf.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
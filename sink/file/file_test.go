@@ -0,0 +1,49 @@
+package file
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Sent metrics.Counter `metric:"sent"`
+}
+
+func TestSend(t *testing.T) {
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Sent.Inc(4)
+	path := filepath.Join(t.TempDir(), "metrics.json.gz")
+	sink := New(path)
+	sink.Gzip = true
+	if err := sink.Send(mTags.Snapshot()); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("file not written: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("file not compressed: %v", err)
+	}
+	var data map[string]map[string]float64
+	if err := json.NewDecoder(zr).Decode(&data); err != nil {
+		t.Fatalf("invalid file: %v", err)
+	}
+	if data["sent"]["count"] != 4 {
+		t.Fatalf("unexpected data %v", data)
+	}
+	if files, _ := os.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Fatalf("temporary file left: %v", files)
+	}
+}
@@ -0,0 +1,107 @@
+// Package grafana exports tagtrics annotations as Grafana annotations.
+package grafana
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// Sink creates a Grafana annotation for every event recorded with
+// MetricTags.Annotate.  It doesn't export metrics, which Grafana reads from
+// its data sources, so Send does nothing.
+type Sink struct {
+	// Tags are added to the tags of every annotation, e.g. the name of the
+	// service, to filter annotations in dashboards.
+	Tags []string
+	// Client is the HTTP client used to create annotations.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	url    string
+	apiKey string
+}
+
+// annotation is a Grafana annotation in the format of the HTTP API.
+type annotation struct {
+	Time int64    `json:"time"`
+	Tags []string `json:"tags"`
+	Text string   `json:"text"`
+}
+
+// New returns a sink creating annotations in the Grafana at url, e.g.
+// "https://grafana.example.com", authenticated with the service account
+// token or API key apiKey.
+func New(url, apiKey string) *Sink {
+	return &Sink{url: strings.TrimSuffix(url, "/"), apiKey: apiKey}
+}
+
+// Send does nothing.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	return nil
+}
+
+// SendEvents creates an annotation per event.  The name of an event is its
+// first tag followed by its tags formatted as "key:value".
+func (s *Sink) SendEvents(events []tagtrics.Event) error {
+	for _, e := range events {
+		if err := s.post(newAnnotation(e, s.Tags)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newAnnotation returns the annotation of an event with the extra tags.
+func newAnnotation(e tagtrics.Event, extra []string) annotation {
+	a := annotation{Time: e.Time.UnixNano() / 1e6, Text: e.Text}
+	if a.Text == "" {
+		a.Text = e.Name
+	}
+	a.Tags = append(a.Tags, e.Name)
+	keys := make([]string, 0, len(e.Tags))
+	for k := range e.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		a.Tags = append(a.Tags, k+":"+e.Tags[k])
+	}
+	a.Tags = append(a.Tags, extra...)
+	return a
+}
+
+// post creates an annotation.
+func (s *Sink) post(a annotation) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.url+"/api/annotations", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("grafana: unexpected status %s: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package grafana
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+func TestSendEvents(t *testing.T) {
+	var got []annotation
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/annotations" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var a annotation
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("failed to decode annotation: %v", err)
+		}
+		got = append(got, a)
+	}))
+	defer srv.Close()
+
+	tags := tagtrics.NewMetricTags(&struct{}{}, func() {}, time.Hour, metrics.NewRegistry(), ".")
+	sink := New(srv.URL+"/", "key")
+	sink.Tags = []string{"service:api"}
+	tags.AddSink(sink)
+	go tags.Run()
+	tags.Annotate("deploy", "Deployed 1.2", map[string]string{"version": "1.2"})
+	tags.Stop()
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 annotation, got %d", len(got))
+	}
+	if want := []string{"deploy", "version:1.2", "service:api"}; got[0].Text != "Deployed 1.2" || !reflect.DeepEqual(got[0].Tags, want) {
+		t.Fatalf("unexpected annotation %+v", got[0])
+	}
+	if got[0].Time == 0 {
+		t.Fatalf("missing time")
+	}
+}
+
+func TestSendEventsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	if err := New(srv.URL, "bad").SendEvents([]tagtrics.Event{{Name: "deploy"}}); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
@@ -0,0 +1,156 @@
+// Package graphite exports tagtrics snapshots to Graphite with the plaintext
+// protocol.
+package graphite
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// DefaultTimeout is the timeout of the connection to Graphite unless
+// configured otherwise.
+const DefaultTimeout = 10 * time.Second
+
+// Sink sends every statistic of every snapshot as a series named after the
+// metric and the statistic, e.g. "queue.wait.count".
+type Sink struct {
+	// Prefix is prepended to every series name if set, e.g. "app.host1".
+	Prefix string
+	// Tagged sends the labels of a metric as Graphite 1.1 tags, e.g.
+	// "build.value;version=1.2", instead of appending their values to the
+	// series name.
+	Tagged bool
+	// Timeout bounds connecting to Graphite and sending a snapshot.  If not
+	// set, DefaultTimeout is used.
+	Timeout time.Duration
+	// EventsURL is the URL of the events API of graphite-web, e.g.
+	// "http://graphite.example.com/events/".  If set, the events recorded
+	// with MetricTags.Annotate are sent there.
+	EventsURL string
+	// Client is the HTTP client used to send events.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	addr string
+}
+
+// New returns a sink sending series to the Graphite plaintext listener at
+// addr, e.g. "localhost:2003".
+func New(addr string) *Sink {
+	return &Sink{addr: addr}
+}
+
+// Send sends the snapshot over a new TCP connection.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	conn, err := net.DialTimeout("tcp", s.addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	return s.Write(conn, snapshot)
+}
+
+// Write writes the snapshot to w in the plaintext protocol.
+func (s *Sink) Write(w io.Writer, snapshot *tagtrics.Snapshot) error {
+	bw := bufio.NewWriter(w)
+	ts := snapshot.Time.Unix()
+	for _, p := range snapshot.Points() {
+		value := strconv.FormatFloat(p.Value, 'f', -1, 64)
+		if _, err := fmt.Fprintf(bw, "%s %s %d\n", s.series(p), value, ts); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// series returns the name of the series of p including its tags.
+func (s *Sink) series(p tagtrics.Point) string {
+	name := p.Name + "." + p.Stat
+	if s.Tagged {
+		name = p.Family + "." + p.Stat
+	}
+	if s.Prefix != "" {
+		name = s.Prefix + "." + name
+	}
+	keys := make([]string, 0, len(p.Labels))
+	for k := range p.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if s.Tagged {
+			name += ";" + sanitize(k, ";=~ ") + "=" + sanitize(p.Labels[k], "; ~")
+		} else {
+			name += "." + sanitize(p.Labels[k], ". ")
+		}
+	}
+	return name
+}
+
+// sanitize replaces the characters in chars with underscores.
+func sanitize(s, chars string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(chars, r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// event is a graphite-web event.
+type event struct {
+	What string   `json:"what"`
+	Tags []string `json:"tags"`
+	Data string   `json:"data"`
+	When int64    `json:"when"`
+}
+
+// SendEvents sends the events to EventsURL, if set.  Their tags are
+// formatted as "key:value".
+func (s *Sink) SendEvents(events []tagtrics.Event) error {
+	if s.EventsURL == "" {
+		return nil
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for _, e := range events {
+		ev := event{What: e.Name, Data: e.Text, When: e.Time.Unix(), Tags: []string{}}
+		for k, v := range e.Tags {
+			ev.Tags = append(ev.Tags, k+":"+v)
+		}
+		sort.Strings(ev.Tags)
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(s.EventsURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("graphite: unexpected status %s: %s", resp.Status, msg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package graphite
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Build tagtrics.Info   `metric:"build"`
+	Sent  metrics.Counter `metric:"sent"`
+}
+
+func snapshot() *tagtrics.Snapshot {
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Build.Set("version", "1.2")
+	m.Sent.Inc(4)
+	s := mTags.Snapshot()
+	s.Time = time.Unix(1490054400, 0)
+	return s
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		tagged bool
+		want   []string
+	}{
+		{false, []string{"app.build.value.1_2 1 1490054400", "app.sent.count 4 1490054400"}},
+		{true, []string{"app.build.value;version=1.2 1 1490054400", "app.sent.count 4 1490054400"}},
+	}
+	for _, test := range tests {
+		sink := New("")
+		sink.Prefix, sink.Tagged = "app", test.tagged
+		buf := bytes.NewBuffer(nil)
+		if err := sink.Write(buf, snapshot()); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+		for _, line := range test.want {
+			if !strings.Contains(buf.String(), line+"\n") {
+				t.Fatalf("tagged=%v: %q not found in:\n%s", test.tagged, line, buf)
+			}
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		b, _ := ioutil.ReadAll(conn)
+		conn.Close()
+		received <- string(b)
+	}()
+	if err := New(l.Addr().String()).Send(snapshot()); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if got := <-received; !strings.Contains(got, "sent.count 4 1490054400\n") {
+		t.Fatalf("unexpected data: %q", got)
+	}
+}
+
+func TestSendEvents(t *testing.T) {
+	var got event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode event: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	s := New("localhost:0")
+	if err := s.SendEvents([]tagtrics.Event{{Name: "deploy"}}); err != nil {
+		t.Fatalf("unexpected error without EventsURL: %v", err)
+	}
+	s.EventsURL = srv.URL + "/events/"
+	e := tagtrics.Event{Name: "deploy", Text: "1.2", Tags: map[string]string{"env": "prod"}, Time: time.Unix(100, 0)}
+	if err := s.SendEvents([]tagtrics.Event{e}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (event{What: "deploy", Tags: []string{"env:prod"}, Data: "1.2", When: 100}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+// Package honeycomb exports tagtrics snapshots as Honeycomb events.
+package honeycomb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// DefaultAPIHost is the Honeycomb API events are sent to unless configured
+// otherwise.
+const DefaultAPIHost = "https://api.honeycomb.io"
+
+// Sink sends snapshots to a Honeycomb dataset with the batch events API.
+// By default every snapshot is sent as a single event with a field per
+// statistic named after the metric and the statistic, e.g.
+// "queue.wait.count".
+type Sink struct {
+	// APIHost is the URL of the Honeycomb API.  If not set, DefaultAPIHost
+	// is used.
+	APIHost string
+	// PerMetric sends an event per metric instead of an event per snapshot.
+	// The events have a "name" field holding the metric name, a field per
+	// statistic and the labels and metadata of the metric.
+	PerMetric bool
+	// Client is the HTTP client used to send events.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	apiKey  string
+	dataset string
+}
+
+// event is a Honeycomb event in the format of the batch API.
+type event struct {
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// New returns a sink sending events to dataset authenticated with apiKey.
+func New(apiKey, dataset string) *Sink {
+	return &Sink{apiKey: apiKey, dataset: dataset}
+}
+
+// Send sends the snapshot to Honeycomb.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	var events []event
+	if s.PerMetric {
+		events = perMetric(snapshot)
+	} else {
+		events = []event{perSnapshot(snapshot)}
+	}
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	host := s.APIHost
+	if host == "" {
+		host = DefaultAPIHost
+	}
+	req, err := http.NewRequest("POST", host+"/1/batch/"+url.PathEscape(s.dataset), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Honeycomb-Team", s.apiKey)
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("honeycomb: unexpected status %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// perSnapshot returns a single event holding every statistic of the snapshot.
+func perSnapshot(snapshot *tagtrics.Snapshot) event {
+	data := make(map[string]interface{})
+	for _, p := range snapshot.Points() {
+		data[p.Name+"."+p.Stat] = p.Value
+		for k, v := range p.Labels {
+			data[p.Name+"."+k] = v
+		}
+	}
+	return event{Time: snapshot.Time, Data: data}
+}
+
+// perMetric returns an event per metric of the snapshot.
+func perMetric(snapshot *tagtrics.Snapshot) []event {
+	var events []event
+	for _, name := range snapshot.Names() {
+		stats := snapshot.Stats(name)
+		if stats == nil {
+			continue
+		}
+		data := map[string]interface{}{"name": name}
+		for k, v := range snapshot.Labels(name) {
+			data[k] = v
+		}
+		if meta, ok := snapshot.Meta[name]; ok {
+			data["type"] = meta.Type
+			if meta.Unit != "" {
+				data["unit"] = meta.Unit
+			}
+		}
+		for k, v := range stats {
+			data[k] = v
+		}
+		events = append(events, event{Time: snapshot.Time, Data: data})
+	}
+	return events
+}
@@ -0,0 +1,79 @@
+package honeycomb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Queue struct {
+		Depth metrics.Gauge   `metric:"depth" unit:"messages"`
+		Sent  metrics.Counter `metric:"sent"`
+	} `metric:"queue"`
+}
+
+func TestSend(t *testing.T) {
+	var got []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/batch/my-dataset" || r.Header.Get("X-Honeycomb-Team") != "key" {
+			t.Errorf("unexpected request %s with key %q", r.URL.Path, r.Header.Get("X-Honeycomb-Team"))
+		}
+		var events []struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			t.Errorf("failed to decode events: %v", err)
+		}
+		got = got[:0]
+		for _, e := range events {
+			got = append(got, e.Data)
+		}
+	}))
+	defer srv.Close()
+
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Queue.Depth.Update(3)
+	m.Queue.Sent.Inc(2)
+	sink := New("key", "my-dataset")
+	sink.APIHost = srv.URL
+
+	if err := sink.Send(mTags.Snapshot()); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if len(got) != 1 || got[0]["queue.depth.value"] != 3.0 || got[0]["queue.sent.count"] != 2.0 {
+		t.Fatalf("unexpected events: %v", got)
+	}
+
+	sink.PerMetric = true
+	if err := sink.Send(mTags.Snapshot()); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	var depth map[string]interface{}
+	for _, data := range got {
+		if data["name"] == "queue.depth" {
+			depth = data
+		}
+	}
+	if depth == nil || depth["value"] != 3.0 || depth["type"] != "gauge" || depth["unit"] != "messages" {
+		t.Fatalf("unexpected events: %v", got)
+	}
+}
+
+func TestSendError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown API key", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	sink := New("bad", "my-dataset")
+	sink.APIHost = srv.URL
+	if err := sink.Send(&tagtrics.Snapshot{}); err == nil {
+		t.Fatalf("expected error for unauthorized request")
+	}
+}
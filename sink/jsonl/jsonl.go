@@ -0,0 +1,63 @@
+// Package jsonl appends tagtrics snapshots to a writer as JSON Lines, one
+// compact JSON object per snapshot, to keep a history of every flush which
+// is easy to grep and to replay.
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// Sink appends every snapshot to a writer as a line in the format of
+// Snapshot.WriteJSONLine.
+type Sink struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+// New returns a sink appending snapshots to w, e.g. a file opened with
+// os.O_APPEND.
+func New(w io.Writer) *Sink {
+	return &Sink{w: w}
+}
+
+// Send appends the snapshot as a line.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return snapshot.WriteJSONLine(s.w)
+}
+
+// Line is a snapshot read back from a line.
+type Line struct {
+	// Time is when the snapshot was taken.
+	Time time.Time `json:"time"`
+	// Metrics holds the statistics of every metric keyed by name.  The
+	// value of healthchecks is their error, if any, keyed by "error".
+	Metrics map[string]map[string]interface{} `json:"metrics"`
+}
+
+// Replay calls f with every line read from r in order until f returns an
+// error, which is returned, or r is exhausted.  Empty lines are skipped.
+func Replay(r io.Reader, f func(Line) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var l Line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return err
+		}
+		if err := f(l); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,44 @@
+package jsonl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Sent metrics.Counter `metric:"sent"`
+}
+
+func TestSendReplay(t *testing.T) {
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	var buf bytes.Buffer
+	sink := New(&buf)
+	start := time.Date(2017, 3, 21, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		m.Sent.Inc(2)
+		snapshot := mTags.Snapshot()
+		snapshot.Time = start.Add(time.Duration(i) * time.Minute)
+		if err := sink.Send(snapshot); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"time":"2017-03-21T00:00:00Z","metrics":{`) {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+
+	var counts []float64
+	err := Replay(&buf, func(l Line) error {
+		counts = append(counts, l.Metrics["sent"]["count"].(float64))
+		return nil
+	})
+	if err != nil || len(counts) != 2 || counts[0] != 2 || counts[1] != 4 {
+		t.Fatalf("unexpected replay %v: %v", counts, err)
+	}
+}
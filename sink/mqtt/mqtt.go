@@ -0,0 +1,202 @@
+// Package mqtt publishes tagtrics snapshots to an MQTT broker.
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// DefaultTimeout is the timeout of the connection to the broker unless
+// configured otherwise.
+const DefaultTimeout = 10 * time.Second
+
+// Sink publishes snapshots with MQTT 3.1.1.  By default the statistics of
+// every metric are published as a JSON object to a topic per metric, the
+// topic prefix followed by the metric name with dots replaced with slashes,
+// e.g. "tagtrics/queue/wait".
+type Sink struct {
+	// ClientID identifies the client to the broker.  If not set, the broker
+	// assigns one.
+	ClientID string
+	// Username and Password authenticate the client if set.
+	Username, Password string
+	// QoS is the quality of service level of the published messages: 0, 1
+	// or 2.
+	QoS byte
+	// Retain sets the retain flag of the published messages so new
+	// subscribers get the last snapshot.
+	Retain bool
+	// Topic publishes every snapshot as a single message holding the same
+	// JSON as MetricTags.ToJSON to the topic instead of a message per metric.
+	Topic string
+	// Timeout bounds connecting to the broker and publishing a snapshot.  If
+	// not set, DefaultTimeout is used.
+	Timeout time.Duration
+	// Dial connects to the broker.  If not set, a TCP connection is made,
+	// use it for TLS or websockets.
+	Dial func(addr string, timeout time.Duration) (net.Conn, error)
+
+	addr   string
+	prefix string
+}
+
+// New returns a sink publishing to the broker at addr, e.g.
+// "localhost:1883", to topics prefixed with prefix.
+func New(addr, prefix string) *Sink {
+	return &Sink{addr: addr, prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+// message is a message to publish.
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// Send publishes the snapshot over a new connection.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	if s.QoS > 2 {
+		return fmt.Errorf("mqtt: invalid QoS %d", s.QoS)
+	}
+	messages, err := s.messages(snapshot)
+	if err != nil {
+		return err
+	}
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	dial := s.Dial
+	if dial == nil {
+		dial = func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("tcp", addr, timeout)
+		}
+	}
+	conn, err := dial(s.addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	r := bufio.NewReader(conn)
+	if err := s.connect(conn, r); err != nil {
+		return err
+	}
+	for i, m := range messages {
+		// Packet identifiers must be non-zero.
+		if err := s.publish(conn, r, m, uint16(i%0xffff+1)); err != nil {
+			return err
+		}
+	}
+	return packet{header: disconnect << 4}.write(conn)
+}
+
+// messages returns the messages of the snapshot.
+func (s *Sink) messages(snapshot *tagtrics.Snapshot) ([]message, error) {
+	if s.Topic != "" {
+		buf := bytes.NewBuffer(nil)
+		if err := snapshot.WriteJSON(buf); err != nil {
+			return nil, err
+		}
+		return []message{{topic: s.Topic, payload: buf.Bytes()}}, nil
+	}
+	var messages []message
+	for _, name := range snapshot.Names() {
+		stats := snapshot.Stats(name)
+		if stats == nil {
+			continue
+		}
+		payload, err := json.Marshal(stats)
+		if err != nil {
+			return nil, err
+		}
+		topic := strings.Replace(name, ".", "/", -1)
+		if s.prefix != "" {
+			topic = s.prefix + "/" + topic
+		}
+		messages = append(messages, message{topic: topic, payload: payload})
+	}
+	return messages, nil
+}
+
+// connect opens an MQTT session with a clean session and no keep alive.
+func (s *Sink) connect(conn net.Conn, r *bufio.Reader) error {
+	flags := byte(0x02)
+	if s.Username != "" {
+		flags |= 0x80
+	}
+	if s.Password != "" {
+		flags |= 0x40
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags, 0, 0)
+	body = appendString(body, s.ClientID)
+	if s.Username != "" {
+		body = appendString(body, s.Username)
+	}
+	if s.Password != "" {
+		body = appendString(body, s.Password)
+	}
+	if err := (packet{header: connect << 4, body: body}).write(conn); err != nil {
+		return err
+	}
+	p, err := readPacket(r)
+	if err != nil {
+		return err
+	}
+	if p.kind() != connack || len(p.body) != 2 {
+		return fmt.Errorf("mqtt: unexpected packet type %d instead of CONNACK", p.kind())
+	}
+	if p.body[1] != 0 {
+		return fmt.Errorf("mqtt: connection refused with code %d", p.body[1])
+	}
+	return nil
+}
+
+// publish publishes a message and waits for its acknowledgement according to
+// the QoS.
+func (s *Sink) publish(conn net.Conn, r *bufio.Reader, m message, id uint16) error {
+	header := byte(publish<<4) | s.QoS<<1
+	if s.Retain {
+		header |= 1
+	}
+	body := appendString(nil, m.topic)
+	if s.QoS > 0 {
+		body = appendUint16(body, id)
+	}
+	if err := (packet{header: header, body: append(body, m.payload...)}).write(conn); err != nil {
+		return err
+	}
+	switch s.QoS {
+	case 1:
+		return expect(r, puback, id)
+	case 2:
+		if err := expect(r, pubrec, id); err != nil {
+			return err
+		}
+		if err := (packet{header: pubrel<<4 | 2, body: appendUint16(nil, id)}).write(conn); err != nil {
+			return err
+		}
+		return expect(r, pubcomp, id)
+	}
+	return nil
+}
+
+// expect reads the acknowledgement of the given type for the packet id.
+func expect(r *bufio.Reader, kind byte, id uint16) error {
+	p, err := readPacket(r)
+	if err != nil {
+		return err
+	}
+	if p.kind() != kind || len(p.body) < 2 || binary.BigEndian.Uint16(p.body) != id {
+		return fmt.Errorf("mqtt: unexpected packet type %d instead of %d for %d", p.kind(), kind, id)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Queue struct {
+		Sent metrics.Counter `metric:"sent"`
+	} `metric:"queue"`
+}
+
+// broker accepts a connection and returns the messages published on it.
+func broker(t *testing.T, l net.Listener) <-chan map[string][]byte {
+	published := make(chan map[string][]byte, 1)
+	go func() {
+		messages := make(map[string][]byte)
+		defer func() { published <- messages }()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			p, err := readPacket(r)
+			if err != nil {
+				t.Errorf("failed to read packet: %v", err)
+				return
+			}
+			switch p.kind() {
+			case connect:
+				if string(p.body[2:6]) != "MQTT" {
+					t.Errorf("unexpected protocol %q", p.body[2:6])
+				}
+				packet{header: connack << 4, body: []byte{0, 0}}.write(conn)
+			case publish:
+				n := int(binary.BigEndian.Uint16(p.body))
+				topic, rest := string(p.body[2:2+n]), p.body[2+n:]
+				qos := p.header >> 1 & 3
+				if qos > 0 {
+					id := rest[:2]
+					rest = rest[2:]
+					if qos == 1 {
+						packet{header: puback << 4, body: id}.write(conn)
+					} else {
+						packet{header: pubrec << 4, body: id}.write(conn)
+					}
+				}
+				messages[topic] = rest
+			case pubrel:
+				packet{header: pubcomp << 4, body: p.body}.write(conn)
+			case disconnect:
+				return
+			}
+		}
+	}()
+	return published
+}
+
+func TestSend(t *testing.T) {
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Queue.Sent.Inc(4)
+
+	for qos := byte(0); qos <= 2; qos++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		published := broker(t, l)
+		sink := New(l.Addr().String(), "edge/")
+		sink.QoS = qos
+		if err := sink.Send(mTags.Snapshot()); err != nil {
+			t.Fatalf("failed to send with QoS %d: %v", qos, err)
+		}
+		messages := <-published
+		l.Close()
+		var stats map[string]float64
+		if err := json.Unmarshal(messages["edge/queue/sent"], &stats); err != nil || stats["count"] != 4 {
+			t.Fatalf("unexpected messages with QoS %d: %v", qos, messages)
+		}
+	}
+}
+
+func TestSendSingleTopic(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	published := broker(t, l)
+	mTags := tagtrics.NewMetricTags(&testMetrics{}, func() {}, time.Second, metrics.NewRegistry(), ".")
+	sink := New(l.Addr().String(), "")
+	sink.Topic = "edge/snapshot"
+	if err := sink.Send(mTags.Snapshot()); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	messages := <-published
+	var snapshot map[string]map[string]interface{}
+	if len(messages) != 1 || json.Unmarshal(messages["edge/snapshot"], &snapshot) != nil || snapshot["queue.sent"] == nil {
+		t.Fatalf("unexpected messages: %v", messages)
+	}
+}
@@ -0,0 +1,92 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types.
+const (
+	connect    = 1
+	connack    = 2
+	publish    = 3
+	puback     = 4
+	pubrec     = 5
+	pubrel     = 6
+	pubcomp    = 7
+	disconnect = 14
+)
+
+// packet is an MQTT control packet.
+type packet struct {
+	// header is the first byte of the fixed header holding the packet type
+	// and flags.
+	header byte
+	body   []byte
+}
+
+// kind returns the control packet type.
+func (p packet) kind() byte {
+	return p.header >> 4
+}
+
+// write writes the packet to w.
+func (p packet) write(w io.Writer) error {
+	buf := []byte{p.header}
+	n := len(p.body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(buf, p.body...))
+	return err
+}
+
+// readPacket reads a packet from r.
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	var n, shift uint
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		if i == 4 {
+			return packet{}, errors.New("mqtt: invalid remaining length")
+		}
+		n |= uint(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{header: header, body: body}, nil
+}
+
+// appendString appends a length-prefixed string to buf.
+func appendString(buf []byte, s string) []byte {
+	return append(appendUint16(buf, uint16(len(s))), s...)
+}
+
+// appendUint16 appends a big endian uint16 to buf.
+func appendUint16(buf []byte, v uint16) []byte {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return append(buf, b[:]...)
+}
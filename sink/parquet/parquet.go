@@ -0,0 +1,143 @@
+// Package parquet archives tagtrics snapshots as Parquet files, e.g. in a
+// local directory or S3, for offline capacity analysis.
+package parquet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// DefaultInterval is how long snapshots are accumulated before being
+// written to a file unless configured otherwise.
+const DefaultInterval = time.Hour
+
+// Store stores the files written by a Sink.
+type Store interface {
+	// Put stores the file name holding data.
+	Put(name string, data []byte) error
+}
+
+// Dir is a Store writing files to a local directory.
+type Dir string
+
+// Put writes the file to the directory, creating the directories of a name
+// such as "api/tagtrics-20170321T000000Z.parquet".  It is written to a
+// temporary file next to it first so readers never see partial files.
+func (d Dir) Put(name string, data []byte) error {
+	path := filepath.Join(string(d), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Sink accumulates the statistics of the snapshots it is sent and writes
+// them to a Parquet file every Interval.  The files have a row per
+// statistic with the columns:
+//
+//	timestamp: INT64 (TIMESTAMP_MILLIS), the time of the snapshot
+//	name:      BYTE_ARRAY (UTF8), the name of the metric
+//	stat:      BYTE_ARRAY (UTF8), the statistic, e.g. "count"
+//	value:     DOUBLE, the value of the statistic
+//
+// and are named after the time of their first snapshot, e.g.
+// "tagtrics-20170321T000000Z.parquet".  The rows of a file which fails to be
+// stored are dropped.
+type Sink struct {
+	// Interval is how long snapshots are accumulated before being written
+	// to a file.  If not set, DefaultInterval is used.
+	Interval time.Duration
+	// Prefix is prepended to the names of the files, e.g. "api/".
+	Prefix string
+
+	store Store
+	mutex sync.Mutex
+	// start is the time of the first snapshot of the pending rows.
+	start     time.Time
+	rows      int
+	timestamp column
+	name      column
+	stat      column
+	value     column
+}
+
+// New returns a sink archiving snapshots to store, e.g. Dir("/var/metrics").
+func New(store Store) *Sink {
+	s := &Sink{store: store}
+	s.reset()
+	return s
+}
+
+// reset drops the pending rows.
+func (s *Sink) reset() {
+	s.rows = 0
+	s.timestamp = column{name: "timestamp", typ: typeInt64, converted: convertedTimestampMillis}
+	s.name = column{name: "name", typ: typeByteArray, converted: convertedUTF8}
+	s.stat = column{name: "stat", typ: typeByteArray, converted: convertedUTF8}
+	s.value = column{name: "value", typ: typeDouble, converted: -1}
+}
+
+// Send adds the statistics of the snapshot to the pending rows and writes
+// them to a file once the first of them is older than Interval.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.rows == 0 {
+		s.start = snapshot.Time
+	}
+	ts := snapshot.Time.UnixNano() / 1e6
+	for _, p := range snapshot.Points() {
+		s.timestamp.int64(ts)
+		s.name.byteArray(p.Name)
+		s.stat.byteArray(p.Stat)
+		s.value.double(p.Value)
+		s.rows++
+	}
+	interval := s.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	if snapshot.Time.Sub(s.start) < interval {
+		return nil
+	}
+	return s.write()
+}
+
+// Close writes the pending rows to a file.  It is meant to be called after
+// the final flush.
+func (s *Sink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.write()
+}
+
+// write writes the pending rows to a file, if any.
+func (s *Sink) write() error {
+	if s.rows == 0 {
+		return nil
+	}
+	data := encodeFile(s.rows, []*column{&s.timestamp, &s.name, &s.stat, &s.value})
+	name := s.Prefix + "tagtrics-" + s.start.UTC().Format("20060102T150405Z") + ".parquet"
+	s.reset()
+	if err := s.store.Put(name, data); err != nil {
+		return fmt.Errorf("parquet: failed to store %s: %v", name, err)
+	}
+	return nil
+}
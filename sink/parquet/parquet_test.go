@@ -0,0 +1,217 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Sent metrics.Counter `metric:"sent"`
+}
+
+// decoder decodes Thrift structs written with the compact protocol into
+// maps keyed by field id.
+type decoder struct {
+	t   *testing.T
+	buf []byte
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.t.Fatalf("invalid varint")
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.t.Fatalf("invalid varint")
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) value(typ byte) interface{} {
+	switch typ {
+	case typeI32, typeI64:
+		return d.varint()
+	case typeBinary:
+		n := d.uvarint()
+		s := string(d.buf[:n])
+		d.buf = d.buf[n:]
+		return s
+	case typeList:
+		h := d.buf[0]
+		d.buf = d.buf[1:]
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(d.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = d.value(h & 0xf)
+		}
+		return list
+	case typeStruct:
+		return d.structure()
+	}
+	d.t.Fatalf("unexpected type %d", typ)
+	return nil
+}
+
+func (d *decoder) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var id int16
+	for {
+		h := d.buf[0]
+		d.buf = d.buf[1:]
+		if h == 0 {
+			return fields
+		}
+		if delta := h >> 4; delta != 0 {
+			id += int16(delta)
+		} else {
+			id = int16(d.varint())
+		}
+		fields[id] = d.value(h & 0xf)
+	}
+}
+
+func TestSend(t *testing.T) {
+	dir := t.TempDir()
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	sink := New(Dir(dir))
+	sink.Interval = time.Minute
+	start := time.Date(2017, 3, 21, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		m.Sent.Inc(1)
+		snapshot := mTags.Snapshot()
+		snapshot.Time = start.Add(time.Duration(i) * 30 * time.Second)
+		if err := sink.Send(snapshot); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "tagtrics-20170321T000000Z.parquet"))
+	if err != nil {
+		t.Fatalf("file not written: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("missing magic numbers")
+	}
+	n := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &decoder{t: t, buf: data[len(data)-8-n : len(data)-8]}
+	meta := footer.structure()
+	rows := meta[3].(int64)
+	if rows == 0 || rows%3 != 0 {
+		t.Fatalf("expected rows of 3 snapshots, got %d", rows)
+	}
+	schema := meta[2].([]interface{})
+	var names []string
+	for _, e := range schema[1:] {
+		names = append(names, e.(map[int16]interface{})[4].(string))
+	}
+	if strings.Join(names, ",") != "timestamp,name,stat,value" {
+		t.Fatalf("unexpected columns %v", names)
+	}
+
+	// Find the count of sent in the last snapshot from the name, stat and
+	// value columns.
+	chunks := meta[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+	column := func(i int) []byte {
+		cm := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+		offset, size := cm[9].(int64), cm[7].(int64)
+		page := &decoder{t: t, buf: data[offset : offset+size]}
+		header := page.structure()
+		if header[2].(int64) != int64(len(page.buf)) {
+			t.Fatalf("page size %d, got %d bytes", header[2], len(page.buf))
+		}
+		return page.buf
+	}
+	strs := func(b []byte) []string {
+		var s []string
+		for len(b) > 0 {
+			l := binary.LittleEndian.Uint32(b)
+			s, b = append(s, string(b[4:4+l])), b[4+l:]
+		}
+		return s
+	}
+	nameCol, statCol, valueCol := strs(column(1)), strs(column(2)), column(3)
+	var last float64
+	for i := range nameCol {
+		if nameCol[i] == "sent" && statCol[i] == "count" {
+			last = math.Float64frombits(binary.LittleEndian.Uint64(valueCol[8*i:]))
+		}
+	}
+	if last != 3 {
+		t.Fatalf("expected count 3, got %v", last)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected no pending rows, got files %v", files)
+	}
+}
+
+func TestS3(t *testing.T) {
+	var path, auth string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s3 := &S3{Bucket: "metrics", Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: srv.URL}
+	s3.now = func() time.Time { return time.Date(2017, 3, 21, 0, 0, 0, 0, time.UTC) }
+	if err := s3.Put("api/file.parquet", []byte("PAR1")); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	if path != "/metrics/api/file.parquet" || string(body) != "PAR1" {
+		t.Fatalf("unexpected upload of %q to %s", body, path)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKID/20170321/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(auth, want) || len(auth) != len(want)+64 {
+		t.Fatalf("unexpected authorization %q", auth)
+	}
+}
+
+func TestDirPrefix(t *testing.T) {
+	dir := t.TempDir()
+	mTags := tagtrics.NewMetricTags(&testMetrics{}, func() {}, time.Second, metrics.NewRegistry(), ".")
+	sink := New(Dir(dir))
+	sink.Prefix = "api/"
+	snapshot := mTags.Snapshot()
+	snapshot.Time = time.Date(2017, 3, 21, 0, 0, 0, 0, time.UTC)
+	if err := sink.Send(snapshot); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "api", "tagtrics-20170321T000000Z.parquet")); err != nil {
+		t.Fatalf("file not written beneath the prefix: %v", err)
+	}
+	files, _ := os.ReadDir(filepath.Join(dir, "api"))
+	if len(files) != 1 {
+		t.Fatalf("expected only the file beneath the prefix, got %v", files)
+	}
+}
@@ -0,0 +1,119 @@
+package parquet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// S3 is a Store uploading files to an S3 bucket.  Requests are signed with
+// AWS Signature Version 4.
+type S3 struct {
+	// Bucket and Region locate the bucket, e.g. "metrics" and "us-east-1".
+	Bucket, Region string
+	// AccessKeyID and SecretAccessKey are the credentials used to sign
+	// requests and SessionToken the token of temporary credentials, if any.
+	AccessKeyID, SecretAccessKey, SessionToken string
+	// Endpoint is the URL of the S3 compatible service, e.g.
+	// "http://minio:9000".  If not set, the bucket's virtual host at AWS is
+	// used.
+	Endpoint string
+	// Client is the HTTP client used to upload files.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// now returns the time requests are signed at.
+	now func() time.Time
+}
+
+// url returns the URL of the object key.
+func (s *S3) url(key string) string {
+	if s.Endpoint != "" {
+		return strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/" + key
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, key)
+}
+
+// Put uploads the file as the object name.
+func (s *S3) Put(name string, data []byte) error {
+	req, err := http.NewRequest("PUT", s.url(name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.apache.parquet")
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	s.sign(req, data, now().UTC())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("parquet: unexpected status %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// sign adds the headers of an AWS Signature Version 4 to req.
+func (s *S3) sign(req *http.Request, body []byte, t time.Time) {
+	date, stamp := t.Format("20060102"), t.Format("20060102T150405Z")
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if s.SessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	var canonical strings.Builder
+	for _, h := range headers {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonical.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signed := strings.Join(headers, ";")
+	request := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonical.String(),
+		signed,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(request))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{date, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,215 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// The page headers and footer of Parquet files are Thrift structs encoded
+// by hand with the compact protocol to avoid a dependency on Thrift.  The
+// field ids are those of parquet-format's parquet.thrift.
+
+// Types of the compact protocol.
+const (
+	typeI32    = 5
+	typeI64    = 6
+	typeBinary = 8
+	typeList   = 9
+	typeStruct = 12
+)
+
+// compact writes Thrift structs with the compact protocol.
+type compact struct {
+	buf []byte
+	// id is the id of the last field written in the current struct and
+	// ids those of the enclosing structs.
+	id  int16
+	ids []int16
+}
+
+// field writes the header of field id of type typ.
+func (c *compact) field(id int16, typ byte) {
+	if d := id - c.id; d > 0 && d <= 15 {
+		c.buf = append(c.buf, byte(d)<<4|typ)
+	} else {
+		c.buf = append(c.buf, typ)
+		c.buf = binary.AppendVarint(c.buf, int64(id))
+	}
+	c.id = id
+}
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(id, typeI32)
+	c.buf = binary.AppendVarint(c.buf, int64(v))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(id, typeI64)
+	c.buf = binary.AppendVarint(c.buf, v)
+}
+
+func (c *compact) binary(id int16, s string) {
+	c.field(id, typeBinary)
+	c.str(s)
+}
+
+// str writes a string without a field header, e.g. a list element.
+func (c *compact) str(s string) {
+	c.buf = binary.AppendUvarint(c.buf, uint64(len(s)))
+	c.buf = append(c.buf, s...)
+}
+
+// list writes the header of field id holding a list of n elements of type
+// typ.  The elements follow without field headers.
+func (c *compact) list(id int16, typ byte, n int) {
+	c.field(id, typeList)
+	if n < 15 {
+		c.buf = append(c.buf, byte(n)<<4|typ)
+	} else {
+		c.buf = append(c.buf, 0xf0|typ)
+		c.buf = binary.AppendUvarint(c.buf, uint64(n))
+	}
+}
+
+// i32s writes field id holding a list of i32.
+func (c *compact) i32s(id int16, vs ...int32) {
+	c.list(id, typeI32, len(vs))
+	for _, v := range vs {
+		c.buf = binary.AppendVarint(c.buf, int64(v))
+	}
+}
+
+// begin starts a struct without a field header, e.g. a list element, and
+// structField one in field id.  Both are closed with end.
+func (c *compact) begin() {
+	c.ids = append(c.ids, c.id)
+	c.id = 0
+}
+
+func (c *compact) structField(id int16) {
+	c.field(id, typeStruct)
+	c.begin()
+}
+
+func (c *compact) end() {
+	c.buf = append(c.buf, 0)
+	c.id, c.ids = c.ids[len(c.ids)-1], c.ids[:len(c.ids)-1]
+}
+
+// Values of the parquet.thrift enums used by the files.
+const (
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	repetitionRequired = 0
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+
+	pageData = 0
+)
+
+// column is a required column of a file with its PLAIN encoded values.
+type column struct {
+	name      string
+	typ       int32
+	converted int32
+	values    []byte
+}
+
+func (c *column) int64(v int64) {
+	c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v))
+}
+
+func (c *column) double(v float64) {
+	c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(v))
+}
+
+func (c *column) byteArray(s string) {
+	c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(s)))
+	c.values = append(c.values, s...)
+}
+
+// encodeFile returns a Parquet file of a single row group holding rows rows
+// of the given columns, each written as a single uncompressed data page.
+// The columns being required, the pages have no repetition or definition
+// levels.
+func encodeFile(rows int, columns []*column) []byte {
+	buf := []byte("PAR1")
+	offsets := make([]int64, len(columns))
+	sizes := make([]int64, len(columns))
+	for i, col := range columns {
+		var h compact
+		h.begin()
+		h.i32(1, pageData)
+		h.i32(2, int32(len(col.values)))
+		h.i32(3, int32(len(col.values)))
+		h.structField(5)
+		h.i32(1, int32(rows))
+		h.i32(2, encodingPlain)
+		h.i32(3, encodingRLE)
+		h.i32(4, encodingRLE)
+		h.end()
+		h.end()
+		offsets[i] = int64(len(buf))
+		sizes[i] = int64(len(h.buf) + len(col.values))
+		buf = append(buf, h.buf...)
+		buf = append(buf, col.values...)
+	}
+
+	var f compact
+	f.begin()
+	f.i32(1, 1)
+	f.list(2, typeStruct, len(columns)+1)
+	f.begin()
+	f.binary(4, "schema")
+	f.i32(5, int32(len(columns)))
+	f.end()
+	for _, col := range columns {
+		f.begin()
+		f.i32(1, col.typ)
+		f.i32(3, repetitionRequired)
+		f.binary(4, col.name)
+		if col.converted >= 0 {
+			f.i32(6, col.converted)
+		}
+		f.end()
+	}
+	f.i64(3, int64(rows))
+	f.list(4, typeStruct, 1)
+	f.begin()
+	f.list(1, typeStruct, len(columns))
+	var total int64
+	for i, col := range columns {
+		f.begin()
+		f.i64(2, offsets[i])
+		f.structField(3)
+		f.i32(1, col.typ)
+		f.i32s(2, encodingPlain, encodingRLE)
+		f.list(3, typeBinary, 1)
+		f.str(col.name)
+		f.i32(4, codecUncompressed)
+		f.i64(5, int64(rows))
+		f.i64(6, sizes[i])
+		f.i64(7, sizes[i])
+		f.i64(9, offsets[i])
+		f.end()
+		f.end()
+		total += sizes[i]
+	}
+	f.i64(2, total)
+	f.i64(3, int64(rows))
+	f.end()
+	f.binary(6, "tagtrics")
+	f.end()
+
+	buf = append(buf, f.buf...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(f.buf)))
+	return append(buf, "PAR1"...)
+}
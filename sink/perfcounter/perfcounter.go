@@ -0,0 +1,205 @@
+// Package perfcounter exports selected tagtrics statistics as Windows
+// performance counters, e.g. for services on customer-managed Windows hosts
+// monitored with perfmon.
+//
+// The counters are published with the PerfLib version 2 API whose provider
+// and counter set are described by an instrumentation manifest installed
+// with lodctr.  WriteManifest writes the manifest of a Sink, e.g. from an
+// installer:
+//
+//	s.WriteManifest(f, `C:\Program Files\API\api.exe`)
+//	// then, as an administrator: lodctr /m:api.man
+//
+// Sending fails on other platforms.
+package perfcounter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// Counter selects the statistic of a metric published as a counter.
+type Counter struct {
+	// Metric is the name of the metric, e.g. "api.requests".
+	Metric string
+	// Stat is the statistic of the metric as returned by
+	// Snapshot.Stats, e.g. "count", "value" or "99%".
+	Stat string
+	// Name is the name of the counter shown in perfmon.  If not set, Metric
+	// and Stat joined with a space are used.
+	Name string
+	// Help is the description of the counter shown in perfmon.
+	Help string
+	// Decimals is the number of decimal places kept of the statistic,
+	// which performance counters hold as integers.
+	Decimals int
+}
+
+// Sink publishes the selected statistics of every snapshot as the counters
+// of a single instance counter set.  Statistics missing from a snapshot are
+// published as zero.
+type Sink struct {
+	// Counters are the counters of the counter set in order.  They must not
+	// change once the manifest is installed.
+	Counters []Counter
+
+	name                   string
+	providerID, counterSet guid
+	// provider holds the state of the started provider on Windows.
+	provider *provider
+}
+
+// New returns a sink publishing the counter set named name, e.g. "API",
+// of the provider with the GUID providerID.  counterSetID is the GUID of the
+// counter set.  GUIDs are formatted like
+// "{ab8e2a2c-0ffb-4d1f-9a66-2f1c4f2b6a11}".
+func New(name, providerID, counterSetID string, counters ...Counter) (*Sink, error) {
+	s := &Sink{Counters: counters, name: name}
+	var err error
+	if s.providerID, err = parseGUID(providerID); err != nil {
+		return nil, err
+	}
+	if s.counterSet, err = parseGUID(counterSetID); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// values returns the values of the counters in snapshot.
+func (s *Sink) values(snapshot *tagtrics.Snapshot) []int64 {
+	values := make([]int64, len(s.Counters))
+	for i, c := range s.Counters {
+		v, ok := snapshot.Stats(c.Metric)[c.Stat]
+		if !ok || math.IsNaN(v) {
+			continue
+		}
+		values[i] = int64(math.Round(v * math.Pow10(c.Decimals)))
+	}
+	return values
+}
+
+// name returns the name of the counter in perfmon.
+func (c Counter) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Metric + " " + c.Stat
+}
+
+// WriteManifest writes the instrumentation manifest describing the counter
+// set to w.  binary is the path of the executable publishing the counters.
+func (s *Sink) WriteManifest(w io.Writer, binary string) error {
+	type counter struct {
+		ID           int    `xml:"id,attr"`
+		URI          string `xml:"uri,attr"`
+		Name         string `xml:"name,attr"`
+		Description  string `xml:"description,attr"`
+		Type         string `xml:"type,attr"`
+		DetailLevel  string `xml:"detailLevel,attr"`
+		DefaultScale int    `xml:"defaultScale,attr"`
+	}
+	type counterSet struct {
+		GUID        string    `xml:"guid,attr"`
+		URI         string    `xml:"uri,attr"`
+		Name        string    `xml:"name,attr"`
+		Description string    `xml:"description,attr"`
+		Instances   string    `xml:"instances,attr"`
+		Counters    []counter `xml:"counter"`
+	}
+	type provider struct {
+		Name                string     `xml:"providerName,attr"`
+		GUID                string     `xml:"providerGuid,attr"`
+		ApplicationIdentity string     `xml:"applicationIdentity,attr"`
+		Type                string     `xml:"providerType,attr"`
+		CounterSet          counterSet `xml:"counterSet"`
+	}
+	type manifest struct {
+		XMLName  xml.Name `xml:"http://schemas.microsoft.com/win/2004/08/events instrumentationManifest"`
+		Counters struct {
+			XMLNS         string   `xml:"xmlns,attr"`
+			SchemaVersion string   `xml:"schemaVersion,attr"`
+			Provider      provider `xml:"provider"`
+		} `xml:"instrumentation>counters"`
+	}
+	uri := "tagtrics." + strings.ReplaceAll(s.name, " ", "_")
+	set := counterSet{
+		GUID:        s.counterSet.String(),
+		URI:         uri,
+		Name:        s.name,
+		Description: s.name + " metrics",
+		Instances:   "single",
+	}
+	for i, c := range s.Counters {
+		set.Counters = append(set.Counters, counter{
+			ID:           i + 1,
+			URI:          uri + "." + strconv.Itoa(i+1),
+			Name:         c.name(),
+			Description:  c.Help,
+			Type:         "perf_counter_large_rawcount",
+			DetailLevel:  "standard",
+			DefaultScale: -c.Decimals,
+		})
+	}
+	var m manifest
+	m.Counters.XMLNS = "http://schemas.microsoft.com/win/2005/12/counters"
+	m.Counters.SchemaVersion = "2.0"
+	m.Counters.Provider = provider{
+		Name:                s.name,
+		GUID:                s.providerID.String(),
+		ApplicationIdentity: binary,
+		Type:                "userMode",
+		CounterSet:          set,
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// guid is a Windows GUID in memory layout.
+type guid struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+// parseGUID parses a GUID formatted like
+// "{ab8e2a2c-0ffb-4d1f-9a66-2f1c4f2b6a11}", with or without the braces.
+func parseGUID(s string) (guid, error) {
+	var g guid
+	hex := strings.ReplaceAll(strings.Trim(s, "{}"), "-", "")
+	if len(hex) != 32 || len(strings.Trim(s, "{}")) != 36 {
+		return g, fmt.Errorf("perfcounter: invalid GUID %q", s)
+	}
+	var b [16]byte
+	for i := range b {
+		v, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return g, fmt.Errorf("perfcounter: invalid GUID %q", s)
+		}
+		b[i] = byte(v)
+	}
+	g.Data1 = uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	g.Data2 = uint16(b[4])<<8 | uint16(b[5])
+	g.Data3 = uint16(b[6])<<8 | uint16(b[7])
+	copy(g.Data4[:], b[8:])
+	return g, nil
+}
+
+// String formats g with braces like the manifest does.
+func (g guid) String() string {
+	return fmt.Sprintf("{%08x-%04x-%04x-%x-%x}", g.Data1, g.Data2, g.Data3, g.Data4[:2], g.Data4[2:])
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package perfcounter
+
+import (
+	"errors"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// provider is only used on Windows.
+type provider struct{}
+
+// Send fails since performance counters only exist on Windows.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	return errors.New("perfcounter: performance counters are only supported on Windows")
+}
+
+// Close does nothing on other platforms.
+func (s *Sink) Close() error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package perfcounter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+func TestSink(t *testing.T) {
+	s, err := New("API", "{ab8e2a2c-0ffb-4d1f-9a66-2f1c4f2b6a11}", "4c1e9f3a-3b5e-4f7e-8d2c-6a0b9e1f2d33",
+		Counter{Metric: "requests", Stat: "count", Name: "Requests", Help: "Requests served"},
+		Counter{Metric: "load", Stat: "value", Decimals: 2},
+		Counter{Metric: "missing", Stat: "count"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.providerID.String(); got != "{ab8e2a2c-0ffb-4d1f-9a66-2f1c4f2b6a11}" {
+		t.Fatalf("parsed GUID formats as %s", got)
+	}
+	if _, err := New("API", "{ab8e2a2c}", "4c1e9f3a-3b5e-4f7e-8d2c-6a0b9e1f2d33"); err == nil {
+		t.Fatalf("invalid GUID accepted")
+	}
+
+	m := &struct {
+		Requests metrics.Counter         `metric:"requests"`
+		Load     tagtrics.Gauge[float64] `metric:"load"`
+	}{}
+	tags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Requests.Inc(3)
+	m.Load.Update(0.756)
+	if got := s.values(tags.Snapshot()); got[0] != 3 || got[1] != 76 || got[2] != 0 {
+		t.Fatalf("unexpected values %v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteManifest(&buf, `C:\api.exe`); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`providerGuid="{ab8e2a2c-0ffb-4d1f-9a66-2f1c4f2b6a11}"`,
+		`applicationIdentity="C:\api.exe"`,
+		`<counter id="1" uri="tagtrics.API.1" name="Requests" description="Requests served"`,
+		`name="load value" description="" type="perf_counter_large_rawcount" detailLevel="standard" defaultScale="-2"`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("manifest missing %s:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package perfcounter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// The PerfLib version 2 API of advapi32.dll.
+var (
+	advapi32                   = syscall.NewLazyDLL("advapi32.dll")
+	procPerfStartProvider      = advapi32.NewProc("PerfStartProvider")
+	procPerfStopProvider       = advapi32.NewProc("PerfStopProvider")
+	procPerfSetCounterSetInfo  = advapi32.NewProc("PerfSetCounterSetInfo")
+	procPerfCreateInstance     = advapi32.NewProc("PerfCreateInstance")
+	procPerfDeleteInstance     = advapi32.NewProc("PerfDeleteInstance")
+	procPerfSetCounterRefValue = advapi32.NewProc("PerfSetCounterRefValue")
+)
+
+// Constants of winperf.h and perflib.h.
+const (
+	perfCounterLargeRawcount     = 0x00010100
+	perfAttribByReference        = 0x1
+	perfDetailNovice             = 100
+	perfCountersetSingleInstance = 0
+)
+
+// provider is a started PerfLib provider publishing the counter set of a
+// Sink.  The counters refer to values, which are updated in place.
+type provider struct {
+	handle   uintptr
+	instance uintptr
+	values   []int64
+}
+
+// Send publishes the statistics of the snapshot, starting the provider on
+// the first call.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	if s.provider == nil {
+		p, err := s.start()
+		if err != nil {
+			return err
+		}
+		s.provider = p
+	}
+	for i, v := range s.values(snapshot) {
+		atomic.StoreInt64(&s.provider.values[i], v)
+	}
+	return nil
+}
+
+// Close stops publishing the counters.
+func (s *Sink) Close() error {
+	p := s.provider
+	if p == nil {
+		return nil
+	}
+	s.provider = nil
+	procPerfDeleteInstance.Call(p.handle, p.instance)
+	if r, _, _ := procPerfStopProvider.Call(p.handle); r != 0 {
+		return fmt.Errorf("perfcounter: failed to stop provider: %v", syscall.Errno(r))
+	}
+	return nil
+}
+
+// start starts the provider, registers the counter set and creates its
+// instance with the counters referring to the values of the provider.
+func (s *Sink) start() (*provider, error) {
+	p := &provider{values: make([]int64, len(s.Counters))}
+	if err := procPerfStartProvider.Find(); err != nil {
+		return nil, fmt.Errorf("perfcounter: %v", err)
+	}
+	if r, _, _ := procPerfStartProvider.Call(uintptr(unsafe.Pointer(&s.providerID)), 0, uintptr(unsafe.Pointer(&p.handle))); r != 0 {
+		return nil, fmt.Errorf("perfcounter: failed to start provider: %v", syscall.Errno(r))
+	}
+	template := s.template()
+	if r, _, _ := procPerfSetCounterSetInfo.Call(p.handle, uintptr(unsafe.Pointer(&template[0])), uintptr(len(template))); r != 0 {
+		procPerfStopProvider.Call(p.handle)
+		return nil, fmt.Errorf("perfcounter: failed to register counter set: %v", syscall.Errno(r))
+	}
+	name, err := syscall.UTF16PtrFromString(s.name)
+	if err != nil {
+		procPerfStopProvider.Call(p.handle)
+		return nil, err
+	}
+	instance, _, errno := procPerfCreateInstance.Call(p.handle, uintptr(unsafe.Pointer(&s.counterSet)), uintptr(unsafe.Pointer(name)), 0)
+	if instance == 0 {
+		procPerfStopProvider.Call(p.handle)
+		return nil, fmt.Errorf("perfcounter: failed to create instance: %v", errno)
+	}
+	p.instance = instance
+	for i := range p.values {
+		if r, _, _ := procPerfSetCounterRefValue.Call(p.handle, p.instance, uintptr(i+1), uintptr(unsafe.Pointer(&p.values[i]))); r != 0 {
+			procPerfDeleteInstance.Call(p.handle, p.instance)
+			procPerfStopProvider.Call(p.handle)
+			return nil, fmt.Errorf("perfcounter: failed to set counter %d: %v", i+1, syscall.Errno(r))
+		}
+	}
+	return p, nil
+}
+
+// template returns the PERF_COUNTERSET_INFO structure describing the
+// counter set followed by the PERF_COUNTER_INFO structure of every counter.
+func (s *Sink) template() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, struct {
+		CounterSet, Provider guid
+		NumCounters          uint32
+		InstanceType         uint32
+	}{s.counterSet, s.providerID, uint32(len(s.Counters)), perfCountersetSingleInstance})
+	for i, c := range s.Counters {
+		binary.Write(&buf, binary.LittleEndian, struct {
+			ID, Type    uint32
+			Attrib      uint64
+			Size        uint32
+			DetailLevel uint32
+			Scale       int32
+			Offset      uint32
+		}{uint32(i + 1), perfCounterLargeRawcount, perfAttribByReference, 8, perfDetailNovice, int32(-c.Decimals), uint32(8 * i)})
+	}
+	return buf.Bytes()
+}
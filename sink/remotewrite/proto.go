@@ -0,0 +1,75 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// The messages of the remote_write protocol are encoded by hand to avoid a
+// dependency on protobuf.  The field numbers are those of
+// prometheus/prompb:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+
+// series is a time series with a single sample.
+type series struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// encodeWriteRequest returns the WriteRequest message of the given series.
+func encodeWriteRequest(ts []series) []byte {
+	var buf []byte
+	for _, s := range ts {
+		buf = appendMessage(buf, 1, encodeTimeSeries(s))
+	}
+	return buf
+}
+
+// encodeTimeSeries returns the TimeSeries message of s.  Labels are sorted
+// by name as receivers require.
+func encodeTimeSeries(s series) []byte {
+	names := make([]string, 0, len(s.labels))
+	for name := range s.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf []byte
+	for _, name := range names {
+		var label []byte
+		label = appendMessage(label, 1, []byte(name))
+		label = appendMessage(label, 2, []byte(s.labels[name]))
+		buf = appendMessage(buf, 1, label)
+	}
+	var sample []byte
+	sample = appendVarint(sample, 1<<3|1)
+	sample = appendFixed64(sample, math.Float64bits(s.value))
+	sample = appendVarint(sample, 2<<3)
+	sample = appendVarint(sample, uint64(s.timestamp))
+	return appendMessage(buf, 2, sample)
+}
+
+// appendMessage appends a length-delimited field to buf.
+func appendMessage(buf []byte, field int, b []byte) []byte {
+	buf = appendVarint(buf, uint64(field)<<3|2)
+	buf = appendVarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+// appendVarint appends v as a varint to buf.
+func appendVarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+// appendFixed64 appends v as a little endian fixed64 to buf.
+func appendFixed64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
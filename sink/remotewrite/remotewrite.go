@@ -0,0 +1,91 @@
+// Package remotewrite exports tagtrics snapshots with the Prometheus
+// remote_write protocol, e.g. to VictoriaMetrics, Mimir or Thanos receivers.
+package remotewrite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/sendgrid/tagtrics"
+	"github.com/sendgrid/tagtrics/internal/prom"
+)
+
+// Sink sends every statistic of every snapshot as a sample.  Statistics are
+// named after the metric and the statistic with the characters Prometheus
+// doesn't allow replaced with underscores, e.g. "queue_wait_count", except
+// percentiles which are a "quantile" label of the metric's series.
+type Sink struct {
+	// Labels are added to every series, e.g. {"job": "api"}.
+	Labels map[string]string
+	// Username and Password are used for basic authentication if set.
+	Username, Password string
+	// BearerToken is sent in the Authorization header if set.
+	BearerToken string
+	// Client is the HTTP client used to send samples.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	url string
+}
+
+// New returns a sink writing to the remote_write endpoint at url, e.g.
+// "http://mimir/api/v1/push".
+func New(url string) *Sink {
+	return &Sink{url: url}
+}
+
+// Send writes the snapshot.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	body := snappyEncode(encodeWriteRequest(s.series(snapshot)))
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if s.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.BearerToken)
+	} else if s.Username != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remotewrite: unexpected status %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// series returns the series of every point of the snapshot.
+func (s *Sink) series(snapshot *tagtrics.Snapshot) []series {
+	ts := snapshot.Time.UnixNano() / 1e6
+	var all []series
+	for _, p := range snapshot.Points() {
+		name, extra := prom.Series(p.Family, p.Stat)
+		labels := make(map[string]string, len(s.Labels)+len(p.Labels)+len(extra)+1)
+		for k, v := range s.Labels {
+			labels[prom.LabelName(k)] = v
+		}
+		for k, v := range p.Labels {
+			labels[prom.LabelName(k)] = v
+		}
+		for k, v := range extra {
+			labels[k] = v
+		}
+		labels["__name__"] = name
+		all = append(all, series{labels: labels, value: p.Value, timestamp: ts})
+	}
+	return all
+}
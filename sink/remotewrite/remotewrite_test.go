@@ -0,0 +1,94 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Sent metrics.Counter `metric:"sent"`
+	Wait metrics.Timer   `metric:"wait"`
+}
+
+// fields decodes the fields of a protobuf message keyed by field number.
+func fields(t *testing.T, b []byte) map[int][][]byte {
+	f := make(map[int][][]byte)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		var v []byte
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+			v, b = b[:n], b[n:]
+		case 1:
+			v, b = b[:8], b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			v, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		f[int(key>>3)] = append(f[int(key>>3)], v)
+	}
+	return f
+}
+
+func TestSend(t *testing.T) {
+	got := make(map[string]float64)
+	var timestamp uint64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		req, err := snappyDecode(body)
+		if err != nil {
+			t.Errorf("invalid snappy body: %v", err)
+			return
+		}
+		for _, ts := range fields(t, req)[1] {
+			f := fields(t, ts)
+			var id string
+			for _, label := range f[1] {
+				l := fields(t, label)
+				id += string(l[1][0]) + "=" + string(l[2][0]) + ","
+			}
+			sample := fields(t, f[2][0])
+			got[id] = math.Float64frombits(binary.LittleEndian.Uint64(sample[1][0]))
+			timestamp, _ = binary.Uvarint(sample[2][0])
+		}
+	}))
+	defer srv.Close()
+
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Sent.Inc(4)
+	m.Wait.Update(time.Millisecond)
+	snapshot := mTags.Snapshot()
+	snapshot.Time = time.Unix(1490054400, 0)
+	sink := New(srv.URL)
+	sink.Labels = map[string]string{"job": "api"}
+	sink.BearerToken = "token"
+
+	if err := sink.Send(snapshot); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if v, ok := got["__name__=sent_count,job=api,"]; !ok || v != 4 {
+		t.Fatalf("unexpected series: %v", got)
+	}
+	if v, ok := got["__name__=wait,job=api,quantile=0.99,"]; !ok || v != 1e6 {
+		t.Fatalf("unexpected series: %v", got)
+	}
+	if timestamp != 1490054400000 {
+		t.Fatalf("unexpected timestamp %d", timestamp)
+	}
+}
@@ -0,0 +1,69 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+)
+
+// snappyEncode compresses src in the snappy block format remote_write
+// receivers expect.  It is a simple greedy encoder which finds repeated
+// 4-byte sequences with a hash table and emits them as copies, trading some
+// compression ratio against the reference implementation for brevity.
+func snappyEncode(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen64, len(src)/2+16)
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+	var table [1 << 14]int
+	lit := 0
+	for i := 0; i+4 <= len(src); {
+		key := binary.LittleEndian.Uint32(src[i:])
+		h := (key * 0x1e35a7bd) >> 18
+		// Positions are stored plus one so zero means empty.
+		cand := table[h] - 1
+		table[h] = i + 1
+		if cand < 0 || i-cand >= 1<<16 || binary.LittleEndian.Uint32(src[cand:]) != key {
+			i++
+			continue
+		}
+		n := 4
+		for i+n < len(src) && src[cand+n] == src[i+n] {
+			n++
+		}
+		dst = emitLiteral(dst, src[lit:i])
+		dst = emitCopy(dst, i-cand, n)
+		i += n
+		lit = i
+	}
+	return emitLiteral(dst, src[lit:])
+}
+
+// emitLiteral appends a literal element holding lit to dst.
+func emitLiteral(dst, lit []byte) []byte {
+	n := len(lit) - 1
+	switch {
+	case len(lit) == 0:
+		return dst
+	case n < 60:
+		dst = append(dst, byte(n<<2))
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// emitCopy appends copy elements of n bytes at offset back to dst.
+func emitCopy(dst []byte, offset, n int) []byte {
+	for n > 0 {
+		l := n
+		if l > 64 {
+			l = 64
+		}
+		dst = append(dst, byte((l-1)<<2|2), byte(offset), byte(offset>>8))
+		n -= l
+	}
+	return dst
+}
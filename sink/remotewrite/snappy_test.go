@@ -0,0 +1,76 @@
+package remotewrite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// snappyDecode decodes the snappy block format.
+func snappyDecode(src []byte) ([]byte, error) {
+	n, l := binary.Uvarint(src)
+	if l <= 0 {
+		return nil, errors.New("invalid length")
+	}
+	src = src[l:]
+	var dst []byte
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			length := int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				length = 0
+				for i := 0; i < extra; i++ {
+					length |= int(src[i]) << (8 * uint(i))
+				}
+				src = src[extra:]
+			}
+			length++
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+		case 2:
+			length := int(tag>>2) + 1
+			offset := int(src[1]) | int(src[2])<<8
+			if offset == 0 || offset > len(dst) {
+				return nil, errors.New("invalid offset")
+			}
+			for i := 0; i < length; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+			src = src[3:]
+		default:
+			return nil, errors.New("unexpected element")
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, errors.New("length mismatch")
+	}
+	return dst, nil
+}
+
+func TestSnappyEncode(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	inputs := [][]byte{
+		nil,
+		[]byte("abc"),
+		[]byte(strings.Repeat("queue_wait_count", 10000)),
+		random,
+	}
+	for _, in := range inputs {
+		enc := snappyEncode(in)
+		out, err := snappyDecode(enc)
+		if err != nil || !bytes.Equal(in, out) {
+			t.Fatalf("failed to round trip %d bytes: %v", len(in), err)
+		}
+	}
+	if enc := snappyEncode(inputs[2]); len(enc) > len(inputs[2])/10 {
+		t.Fatalf("repeated input not compressed: %d bytes", len(enc))
+	}
+}
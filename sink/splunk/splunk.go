@@ -0,0 +1,153 @@
+// Package splunk exports tagtrics snapshots to the Splunk HTTP Event
+// Collector as metric events.
+package splunk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+// DefaultBatchSize is the maximum number of events sent in a request unless
+// configured otherwise.
+const DefaultBatchSize = 100
+
+// Sink sends an event per metric of every snapshot to the HTTP Event
+// Collector in the multiple-metric format: every statistic is a
+// "metric_name:<metric>.<statistic>" field and the labels of the metric are
+// dimensions.
+type Sink struct {
+	// Host, Source, SourceType and Index are set on every event if not
+	// empty.  Index must be a metrics index.
+	Host, Source, SourceType, Index string
+	// BatchSize is the maximum number of events sent in a request.  If not
+	// set, DefaultBatchSize is used.
+	BatchSize int
+	// Gzip compresses the requests.
+	Gzip bool
+	// Client is the HTTP client used to send events.  If not set,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	url   string
+	token string
+}
+
+// event is a metric event of the HTTP Event Collector.
+type event struct {
+	Time       float64                `json:"time"`
+	Event      string                 `json:"event"`
+	Host       string                 `json:"host,omitempty"`
+	Source     string                 `json:"source,omitempty"`
+	SourceType string                 `json:"sourcetype,omitempty"`
+	Index      string                 `json:"index,omitempty"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+// New returns a sink sending events to the collector at url, e.g.
+// "https://splunk:8088", authenticated with token.
+func New(url, token string) *Sink {
+	return &Sink{url: strings.TrimSuffix(url, "/"), token: token}
+}
+
+// Send sends the snapshot in batches of at most BatchSize events.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	events := s.events(snapshot)
+	size := s.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	for len(events) > 0 {
+		n := size
+		if n > len(events) {
+			n = len(events)
+		}
+		if err := s.post(events[:n]); err != nil {
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
+
+// events returns the events of every metric of the snapshot.
+func (s *Sink) events(snapshot *tagtrics.Snapshot) []event {
+	t := float64(snapshot.Time.UnixNano()/1e6) / 1e3
+	var events []event
+	for _, name := range snapshot.Names() {
+		stats := snapshot.Stats(name)
+		if stats == nil {
+			continue
+		}
+		fields := make(map[string]interface{})
+		for k, v := range snapshot.Labels(name) {
+			fields[k] = v
+		}
+		for k, v := range stats {
+			fields["metric_name:"+name+"."+k] = v
+		}
+		events = append(events, event{
+			Time:       t,
+			Event:      "metric",
+			Host:       s.Host,
+			Source:     s.Source,
+			SourceType: s.SourceType,
+			Index:      s.Index,
+			Fields:     fields,
+		})
+	}
+	return events
+}
+
+// post sends a batch of events to the collector.
+func (s *Sink) post(events []event) error {
+	buf := bytes.NewBuffer(nil)
+	var w io.Writer = buf
+	var zw *gzip.Writer
+	if s.Gzip {
+		zw = gzip.NewWriter(buf)
+		w = zw
+	}
+	// The collector expects concatenated events rather than an array.
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest("POST", s.url+"/services/collector", buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Splunk "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+	if zw != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("splunk: unexpected status %s: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package splunk
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Queue struct {
+		Depth metrics.Gauge   `metric:"depth"`
+		Sent  metrics.Counter `metric:"sent"`
+		Wait  metrics.Timer   `metric:"wait"`
+	} `metric:"queue"`
+}
+
+func TestSend(t *testing.T) {
+	var requests int
+	var events []event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/collector" || r.Header.Get("Authorization") != "Splunk token" {
+			t.Errorf("unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("invalid gzip body: %v", err)
+				return
+			}
+			body = zr
+		}
+		dec := json.NewDecoder(body)
+		for dec.More() {
+			var e event
+			if err := dec.Decode(&e); err != nil {
+				t.Errorf("invalid event: %v", err)
+			}
+			events = append(events, e)
+		}
+		requests++
+		w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer srv.Close()
+
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Queue.Depth.Update(3)
+	snapshot := mTags.Snapshot()
+	snapshot.Time = time.Unix(1490054400, 250e6)
+	sink := New(srv.URL, "token")
+	sink.Index, sink.BatchSize, sink.Gzip = "metrics", 2, true
+
+	if err := sink.Send(snapshot); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if n := len(snapshot.Names()); len(events) != n || requests != (n+1)/2 {
+		t.Fatalf("expected %d events in %d requests, got %d in %d", n, (n+1)/2, len(events), requests)
+	}
+	var found bool
+	for _, e := range events {
+		if e.Event != "metric" || e.Index != "metrics" || e.Time != 1490054400.25 {
+			t.Fatalf("unexpected event: %+v", e)
+		}
+		if v, ok := e.Fields["metric_name:queue.depth.value"]; ok {
+			found = v == 3.0
+		}
+	}
+	if !found {
+		t.Fatalf("queue.depth not sent: %+v", events)
+	}
+}
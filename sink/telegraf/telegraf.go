@@ -0,0 +1,156 @@
+// Package telegraf exports tagtrics snapshots in the InfluxDB line protocol
+// to a Telegraf socket_listener.
+package telegraf
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sendgrid/tagtrics"
+)
+
+const (
+	// DefaultTimeout is the timeout of the connection to Telegraf unless
+	// configured otherwise.
+	DefaultTimeout = 5 * time.Second
+	// DefaultMaxPacketSize is the maximum size of a datagram unless
+	// configured otherwise.
+	DefaultMaxPacketSize = 1400
+)
+
+// Sink sends a line per metric of every snapshot.  The metric name is the
+// measurement, its labels are tags and its statistics are fields, e.g.
+// "queue.wait count=3,max=12 1490054400000000000".
+type Sink struct {
+	// Tags are added to every line, e.g. {"host": "api1"}.
+	Tags map[string]string
+	// Timeout bounds connecting to Telegraf and sending a snapshot.  If not
+	// set, DefaultTimeout is used.
+	Timeout time.Duration
+	// MaxPacketSize is the maximum size of a datagram for the "udp" and
+	// "unixgram" networks.  Lines are never split so longer lines are sent
+	// in a datagram of their own.  If not set, DefaultMaxPacketSize is used.
+	MaxPacketSize int
+
+	network string
+	addr    string
+}
+
+// New returns a sink sending lines to the Telegraf socket_listener at addr
+// on network, which is one of "udp", "tcp", "unix" or "unixgram", e.g.
+// New("unixgram", "/var/run/telegraf.sock").
+func New(network, addr string) *Sink {
+	return &Sink{network: network, addr: addr}
+}
+
+// Send sends the snapshot over a new connection.
+func (s *Sink) Send(snapshot *tagtrics.Snapshot) error {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	conn, err := net.DialTimeout(s.network, s.addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if s.network != "udp" && s.network != "unixgram" {
+		return s.Write(conn, snapshot)
+	}
+	max := s.MaxPacketSize
+	if max == 0 {
+		max = DefaultMaxPacketSize
+	}
+	buf := bytes.NewBuffer(nil)
+	for _, line := range s.lines(snapshot) {
+		if buf.Len() > 0 && buf.Len()+len(line) > max {
+			if _, err := conn.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		_, err = conn.Write(buf.Bytes())
+	}
+	return err
+}
+
+// Write writes the snapshot to w in the line protocol.
+func (s *Sink) Write(w io.Writer, snapshot *tagtrics.Snapshot) error {
+	_, err := io.WriteString(w, strings.Join(s.lines(snapshot), ""))
+	return err
+}
+
+// lines returns the newline terminated lines of every metric of the
+// snapshot.
+func (s *Sink) lines(snapshot *tagtrics.Snapshot) []string {
+	ts := strconv.FormatInt(snapshot.Time.UnixNano(), 10)
+	var lines []string
+	for _, name := range snapshot.Names() {
+		stats := snapshot.Stats(name)
+		if len(stats) == 0 {
+			continue
+		}
+		line := escape(snapshot.Family(name), ", ") + tags(s.Tags, snapshot.Labels(name)) + " "
+		keys := make([]string, 0, len(stats))
+		for k := range stats {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i > 0 {
+				line += ","
+			}
+			line += escape(k, ",= ") + "=" + strconv.FormatFloat(stats[k], 'f', -1, 64)
+		}
+		lines = append(lines, line+" "+ts+"\n")
+	}
+	return lines
+}
+
+// tags returns the tags of a line, labels overriding the tags of the sink.
+func tags(common, labels map[string]string) string {
+	all := make(map[string]string, len(common)+len(labels))
+	for k, v := range common {
+		all[k] = v
+	}
+	for k, v := range labels {
+		all[k] = v
+	}
+	keys := make([]string, 0, len(all))
+	for k, v := range all {
+		// Empty tag values are not allowed.
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var t string
+	for _, k := range keys {
+		t += "," + escape(k, ",= ") + "=" + escape(all[k], ",= ")
+	}
+	return t
+}
+
+// escape escapes the characters in chars with a backslash.
+func escape(s, chars string) string {
+	if !strings.ContainsAny(s, chars) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package telegraf
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/sendgrid/tagtrics"
+)
+
+type testMetrics struct {
+	Build tagtrics.Info   `metric:"build info"`
+	Sent  metrics.Counter `metric:"sent"`
+}
+
+func snapshot() *tagtrics.Snapshot {
+	m := &testMetrics{}
+	mTags := tagtrics.NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Build.Set("version", "1.2,rc")
+	m.Sent.Inc(4)
+	s := mTags.Snapshot()
+	s.Time = time.Unix(1490054400, 0)
+	return s
+}
+
+func TestWrite(t *testing.T) {
+	sink := New("", "")
+	sink.Tags = map[string]string{"host": "api1"}
+	buf := bytes.NewBuffer(nil)
+	if err := sink.Write(buf, snapshot()); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	for _, line := range []string{
+		`build\ info,host=api1,version=1.2\,rc value=1 1490054400000000000`,
+		`sent,host=api1 count=4 1490054400000000000`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("%q not found in:\n%s", line, buf)
+		}
+	}
+}
+
+func TestSendUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+	sink := New("udp", conn.LocalAddr().String())
+	sink.MaxPacketSize = 200
+	s := snapshot()
+	if err := sink.Send(s); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	var lines int
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 64*1024)
+	for lines < len(sink.lines(s)) {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("received %d lines: %v", lines, err)
+		}
+		if n > sink.MaxPacketSize && strings.Count(string(buf[:n]), "\n") > 1 {
+			t.Fatalf("datagram of %d bytes exceeds the maximum", n)
+		}
+		lines += strings.Count(string(buf[:n]), "\n")
+	}
+}
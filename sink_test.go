@@ -0,0 +1,108 @@
+package tagtrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSink(t *testing.T) {
+	m := &metaMetrics{}
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(m, func() {}, time.Second, r, ".")
+	var sent []*Snapshot
+	mTags.AddSink(SinkFunc(func(s *Snapshot) error {
+		sent = append(sent, s)
+		return nil
+	}))
+	mTags.AddSink(SinkFunc(func(s *Snapshot) error {
+		return errors.New("backend down")
+	}))
+
+	m.Queue.Depth.Update(3)
+	mTags.flush()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 snapshot, got %d", len(sent))
+	}
+	if c := r.Get("tagtrics.sink.errors").(metrics.Counter).Count(); c != 1 {
+		t.Fatalf("expected 1 sink error, got %d", c)
+	}
+
+	var depth []Point
+	for _, p := range sent[0].Points() {
+		if p.Name == "queue.depth" {
+			depth = append(depth, p)
+		}
+	}
+	if len(depth) != 1 || depth[0].Stat != "value" || depth[0].Value != 3 {
+		t.Fatalf("unexpected points for queue.depth: %v", depth)
+	}
+	points := sent[0].Points()
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		if a.Name > b.Name || a.Name == b.Name && a.Stat >= b.Stat {
+			t.Fatalf("points are not sorted: %v before %v", a, b)
+		}
+	}
+}
+
+func TestFlushSnapshotConsistent(t *testing.T) {
+	m := &metaMetrics{}
+	var mTags *MetricTags
+	var handled *Snapshot
+	h := func() {
+		// Updates during the flush are left for the next one.
+		m.Queue.Depth.Update(5)
+		handled = mTags.Snapshot()
+	}
+	mTags = NewMetricTags(m, h, time.Second, metrics.NewRegistry(), ".")
+	var sent *Snapshot
+	mTags.AddSink(SinkFunc(func(s *Snapshot) error {
+		sent = s
+		return nil
+	}))
+
+	m.Queue.Depth.Update(3)
+	mTags.flush()
+	if handled != sent {
+		t.Fatalf("update handler and sinks got different snapshots")
+	}
+	if v := sent.Stats("queue.depth")["value"]; v != 3 {
+		t.Fatalf("expected the value at the start of the flush, got %v", v)
+	}
+	if v := mTags.Snapshot().Stats("queue.depth")["value"]; v != 5 {
+		t.Fatalf("expected a new snapshot after the flush, got %v", v)
+	}
+}
+
+func TestNamedSink(t *testing.T) {
+	m := &struct {
+		Sent     metrics.Counter `metric:"sent"`
+		Internal struct {
+			Retries metrics.Counter `metric:"retries"`
+		} `metric:"internal" sink:"debug"`
+	}{}
+	mTags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	var sent, debug *Snapshot
+	mTags.AddSink(SinkFunc(func(s *Snapshot) error {
+		sent = s
+		return nil
+	}))
+	mTags.AddNamedSink("debug", SinkFunc(func(s *Snapshot) error {
+		debug = s
+		return nil
+	}))
+	mTags.flush()
+
+	if _, ok := sent.Metrics["sent"]; !ok {
+		t.Fatalf("default sink missing sent")
+	}
+	if _, ok := sent.Metrics["internal.retries"]; ok {
+		t.Fatalf("default sink got a metric routed to debug")
+	}
+	if len(debug.Metrics) != 1 || debug.Meta["internal.retries"].Sink != "debug" {
+		t.Fatalf("debug sink got %v", debug.Names())
+	}
+}
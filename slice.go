@@ -0,0 +1,112 @@
+package tagtrics
+
+import (
+	"reflect"
+)
+
+// isStructSlice reports whether t is a slice of pointers to structs, e.g.
+// []*ConsumerMetrics.
+func isStructSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Ptr && t.Elem().Elem().Kind() == reflect.Struct
+}
+
+// initSlice initializes the non-nil elements of the slice field val of
+// branch b named with the tag options opts like those of an array.
+func (m *MetricTags) initSlice(val reflect.Value, b branch, opts tagOptions) error {
+	var skipped error
+	n := val.Len()
+	for i := 0; i < n; i++ {
+		v := val.Index(i)
+		if v.IsNil() {
+			continue
+		}
+		eb, err := b.child(m, opts.elementName(i, n), nil).enter(v.Elem())
+		if err == nil {
+			err = eb.checkLimits(m)
+		}
+		if err != nil {
+			return err
+		}
+		if err := m.initElement(v, m.elementBranch(eb, v)); err != nil {
+			if !isNameError(err) {
+				return err
+			}
+			if skipped == nil {
+				skipped = err
+			}
+		}
+	}
+	return skipped
+}
+
+// initElement initializes the metrics of the pointer v held by a map or a
+// slice for branch b.
+func (m *MetricTags) initElement(v reflect.Value, b branch) error {
+	if in, ok := v.Interface().(Initializer); ok {
+		if b.rescan {
+			return nil
+		}
+		return m.initCustom(in, b)
+	}
+	return m.initializeFieldTagPath(v.Elem(), b)
+}
+
+// elementBranch returns the branch of the map value or slice element v
+// beneath branch b and records v as initialized.  A rescan only looks for
+// new elements beneath the elements initialized before and initializes the
+// new ones.
+func (m *MetricTags) elementBranch(b branch, v reflect.Value) branch {
+	b.rescan = m.markElement(v.Interface()) && b.rescan
+	return b
+}
+
+// markElement records the map value or slice element v as initialized and
+// reports whether it was initialized before.
+func (m *MetricTags) markElement(v interface{}) bool {
+	if m.elements == nil {
+		m.elements = make(map[interface{}]bool)
+	}
+	known := m.elements[v]
+	m.elements[v] = true
+	return known
+}
+
+// Rescan initializes the metrics of the elements added to the slices and
+// maps of metricsData and of the structs added with Register since they
+// were traversed, e.g. the metrics of partitions appended to a
+// []*PartitionMetrics once they are assigned.  The elements initialized
+// before are left untouched and nil elements are skipped.  The slices and
+// maps must not be modified while Rescan runs.
+func (m *MetricTags) Rescan() error {
+	m.scanMutex.Lock()
+	defer m.scanMutex.Unlock()
+	if err := m.rescan("", m.metricsData); err != nil {
+		return err
+	}
+	for _, s := range m.structs {
+		if err := m.rescan(s.prefix, s.structPtr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rescan initializes the metrics of the elements added to the slices and
+// maps of the struct structPtr points to, whose names are prefixed with
+// prefix.
+func (m *MetricTags) rescan(prefix string, structPtr interface{}) error {
+	if _, ok := structPtr.(Initializer); ok {
+		return nil
+	}
+	v := reflect.ValueOf(structPtr).Elem()
+	root := rootBranch(prefix, m.separator)
+	root.rescan = true
+	m.metaMutex.RLock()
+	root.start = m.registered
+	m.metaMutex.RUnlock()
+	b, err := root.enter(v)
+	if err != nil {
+		return err
+	}
+	return m.initializeFieldTagPath(v, b)
+}
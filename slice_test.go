@@ -0,0 +1,43 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+type consumerMetrics struct {
+	Lag metrics.Gauge `metric:"lag"`
+}
+
+func TestSlice(t *testing.T) {
+	m := &struct {
+		Consumers []*consumerMetrics          `metric:"consumers,names=orders"`
+		Services  map[string]*consumerMetrics `metric:"services"`
+	}{
+		Consumers: []*consumerMetrics{{}, nil},
+		Services:  map[string]*consumerMetrics{"mysql": {}},
+	}
+	r := metrics.NewRegistry()
+	tags := NewMetricTags(m, func() {}, time.Second, r, ".")
+	if r.Get("consumers.orders.lag") == nil || r.Get("consumers.1.lag") != nil || r.Get("services.mysql.lag") == nil {
+		t.Fatalf("unexpected metrics registered")
+	}
+	lag := m.Consumers[0].Lag
+
+	m.Consumers[1] = &consumerMetrics{}
+	m.Consumers = append(m.Consumers, &consumerMetrics{})
+	m.Services["redis"] = &consumerMetrics{}
+	if err := tags.Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"consumers.1.lag", "consumers.2.lag", "services.redis.lag"} {
+		if r.Get(name) == nil {
+			t.Fatalf("%s not registered by Rescan", name)
+		}
+	}
+	if m.Consumers[0].Lag != lag || r.Get("consumers.orders.lag") != lag {
+		t.Fatalf("Rescan initialized an element again")
+	}
+}
@@ -0,0 +1,433 @@
+package tagtrics
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// Snapshot is a point-in-time copy of all the metrics in a registry.  Values
+// in a Snapshot never change so it can be handed to exporters safely.
+type Snapshot struct {
+	// Time is when the snapshot was taken.
+	Time time.Time
+	// Metrics holds the snapshot of every metric in the registry keyed by
+	// name.  Values are go-metrics snapshots such as metrics.Counter or
+	// metrics.Timer.
+	Metrics map[string]interface{}
+	// Meta holds the metadata of the metrics initialized from tagged structs
+	// keyed by name.
+	Meta map[string]MetricMeta
+	// Percentiles are the percentiles exported for histograms and timers
+	// without percentiles of their own in Meta.
+	Percentiles []float64
+	// DurationUnit is the unit of the durations exported for timers without
+	// a unit of their own in Meta.  Zero means nanoseconds.
+	DurationUnit time.Duration
+	// Exemplars holds the last exemplar recorded with RecordWithExemplar
+	// keyed by metric name.
+	Exemplars map[string]Exemplar
+	// BaseLabels are the labels of every metric, e.g. the pod reporting them
+	// as set with WithBaseLabels.
+	BaseLabels map[string]string
+	// Separator is the separator of the MetricTags the snapshot was taken
+	// of, splitting metric names into the nested elements of WriteXML.
+	Separator string
+	// FloatPrecision is the number of decimal places statistics are rounded
+	// to.  Zero keeps full precision.
+	FloatPrecision int
+}
+
+// Snapshot captures the current value of every metric in the registry along
+// with their metadata.  While a flush is in progress it returns the snapshot
+// captured at its start instead, so update handlers see the values the sinks
+// are sent.  It never resets windowed metrics so it can be called by any
+// number of readers, e.g. ToJSON or a dashboard polling Handler.
+func (m *MetricTags) Snapshot() *Snapshot {
+	if !m.pullOnly {
+		m.flushingMutex.RLock()
+		s := m.flushing
+		m.flushingMutex.RUnlock()
+		if s != nil {
+			return s
+		}
+	}
+	return m.snapshot(m.nowHandler())
+}
+
+// Scrape captures a snapshot for the scraper collecting the metrics, such
+// as Prometheus through Handler, OpenMetricsHandler or the promcollector
+// adapter.  In pull-only mode every scrape ends a window like a flush does:
+// derived metrics such as rates are computed and the runtime statistics are
+// sampled before capturing and windowed metrics are reset after, so only
+// one scraper should call it.  Otherwise flushes end the windows and it is
+// the same as Snapshot.
+func (m *MetricTags) Scrape() *Snapshot {
+	if !m.pullOnly {
+		return m.Snapshot()
+	}
+	m.pullMutex.Lock()
+	defer m.pullMutex.Unlock()
+	now := m.nowHandler()
+	if m.pullStats == nil {
+		m.pullStats = newRuntimeStats(m, now)
+	}
+	m.pullStats.capture(m, now)
+	m.beginWindow(now)
+	defer m.endWindow()
+	return m.snapshot(now)
+}
+
+// snapshot captures the current value of every metric at now, updating the
+// uptime first.
+func (m *MetricTags) snapshot(now time.Time) *Snapshot {
+	m.updateUptime()
+	s := &Snapshot{
+		Time:           now,
+		Metrics:        make(map[string]interface{}),
+		Meta:           make(map[string]MetricMeta),
+		Exemplars:      make(map[string]Exemplar),
+		Percentiles:    m.Percentiles,
+		DurationUnit:   m.durationUnit,
+		FloatPrecision: m.floatPrecision,
+		Separator:      m.separator,
+		BaseLabels:     m.baseLabels,
+	}
+	m.registry.Each(func(name string, i interface{}) {
+		s.Metrics[name] = snapshotMetric(i)
+		if e, ok := m.exemplar(i); ok {
+			s.Exemplars[name] = e
+		}
+	})
+	m.metaMutex.RLock()
+	for name, meta := range m.meta {
+		s.Meta[name] = meta
+	}
+	m.metaMutex.RUnlock()
+	return s
+}
+
+// snapshotMetric returns an immutable copy of the given go-metrics metric.
+// Types without a snapshot are returned as is.
+func snapshotMetric(i interface{}) interface{} {
+	switch metric := i.(type) {
+	case metrics.Counter:
+		return metric.Snapshot()
+	case metrics.Gauge:
+		return metric.Snapshot()
+	case metrics.GaugeFloat64:
+		return metric.Snapshot()
+	case metrics.Histogram:
+		return metric.Snapshot()
+	case metrics.Meter:
+		return metric.Snapshot()
+	case metrics.Timer:
+		return metric.Snapshot()
+	}
+	return i
+}
+
+// Stats returns the statistics exported for the named metric keyed by the
+// same names go-metrics uses in JSON, e.g. "count", "mean.rate" or "99.9%".
+// The durations of timers are in their duration unit and values are rounded
+// to FloatPrecision.  It returns nil for unknown metrics and metrics without
+// numeric values.
+func (s *Snapshot) Stats(name string) map[string]float64 {
+	stats := s.stats(name)
+	if s.FloatPrecision > 0 {
+		scale := math.Pow10(s.FloatPrecision)
+		for k, v := range stats {
+			stats[k] = math.Round(v*scale) / scale
+		}
+	}
+	return stats
+}
+
+// stats returns the statistics of the named metric at full precision.
+func (s *Snapshot) stats(name string) map[string]float64 {
+	switch metric := s.Metrics[name].(type) {
+	case metrics.Counter:
+		return map[string]float64{"count": float64(metric.Count())}
+	case metrics.Gauge:
+		return map[string]float64{"value": float64(metric.Value())}
+	case metrics.GaugeFloat64:
+		return map[string]float64{"value": metric.Value()}
+	case metrics.Histogram:
+		stats := map[string]float64{
+			"count":  float64(metric.Count()),
+			"min":    float64(metric.Min()),
+			"max":    float64(metric.Max()),
+			"mean":   metric.Mean(),
+			"stddev": metric.StdDev(),
+		}
+		s.addPercentiles(stats, name, metric.Percentiles)
+		return stats
+	case metrics.Meter:
+		return map[string]float64{
+			"count":     float64(metric.Count()),
+			"1m.rate":   metric.Rate1(),
+			"5m.rate":   metric.Rate5(),
+			"15m.rate":  metric.Rate15(),
+			"mean.rate": metric.RateMean(),
+		}
+	case metrics.Timer:
+		stats := map[string]float64{
+			"count":     float64(metric.Count()),
+			"min":       float64(metric.Min()),
+			"max":       float64(metric.Max()),
+			"mean":      metric.Mean(),
+			"stddev":    metric.StdDev(),
+			"1m.rate":   metric.Rate1(),
+			"5m.rate":   metric.Rate5(),
+			"15m.rate":  metric.Rate15(),
+			"mean.rate": metric.RateMean(),
+		}
+		s.addPercentiles(stats, name, metric.Percentiles)
+		s.scaleDurations(stats, name)
+		return stats
+	}
+	return nil
+}
+
+// percentiles returns the percentiles exported for the named metric.
+func (s *Snapshot) percentiles(name string) []float64 {
+	if ps := s.Meta[name].Percentiles; len(ps) > 0 {
+		return ps
+	}
+	if len(s.Percentiles) > 0 {
+		return s.Percentiles
+	}
+	return DefaultPercentiles
+}
+
+// addPercentiles computes the percentiles of the named metric with f and adds
+// them to stats.
+func (s *Snapshot) addPercentiles(stats map[string]float64, name string, f func([]float64) []float64) {
+	ps := s.percentiles(name)
+	for i, v := range f(ps) {
+		stats[percentileKey(ps[i])] = v
+	}
+}
+
+// percentileKey returns the name of the statistic for percentile p, which is
+// "median" for 0.5 and the percentage otherwise, e.g. "99.9%" for 0.999.
+func percentileKey(p float64) string {
+	if p == 0.5 {
+		return "median"
+	}
+	// Round to get rid of floating point noise such as 99.89999999999999.
+	pct := math.Round(p*1e8) / 1e6
+	return strconv.FormatFloat(pct, 'f', -1, 64) + "%"
+}
+
+// Labels returns the labels of the named metric, the base labels merged with
+// the labels of the map keys in its metadata and its own such as the labels
+// of an Info, in increasing precedence.  It returns nil if the metric has no
+// labels.
+func (s *Snapshot) Labels(name string) map[string]string {
+	layers := []map[string]string{s.BaseLabels, s.Meta[name].Labels}
+	if l, ok := s.Metrics[name].(interface {
+		Labels() map[string]string
+	}); ok {
+		layers = append(layers, l.Labels())
+	}
+	var labels map[string]string
+	merged := false
+	for _, layer := range layers {
+		switch {
+		case len(layer) == 0:
+		case labels == nil:
+			labels = layer
+		case !merged:
+			merged = true
+			c := make(map[string]string, len(labels)+len(layer))
+			for k, v := range labels {
+				c[k] = v
+			}
+			labels = c
+			fallthrough
+		default:
+			for k, v := range layer {
+				labels[k] = v
+			}
+		}
+	}
+	return labels
+}
+
+// Family returns the name of the named metric without the segments of map
+// keys exported as labels, which is name for most metrics.
+func (s *Snapshot) Family(name string) string {
+	if f := s.Meta[name].Family; f != "" {
+		return f
+	}
+	return name
+}
+
+// Point is a single statistic of a metric, the unit most backends ingest.
+type Point struct {
+	// Name is the name of the metric.
+	Name string
+	// Family is the name of the metric without the segments of map keys
+	// exported as Labels, the name to use along with them.
+	Family string
+	// Stat is the name of the statistic as returned by Stats, e.g. "count".
+	Stat string
+	// Value is the value of the statistic.
+	Value float64
+	// Labels are the labels of the metric, if any.
+	Labels map[string]string
+}
+
+// Names returns the sorted names of the metrics in the snapshot.
+func (s *Snapshot) Names() []string {
+	names := make([]string, 0, len(s.Metrics))
+	for name := range s.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Points flattens the snapshot into the statistics of every metric sorted by
+// metric name and statistic.  Metrics without numeric values are skipped.
+func (s *Snapshot) Points() []Point {
+	var points []Point
+	for _, name := range s.Names() {
+		stats := s.Stats(name)
+		keys := make([]string, 0, len(stats))
+		for k := range stats {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		family, labels := s.Family(name), s.Labels(name)
+		for _, k := range keys {
+			points = append(points, Point{Name: name, Family: family, Stat: k, Value: stats[k], Labels: labels})
+		}
+	}
+	return points
+}
+
+// WriteJSON writes the snapshot to w as a JSON object of metric names to
+// their statistics, in the same format as go-metrics.
+func (s *Snapshot) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s.jsonData())
+}
+
+// WriteJSONLine writes the snapshot to w as a single line of compact JSON
+// holding its time and its metrics in the format of WriteJSON, e.g.
+//
+//	{"time":"2017-03-21T00:00:00Z","metrics":{"sent":{"count":4}}}
+//
+// Appending a line per flush to a file keeps a history which is easy to
+// grep and to replay.
+func (s *Snapshot) WriteJSONLine(w io.Writer) error {
+	return json.NewEncoder(w).Encode(struct {
+		Time    time.Time              `json:"time"`
+		Metrics map[string]interface{} `json:"metrics"`
+	}{s.Time, s.jsonData()})
+}
+
+// jsonData returns the metrics of the snapshot as written by WriteJSON.
+func (s *Snapshot) jsonData() map[string]interface{} {
+	data := make(map[string]interface{}, len(s.Metrics))
+	for name, i := range s.Metrics {
+		if h, ok := i.(metrics.Healthcheck); ok {
+			var e interface{}
+			if err := h.Error(); err != nil {
+				e = err.Error()
+			}
+			data[name] = map[string]interface{}{"error": e}
+			continue
+		}
+		data[name] = s.Stats(name)
+	}
+	return data
+}
+
+// WriteJSONGzip writes the snapshot to w as WriteJSON does compressed with
+// gzip.  The JSON of large registries compresses well, often 20 times.
+func (s *Snapshot) WriteJSONGzip(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := s.WriteJSON(zw); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// ForSink returns the snapshot of the metrics routed to the sink with the
+// given name by the "sink" struct tag, or of the metrics without one for
+// the empty name.  It returns s itself if it has no metric to leave out.
+func (s *Snapshot) ForSink(name string) *Snapshot {
+	routed := false
+	for _, meta := range s.Meta {
+		if meta.Sink != name {
+			routed = true
+			break
+		}
+	}
+	if !routed && name == "" {
+		return s
+	}
+	c := *s
+	c.Metrics = make(map[string]interface{})
+	c.Meta = make(map[string]MetricMeta)
+	c.Exemplars = make(map[string]Exemplar)
+	for n, metric := range s.Metrics {
+		meta, ok := s.Meta[n]
+		if meta.Sink != name {
+			continue
+		}
+		c.Metrics[n] = metric
+		if ok {
+			c.Meta[n] = meta
+		}
+		if e, ok := s.Exemplars[n]; ok {
+			c.Exemplars[n] = e
+		}
+	}
+	return &c
+}
+
+// WithPrefix returns the snapshot of the metrics named prefix or beneath it,
+// e.g. "messages.smtp.latency" for the prefix "messages.smtp", or of the
+// family prefix.  Whole name segments are matched whatever the separator
+// they were joined with, e.g. "api.http_latency" for the prefix "api.http"
+// of a field with `metric:"http,separator=_"`.
+func (s *Snapshot) WithPrefix(prefix string) *Snapshot {
+	return s.filter(func(name string) bool {
+		meta, ok := s.Meta[name]
+		if !ok {
+			meta = MetricMeta{Name: name}
+		}
+		return meta.beneath(prefix, s.Separator)
+	})
+}
+
+// filter returns the snapshot of the metrics keep returns true for.
+func (s *Snapshot) filter(keep func(name string) bool) *Snapshot {
+	c := *s
+	c.Metrics = make(map[string]interface{})
+	c.Meta = make(map[string]MetricMeta)
+	c.Exemplars = make(map[string]Exemplar)
+	for n, metric := range s.Metrics {
+		if !keep(n) {
+			continue
+		}
+		c.Metrics[n] = metric
+		if meta, ok := s.Meta[n]; ok {
+			c.Meta[n] = meta
+		}
+		if e, ok := s.Exemplars[n]; ok {
+			c.Exemplars[n] = e
+		}
+	}
+	return &c
+}
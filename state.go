@@ -0,0 +1,123 @@
+package tagtrics
+
+import (
+	"sync/atomic"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// BoolGauge is a gauge holding 1 when set and 0 otherwise, for things like
+// feature toggles.
+type BoolGauge interface {
+	metrics.Gauge
+	// Set sets the gauge to 1 if v is true and 0 otherwise.
+	Set(v bool)
+	// IsSet reports whether the gauge is 1.
+	IsSet() bool
+}
+
+// NewBoolGauge constructs a new BoolGauge.
+func NewBoolGauge() BoolGauge {
+	return &boolGauge{}
+}
+
+// boolGauge is the standard implementation of a BoolGauge.
+type boolGauge struct {
+	value int64
+}
+
+// Set sets the gauge to 1 if v is true and 0 otherwise.
+func (g *boolGauge) Set(v bool) {
+	if v {
+		g.Update(1)
+	} else {
+		g.Update(0)
+	}
+}
+
+// IsSet reports whether the gauge is 1.
+func (g *boolGauge) IsSet() bool {
+	return g.Value() != 0
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *boolGauge) Snapshot() metrics.Gauge {
+	return metrics.GaugeSnapshot(g.Value())
+}
+
+// Update sets the gauge to 1 if v is non-zero and 0 otherwise.
+func (g *boolGauge) Update(v int64) {
+	if v != 0 {
+		v = 1
+	}
+	atomic.StoreInt64(&g.value, v)
+}
+
+// Value returns the gauge's current value.
+func (g *boolGauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// StateGauge is a gauge holding one of a small set of named states, such as
+// the state of a connection.  Its value is the index of the current state.
+type StateGauge interface {
+	metrics.Gauge
+	// SetState sets the current state.  Unknown states are ignored.
+	SetState(s string)
+	// State returns the current state.
+	State() string
+	// States returns all the states in order of their values.
+	States() []string
+}
+
+// NewStateGauge constructs a new StateGauge with the given states.  The
+// initial state is the first one.
+func NewStateGauge(states ...string) StateGauge {
+	return &stateGauge{states: states}
+}
+
+// stateGauge is the standard implementation of a StateGauge.
+type stateGauge struct {
+	value  int64
+	states []string
+}
+
+// SetState sets the current state.  Unknown states are ignored.
+func (g *stateGauge) SetState(s string) {
+	for i, state := range g.states {
+		if state == s {
+			g.Update(int64(i))
+			return
+		}
+	}
+}
+
+// State returns the current state.
+func (g *stateGauge) State() string {
+	if v := g.Value(); v < int64(len(g.states)) {
+		return g.states[v]
+	}
+	return ""
+}
+
+// States returns all the states in order of their values.
+func (g *stateGauge) States() []string {
+	return g.states
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *stateGauge) Snapshot() metrics.Gauge {
+	return metrics.GaugeSnapshot(g.Value())
+}
+
+// Update sets the state by index.  Indexes out of range are ignored.
+func (g *stateGauge) Update(v int64) {
+	if v >= 0 && v < int64(len(g.states)) {
+		atomic.StoreInt64(&g.value, v)
+	}
+}
+
+// Value returns the index of the current state.
+func (g *stateGauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
@@ -0,0 +1,44 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type stateMetrics struct {
+	Enabled    BoolGauge  `metric:"enabled"`
+	Connection StateGauge `metric:"connection,states=closed;connecting;open"`
+}
+
+func TestStateGauges(t *testing.T) {
+	m := &stateMetrics{}
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(m, func() {}, time.Second, r, ".")
+
+	m.Enabled.Set(true)
+	if !m.Enabled.IsSet() || r.Get("enabled").(metrics.Gauge).Value() != 1 {
+		t.Fatalf("bool gauge not set")
+	}
+	m.Enabled.Update(5)
+	if m.Enabled.Value() != 1 {
+		t.Fatalf("bool gauge is not 0 or 1: %d", m.Enabled.Value())
+	}
+
+	if m.Connection.State() != "closed" {
+		t.Fatalf("unexpected initial state %q", m.Connection.State())
+	}
+	m.Connection.SetState("open")
+	m.Connection.SetState("unknown")
+	if m.Connection.State() != "open" || m.Connection.Value() != 2 {
+		t.Fatalf("unexpected state %q (%d)", m.Connection.State(), m.Connection.Value())
+	}
+	meta, _ := mTags.Metadata("connection")
+	if len(meta.States) != 3 || meta.States[2] != "open" {
+		t.Fatalf("unexpected states in metadata: %v", meta.States)
+	}
+	if mTags.Snapshot().Stats("connection")["value"] != 2 {
+		t.Fatalf("unexpected snapshot value")
+	}
+}
@@ -0,0 +1,121 @@
+package tagtrics
+
+import (
+	"math"
+	"sync"
+)
+
+// histogramStats holds the statistics the custom histograms keep over every
+// recorded value, along with the mutex guarding them and the rest of the
+// histogram embedding them.
+type histogramStats struct {
+	mutex sync.Mutex
+	// frozen is set on snapshots which must not be updated.
+	frozen bool
+
+	totalCount int64
+	sum        int64
+	// sumSquares is kept as a float to avoid overflowing with nanoseconds.
+	sumSquares float64
+	min, max   int64
+}
+
+// clearStats forgets every recorded value.  The caller must hold the mutex.
+func (s *histogramStats) clearStats() {
+	s.totalCount, s.sum, s.sumSquares = 0, 0, 0
+	s.min, s.max = math.MaxInt64, math.MinInt64
+}
+
+// frozenStats returns a copy of the statistics for a snapshot.  The caller
+// must hold the mutex.
+func (s *histogramStats) frozenStats() histogramStats {
+	return histogramStats{
+		frozen:     true,
+		totalCount: s.totalCount,
+		sum:        s.sum,
+		sumSquares: s.sumSquares,
+		min:        s.min,
+		max:        s.max,
+	}
+}
+
+// checkWritable panics if the histogram is a snapshot, kind naming it in
+// the message.  The caller must hold the mutex.
+func (s *histogramStats) checkWritable(method, kind string) {
+	if s.frozen {
+		panic(method + " called on a " + kind + " snapshot")
+	}
+}
+
+// record adds v to the statistics.  The caller must hold the mutex.
+func (s *histogramStats) record(v int64) {
+	s.totalCount++
+	s.sum += v
+	s.sumSquares += float64(v) * float64(v)
+	if v < s.min {
+		s.min = v
+	}
+	if v > s.max {
+		s.max = v
+	}
+}
+
+// Count returns the number of recorded values.
+func (s *histogramStats) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.totalCount
+}
+
+// Max returns the largest recorded value.
+func (s *histogramStats) Max() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.totalCount == 0 {
+		return 0
+	}
+	return s.max
+}
+
+// Mean returns the mean of the recorded values.
+func (s *histogramStats) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.totalCount == 0 {
+		return 0
+	}
+	return float64(s.sum) / float64(s.totalCount)
+}
+
+// Min returns the smallest recorded value.
+func (s *histogramStats) Min() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.totalCount == 0 {
+		return 0
+	}
+	return s.min
+}
+
+// StdDev returns the standard deviation of the recorded values.
+func (s *histogramStats) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Sum returns the sum of the recorded values.
+func (s *histogramStats) Sum() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sum
+}
+
+// Variance returns the variance of the recorded values.
+func (s *histogramStats) Variance() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.totalCount == 0 {
+		return 0
+	}
+	mean := float64(s.sum) / float64(s.totalCount)
+	return s.sumSquares/float64(s.totalCount) - mean*mean
+}
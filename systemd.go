@@ -0,0 +1,135 @@
+package tagtrics
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// WithSystemdWatchdog ties the liveness of the metric pipeline to process
+// supervision when running as a systemd service with WatchdogSec set: every
+// successful flush notifies the watchdog with sd_notify WATCHDOG=1 so
+// systemd restarts a service whose flushes stall.  The flush interval must
+// be shorter than the watchdog timeout.  It also registers the
+// "tagtrics.systemd.uptime" gauge of the seconds since the MetricTags was
+// created and the "tagtrics.systemd.restarts" gauge of the times systemd
+// restarted the service.  Outside of systemd nothing is notified.
+func WithSystemdWatchdog() Option {
+	return func(m *MetricTags) {
+		m.systemd = &systemdWatchdog{}
+	}
+}
+
+// systemdWatchdog notifies the systemd watchdog after every successful
+// flush.
+type systemdWatchdog struct {
+	// socket is the address of the notification socket, empty if the
+	// watchdog is not enabled for the process.
+	socket string
+	uptime metrics.Gauge
+	start  time.Time
+}
+
+// initSystemd registers the metrics of WithSystemdWatchdog, if used, and
+// checks the flush interval against the watchdog timeout.
+func (m *MetricTags) initSystemd() {
+	w := m.systemd
+	if w == nil {
+		return
+	}
+	w.start = m.nowHandler()
+	if timeout, ok := watchdogTimeout(); ok {
+		w.socket = os.Getenv("NOTIFY_SOCKET")
+		if !m.pullOnly && m.interval() >= timeout {
+			m.warn("flush interval exceeds the systemd watchdog timeout", "interval", m.interval(), "timeout", timeout)
+		}
+	}
+	w.uptime = metrics.NewGauge()
+	m.register(newMeta(JoinName(m.selfPrefix, m.separator, "systemd.uptime"), "gauge", "Time since the service started", "seconds", nil), w.uptime)
+	restarts := metrics.NewGauge()
+	restarts.Update(systemdRestarts())
+	m.register(newMeta(JoinName(m.selfPrefix, m.separator, "systemd.restarts"), "gauge", "Times systemd restarted the service", "", nil), restarts)
+	m.derived = append(m.derived, w)
+}
+
+// update implements derived.
+func (w *systemdWatchdog) update(now time.Time) {
+	w.uptime.Update(int64(now.Sub(w.start) / time.Second))
+}
+
+// notify notifies the watchdog, if enabled, that the process is alive.
+func (w *systemdWatchdog) notify() error {
+	if w.socket == "" {
+		return nil
+	}
+	return sdNotify(w.socket, "WATCHDOG=1")
+}
+
+// watchdogTimeout returns the timeout of the systemd watchdog if it is
+// enabled for the process.
+func watchdogTimeout() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// sdNotify sends state to the systemd notification socket at addr, an
+// abstract socket if it starts with "@".
+func sdNotify(addr, state string) error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// systemdRestarts returns the number of times systemd restarted the service
+// unit of the process, or zero if it is not running as a systemd service.
+func systemdRestarts() int64 {
+	unit := systemdUnit()
+	if unit == "" {
+		return 0
+	}
+	out, err := exec.Command("systemctl", "show", "--property=NRestarts", "--value", unit).Output()
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	return n
+}
+
+// systemdUnit returns the name of the service unit of the process according
+// to its control group, if any.
+func systemdUnit() string {
+	if os.Getenv("INVOCATION_ID") == "" {
+		return ""
+	}
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		segments := strings.Split(s.Text(), "/")
+		for i := len(segments) - 1; i >= 0; i-- {
+			if strings.HasSuffix(segments[i], ".service") {
+				return segments[i]
+			}
+		}
+	}
+	return ""
+}
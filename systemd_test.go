@@ -0,0 +1,40 @@
+package tagtrics
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestSystemdWatchdog(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", addr)
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(&metaMetrics{}, func() {}, time.Second, r, ".", WithSystemdWatchdog())
+	mTags.nowHandler = func() time.Time { return mTags.systemd.start.Add(90 * time.Second) }
+	mTags.flush()
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "WATCHDOG=1" {
+		t.Fatalf("expected a watchdog notification, got %q, %v", buf[:n], err)
+	}
+	if v := r.Get("tagtrics.systemd.uptime").(metrics.Gauge).Value(); v != 90 {
+		t.Fatalf("expected an uptime of 90s, got %d", v)
+	}
+	if r.Get("tagtrics.systemd.restarts") == nil {
+		t.Fatalf("restarts not registered")
+	}
+}
@@ -0,0 +1,326 @@
+package tagtrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// tagOptions holds the comma separated options that follow the metric name in
+// a "metric" struct tag, e.g. `metric:"latency,percentiles=50;99"`.  Options
+// without a value are stored with an empty value.
+type tagOptions map[string]string
+
+// parseTag splits a "metric" struct tag into the metric name and its options.
+func parseTag(tag string) (string, tagOptions) {
+	parts := strings.Split(tag, ",")
+	opts := make(tagOptions, len(parts)-1)
+	for _, opt := range parts[1:] {
+		kv := strings.SplitN(opt, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if key == "" {
+			continue
+		}
+		if len(kv) == 2 {
+			opts[key] = strings.TrimSpace(kv[1])
+		} else {
+			opts[key] = ""
+		}
+	}
+	return strings.TrimSpace(parts[0]), opts
+}
+
+// Has reports whether the option name was given.
+func (o tagOptions) Has(name string) bool {
+	_, ok := o[name]
+	return ok
+}
+
+// list returns the semicolon separated values of the named option.
+func (o tagOptions) list(name string) []string {
+	v, ok := o[name]
+	if !ok || v == "" {
+		return nil
+	}
+	values := strings.Split(v, ";")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+// percentiles parses the "percentiles" option, a semicolon separated list of
+// percentages such as "50;90;99.9", into quantiles between 0 and 1.  It
+// returns nil if the option is not set.
+func (o tagOptions) percentiles() ([]float64, error) {
+	v, ok := o["percentiles"]
+	if !ok {
+		return nil, nil
+	}
+	var ps []float64
+	for _, s := range strings.Split(v, ";") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || p <= 0 || p >= 100 {
+			return nil, fmt.Errorf("invalid percentile %q", s)
+		}
+		ps = append(ps, p/100)
+	}
+	return ps, nil
+}
+
+// int64 parses the named option as an integer.  It returns def if the option
+// is not set.
+func (o tagOptions) int64(name string, def int64) (int64, error) {
+	v, ok := o[name]
+	if !ok {
+		return def, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return i, nil
+}
+
+// interval parses the "interval" option as a positive duration.  It returns
+// 0 if the option is not set or invalid.
+func (o tagOptions) interval() time.Duration {
+	d, err := time.ParseDuration(o["interval"])
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// histogram creates the histogram selected by the "sample" option.  It returns
+// nil if the option is not set so the caller can use its default.
+func (o tagOptions) histogram() (metrics.Histogram, error) {
+	sample, ok := o["sample"]
+	if !ok {
+		return nil, nil
+	}
+	switch sample {
+	case "hdr":
+		min, err := o.int64("min", DefaultHDRMin)
+		if err != nil {
+			return nil, err
+		}
+		max, err := o.int64("max", DefaultHDRMax)
+		if err != nil {
+			return nil, err
+		}
+		sigFigs, err := o.int64("sigfigs", DefaultHDRSigFigs)
+		if err != nil {
+			return nil, err
+		}
+		return newHDRHistogram(min, max, int(sigFigs))
+	case "buckets":
+		bounds, err := o.bucketBounds()
+		if err != nil {
+			return nil, err
+		}
+		return newBucketHistogram(bounds)
+	case "ckms":
+		qs, err := o.percentiles()
+		if err != nil {
+			return nil, err
+		}
+		if qs == nil {
+			qs = DefaultPercentiles
+		}
+		epsilon := DefaultCKMSEpsilon
+		if v, ok := o["epsilon"]; ok {
+			if epsilon, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("invalid epsilon %q", v)
+			}
+		}
+		window := DefaultCKMSWindow
+		if v, ok := o["window"]; ok {
+			if window, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("invalid window %q", v)
+			}
+		}
+		return newCKMSHistogram(qs, epsilon, window)
+	case "tdigest":
+		compression := float64(DefaultTDigestCompression)
+		if v, ok := o["compression"]; ok {
+			var err error
+			if compression, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("invalid compression %q", v)
+			}
+		}
+		return newTDigestHistogram(compression)
+	}
+	return nil, fmt.Errorf("unknown sample %q", sample)
+}
+
+// metricKinds maps the field types initialized by NewMetricTags, formatted
+// like reflect.Type.String(), to the kind of metric in their metadata.
+var metricKinds = map[string]string{
+	"metrics.Counter":             "counter",
+	"metrics.Gauge":               "gauge",
+	"metrics.Histogram":           "histogram",
+	"metrics.Meter":               "meter",
+	"metrics.Timer":               "timer",
+	"tagtrics.BoolGauge":          "gauge",
+	"tagtrics.CardinalityCounter": "gauge",
+	"tagtrics.Info":               "info",
+	"tagtrics.MinMaxGauge":        "gauge",
+	"tagtrics.Outcome":            "counter",
+	"tagtrics.StateGauge":         "gauge",
+}
+
+// genericKinds maps the generic field types to the kind of metric in their
+// metadata.
+var genericKinds = map[string]string{
+	"tagtrics.Gauge":          "gauge",
+	"tagtrics.LabeledCounter": "counter",
+	"tagtrics.LabeledTimer":   "timer",
+	// A LazyMap holds the metric structs of its keys.
+	"tagtrics.LazyMap": "map",
+}
+
+// funcKinds maps the field types exported as functional gauges reading the
+// field to the kind of metric in their metadata.
+var funcKinds = map[string]string{
+	"func() float64": "gauge",
+	"func() int64":   "gauge",
+	"time.Duration":  "gauge",
+	"time.Time":      "gauge",
+}
+
+// ValidateField checks that a struct field of the given type with the given
+// "metric" tag can be initialized by NewMetricTags.  typeName is formatted
+// like reflect.Type.String(), e.g. "metrics.Timer" or
+// "tagtrics.Gauge[int64]".  It is meant for tools checking metric structs
+// before they are used.
+func ValidateField(typeName, tag string) error {
+	kind := metricKinds[typeName]
+	if i := strings.Index(typeName, "["); i > 0 {
+		kind = genericKinds[typeName[:i]]
+	}
+	if k, ok := funcKinds[typeName]; ok {
+		kind = k
+	}
+	if kind == "" {
+		return fmt.Errorf("unsupported metric type %s", typeName)
+	}
+	_, opts := parseTag(tag)
+	return opts.validate(typeName)
+}
+
+// validateLabel checks the "label" option of a field, isMap reporting
+// whether the field is a map or a LazyMap whose keys it exports.
+func (o tagOptions) validateLabel(isMap bool) error {
+	if !isMap {
+		return fmt.Errorf("option label is only supported by maps")
+	}
+	if o["label"] == "" {
+		return fmt.Errorf("option label needs a label name")
+	}
+	return nil
+}
+
+// validate checks that every option is known and valid for a field of the
+// given type.
+func (o tagOptions) validate(typeName string) error {
+	isHistogram := typeName == "metrics.Histogram" || typeName == "metrics.Timer"
+	// The timers of a LabeledTimer get the percentiles and duration unit of
+	// the field.
+	isTimer := typeName == "metrics.Timer" || strings.HasPrefix(typeName, "tagtrics.LabeledTimer[")
+	isDuration := isTimer || typeName == "time.Duration"
+	for name, v := range o {
+		var err error
+		switch name {
+		case "percentiles":
+			if !isHistogram && !isTimer {
+				return fmt.Errorf("option %s is not supported by %s", name, typeName)
+			}
+			_, err = o.percentiles()
+		case "sample":
+			if !isHistogram {
+				return fmt.Errorf("option %s is not supported by %s", name, typeName)
+			}
+			_, err = o.histogram()
+		case "duration":
+			if !isDuration {
+				return fmt.Errorf("option %s is not supported by %s", name, typeName)
+			}
+			if _, ok := durationUnits[v]; !ok {
+				err = fmt.Errorf("invalid duration unit %q", v)
+			}
+		case "min", "max", "sigfigs":
+			if o["sample"] != "hdr" {
+				return fmt.Errorf("option %s requires sample=hdr", name)
+			}
+		case "buckets", "start", "factor", "count":
+			if o["sample"] != "buckets" {
+				return fmt.Errorf("option %s requires sample=buckets", name)
+			}
+		case "epsilon", "window":
+			if o["sample"] != "ckms" {
+				return fmt.Errorf("option %s requires sample=ckms", name)
+			}
+		case "compression":
+			if o["sample"] != "tdigest" {
+				return fmt.Errorf("option %s requires sample=tdigest", name)
+			}
+		case "states":
+			if typeName != "tagtrics.StateGauge" {
+				return fmt.Errorf("option %s is not supported by %s", name, typeName)
+			}
+			if len(o.list(name)) == 0 {
+				err = fmt.Errorf("option %s needs at least one state", name)
+			}
+		case "precision":
+			if typeName != "tagtrics.CardinalityCounter" {
+				return fmt.Errorf("option %s is not supported by %s", name, typeName)
+			}
+			var precision int64
+			if precision, err = o.int64(name, 0); err == nil {
+				_, err = NewCardinalityCounter(int(precision))
+			}
+		case "rate":
+			if typeName != "metrics.Counter" {
+				return fmt.Errorf("option %s is not supported by %s", name, typeName)
+			}
+		case "reset":
+			if typeName != "metrics.Counter" && !isHistogram {
+				return fmt.Errorf("option %s is not supported by %s", name, typeName)
+			}
+		case "separator":
+			if v == "" {
+				err = fmt.Errorf("option %s needs a separator", name)
+			} else if strings.ContainsAny(v, separatorChars) {
+				err = fmt.Errorf("invalid %s %q", name, v)
+			}
+		case "optional":
+			if v == "" {
+				err = fmt.Errorf("option %s needs a feature flag name", name)
+			}
+		case "index":
+			if v != "decimal" && v != "hex" {
+				err = fmt.Errorf("invalid %s %q, must be decimal or hex", name, v)
+			}
+		case "names":
+			if len(o.list(name)) == 0 {
+				err = fmt.Errorf("option %s needs at least one name", name)
+			}
+		case "interval":
+			if o.interval() == 0 {
+				err = fmt.Errorf("invalid %s %q, must be a positive duration", name, v)
+			}
+		case "label":
+			err = o.validateLabel(strings.HasPrefix(typeName, "tagtrics.LazyMap["))
+		default:
+			err = fmt.Errorf("unknown option %s", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package tagtrics
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		name string
+		opts tagOptions
+	}{
+		{"", "", tagOptions{}},
+		{"latency", "latency", tagOptions{}},
+		{"latency,percentiles=50;99", "latency", tagOptions{"percentiles": "50;99"}},
+		{",reset, rate", "", tagOptions{"reset": "", "rate": ""}},
+	}
+	for _, test := range tests {
+		name, opts := parseTag(test.tag)
+		if name != test.name || !reflect.DeepEqual(opts, test.opts) {
+			t.Errorf("parseTag(%q) = %q, %v; want %q, %v", test.tag, name, opts, test.name, test.opts)
+		}
+	}
+}
+
+type percentileMetrics struct {
+	Latency metrics.Timer     `metric:"latency,percentiles=50;90;99.99"`
+	Size    metrics.Histogram `metric:"size"`
+	Bad     metrics.Timer     `metric:"bad,percentiles=fifty"`
+}
+
+func TestPercentilesTag(t *testing.T) {
+	m := &percentileMetrics{}
+	mTags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	mTags.Percentiles = []float64{0.25}
+	m.Latency.Update(time.Millisecond)
+	m.Size.Update(1)
+	m.Bad.Update(time.Millisecond)
+
+	var j map[string]map[string]float64
+	if err := json.Unmarshal(mTags.ToJSON(), &j); err != nil {
+		t.Fatalf("failed to convert metrics data to JSON: %v", err)
+	}
+	for _, k := range []string{"median", "90%", "99.99%"} {
+		if j["latency"][k] != 1000000 {
+			t.Fatalf("missing %s in %v", k, j["latency"])
+		}
+	}
+	if _, ok := j["latency"]["99%"]; ok {
+		t.Fatalf("default percentile exported for latency: %v", j["latency"])
+	}
+	if _, ok := j["size"]["25%"]; !ok {
+		t.Fatalf("instance percentiles not used for size: %v", j["size"])
+	}
+	if _, ok := j["bad"]["25%"]; !ok {
+		t.Fatalf("instance percentiles not used for bad: %v", j["bad"])
+	}
+}
+
+func TestValidateField(t *testing.T) {
+	tests := []struct {
+		typeName, tag string
+		valid         bool
+	}{
+		{"metrics.Timer", "latency,percentiles=50;99,sample=hdr,sigfigs=2", true},
+		{"metrics.Counter", "sent,rate", true},
+		{"tagtrics.Gauge[int64]", "depth", true},
+		{"tagtrics.StateGauge", "state,states=on;off", true},
+		{"metrics.Histogram", "size,reset", true},
+		{"metrics.Counter", "sent,separator=_", true},
+		{"metrics.Counter", "sent,separator", false},
+		{"metrics.Counter", "sent,separator=;", false},
+		{"metrics.Counter", "sent,interval=10s", true},
+		{"metrics.Counter", "sent,interval=soon", false},
+		{"int", "config", false},
+		{"metrics.Meter", "requests,reset", false},
+		{"metrics.Counter", "sent,reset,rate", true},
+		{"metrics.Counter", "sent,percentiles=99", false},
+		{"metrics.Timer", "latency,percentiles=200", false},
+		{"metrics.Timer", "latency,sigfigs=2", false},
+		{"metrics.Timer", "latency,sample=buckets,buckets=1ms;1s", true},
+		{"metrics.Histogram", "size,sample=buckets,start=1,factor=2,count=10", true},
+		{"metrics.Histogram", "size,sample=buckets,buckets=10;1", false},
+		{"metrics.Histogram", "size,buckets=1;10", false},
+		{"metrics.Timer", "latency,sample=magic", false},
+		{"tagtrics.CardinalityCounter", "ips,precision=30", false},
+		{"metrics.Meter", "requests,optional", false},
+		{"metrics.Meter", "requests,bogus", false},
+	}
+	for _, test := range tests {
+		err := ValidateField(test.typeName, test.tag)
+		if (err == nil) != test.valid {
+			t.Errorf("ValidateField(%q, %q) = %v", test.typeName, test.tag, err)
+		}
+	}
+	for typeName := range metricKinds {
+		if newMetric(typeName, tagOptions{}, DefaultSampleSize) == nil {
+			t.Errorf("%s is not initialized by newMetric", typeName)
+		}
+	}
+}
+
+type separatorMetrics struct {
+	HTTP struct {
+		Latency metrics.Timer   `metric:"latency"`
+		Sent    metrics.Counter `metric:"sent,rate"`
+		Codes   map[string]*subMetrics
+	} `metric:"http,separator=_"`
+	Peak MinMaxGauge            `metric:"peak,separator=:"`
+	Hits LabeledCounter[string] `metric:"hits,separator=/"`
+	Map  map[string]*subMetrics `metric:"map,separator=-"`
+}
+
+func TestSeparatorOption(t *testing.T) {
+	m := &separatorMetrics{}
+	m.HTTP.Codes = map[string]*subMetrics{"200": {}}
+	m.Map = map[string]*subMetrics{"a": {}}
+	r := metrics.NewRegistry()
+	NewMetricTags(m, func() {}, time.Second, r, ".")
+	m.Hits.Inc("x", 1)
+	for _, name := range []string{"http_latency", "http_sent", "http_sent_rate", "http_codes_200_counter",
+		"peak:min", "peak:max", "hits/x", "map-a-counter"} {
+		if r.Get(name) == nil {
+			t.Errorf("%s is not registered", name)
+		}
+	}
+}
+
+func TestFuncGauge(t *testing.T) {
+	m := &struct {
+		Size    func() int64   `metric:"size"`
+		Lag     func() float64 `metric:"lag" unit:"seconds"`
+		Later   func() int64   `metric:"later"`
+		Handler func()         `metric:"handler"`
+	}{
+		Size: func() int64 { return 3 },
+		Lag:  func() float64 { return 1.5 },
+	}
+	r := metrics.NewRegistry()
+	tags := NewMetricTags(m, func() {}, time.Second, r, ".")
+	m.Later = func() int64 { return 7 }
+	s := tags.Snapshot()
+	if s.Stats("size")["value"] != 3 || s.Stats("lag")["value"] != 1.5 || s.Stats("later")["value"] != 7 {
+		t.Fatalf("unexpected func gauge values %v %v %v", s.Stats("size"), s.Stats("lag"), s.Stats("later"))
+	}
+	if meta, _ := tags.Metadata("lag"); meta.Type != "gauge" || meta.Unit != "seconds" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if r.Get("handler") != nil {
+		t.Fatalf("func() field registered")
+	}
+	if err := ValidateField("func() float64", "lag"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+type methodMetrics struct {
+	Requests metrics.Counter `metric:"requests"`
+	Queue    []int
+}
+
+func (m *methodMetrics) MetricQueueDepth() int64  { return int64(len(m.Queue)) }
+func (m *methodMetrics) MetricLoad() float64      { return 0.5 }
+func (m *methodMetrics) MetricLabel() string      { return "ignored" }
+func (m *methodMetrics) MetricScaled(int64) int64 { return 0 }
+func (m *methodMetrics) Metric() int64            { return 0 }
+
+func TestMethodGauges(t *testing.T) {
+	m := &methodMetrics{Queue: []int{1, 2}}
+	r := metrics.NewRegistry()
+	tags := NewMetricTags(m, func() {}, time.Second, r, ".", WithMethodGauges())
+	m.Queue = append(m.Queue, 3)
+	s := tags.Snapshot()
+	if s.Stats("queuedepth")["value"] != 3 || s.Stats("load")["value"] != 0.5 {
+		t.Fatalf("unexpected method gauges %v %v", s.Stats("queuedepth"), s.Stats("load"))
+	}
+	for _, name := range []string{"label", "scaled", ""} {
+		if r.Get(name) != nil {
+			t.Errorf("method registered as %q", name)
+		}
+	}
+	if r.Get("requests") == nil {
+		t.Fatalf("fields not registered")
+	}
+}
+
+func TestTimeGauge(t *testing.T) {
+	m := &struct {
+		LastSync time.Time `metric:"last_sync"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	if v := tags.Snapshot().Stats("last_sync")["value"]; v != 0 {
+		t.Fatalf("expected 0 before the first sync, got %v", v)
+	}
+	m.LastSync = time.Unix(1700000000, 5e8)
+	if v := tags.Snapshot().Stats("last_sync")["value"]; v != 1700000000 {
+		t.Fatalf("expected the unix time, got %v", v)
+	}
+}
@@ -2,8 +2,13 @@ package tagtrics
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	metrics "github.com/rcrowley/go-metrics"
@@ -17,12 +22,33 @@ const (
 	// expensive as stopping the world, but it isn't cheap so don't do it too
 	// often either.
 	DefaultStatsGCCollection = time.Duration(1 * time.Minute)
+	// DefaultSampleSize is the number of values sampled by histograms and
+	// timers unless set with WithDefaultSampleSize, the size used by
+	// go-metrics.
+	DefaultSampleSize = 1028
+	// DefaultMaxDepth and DefaultMaxMetrics are the limits of the nesting
+	// depth of metricsData and of the number of metrics it registers unless
+	// set with WithTraversalLimits.
+	DefaultMaxDepth   = 32
+	DefaultMaxMetrics = 100000
+	// DefaultSeparator joins the segments of metric names when NewMetricTags
+	// is passed an empty separator.
+	DefaultSeparator = "."
 )
 
+// DefaultPercentiles are the percentiles exported for histograms and timers
+// unless configured otherwise.
+var DefaultPercentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
 // MetricsUpdateHandler is the handler that will be called every
 // MetricTags.flushInterval to update the stats remotely.
 type MetricsUpdateHandler func()
 
+// FlushFunc is an update handler set with WithFlushFunc.  It is passed the
+// snapshot of the flush and a context whose deadline is the next flush, so
+// it can bound its network calls, and reports failures with its error.
+type FlushFunc func(ctx context.Context, s *Snapshot) error
+
 // MetricTags traverses a given struct to initialize its metrics data types
 // for a given namespace so they can be ready to use in the application and
 // constantly update a configured source.
@@ -38,8 +64,12 @@ type MetricTags struct {
 	// updateHandler is the handler that is called to constantly update stats
 	// with a remote system.
 	updateHandler MetricsUpdateHandler
-	// flushInterval holds how often updateHandler is called.
+	// flushInterval holds how often updateHandler is called.  It is read
+	// and written atomically since SetFlushInterval changes it while Run
+	// is running.
 	flushInterval time.Duration
+	// intervalCh wakes Run up when the flush interval changes.
+	intervalCh chan struct{}
 	// registry is the metrics registry used to initialize all metrics in
 	// metricsData as well as the Go runtime metrics.
 	registry metrics.Registry
@@ -49,20 +79,185 @@ type MetricTags struct {
 	// StatsGCCollection is how often a sample of the Go runtime GC
 	// statistics is collected.  If not set, DefaultStatsGCCollection is used.
 	StatsGCCollection time.Duration
+	// Percentiles are the percentiles exported for histograms and timers
+	// that do not set their own with the "percentiles" tag option.  If not
+	// set, DefaultPercentiles is used.
+	Percentiles []float64
+	// ShutdownTimeout is how long RunUntilSignal waits for the final flush.
+	// If not set, DefaultShutdownTimeout is used.
+	ShutdownTimeout time.Duration
 	// Separator is the separator used in between metric field names while
 	// traversing metricsData.  The resulting name is the name assigned to that
 	// field.
 	separator string
+	// meta holds the metadata of every metric initialized from metricsData
+	// keyed by metric name.  It is guarded by metaMutex since some metrics
+	// are registered on first use.
+	meta      map[string]MetricMeta
+	metaMutex sync.RWMutex
+	// timerNames holds the names of the timers in meta for TimedSection.
+	timerNames map[metrics.Timer]string
+	// self holds the metrics about tagtrics itself.
+	self selfMetrics
+	// windowed holds the metrics which are reset after every flush.
+	windowed []windowed
+	// derived holds the metrics which are recomputed before every flush.
+	derived []derived
+	// flagResolver reports whether the feature flags of optional metrics are
+	// enabled.
+	flagResolver FlagResolver
+	// resetOnFlush makes every counter, histogram and timer count per
+	// flush interval.
+	resetOnFlush bool
+	// pullOnly disables the Run worker so metrics are only collected by
+	// Snapshot and Scrape.  pullMutex serializes the scrapes and pullStats
+	// samples the runtime statistics.
+	pullOnly  bool
+	pullMutex sync.Mutex
+	pullStats *runtimeStats
+	// sampleSize is the size of the samples of histograms and timers
+	// without a "sample" tag option.
+	sampleSize int
+	// durationUnit is the unit of the durations exported for timers.
+	durationUnit time.Duration
+	// floatPrecision is the number of decimal places of exported
+	// statistics, zero for full precision.
+	floatPrecision int
+	// methodGauges registers the methods of the metrics struct matching
+	// MethodPrefix as gauges.
+	methodGauges bool
+	// nameCase is how the names of fields without a name in their tag are
+	// converted to metric names.
+	nameCase NameCase
+	// sinks are sent a snapshot of the metrics routed to them on every
+	// flush.
+	sinks []routedSink
+	// flushing is the snapshot of the flush in progress, if any, returned
+	// by Snapshot so the update handler and the sinks see the same values.
+	flushing      *Snapshot
+	flushingMutex sync.RWMutex
+	// exemplars holds the last exemplar recorded with RecordWithExemplar
+	// keyed by metric.
+	exemplars     map[interface{}]Exemplar
+	exemplarMutex sync.RWMutex
+	// flushes counts the successful flushes if enabled with
+	// WithFlushCounter.
+	flushes metrics.Counter
+	// events are the annotations to deliver on the next flush.
+	events     []Event
+	eventMutex sync.Mutex
+	// err is the error which stopped the initialization of metricsData.
+	err error
+	// maxDepth and maxMetrics are the limits of the traversal of structs,
+	// zero for no limit, and registered counts the metrics registered so
+	// far.
+	maxDepth, maxMetrics int
+	registered           int
+	// elements records the map values and slice elements initialized so
+	// far so Rescan only initializes the new ones.  It is guarded by
+	// scanMutex along with the traversals.
+	elements  map[interface{}]bool
+	scanMutex sync.Mutex
+	// baseLabels are the labels of every exported metric.
+	baseLabels map[string]string
+	// registrars register the metrics endpoint with service discovery
+	// while Run runs.
+	registrars []Registrar
+	// scopes holds the scopes returned by Scope keyed by tenant, capped
+	// to maxTenants scopes of maxTenantMetrics metrics each.
+	scopes                       map[string]*Scope
+	scopeMutex                   sync.Mutex
+	maxTenants, maxTenantMetrics int
+	// systemd notifies the systemd watchdog if enabled with
+	// WithSystemdWatchdog.
+	systemd *systemdWatchdog
+	// logger receives the recoverable conditions tagtrics runs into, the
+	// standard logger if nil.
+	logger Logger
+	// delivery configures the delivery of the snapshots to the sinks in
+	// the background, synchronous if nil.
+	delivery *Delivery
+	// existingMetrics binds fields to the metrics already registered
+	// under their names.
+	existingMetrics bool
+	// flushFunc is called with the snapshot of every flush after
+	// updateHandler, if set.
+	flushFunc FlushFunc
+	// sendMutex serializes sending snapshots to the sinks.
+	sendMutex sync.Mutex
+	// chunkSize is the maximum number of metrics sent to the sinks at once
+	// over pacingWindow if set with WithFlushPacing.
+	chunkSize    int
+	pacingWindow time.Duration
+	// intervalDue holds when the metrics of every interval set by the
+	// "interval" tag option are next sent to the sinks.  It is guarded by
+	// metaMutex.
+	intervalDue map[time.Duration]time.Time
+	// pausedAt is when the flushes were paused by Pause, zero if they
+	// aren't.
+	pausedAt   time.Time
+	pauseMutex sync.Mutex
+	// jitter is the fraction of the flush interval the wait between
+	// flushes is randomized by, as set by WithFlushJitter.
+	jitter float64
+	// aligned aligns the flushes on multiples of the flush interval as set
+	// by WithAlignedFlushes.
+	aligned bool
+	// hooks holds the flush hooks added with AddFlushHook in order.
+	hooks []flushHook
+	// errorHandler is called with the failures of the flushes and the
+	// sinks if set with WithErrorHandler.
+	errorHandler ErrorHandler
+	// flushMutex serializes the scheduled and manual flushes.
+	flushMutex sync.Mutex
+	// structs holds the structs added with Register in order, guarded by
+	// scanMutex.
+	structs []registeredStruct
+	// noRuntimeStats disables the Go runtime statistics as set with
+	// WithoutRuntimeStats.
+	noRuntimeStats bool
+	// counterWraps counts the counters which decreased between flushes if
+	// enabled with WithCounterWrapDetection.
+	counterWraps metrics.Counter
+	// lastCounts holds the counts of the counters at the previous flush,
+	// guarded by flushMutex.
+	lastCounts map[string]int64
+	// recoverPanics turns the panics of flushes into flush errors as set
+	// with WithPanicRecovery.
+	recoverPanics bool
+	// selfPrefix is the prefix of the self metrics, see selfNamespace.
+	selfPrefix string
+}
+
+// multiMetric is implemented by field types which are exported as several
+// metrics, such as a MinMaxGauge.
+type multiMetric interface {
+	// exportedMetrics returns the metrics to register keyed by the suffix
+	// appended to the field's metric name.
+	exportedMetrics() map[string]interface{}
+}
+
+// windowed is implemented by metrics which only cover the values recorded
+// since the last flush.
+type windowed interface {
+	// resetWindow is called after every flush to start a new window.
+	resetWindow()
 }
 
 // NewMetricTags creates a new MetricTags.  metricsData is the struct containing
 // "metric" tags and fields to be initialized in the registry namespace
-// separated by separator.  updateHandler is the handler what is called every
-// flushInterval to constantly update metrics.  metricsData gets initialized
-// before return.
-func NewMetricTags(metricsData interface{}, updateHandler MetricsUpdateHandler, flushInterval time.Duration, registry metrics.Registry, separator string) *MetricTags {
+// separated by separator, DefaultSeparator if empty.  updateHandler is the
+// handler what is called every flushInterval to constantly update metrics,
+// if not nil.  A zero flushInterval selects the pull-only mode of
+// WithPullOnly.  metricsData gets initialized before return after applying
+// options.  A separator containing the characters of struct tags is replaced
+// with DefaultSeparator and reported by Err.
+//
+// The metrics are registered in registry.
+func NewMetricTags(metricsData interface{}, updateHandler MetricsUpdateHandler, flushInterval time.Duration, registry metrics.Registry, separator string, options ...Option) *MetricTags {
 	m := &MetricTags{
 		quitCh:             make(chan struct{}),
+		intervalCh:         make(chan struct{}, 1),
 		nowHandler:         time.Now,
 		metricsData:        metricsData,
 		updateHandler:      updateHandler,
@@ -70,58 +265,316 @@ func NewMetricTags(metricsData interface{}, updateHandler MetricsUpdateHandler,
 		registry:           registry,
 		StatsMemCollection: DefaultStatsMemCollection,
 		StatsGCCollection:  DefaultStatsGCCollection,
+		Percentiles:        DefaultPercentiles,
 		separator:          separator,
+		sampleSize:         DefaultSampleSize,
+		maxDepth:           DefaultMaxDepth,
+		maxMetrics:         DefaultMaxMetrics,
+		maxTenants:         DefaultMaxTenants,
+		maxTenantMetrics:   DefaultMaxTenantMetrics,
+		meta:               make(map[string]MetricMeta),
+	}
+	for _, option := range options {
+		option(m)
+	}
+	if flushInterval == 0 {
+		m.pullOnly = true
+	}
+	if m.separator == "" {
+		m.separator = DefaultSeparator
+	}
+	sepErr := checkSeparator(m.separator)
+	if sepErr != nil {
+		m.separator = DefaultSeparator
 	}
 	// Initialize metric fields
-	m.initializeFieldTagPath(reflect.ValueOf(m.metricsData).Elem(), "")
+	m.err = m.initStruct("", m.metricsData)
+	if m.err == nil {
+		m.err = sepErr
+	}
+	if m.err != nil {
+		m.warn("failed to initialize metrics", "err", m.err)
+	}
+	m.selfPrefix = m.selfNamespace()
+	m.initStruct(m.selfPrefix, &m.self)
+	m.initFlushCounter()
+	m.initCounterWraps()
+	m.initSystemd()
 	return m
 }
 
-// Run periodically calls m.updateHandler.
+// separatorChars are the characters of struct tags separators can't contain
+// since they would make metric names ambiguous.
+const separatorChars = ",;=\"` \t\n"
+
+// checkSeparator returns an error if sep contains separatorChars.
+func checkSeparator(sep string) error {
+	if strings.ContainsAny(sep, separatorChars) {
+		return fmt.Errorf("tagtrics: invalid separator %q, it can't contain the characters of struct tags", sep)
+	}
+	return nil
+}
+
+// initStruct initializes the metric fields of the struct structPtr points to
+// with names prefixed with prefix, if any.  It stops at the first error.
+func (m *MetricTags) initStruct(prefix string, structPtr interface{}) error {
+	m.scanMutex.Lock()
+	defer m.scanMutex.Unlock()
+	if m.methodGauges {
+		m.initMethods(prefix, structPtr)
+	}
+	root := rootBranch(prefix, m.separator)
+	root.internal = structPtr == &m.self
+	if in, ok := structPtr.(Initializer); ok {
+		return m.initCustom(in, root)
+	}
+	if g, ok := structPtr.(Generated); ok {
+		var err error
+		g.TagtricsInit(&Binder{m: m, prefix: prefix, err: &err, ends: make(map[string][]int)})
+		return err
+	}
+	v := reflect.ValueOf(structPtr).Elem()
+	m.metaMutex.RLock()
+	root.start = m.registered
+	m.metaMutex.RUnlock()
+	b, err := root.enter(v)
+	if err != nil {
+		return err
+	}
+	return m.initializeFieldTagPath(v, b)
+}
+
+// Err returns the error which stopped the initialization of metricsData, if
+// any, such as a cycle of structs, or the error of an invalid separator.
+// The metrics initialized before the error can be used.  It also returns
+// the first metric name containing the separator, whose field is left nil
+// while the other fields are initialized.
+func (m *MetricTags) Err() error {
+	return m.err
+}
+
+// Run periodically calls m.updateHandler.  It registers the endpoint with
+// the registrars added with AddRegistrar first.  It returns right away in
+// pull-only mode.  Flushes are due every flush interval whatever time they
+// take; those due while a flush overran the interval are skipped and
+// counted in the "tagtrics.flush.overruns" self metric.  It can be called
+// again after Stop returned.
 func (m *MetricTags) Run() {
-	// Collect Go's runtime stats the first time this is run.
-	metrics.RegisterDebugGCStats(m.registry)
-	metrics.RegisterRuntimeMemStats(m.registry)
+	m.start()
+	if m.pullOnly {
+		return
+	}
+	m.run(nil)
+}
+
+// start delivers the snapshots queued by WithDelivery and registers the
+// endpoint with the registrars, before Run, RunContext or a Flusher start
+// flushing m.
+func (m *MetricTags) start() {
+	m.startDelivery()
+	m.registerEndpoints()
+}
 
-	updateTime := m.nowHandler()
-	gcTime, memTime := updateTime, updateTime
+// run flushes every interval until Stop is called, which it reports, or
+// done is closed, and returns the error of the final flush.
+func (m *MetricTags) run(done <-chan struct{}) (stopped bool, err error) {
+	// Collect Go's runtime stats the first time this is run.
+	stats := newRuntimeStats(m, m.nowHandler())
+	// The schedule follows the wall clock rather than nowHandler.
+	prevTick := time.Now()
+	tick, _ := m.nextTick(prevTick, prevTick)
+	flushAt := m.flushTime(tick)
 	for {
-		now := m.nowHandler()
-		// Get GC runtime stats
-		if now.Sub(gcTime) > m.StatsGCCollection {
-			metrics.CaptureDebugGCStatsOnce(m.registry)
-			gcTime = now
-		}
-		// Get memory runtime stats
-		if now.Sub(memTime) > m.StatsMemCollection {
-			metrics.CaptureRuntimeMemStatsOnce(m.registry)
-			memTime = now
+		stats.capture(m, m.nowHandler())
+		// Wake up early for the metrics flushed more often than the
+		// others.
+		wakeAt := flushAt
+		if due, ok := m.nextIntervalDue(); ok && due.Before(wakeAt) {
+			wakeAt = due
 		}
 		select {
 		case <-m.quitCh:
 			// Update stats one last time
-			m.updateHandler()
+			err := m.flushWithin(m.interval())
 			m.quitCh <- struct{}{}
-			return
-		case <-time.After(m.flushInterval):
-			m.updateHandler()
+			return true, err
+		case <-done:
+			return false, m.flushWithin(m.interval())
+		case <-m.intervalCh:
+			tick, _ = m.nextTick(prevTick, time.Now())
+			flushAt = m.flushTime(tick)
+		case <-time.After(time.Until(wakeAt)):
+			if time.Now().Before(flushAt) {
+				m.flushIntervals()
+				continue
+			}
+			m.flush()
+			var missed int64
+			prevTick = tick
+			if tick, missed = m.nextTick(prevTick, time.Now()); missed > 0 {
+				m.self.Flush.Overruns.Inc(missed)
+				m.warn("flush overran the interval", "missed", missed)
+			}
+			flushAt = m.flushTime(tick)
 		}
 	}
 }
 
-// Stop stops the Run worker and waits for it to finish.
+// flush captures a snapshot of every metric, calls m.updateHandler, sends the
+// snapshot to the sinks and keeps track of how it went in the self metrics.
+// The update handler gets the same snapshot from Snapshot so every value of
+// a flush is from the same point in time, no matter how long the handler and
+// the sinks take.  A panicking handler takes the worker down unless
+// WithPanicRecovery is used.
+func (m *MetricTags) flush() {
+	m.flushWithin(m.interval())
+}
+
+// SetFlushInterval changes how often Run flushes to d, e.g. to report less
+// often while the metrics backend is under pressure.  The next flush is
+// rescheduled right away, d after the previous one, without restarting
+// Run.  Intervals which aren't positive are ignored since the pull-only mode
+// can't be switched to at runtime.
+func (m *MetricTags) SetFlushInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	atomic.StoreInt64((*int64)(&m.flushInterval), int64(d))
+	select {
+	case m.intervalCh <- struct{}{}:
+	default:
+		// Run will pick the interval up anyway.
+	}
+}
+
+// interval returns the current flush interval.
+func (m *MetricTags) interval() time.Duration {
+	return time.Duration(atomic.LoadInt64((*int64)(&m.flushInterval)))
+}
+
+// flushWithin flushes like flush, the next flush being due in interval, and
+// returns the error of the update handler, the FlushFunc or the first
+// failing flush hook.
+func (m *MetricTags) flushWithin(interval time.Duration) error {
+	return m.flushContext(context.Background(), interval)
+}
+
+// flushContext flushes like flushWithin, the FlushFunc and the flush hooks
+// being passed ctx with a deadline interval after the start of the flush if
+// interval is positive.
+func (m *MetricTags) flushContext(ctx context.Context, interval time.Duration) (err error) {
+	if m.skipFlush() {
+		return nil
+	}
+	m.flushMutex.Lock()
+	defer m.flushMutex.Unlock()
+	now := m.nowHandler()
+	m.beginWindow(now)
+	s := m.snapshot(now)
+	m.checkWraps(s)
+	m.setFlushing(s)
+	start := time.Now()
+	// finished is unset while a panic unwinds the flush.
+	finished := false
+	defer func() {
+		if !finished && m.recoverPanics {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("tagtrics: update handler panicked: %v", r)
+				finished = true
+			}
+		}
+		m.setFlushing(nil)
+		m.self.Flush.Duration.UpdateSince(start)
+		if !finished {
+			m.self.Flush.Errors.Inc(1)
+		} else if err != nil {
+			m.self.Flush.Errors.Inc(1)
+			m.warn("update handler failed", "err", err)
+			m.reportError(err)
+		} else {
+			m.self.LastFlushTimestamp.Update(now.Unix())
+			m.flushes.Inc(1)
+			if m.systemd != nil {
+				if err := m.systemd.notify(); err != nil {
+					m.warn("failed to notify the systemd watchdog", "err", err)
+				}
+			}
+		}
+		m.endWindow()
+	}()
+	if m.updateHandler != nil {
+		m.updateHandler()
+	}
+	if m.flushFunc != nil || len(m.hooks) > 0 {
+		cancel := context.CancelFunc(func() {})
+		if interval > 0 {
+			ctx, cancel = context.WithDeadline(ctx, start.Add(interval))
+		}
+		if m.flushFunc != nil {
+			err = m.flushFunc(ctx, s)
+		}
+		if herr := m.callHooks(ctx, s); err == nil {
+			err = herr
+		}
+		cancel()
+	}
+	if s := m.dueMetrics(s, time.Now(), true); s != nil {
+		m.sendPaced(s, interval)
+	}
+	m.sendEvents()
+	finished = true
+	return err
+}
+
+// setFlushing sets the snapshot of the flush in progress.
+func (m *MetricTags) setFlushing(s *Snapshot) {
+	m.flushingMutex.Lock()
+	m.flushing = s
+	m.flushingMutex.Unlock()
+}
+
+// beginWindow computes the metrics derived from others at now before their
+// values are read.
+func (m *MetricTags) beginWindow(now time.Time) {
+	for _, d := range m.derived {
+		d.update(now)
+	}
+	f := m.Footprint()
+	m.self.Registry.Size.Update(f.Series)
+	m.self.Registry.Samples.Update(f.Samples)
+	m.self.Registry.SampleBytes.Update(f.SampleBytes)
+}
+
+// endWindow starts a new window of the windowed metrics once their values
+// have been read.
+func (m *MetricTags) endWindow() {
+	for _, w := range m.windowed {
+		w.resetWindow()
+	}
+}
+
+// Stop deregisters the endpoint from the registrars, then stops the Run
+// worker and waits for it to finish, which it does not in pull-only mode,
+// and for the snapshots queued by WithDelivery to be delivered.  Run can
+// then be called again, e.g. when a component is re-enabled.
 func (m *MetricTags) Stop() {
+	m.deregisterEndpoints()
+	if m.pullOnly {
+		m.stopDelivery()
+		return
+	}
 	m.quitCh <- struct{}{}
 	// Wait for it to quit
 	<-m.quitCh
-	close(m.quitCh)
+	m.stopDelivery()
 }
 
 // initializeFieldTagPath traverses the given struct trying to initialize
 // metric values.  The "metric" struct tag is used to determine the name of the
 // metrics for each struct field. If there is no "metric" struct tag, the
-// lowercased struct field name is used for the metric name. The name is
-// prefixed with tags from previous struct fields if any, separated by a dot.
+// lowercased struct field name is used for the metric name, or the name
+// converted as set with WithDerivedNameCase. The name is prefixed with tags
+// from previous struct fields if any, separated by a dot.
 // For example:
 //
 //     	Messages struct {
@@ -137,62 +590,338 @@ func (m *MetricTags) Stop() {
 // respectively.
 //
 // If there is no metric tag for a field it is skipped and assumed it is used
-// for other purposes such as configuration.
-func (m *MetricTags) initializeFieldTagPath(fieldType reflect.Value, prefix string) {
-	for i := 0; i < fieldType.NumField(); i++ {
-		val := fieldType.Field(i)
-		field := fieldType.Type().Field(i)
-
-		tag := field.Tag.Get("metric")
-		if tag == "" {
-			// If tag isn't found, derive tag from the lower case name of
-			// the field.
-			tag = strings.ToLower(field.Name)
-		}
-		if prefix != "" {
-			tag = prefix + m.separator + tag
-		}
-
-		if field.Type.Kind() == reflect.Struct {
-			// Recursively traverse an embedded struct
-			m.initializeFieldTagPath(val, tag)
-		} else if field.Type.Kind() == reflect.Map && field.Type.Key().Kind() == reflect.String {
-			// If this is a map[string]Something, then use the string key as bucket name and recursively generate the metrics below
-			for _, k := range val.MapKeys() {
-				m.initializeFieldTagPath(val.MapIndex(k).Elem(), tag+m.separator+k.String())
+// for other purposes such as configuration.  The other kinds of fields and
+// the tag options are described in the package documentation.
+func (m *MetricTags) initializeFieldTagPath(fieldType reflect.Value, b branch) error {
+	var skipped error
+	for _, f := range planOf(fieldType.Type()) {
+		if f.kind == skippedField {
+			continue
+		}
+		if err := m.initField(fieldType.Field(f.index), f, b); err != nil {
+			err = withField(f.fieldName, err)
+			if !isNameError(err) {
+				return err
 			}
-		} else {
-			// Found a field, initialize
-			switch field.Type.String() {
-			case "metrics.Counter":
-				c := metrics.NewCounter()
-				metrics.Register(tag, c)
-				val.Set(reflect.ValueOf(c))
-			case "metrics.Timer":
-				t := metrics.NewTimer()
-				metrics.Register(tag, t)
-				val.Set(reflect.ValueOf(t))
-			case "metrics.Meter":
-				m := metrics.NewMeter()
-				metrics.Register(tag, m)
-				val.Set(reflect.ValueOf(m))
-			case "metrics.Gauge":
-				g := metrics.NewGauge()
-				metrics.Register(tag, g)
-				val.Set(reflect.ValueOf(g))
-			case "metrics.Histogram":
-				s := metrics.NewUniformSample(1028)
-				h := metrics.NewHistogram(s)
-				metrics.Register(tag, h)
-				val.Set(reflect.ValueOf(h))
+			if skipped == nil {
+				skipped = err
 			}
 		}
 	}
+	return skipped
+}
+
+// nameError is a metric name which can't be used, such as one containing
+// the separator.  The field is skipped and the traversal goes on with the
+// others, the first such error being reported by Err.
+type nameError struct {
+	error
+}
+
+// isNameError reports whether err only skipped the field with a nameError.
+func isNameError(err error) bool {
+	var ne *nameError
+	return errors.As(err, &ne)
+}
+
+// initField initializes the field val of plan f of the struct of branch b.
+func (m *MetricTags) initField(val reflect.Value, f fieldPlan, b branch) error {
+	tag := f.name
+	if tag == "" {
+		// If tag isn't found, derive tag from the name of the field.
+		tag = DerivedName(f.fieldName, m.nameCase)
+	}
+	if !b.internal && strings.Contains(tag, b.sep) {
+		return &nameError{fmt.Errorf("tagtrics: metric name %q beneath %s contains the separator %q", tag, describeName(b.name), b.sep)}
+	}
+	fb := b.child(m, tag, f.opts)
+	if !fb.rescan && f.opts.Has("label") && f.kind != metricField && (f.kind != arrayField || f.elem != metricField) {
+		// The options of metrics are checked when they are created.
+		if err := f.opts.validateLabel(f.kind == mapField || f.kind == lazyField); err != nil {
+			m.warn("invalid tag options", "name", fb.name, "err", err)
+		}
+	}
+	if f.sink != "" {
+		fb.sink = f.sink
+	}
+	if fb.rescan && !f.kind.rescanned() {
+		return nil
+	}
+	if err := fb.checkLimits(m); err != nil {
+		return err
+	}
+
+	var err error
+	switch f.kind {
+	case initializerField:
+		// Fields registering their metrics themselves
+		err = m.initCustom(val.Addr().Interface().(Initializer), fb)
+	case typedField:
+		// Generic metrics are structs initializing themselves
+		if fb.enabled {
+			val.Addr().Interface().(typedMetric).initTyped(m, fb.meta("", f.help, f.unit, f.opts), fb.sep)
+		}
+	case lazyField:
+		// Fields initializing their metrics on first use
+		val.Addr().Interface().(lazyMetric).initLazy(m, fb)
+	case funcField:
+		// Fields read by functional gauges
+		m.initFuncGauge(val.Addr().Interface(), fb, f.help, f.unit, f.opts)
+	case structField:
+		// Recursively traverse an embedded struct
+		err = m.initializeFieldTagPath(val, fb)
+	case arrayField:
+		// Every element of a fixed-size array, e.g. shards
+		err = m.initArray(val, f, fb)
+	case sliceField:
+		// Every non-nil element of a slice of metric structs
+		err = m.initSlice(val, fb, f.opts)
+	case mapField:
+		// If this is a map[string]Something, then use the string key as bucket name and recursively generate the metrics below
+		for _, k := range val.MapKeys() {
+			v := val.MapIndex(k)
+			if v.IsNil() {
+				continue
+			}
+			key := keyName(k)
+			vb, kerr := fb.bucket(key, v.Interface()).enter(v.Elem())
+			if kerr == nil {
+				kerr = vb.checkLimits(m)
+			}
+			if kerr == nil {
+				kerr = m.initElement(v, m.elementBranch(vb, v))
+			}
+			if kerr != nil {
+				kerr = withField(fmt.Sprintf("[%q]", key), kerr)
+				if !isNameError(kerr) {
+					return kerr
+				}
+				if err == nil {
+					err = kerr
+				}
+			}
+		}
+	default:
+		// Found a field, initialize
+		m.initializeMetric(val, f, fb)
+	}
+	if err != nil && !isNameError(err) {
+		return err
+	}
+	if lerr := fb.checkLimits(m); lerr != nil {
+		return lerr
+	}
+	return err
+}
+
+// initializeMetric creates the metric for a struct field, registers it as
+// the name of b and sets the field to it.  A field of an interface type
+// already holding a go-metrics implementation, such as a NilTimer or a
+// custom Counter, keeps it and has it registered instead.  Fields of
+// unsupported types are skipped.
+func (m *MetricTags) initializeMetric(val reflect.Value, f fieldPlan, b branch) {
+	if val.Kind() == reflect.Interface && !val.IsNil() {
+		if held := val.Interface(); metricKind(held) != "" {
+			if b.enabled {
+				m.registerMetric(held, metricKind(held), b, f.help, f.unit, f.opts)
+			}
+			return
+		}
+	}
+	metric := m.initMetric(f.typeName, b, f.help, f.unit, f.opts)
+	if metric != nil {
+		val.Set(reflect.ValueOf(metric))
+	} else if f.name != "" {
+		m.warn("skipped field of unsupported type", "name", b.name, "type", f.typeName)
+	}
+}
+
+// initMetric creates the metric for a field of the given type, registers it
+// as the name of the field's branch b and returns it.  help and unit are the
+// values of the field's "help" and "unit" tags.  Disabled metrics are not
+// registered and use the no-op go-metrics implementations where there is
+// one.  It returns nil for unsupported types.
+func (m *MetricTags) initMetric(typeName string, b branch, help, unit string, opts tagOptions) interface{} {
+	metric := newMetric(typeName, opts, m.sampleSize)
+	if metric == nil {
+		return nil
+	}
+	if err := opts.validate(typeName); err != nil {
+		m.warn("invalid tag options", "name", b.name, "err", err)
+	}
+	if !b.enabled {
+		if n := nilMetric(typeName); n != nil {
+			return n
+		}
+		return metric
+	}
+	if existing := m.existingMetric(typeName, b.name); existing != nil {
+		m.record(b.meta(metricKinds[typeName], help, unit, opts), existing)
+		return existing
+	}
+	if m.resetOnFlush || opts.Has("reset") {
+		if r := newResetMetric(typeName, opts, m.sampleSize); r != nil {
+			metric = r
+		}
+	}
+	m.registerMetric(metric, metricKinds[typeName], b, help, unit, opts)
+	return metric
+}
+
+// registerMetric registers metric of the given kind as the name of the
+// field's branch b, along with the metrics derived from it.
+func (m *MetricTags) registerMetric(metric interface{}, kind string, b branch, help, unit string, opts tagOptions) {
+	if w, ok := metric.(windowed); ok {
+		m.windowed = append(m.windowed, w)
+	}
+	if d, ok := metric.(derived); ok {
+		m.derived = append(m.derived, d)
+	}
+	meta := b.meta(kind, help, unit, opts)
+	if mm, ok := metric.(multiMetric); ok {
+		for suffix, sub := range mm.exportedMetrics() {
+			subMeta := meta.suffixed(b.sep, suffix)
+			if _, ok := sub.(metrics.GaugeFloat64); ok {
+				// Such as the error rate of an Outcome.
+				subMeta.Type = "gauge"
+			}
+			m.register(subMeta, sub)
+		}
+	} else {
+		m.register(meta, metric)
+	}
+	if c, ok := metric.(metrics.Counter); ok && opts.Has("rate") {
+		r := newCounterRate(c, m.nowHandler())
+		m.derived = append(m.derived, r)
+		rate := meta.suffixed(b.sep, "rate")
+		rate.Type = "gauge"
+		rate.Unit += "/s"
+		m.register(rate, r.gauge)
+	}
+}
+
+// existingMetric returns the metric registered as name to bind a field of
+// the given type to if WithExistingMetrics is used, or nil.  Only the
+// fields of go-metrics types are bound, to metrics of the same kind.
+func (m *MetricTags) existingMetric(typeName, name string) interface{} {
+	if !m.existingMetrics || !strings.HasPrefix(typeName, "metrics.") {
+		return nil
+	}
+	existing := m.registry.Get(name)
+	if existing == nil || metricKind(existing) != metricKinds[typeName] {
+		return nil
+	}
+	return existing
+}
+
+// newMeta returns the metadata of the metric named name.
+func newMeta(name, kind, help, unit string, opts tagOptions) MetricMeta {
+	// Invalid percentiles fall back to m.Percentiles.
+	percentiles, _ := opts.percentiles()
+	return MetricMeta{
+		Name:        name,
+		Type:        kind,
+		Help:        help,
+		Unit:        unit,
+		Percentiles: percentiles,
+		States:      opts.list("states"),
+		// Invalid units fall back to the instance default.
+		DurationUnit: opts["duration"],
+		// Invalid intervals fall back to every flush.
+		Interval: opts.interval(),
+	}
+}
+
+// newMetric creates a metric for a field of the given type configured with
+// the tag options.  The metric is nil for unsupported types.
+func newMetric(typeName string, opts tagOptions, sampleSize int) interface{} {
+	switch typeName {
+	case "metrics.Counter":
+		return metrics.NewCounter()
+	case "metrics.Timer":
+		if h, _ := opts.histogram(); h != nil {
+			return newHistogramTimer(h)
+		}
+		return newHistogramTimer(defaultTimerHistogram(sampleSize))
+	case "metrics.Meter":
+		return newClearableMeter()
+	case "metrics.Gauge":
+		return metrics.NewGauge()
+	case "tagtrics.BoolGauge":
+		return NewBoolGauge()
+	case "tagtrics.StateGauge":
+		return NewStateGauge(opts.list("states")...)
+	case "tagtrics.Info":
+		return NewInfo(nil)
+	case "tagtrics.CardinalityCounter":
+		precision, _ := opts.int64("precision", DefaultCardinalityPrecision)
+		c, err := NewCardinalityCounter(int(precision))
+		if err != nil {
+			c, _ = NewCardinalityCounter(DefaultCardinalityPrecision)
+		}
+		return c
+	case "tagtrics.MinMaxGauge":
+		return NewMinMaxGauge()
+	case "tagtrics.Outcome":
+		return NewOutcome()
+	case "metrics.Histogram":
+		if h, _ := opts.histogram(); h != nil {
+			return h
+		}
+		s := metrics.NewUniformSample(sampleSize)
+		return metrics.NewHistogram(s)
+	}
+	return nil
+}
+
+// nilMetric returns the no-op go-metrics implementation for a field of the
+// given type or nil if there is none.
+func nilMetric(typeName string) interface{} {
+	switch typeName {
+	case "metrics.Counter":
+		return metrics.NilCounter{}
+	case "metrics.Timer":
+		return metrics.NilTimer{}
+	case "metrics.Meter":
+		return metrics.NilMeter{}
+	case "metrics.Gauge":
+		return metrics.NilGauge{}
+	case "metrics.Histogram":
+		return metrics.NilHistogram{}
+	}
+	return nil
+}
+
+// register adds metric to the registry under meta.Name and keeps its
+// metadata.
+func (m *MetricTags) register(meta MetricMeta, metric interface{}) {
+	if err := m.registry.Register(meta.Name, metric); err != nil {
+		// The name belongs to another metric, maybe of another MetricTags.
+		m.warn("failed to register metric", "name", meta.Name, "err", err)
+		return
+	}
+	m.record(meta, metric)
+}
+
+// record records the metadata of a metric registered in the registry.
+func (m *MetricTags) record(meta MetricMeta, metric interface{}) {
+	m.metaMutex.Lock()
+	m.meta[meta.Name] = meta
+	m.registered++
+	m.recordInterval(meta.Interval)
+	if t, ok := metric.(metrics.Timer); ok {
+		if m.timerNames == nil {
+			m.timerNames = make(map[metrics.Timer]string)
+		}
+		m.timerNames[t] = meta.Name
+	}
+	m.metaMutex.Unlock()
 }
 
 // ToJSON returns a representation of all the metrics in JSON format.
 func (m *MetricTags) ToJSON() []byte {
+	defer m.self.Snapshot.Serialization.UpdateSince(time.Now())
 	buf := bytes.NewBuffer(nil)
-	metrics.WriteJSONOnce(m.registry, buf)
+	m.Snapshot().WriteJSON(buf)
 	return buf.Bytes()
 }
@@ -121,3 +121,77 @@ func TestMetricTags(t *testing.T) {
 	}
 	mTags.Stop()
 }
+
+func TestSetFlushInterval(t *testing.T) {
+	flushed := make(chan struct{}, 100)
+	mTags := NewMetricTags(&metaMetrics{}, func() { flushed <- struct{}{} }, time.Hour, metrics.NewRegistry(), ".")
+	go mTags.Run()
+	defer mTags.Stop()
+
+	mTags.SetFlushInterval(10 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-flushed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the new interval wasn't picked up")
+		}
+	}
+	mTags.SetFlushInterval(0)
+	if mTags.interval() != 10*time.Millisecond {
+		t.Fatalf("unexpected interval %v", mTags.interval())
+	}
+}
+
+func TestRestart(t *testing.T) {
+	flushed := make(chan struct{}, 100)
+	delivered := make(chan struct{}, 100)
+	mTags := NewMetricTags(&metaMetrics{}, func() { flushed <- struct{}{} }, 10*time.Millisecond, metrics.NewRegistry(), ".",
+		WithDelivery(Delivery{QueueDepth: 10}))
+	mTags.AddSink(SinkFunc(func(*Snapshot) error {
+		delivered <- struct{}{}
+		return nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		go mTags.Run()
+		select {
+		case <-flushed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("run %d didn't flush", i)
+		}
+		mTags.Stop()
+		select {
+		case <-delivered:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("run %d didn't deliver", i)
+		}
+	}
+}
+
+func TestSeparator(t *testing.T) {
+	m := &metaMetrics{}
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(m, func() {}, time.Second, r, "", WithLogger(&recordingLogger{}))
+	if mTags.Err() != nil || r.Get("queue.depth") == nil {
+		t.Fatalf("expected the default separator, got %v", mTags.Err())
+	}
+
+	r = metrics.NewRegistry()
+	mTags = NewMetricTags(&metaMetrics{}, func() {}, time.Second, r, ",", WithLogger(&recordingLogger{}))
+	if mTags.Err() == nil || r.Get("queue.depth") == nil {
+		t.Fatalf("expected an invalid separator replaced by the default one, got %v", mTags.Err())
+	}
+
+	dotted := &struct {
+		Depth metrics.Gauge `metric:"queue.depth"`
+	}{}
+	mTags = NewMetricTags(dotted, func() {}, time.Second, metrics.NewRegistry(), ".", WithLogger(&recordingLogger{}))
+	if mTags.Err() == nil {
+		t.Fatalf("expected an error for a name containing the separator")
+	}
+	// The self metrics keep their names.
+	mTags = NewMetricTags(&metaMetrics{}, func() {}, time.Second, metrics.NewRegistry(), "_", WithLogger(&recordingLogger{}))
+	if mTags.Err() != nil {
+		t.Fatalf("unexpected error %v", mTags.Err())
+	}
+}
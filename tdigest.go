@@ -0,0 +1,317 @@
+package tagtrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// DefaultTDigestCompression is the compression of the t-digests of the
+// fields tagged with sample=tdigest without a "compression" option.  A
+// t-digest keeps about that many centroids.
+const DefaultTDigestCompression = 100
+
+// Centroid is a cluster of values of a t-digest.
+type Centroid struct {
+	Mean  float64 `json:"mean"`
+	Count int64   `json:"count"`
+}
+
+// Digest is the t-digest of a histogram or timer tagged with sample=tdigest
+// as returned by Snapshot.Digest.  Unlike percentiles, digests can be
+// merged with MergeDigests, e.g. by a server aggregating the digests of
+// several shards or processes, while keeping accurate tail percentiles.  It
+// can be encoded to JSON to be sent to such a server.
+type Digest struct {
+	Compression float64 `json:"compression"`
+	// Centroids are sorted by mean.
+	Centroids []Centroid `json:"centroids"`
+	Min       float64    `json:"min"`
+	Max       float64    `json:"max"`
+}
+
+// Count returns the number of values summarized by d.
+func (d *Digest) Count() int64 {
+	var n int64
+	for _, c := range d.Centroids {
+		n += c.Count
+	}
+	return n
+}
+
+// Quantile returns the value at quantile q, which is between 0 and 1,
+// interpolated between the centroids.  It returns 0 if d is empty.
+func (d *Digest) Quantile(q float64) float64 {
+	cs := d.Centroids
+	n := d.Count()
+	if n == 0 {
+		return 0
+	}
+	if len(cs) == 1 || q <= 0 {
+		if q <= 0 {
+			return d.Min
+		}
+		return cs[0].Mean
+	}
+	if q >= 1 {
+		return d.Max
+	}
+	rank := q * float64(n)
+	// Interpolate between the minimum, the centers of the centroids and
+	// the maximum.
+	prevRank, prevValue := 0.0, d.Min
+	var seen float64
+	for _, c := range cs {
+		center := seen + float64(c.Count)/2
+		if rank < center {
+			return interpolate(rank, prevRank, center, prevValue, c.Mean)
+		}
+		prevRank, prevValue = center, c.Mean
+		seen += float64(c.Count)
+	}
+	return interpolate(rank, prevRank, float64(n), prevValue, d.Max)
+}
+
+// interpolate returns the value at x on the line from (x0, y0) to (x1, y1).
+func interpolate(x, x0, x1, y0, y1 float64) float64 {
+	if x1 <= x0 {
+		return y1
+	}
+	return y0 + (y1-y0)*(x-x0)/(x1-x0)
+}
+
+// MergeDigests merges digests into one with the largest of their
+// compressions.  Empty and nil digests are skipped.  It returns nil if
+// every digest is empty.
+func MergeDigests(digests ...*Digest) *Digest {
+	var merged *Digest
+	var cs []Centroid
+	for _, d := range digests {
+		if d == nil || d.Count() == 0 {
+			continue
+		}
+		if merged == nil {
+			merged = &Digest{Compression: d.Compression, Min: d.Min, Max: d.Max}
+		}
+		merged.Compression = math.Max(merged.Compression, d.Compression)
+		merged.Min = math.Min(merged.Min, d.Min)
+		merged.Max = math.Max(merged.Max, d.Max)
+		cs = append(cs, d.Centroids...)
+	}
+	if merged != nil {
+		merged.Centroids = compressCentroids(cs, merged.Compression)
+	}
+	return merged
+}
+
+// compressCentroids sorts cs and merges the neighbors whose combined size
+// keeps the quantiles within the accuracy of compression, the centroids at
+// the tails staying smaller than those in the middle.  It may modify cs.
+func compressCentroids(cs []Centroid, compression float64) []Centroid {
+	if len(cs) == 0 {
+		return nil
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Mean < cs[j].Mean })
+	var total float64
+	for _, c := range cs {
+		total += float64(c.Count)
+	}
+	// k is the scale function k1 of Dunning and Ertl, "Computing Extremely
+	// Accurate Quantiles Using t-Digests".
+	k := func(q float64) float64 {
+		return compression / (2 * math.Pi) * math.Asin(2*math.Min(q, 1)-1)
+	}
+	merged := make([]Centroid, 0, int(compression))
+	cur := cs[0]
+	var seen float64
+	kLeft := k(0)
+	for _, c := range cs[1:] {
+		q := (seen + float64(cur.Count+c.Count)) / total
+		if k(q)-kLeft <= 1 {
+			count := cur.Count + c.Count
+			cur.Mean += (c.Mean - cur.Mean) * float64(c.Count) / float64(count)
+			cur.Count = count
+			continue
+		}
+		merged = append(merged, cur)
+		seen += float64(cur.Count)
+		kLeft = k(seen / total)
+		cur = c
+	}
+	return append(merged, cur)
+}
+
+// tdigestHistogram is a metrics.Histogram backed by a t-digest, created for
+// the fields tagged with sample=tdigest.  It keeps accurate tail
+// percentiles in a bounded number of centroids and its digests merge well
+// across shards and processes.
+type tdigestHistogram struct {
+	histogramStats
+
+	compression float64
+	centroids   []Centroid
+	// buffer holds the values recorded since the last compression.
+	buffer []Centroid
+}
+
+// newTDigestHistogram creates a t-digest histogram with the given
+// compression.
+func newTDigestHistogram(compression float64) (*tdigestHistogram, error) {
+	if compression < 10 || compression > 10000 {
+		return nil, fmt.Errorf("tdigest compression must be between 10 and 10000, got %v", compression)
+	}
+	h := &tdigestHistogram{compression: compression}
+	h.reset()
+	return h, nil
+}
+
+// reset clears all recorded values.  The caller must hold the mutex.
+func (h *tdigestHistogram) reset() {
+	h.centroids, h.buffer = nil, nil
+	h.clearStats()
+}
+
+// compress merges the buffered values into the centroids.  The caller must
+// hold the mutex.
+func (h *tdigestHistogram) compress() {
+	if len(h.buffer) == 0 {
+		return
+	}
+	h.centroids = compressCentroids(append(h.centroids, h.buffer...), h.compression)
+	h.buffer = h.buffer[:0]
+}
+
+// digest returns the digest of the recorded values.  The caller must hold
+// the mutex.
+func (h *tdigestHistogram) digest() *Digest {
+	h.compress()
+	d := &Digest{Compression: h.compression, Centroids: append([]Centroid(nil), h.centroids...)}
+	if h.totalCount > 0 {
+		d.Min, d.Max = float64(h.min), float64(h.max)
+	}
+	return d
+}
+
+// Clear clears the histogram.
+func (h *tdigestHistogram) Clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checkWritable("Clear", "tdigestHistogram")
+	h.reset()
+}
+
+// Percentile returns the value at percentile p which is between 0 and 1.
+func (h *tdigestHistogram) Percentile(p float64) float64 {
+	return h.Percentiles([]float64{p})[0]
+}
+
+// Percentiles returns the values at each of the percentiles ps which are
+// between 0 and 1.
+func (h *tdigestHistogram) Percentiles(ps []float64) []float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	d := h.digest()
+	scores := make([]float64, len(ps))
+	for i, p := range ps {
+		scores[i] = d.Quantile(p)
+	}
+	return scores
+}
+
+// Sample returns a metrics.Sample view of the histogram.
+func (h *tdigestHistogram) Sample() metrics.Sample {
+	return tdigestSample{h}
+}
+
+// Snapshot returns a read-only copy of the histogram.
+func (h *tdigestHistogram) Snapshot() metrics.Histogram {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.compress()
+	return &tdigestHistogram{
+		histogramStats: h.frozenStats(),
+		compression:    h.compression,
+		centroids:      append([]Centroid(nil), h.centroids...),
+	}
+}
+
+// Update records v.
+func (h *tdigestHistogram) Update(v int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checkWritable("Update", "tdigestHistogram")
+	h.buffer = append(h.buffer, Centroid{Mean: float64(v), Count: 1})
+	if len(h.buffer) >= 5*int(h.compression) {
+		h.compress()
+	}
+	h.record(v)
+}
+
+// footprint implements footprinter with the centroids and the buffered
+// values.
+func (h *tdigestHistogram) footprint() (samples, bytes int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	samples = int64(len(h.centroids) + len(h.buffer))
+	return samples, int64(cap(h.centroids)+cap(h.buffer)) * 16
+}
+
+// tdigestSample adapts tdigestHistogram to metrics.Sample.
+type tdigestSample struct {
+	*tdigestHistogram
+}
+
+// Size returns the number of recorded values.
+func (s tdigestSample) Size() int {
+	return int(s.Count())
+}
+
+// Snapshot returns a go-metrics sample snapshot holding Values.
+func (s tdigestSample) Snapshot() metrics.Sample {
+	return metrics.NewSampleSnapshot(s.Count(), s.Values())
+}
+
+// Values returns the mean of every centroid.  A t-digest does not keep
+// individual values so this is an approximation of their distribution, not
+// a list of everything recorded.
+func (s tdigestSample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.compress()
+	values := make([]int64, len(s.centroids))
+	for i, c := range s.centroids {
+		values[i] = int64(math.Round(c.Mean))
+	}
+	return values
+}
+
+// Digest returns the t-digest of the named histogram or timer if it is
+// tagged with sample=tdigest, or nil.  The values of timers are in the
+// duration unit of the snapshot.
+func (s *Snapshot) Digest(name string) *Digest {
+	var h *tdigestHistogram
+	unit := 1.0
+	switch metric := s.Metrics[name].(type) {
+	case *tdigestHistogram:
+		h = metric
+	case *histogramTimer:
+		h, _ = metric.histogram.(*tdigestHistogram)
+		unit = float64(s.durationUnit(name))
+	}
+	if h == nil {
+		return nil
+	}
+	h.mutex.Lock()
+	d := h.digest()
+	h.mutex.Unlock()
+	if unit != 1 {
+		for i := range d.Centroids {
+			d.Centroids[i].Mean /= unit
+		}
+		d.Min /= unit
+		d.Max /= unit
+	}
+	return d
+}
@@ -0,0 +1,108 @@
+package tagtrics
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestTDigestHistogram(t *testing.T) {
+	h, err := newTDigestHistogram(100)
+	if err != nil {
+		t.Fatalf("failed to create histogram: %v", err)
+	}
+	const n = 100000
+	for _, i := range rand.New(rand.NewSource(1)).Perm(n) {
+		h.Update(int64(i + 1))
+	}
+	if h.Count() != n || h.Min() != 1 || h.Max() != n {
+		t.Fatalf("unexpected count/min/max: %d %d %d", h.Count(), h.Min(), h.Max())
+	}
+	qs := []float64{0.5, 0.99, 0.999}
+	ps := h.Percentiles(qs)
+	for i, q := range qs {
+		if math.Abs(ps[i]-q*n)/(q*n) > 0.001 {
+			t.Fatalf("percentile %v: got %f want %f", q, ps[i], q*n)
+		}
+	}
+	if samples, _ := h.footprint(); samples > 200 {
+		t.Fatalf("expected about 100 centroids, got %d", samples)
+	}
+	s := h.Snapshot()
+	h.Update(1)
+	if s.Count() != n || s.Percentile(0.5) != ps[0] {
+		t.Fatalf("snapshot changed after update")
+	}
+	if _, err := newTDigestHistogram(1); err == nil {
+		t.Fatalf("expected error for compression 1")
+	}
+}
+
+func TestMergeDigests(t *testing.T) {
+	// Two shards see the lower and the upper half of the values.
+	var shards [2]*tdigestHistogram
+	for i := range shards {
+		shards[i], _ = newTDigestHistogram(100)
+		for v := 1; v <= 50000; v++ {
+			shards[i].Update(int64(i*50000 + v))
+		}
+	}
+	var digests []*Digest
+	for _, h := range shards {
+		data, err := json.Marshal(h.digest())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d Digest
+		if err := json.Unmarshal(data, &d); err != nil {
+			t.Fatal(err)
+		}
+		digests = append(digests, &d)
+	}
+	merged := MergeDigests(append(digests, nil, &Digest{})...)
+	if merged.Count() != 100000 || merged.Min != 1 || merged.Max != 100000 {
+		t.Fatalf("unexpected merged digest: %d %v %v", merged.Count(), merged.Min, merged.Max)
+	}
+	if len(merged.Centroids) > 200 {
+		t.Fatalf("merged digest not compressed: %d centroids", len(merged.Centroids))
+	}
+	for _, q := range []float64{0.25, 0.5, 0.99} {
+		if v := merged.Quantile(q); math.Abs(v-q*100000) > 500 {
+			t.Fatalf("quantile %v: got %f", q, v)
+		}
+	}
+	if MergeDigests() != nil {
+		t.Fatalf("expected nil merging no digests")
+	}
+}
+
+type tdigestMetrics struct {
+	Latency metrics.Timer     `metric:"latency,sample=tdigest,compression=200"`
+	Size    metrics.Histogram `metric:"size,sample=tdigest"`
+}
+
+func TestTDigestTag(t *testing.T) {
+	m := &tdigestMetrics{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithDurationUnit(time.Millisecond))
+	h, ok := m.Size.(*tdigestHistogram)
+	if !ok || h.compression != DefaultTDigestCompression {
+		t.Fatalf("size is not a default t-digest: %T", m.Size)
+	}
+	m.Latency.Update(2 * time.Millisecond)
+	m.Latency.Update(4 * time.Millisecond)
+	s := tags.Snapshot()
+	d := s.Digest("latency")
+	if d == nil || d.Compression != 200 || d.Count() != 2 || d.Min != 2 || d.Max != 4 {
+		t.Fatalf("unexpected digest %+v", d)
+	}
+	if s.Digest("size") == nil || s.Digest("tagtrics.flush.duration") != nil {
+		t.Fatalf("unexpected digests of other metrics")
+	}
+	if err := ValidateField("metrics.Histogram", "size,compression=100"); err == nil {
+		t.Fatalf("expected an error for compression without sample=tdigest")
+	}
+}
@@ -0,0 +1,124 @@
+package tagtrics
+
+import (
+	"sync"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// histogramTimer is a metrics.Timer backed by any metrics.Histogram.
+// metrics.NewCustomTimer only works with the go-metrics histograms since its
+// snapshots expect their concrete types.
+type histogramTimer struct {
+	mutex     sync.Mutex
+	histogram metrics.Histogram
+	meter     metrics.Meter
+	// frozen is set on snapshots which must not be updated.
+	frozen bool
+}
+
+// newHistogramTimer returns a timer recording durations in h.
+func newHistogramTimer(h metrics.Histogram) metrics.Timer {
+	return &histogramTimer{histogram: h, meter: newClearableMeter()}
+}
+
+// defaultTimerHistogram returns the histogram of the timers created by
+// metrics.NewTimer with a sample of the given size.
+func defaultTimerHistogram(sampleSize int) metrics.Histogram {
+	return metrics.NewHistogram(metrics.NewExpDecaySample(sampleSize, 0.015))
+}
+
+// Clear clears the histogram and the meter of the timer.
+func (t *histogramTimer) Clear() {
+	t.histogram.Clear()
+	if c, ok := t.meter.(clearer); ok {
+		c.Clear()
+	}
+}
+
+// Count returns the number of events recorded.
+func (t *histogramTimer) Count() int64 { return t.histogram.Count() }
+
+// Max returns the maximum value in the sample.
+func (t *histogramTimer) Max() int64 { return t.histogram.Max() }
+
+// Mean returns the mean of the values in the sample.
+func (t *histogramTimer) Mean() float64 { return t.histogram.Mean() }
+
+// Min returns the minimum value in the sample.
+func (t *histogramTimer) Min() int64 { return t.histogram.Min() }
+
+// Percentile returns an arbitrary percentile of the values in the sample.
+func (t *histogramTimer) Percentile(p float64) float64 { return t.histogram.Percentile(p) }
+
+// Percentiles returns a slice of arbitrary percentiles of the values in the
+// sample.
+func (t *histogramTimer) Percentiles(ps []float64) []float64 { return t.histogram.Percentiles(ps) }
+
+// Rate1 returns the one-minute moving average rate of events per second.
+func (t *histogramTimer) Rate1() float64 { return t.meter.Rate1() }
+
+// Rate5 returns the five-minute moving average rate of events per second.
+func (t *histogramTimer) Rate5() float64 { return t.meter.Rate5() }
+
+// Rate15 returns the fifteen-minute moving average rate of events per second.
+func (t *histogramTimer) Rate15() float64 { return t.meter.Rate15() }
+
+// RateMean returns the meter's mean rate of events per second.
+func (t *histogramTimer) RateMean() float64 { return t.meter.RateMean() }
+
+// Snapshot returns a read-only copy of the timer.
+func (t *histogramTimer) Snapshot() metrics.Timer {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return &histogramTimer{
+		histogram: t.histogram.Snapshot(),
+		meter:     t.meter.Snapshot(),
+		frozen:    true,
+	}
+}
+
+// StdDev returns the standard deviation of the values in the sample.
+func (t *histogramTimer) StdDev() float64 { return t.histogram.StdDev() }
+
+// Stop stops the meter.
+func (t *histogramTimer) Stop() { t.meter.Stop() }
+
+// Sum returns the sum in the sample.
+func (t *histogramTimer) Sum() int64 { return t.histogram.Sum() }
+
+// Time records the duration of the execution of the given function.
+func (t *histogramTimer) Time(f func()) {
+	ts := time.Now()
+	f()
+	t.Update(time.Since(ts))
+}
+
+// Update records the duration of an event.
+func (t *histogramTimer) Update(d time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.frozen {
+		panic("Update called on a timer snapshot")
+	}
+	t.histogram.Update(int64(d))
+	t.meter.Mark(1)
+}
+
+// UpdateSince records the duration of an event that started at ts and ends
+// now.
+func (t *histogramTimer) UpdateSince(ts time.Time) {
+	t.Update(time.Since(ts))
+}
+
+// update starts a new interval of histograms which are reset at every
+// flush.
+func (t *histogramTimer) update(now time.Time) {
+	if d, ok := t.histogram.(derived); ok {
+		d.update(now)
+	}
+}
+
+// Variance returns the variance of the values in the sample.
+func (t *histogramTimer) Variance() float64 { return t.histogram.Variance() }
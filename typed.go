@@ -0,0 +1,173 @@
+package tagtrics
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// typedMetric is implemented by pointers to the generic metric types which
+// are initialized in place instead of being assigned to their field.
+type typedMetric interface {
+	// initTyped registers the metric described by meta with m.  sep
+	// separates meta.Name from the suffixes of metrics registered next to it.
+	initTyped(m *MetricTags, meta MetricMeta, sep string)
+}
+
+// Number is the set of types a typed Gauge can hold.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// Gauge is a gauge holding values of type T, giving compile-time type safety
+// to the values recorded into a tagged struct.  Integer gauges are exported
+// as a metrics.Gauge and floating point gauges as a metrics.GaugeFloat64.
+// Declare it by value rather than as a pointer:
+//
+//	Depth tagtrics.Gauge[int64] `metric:"depth"`
+//
+// A Gauge that is not initialized ignores updates.
+type Gauge[T Number] struct {
+	gauge      metrics.Gauge
+	gaugeFloat metrics.GaugeFloat64
+}
+
+// initTyped registers the underlying go-metrics gauge.
+func (g *Gauge[T]) initTyped(m *MetricTags, meta MetricMeta, sep string) {
+	meta.Type = "gauge"
+	var zero T
+	switch reflect.TypeOf(zero).Kind() {
+	case reflect.Float32, reflect.Float64:
+		g.gaugeFloat = metrics.NewGaugeFloat64()
+		m.register(meta, g.gaugeFloat)
+	default:
+		g.gauge = metrics.NewGauge()
+		m.register(meta, g.gauge)
+	}
+}
+
+// Update sets the gauge to v.
+func (g *Gauge[T]) Update(v T) {
+	switch {
+	case g.gaugeFloat != nil:
+		g.gaugeFloat.Update(float64(v))
+	case g.gauge != nil:
+		g.gauge.Update(int64(v))
+	}
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge[T]) Value() T {
+	switch {
+	case g.gaugeFloat != nil:
+		return T(g.gaugeFloat.Value())
+	case g.gauge != nil:
+		return T(g.gauge.Value())
+	}
+	return 0
+}
+
+// LabeledCounter is a set of counters keyed by values of type K, such as an
+// enum of error kinds.  The counter for a key is registered on first use,
+// named after the field's metric name followed by the key formatted with
+// fmt.Sprint.  Declare it by value rather than as a pointer:
+//
+//	Errors tagtrics.LabeledCounter[ErrorKind] `metric:"errors"`
+//
+// A LabeledCounter that is not initialized returns no-op counters.
+type LabeledCounter[K comparable] struct {
+	mutex    sync.RWMutex
+	m        *MetricTags
+	meta     MetricMeta
+	sep      string
+	counters map[K]metrics.Counter
+}
+
+// initTyped keeps what is needed to register counters on first use.
+func (c *LabeledCounter[K]) initTyped(m *MetricTags, meta MetricMeta, sep string) {
+	meta.Type = "counter"
+	c.m, c.meta, c.sep = m, meta, sep
+	c.counters = make(map[K]metrics.Counter)
+}
+
+// With returns the counter for key.
+func (c *LabeledCounter[K]) With(key K) metrics.Counter {
+	c.mutex.RLock()
+	counter, ok := c.counters[key]
+	c.mutex.RUnlock()
+	if ok {
+		return counter
+	}
+	if c.m == nil {
+		return metrics.NilCounter{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if counter, ok := c.counters[key]; ok {
+		return counter
+	}
+	counter = metrics.NewCounter()
+	c.m.register(c.meta.suffixed(c.sep, fmt.Sprint(key)), counter)
+	c.counters[key] = counter
+	return counter
+}
+
+// Inc increments the counter for key by n.
+func (c *LabeledCounter[K]) Inc(key K, n int64) {
+	c.With(key).Inc(n)
+}
+
+// LabeledTimer is a set of timers keyed by values of type K, such as the
+// names of operations.  The timer for a key is registered on first use like
+// the counters of a LabeledCounter.  Declare it by value rather than as a
+// pointer:
+//
+//	Operations tagtrics.LabeledTimer[string] `metric:"operations"`
+//
+// A LabeledTimer that is not initialized returns no-op timers.
+type LabeledTimer[K comparable] struct {
+	mutex  sync.RWMutex
+	m      *MetricTags
+	meta   MetricMeta
+	sep    string
+	timers map[K]metrics.Timer
+}
+
+// initTyped keeps what is needed to register timers on first use.
+func (t *LabeledTimer[K]) initTyped(m *MetricTags, meta MetricMeta, sep string) {
+	meta.Type = "timer"
+	t.m, t.meta, t.sep = m, meta, sep
+	t.timers = make(map[K]metrics.Timer)
+}
+
+// With returns the timer for key.
+func (t *LabeledTimer[K]) With(key K) metrics.Timer {
+	t.mutex.RLock()
+	timer, ok := t.timers[key]
+	t.mutex.RUnlock()
+	if ok {
+		return timer
+	}
+	if t.m == nil {
+		return metrics.NilTimer{}
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if timer, ok := t.timers[key]; ok {
+		return timer
+	}
+	timer = newHistogramTimer(defaultTimerHistogram(t.m.sampleSize))
+	t.m.register(t.meta.suffixed(t.sep, fmt.Sprint(key)), timer)
+	t.timers[key] = timer
+	return timer
+}
+
+// Update records the duration d for key.
+func (t *LabeledTimer[K]) Update(key K, d time.Duration) {
+	t.With(key).Update(d)
+}
@@ -0,0 +1,75 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type errorKind int
+
+func (k errorKind) String() string {
+	return [...]string{"timeout", "refused"}[k]
+}
+
+type typedMetrics struct {
+	Depth  Gauge[int64]              `metric:"depth"`
+	Load   Gauge[float64]            `metric:"load"`
+	Errors LabeledCounter[errorKind] `metric:"errors" help:"Errors by kind"`
+	Beta   struct {
+		Depth Gauge[int32] `metric:"depth"`
+	} `metric:"beta,optional=beta"`
+}
+
+func TestTypedMetrics(t *testing.T) {
+	m := &typedMetrics{}
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(m, func() {}, time.Second, r, ".")
+
+	m.Depth.Update(3)
+	m.Load.Update(0.75)
+	m.Errors.Inc(1, 2)
+	m.Errors.Inc(1, 1)
+	m.Beta.Depth.Update(1)
+
+	if m.Depth.Value() != 3 || r.Get("depth").(metrics.Gauge).Value() != 3 {
+		t.Fatalf("unexpected int gauge value")
+	}
+	if m.Load.Value() != 0.75 || r.Get("load").(metrics.GaugeFloat64).Value() != 0.75 {
+		t.Fatalf("unexpected float gauge value")
+	}
+	if c := r.Get("errors.refused"); c == nil || c.(metrics.Counter).Count() != 3 {
+		t.Fatalf("unexpected labeled counter: %v", c)
+	}
+	if r.Get("errors.timeout") != nil {
+		t.Fatalf("unused label was registered")
+	}
+	if meta, _ := mTags.Metadata("errors.refused"); meta.Type != "counter" || meta.Help != "Errors by kind" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if r.Get("beta.depth") != nil || m.Beta.Depth.Value() != 0 {
+		t.Fatalf("disabled typed gauge was registered")
+	}
+}
+
+func TestLabeledTimer(t *testing.T) {
+	m := &struct {
+		Operations LabeledTimer[string] `metric:"operations,percentiles=99"`
+	}{}
+	r := metrics.NewRegistry()
+	mTags := NewMetricTags(m, func() {}, time.Second, r, ".")
+	m.Operations.Update("query", time.Millisecond)
+	m.Operations.Update("query", 3*time.Millisecond)
+	if timer := r.Get("operations.query"); timer == nil || timer.(metrics.Timer).Count() != 2 {
+		t.Fatalf("unexpected labeled timer: %v", timer)
+	}
+	if meta, _ := mTags.Metadata("operations.query"); meta.Type != "timer" || len(meta.Percentiles) != 1 {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	var unset LabeledTimer[string]
+	unset.Update("query", time.Second)
+	if err := ValidateField("tagtrics.LabeledTimer[string]", "operations,percentiles=99,duration=ms"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package tagtrics
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// uiPage is the page served by UIHandler.  It fetches the metrics from
+// Handler and keeps their history to draw sparklines in the browser.
+//
+//go:embed ui.html
+var uiPage string
+
+var uiTemplate = template.Must(template.New("ui").Parse(uiPage))
+
+// UIHandler returns an HTTP handler serving a small self-contained HTML page
+// for debugging on hosts without access to dashboards.  The page fetches the
+// JSON of the metrics from endpoint, the path Handler is served at, every
+// flush interval and renders them as a tree of metric names split on the
+// separator with a sparkline of the recent history of every statistic.
+// ListenAndServe serves it at its metrics path followed by "/ui", e.g.
+// "/metrics/ui".
+func (m *MetricTags) UIHandler(endpoint string) http.Handler {
+	refresh := m.interval()
+	if refresh <= 0 {
+		refresh = 10 * time.Second
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		uiTemplate.Execute(w, struct {
+			Endpoint, Separator string
+			Refresh             int64
+		}{endpoint, m.separator, int64(refresh / time.Millisecond)})
+	})
+}
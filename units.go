@@ -0,0 +1,69 @@
+package tagtrics
+
+import "time"
+
+// durationUnits maps the values of the "duration" tag option to the units
+// they stand for.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// WithDurationUnit sets the unit of the durations exported for timers, such
+// as time.Millisecond, which is nanoseconds by default.  It applies to the
+// minimum, maximum, mean, standard deviation and percentiles in Stats, and
+// so to ToJSON and every sink, unless a timer sets its own with the
+// "duration" tag option.  The gauges of time.Duration fields are exported
+// in the same unit.  Units below a nanosecond are ignored.
+func WithDurationUnit(unit time.Duration) Option {
+	return func(m *MetricTags) {
+		if unit > 0 {
+			m.durationUnit = unit
+		}
+	}
+}
+
+// fieldDurationUnit returns the unit of the durations exported for a field
+// with the given tag options.
+func (m *MetricTags) fieldDurationUnit(opts tagOptions) time.Duration {
+	if unit, ok := durationUnits[opts["duration"]]; ok {
+		return unit
+	}
+	if m.durationUnit > 0 {
+		return m.durationUnit
+	}
+	return time.Nanosecond
+}
+
+// durationUnit returns the unit of the durations exported for the named
+// timer.
+func (s *Snapshot) durationUnit(name string) time.Duration {
+	if unit, ok := durationUnits[s.Meta[name].DurationUnit]; ok {
+		return unit
+	}
+	if s.DurationUnit > 0 {
+		return s.DurationUnit
+	}
+	return time.Nanosecond
+}
+
+// scaleDurations converts the durations in the statistics of the named timer
+// to its unit.
+func (s *Snapshot) scaleDurations(stats map[string]float64, name string) {
+	unit := float64(s.durationUnit(name))
+	if unit == 1 {
+		return
+	}
+	for k, v := range stats {
+		if k != "count" && !isRate(k) {
+			stats[k] = v / unit
+		}
+	}
+}
+
+// isRate reports whether the statistic named k is a rate of events.
+func isRate(k string) bool {
+	return k == "1m.rate" || k == "5m.rate" || k == "15m.rate" || k == "mean.rate"
+}
@@ -0,0 +1,68 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type durationMetrics struct {
+	Latency metrics.Timer     `metric:"latency"`
+	Slow    metrics.Timer     `metric:"slow,duration=s"`
+	Size    metrics.Histogram `metric:"size"`
+}
+
+func TestDurationUnit(t *testing.T) {
+	m := &durationMetrics{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".", WithDurationUnit(time.Millisecond))
+	m.Latency.Update(2 * time.Millisecond)
+	m.Slow.Update(2 * time.Millisecond)
+	m.Size.Update(2000)
+
+	s := tags.Snapshot()
+	if stats := s.Stats("latency"); stats["max"] != 2 || stats["99%"] != 2 || stats["count"] != 1 {
+		t.Fatalf("unexpected latency stats in milliseconds: %v", stats)
+	}
+	if max := s.Stats("slow")["max"]; max != 0.002 {
+		t.Fatalf("expected 0.002s, got %v", max)
+	}
+	if max := s.Stats("size")["max"]; max != 2000 {
+		t.Fatalf("histogram scaled to %v", max)
+	}
+	if err := ValidateField("metrics.Timer", "latency,duration=h"); err == nil {
+		t.Fatalf("expected an error for an unknown unit")
+	}
+	if err := ValidateField("metrics.Histogram", "size,duration=ms"); err == nil {
+		t.Fatalf("expected an error for a histogram")
+	}
+}
+
+func TestDurationFields(t *testing.T) {
+	m := &struct {
+		Timeout  time.Duration `metric:"timeout"`
+		Interval time.Duration `metric:"interval,duration=s"`
+		Backoff  time.Duration
+		Deadline time.Time
+	}{Timeout: 1500 * time.Microsecond}
+	r := metrics.NewRegistry()
+	tags := NewMetricTags(m, func() {}, time.Second, r, ".", WithDurationUnit(time.Millisecond))
+	m.Interval = 90 * time.Second
+
+	s := tags.Snapshot()
+	if v := s.Stats("timeout")["value"]; v != 1.5 {
+		t.Fatalf("expected 1.5ms, got %v", v)
+	}
+	if v := s.Stats("interval")["value"]; v != 90 {
+		t.Fatalf("expected 90s, got %v", v)
+	}
+	if meta, ok := tags.Metadata("interval"); !ok || meta.Type != "gauge" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if err := ValidateField("time.Duration", "timeout,duration=us"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Get("backoff") != nil || r.Get("deadline") != nil {
+		t.Fatalf("untagged time fields registered")
+	}
+}
@@ -0,0 +1,16 @@
+package tagtrics
+
+import (
+	"time"
+)
+
+// processStart approximates when the process started, as the package is
+// initialized right before main runs.
+var processStart = time.Now()
+
+// updateUptime sets the self metrics of the uptime of the process, which
+// give every service restart visibility for free.
+func (m *MetricTags) updateUptime() {
+	m.self.StartTimestamp.Update(processStart.Unix())
+	m.self.Uptime.Update(int64(time.Since(processStart) / time.Second))
+}
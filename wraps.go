@@ -0,0 +1,55 @@
+package tagtrics
+
+import (
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// WithCounterWrapDetection checks on every flush that no counter decreased
+// since the previous flush, e.g. after a Reset, a misused Dec or an
+// overflow, which otherwise only shows as an inexplicable dip in
+// dashboards.  Decreases are counted in the "tagtrics.counter.wraps" self
+// metric and logged along with the name of the counter.  Counters reset on
+// every flush are not checked.
+func WithCounterWrapDetection() Option {
+	return func(m *MetricTags) {
+		m.counterWraps = metrics.NewCounter()
+	}
+}
+
+// initCounterWraps registers the counter of WithCounterWrapDetection if it
+// was used.
+func (m *MetricTags) initCounterWraps() {
+	if m.counterWraps == nil {
+		return
+	}
+	name := JoinName(JoinName(m.selfPrefix, m.separator, "counter"), m.separator, "wraps")
+	m.register(newMeta(name, "counter", "Counters which decreased between flushes", "", nil), m.counterWraps)
+}
+
+// checkWraps counts and logs the counters of s which decreased since the
+// previous flush if WithCounterWrapDetection is used.  It must be called
+// with flushMutex held.
+func (m *MetricTags) checkWraps(s *Snapshot) {
+	if m.counterWraps == nil {
+		return
+	}
+	counts := make(map[string]int64, len(m.lastCounts))
+	for name, metric := range s.Metrics {
+		c, ok := metric.(metrics.Counter)
+		if !ok {
+			continue
+		}
+		count := c.Count()
+		counts[name] = count
+		last, ok := m.lastCounts[name]
+		if !ok || count >= last {
+			continue
+		}
+		if _, reset := m.registry.Get(name).(*resetCounter); reset {
+			continue
+		}
+		m.counterWraps.Inc(1)
+		m.warn("counter decreased", "name", name, "from", last, "to", count)
+	}
+	m.lastCounts = counts
+}
@@ -0,0 +1,45 @@
+package tagtrics
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestCounterWrapDetection(t *testing.T) {
+	r := metrics.NewRegistry()
+	var l recordingLogger
+	m := &struct {
+		Sent    metrics.Counter `metric:"sent"`
+		Batches metrics.Counter `metric:"batches,reset"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, r, ".", WithCounterWrapDetection(), WithLogger(&l))
+	wraps := r.Get("tagtrics.counter.wraps").(metrics.Counter)
+
+	m.Sent.Inc(5)
+	m.Batches.Inc(5)
+	tags.flush()
+	m.Sent.Inc(1)
+	m.Batches.Inc(1)
+	tags.flush()
+	if c := wraps.Count(); c != 0 || len(l) != 0 {
+		t.Fatalf("unexpected wraps %d: %v", c, l)
+	}
+
+	m.Sent.Dec(2)
+	tags.flush()
+	if c := wraps.Count(); c != 1 || len(l) != 1 || l[0] != "counter decreased name sent from 6 to 4" {
+		t.Fatalf("unexpected wraps %d: %v", c, l)
+	}
+	tags.flush()
+	if c := wraps.Count(); c != 1 {
+		t.Fatalf("the decrease was counted again: %d", c)
+	}
+
+	r = metrics.NewRegistry()
+	NewMetricTags(&struct{}{}, func() {}, time.Second, r, ".")
+	if r.Get("tagtrics.counter.wraps") != nil {
+		t.Fatalf("wraps counter registered without the option")
+	}
+}
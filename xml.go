@@ -0,0 +1,129 @@
+package tagtrics
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// xmlNode is an element of the XML document of a snapshot holding the
+// metric named after the path of nodes leading to it, if any, and the nodes
+// of the names beneath it.
+type xmlNode struct {
+	XMLName  xml.Name   `xml:"metric"`
+	Name     string     `xml:"name,attr"`
+	Type     string     `xml:"type,attr,omitempty"`
+	Unit     string     `xml:"unit,attr,omitempty"`
+	Labels   []xmlPair  `xml:"label"`
+	Stats    []xmlStat  `xml:"stat"`
+	Children []*xmlNode `xml:"metric"`
+}
+
+// xmlPair is a label of a metric.
+type xmlPair struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// xmlStat is a statistic of a metric.
+type xmlStat struct {
+	Name  string  `xml:"name,attr"`
+	Value float64 `xml:",chardata"`
+}
+
+// xmlDocument is the root element of the XML document of a snapshot.
+type xmlDocument struct {
+	XMLName xml.Name   `xml:"metrics"`
+	Time    string     `xml:"time,attr"`
+	Metrics []*xmlNode `xml:"metric"`
+}
+
+// child returns the child node named name, adding it if needed.
+func (n *xmlNode) child(name string) *xmlNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &xmlNode{Name: name}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// WriteXML writes the snapshot to w as an XML document for systems which
+// only ingest XML.  Metric names are split on Separator into nested metric
+// elements, e.g. "queue.depth" becomes a "depth" element within a "queue"
+// element, holding the type and unit of the metric as attributes, its labels
+// as label elements and its statistics as stat elements:
+//
+//	<metrics time="2006-01-02T15:04:05Z">
+//	  <metric name="queue">
+//	    <metric name="depth" type="gauge">
+//	      <stat name="value">3</stat>
+//	    </metric>
+//	  </metric>
+//	</metrics>
+func (s *Snapshot) WriteXML(w io.Writer) error {
+	root := &xmlNode{}
+	for _, name := range s.Names() {
+		stats := s.Stats(name)
+		if stats == nil {
+			continue
+		}
+		n := root
+		segments := []string{name}
+		if s.Separator != "" {
+			segments = strings.Split(name, s.Separator)
+		}
+		for _, segment := range segments {
+			n = n.child(segment)
+		}
+		meta := s.Meta[name]
+		n.Type, n.Unit = meta.Type, meta.Unit
+		labels := s.Labels(name)
+		for _, k := range sortedKeys(labels) {
+			n.Labels = append(n.Labels, xmlPair{Name: k, Value: labels[k]})
+		}
+		keys := make([]string, 0, len(stats))
+		for k := range stats {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			n.Stats = append(n.Stats, xmlStat{Name: k, Value: stats[k]})
+		}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	doc := xmlDocument{Time: s.Time.UTC().Format(time.RFC3339Nano), Metrics: root.Children}
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// sortedKeys returns the sorted keys of m.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ToXML returns a representation of all the metrics in XML format as
+// written by Snapshot.WriteXML.
+func (m *MetricTags) ToXML() []byte {
+	defer m.self.Snapshot.Serialization.UpdateSince(time.Now())
+	buf := bytes.NewBuffer(nil)
+	m.Snapshot().WriteXML(buf)
+	return buf.Bytes()
+}
@@ -0,0 +1,34 @@
+package tagtrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestToXML(t *testing.T) {
+	m := &struct {
+		Queue struct {
+			Depth metrics.Gauge   `metric:"depth" unit:"messages"`
+			Sent  metrics.Counter `metric:"sent,rate"`
+		} `metric:"queue"`
+		Build Info `metric:"build"`
+	}{}
+	tags := NewMetricTags(m, func() {}, time.Second, metrics.NewRegistry(), ".")
+	m.Queue.Depth.Update(3)
+	m.Build.Set("version", "1.2 <beta>")
+	out := string(tags.ToXML())
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<metric name="queue">`,
+		"<metric name=\"depth\" type=\"gauge\" unit=\"messages\">\n      <stat name=\"value\">3</stat>",
+		"<metric name=\"sent\" type=\"counter\">\n      <stat name=\"count\">0</stat>\n      <metric name=\"rate\" type=\"gauge\" unit=\"/s\">",
+		`<label name="version">1.2 &lt;beta&gt;</label>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}